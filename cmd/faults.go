@@ -0,0 +1,313 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gbserver/logging"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// FaultsEnabled puts FaultMiddleware in front of the API, see /_admin/faults.
+var FaultsEnabled = false
+
+// faultHeader asks for a fault on a single request, e.g. "X-Gb-Fault: 502" or "X-Gb-Fault: latency=2000".
+const faultHeader = "X-Gb-Fault"
+
+var faultTypes = []string{"latency", "error", "reset", "truncate", "secondary_rate_limit", "abuse"}
+
+// Fault is a failure injected into the requests it matches.
+type Fault struct {
+	// Type is latency, error, reset, truncate, secondary_rate_limit or abuse.
+	Type string `json:"type"`
+	// Status of an error fault, 500 when empty.
+	Status int `json:"status,omitempty"`
+	// LatencyMS is how long a latency fault holds the request before it is served.
+	LatencyMS int `json:"latency_ms,omitempty"`
+	// Percent of the matching requests that get the fault, every one when empty.
+	Percent float64 `json:"percent,omitempty"`
+	// Method and Path, a regexp on the path, pick the requests, empty matches all.
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+type FaultConfig struct {
+	Faults []Fault `json:"faults"`
+}
+
+// Faults holds the faults injected at the moment, the admin endpoint swaps them at runtime.
+type Faults struct {
+	mu     sync.RWMutex
+	config FaultConfig
+	paths  []*regexp.Regexp
+	// roll returns a number in [0, 100) a fault hits below its Percent.
+	roll func() float64
+}
+
+func NewFaults() *Faults {
+	return &Faults{config: FaultConfig{Faults: []Fault{}}, roll: func() float64 { return rand.Float64() * 100 }}
+}
+
+func validateFault(fault Fault) error {
+	switch {
+	case !slices.Contains(faultTypes, fault.Type):
+		return fmt.Errorf("invalid fault type %q. Specify as %s", fault.Type, strings.Join(faultTypes, ", "))
+	case fault.Status != 0 && (fault.Status < 400 || fault.Status > 599):
+		return fmt.Errorf("invalid fault status %d. Specify as a 4xx or 5xx status", fault.Status)
+	case fault.LatencyMS < 0 || fault.Percent < 0 || fault.Percent > 100:
+		return fmt.Errorf("invalid fault. latency_ms must be positive and percent between 0 and 100")
+	}
+	return nil
+}
+
+// Set replaces the faults, nothing changes when one of them is invalid.
+func (f *Faults) Set(config FaultConfig) error {
+	if config.Faults == nil {
+		config.Faults = []Fault{}
+	}
+	paths := []*regexp.Regexp{}
+	for _, fault := range config.Faults {
+		err := validateFault(fault)
+		if err != nil {
+			return err
+		}
+		path, err := regexp.Compile(fault.Path)
+		if err != nil {
+			return fmt.Errorf("invalid fault path %q. %w", fault.Path, err)
+		}
+		paths = append(paths, path)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.config = config
+	f.paths = paths
+	return nil
+}
+
+func (f *Faults) Config() FaultConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.config
+}
+
+// parseFaultHeader reads a status, "latency=<ms>" or the name of a fault type.
+func parseFaultHeader(value string) (Fault, error) {
+	value = strings.TrimSpace(value)
+	if status, err := strconv.Atoi(value); err == nil {
+		fault := Fault{Type: "error", Status: status}
+		return fault, validateFault(fault)
+	}
+	if latency, found := strings.CutPrefix(value, "latency="); found {
+		latencyMS, err := strconv.Atoi(latency)
+		if err != nil {
+			return Fault{}, fmt.Errorf("invalid fault latency %q. Specify in milliseconds", latency)
+		}
+		fault := Fault{Type: "latency", LatencyMS: latencyMS}
+		return fault, validateFault(fault)
+	}
+	fault := Fault{Type: strings.ReplaceAll(value, "-", "_")}
+	return fault, validateFault(fault)
+}
+
+// pick is the fault for the request, the first configured one that matches and hits, or nil.
+func (f *Faults) pick(r *http.Request) *Fault {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i, fault := range f.config.Faults {
+		if fault.Method != "" && !strings.EqualFold(fault.Method, r.Method) {
+			continue
+		}
+		if !f.paths[i].MatchString(r.URL.Path) {
+			continue
+		}
+		if fault.Percent != 0 && f.roll() >= fault.Percent {
+			continue
+		}
+		return &fault
+	}
+	return nil
+}
+
+func writeFaultError(w http.ResponseWriter, status int, message, documentationURL string) {
+	w.Header().Set("Content-Type", "Application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rateLimitError{Message: message, DocumentationURL: documentationURL})
+}
+
+// resetConnection drops the connection without an answer, with a TCP reset where the connection allows it.
+func resetConnection(w http.ResponseWriter) {
+	if hijacker, ok := w.(http.Hijacker); ok {
+		conn, _, err := hijacker.Hijack()
+		if err == nil {
+			if tcpConn, ok := conn.(*net.TCPConn); ok {
+				tcpConn.SetLinger(0)
+			}
+			conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// errTruncated stops a handler writing into a response that was already cut.
+var errTruncated = errors.New("response truncated by a fault")
+
+// truncateWriter holds the body back so only half of it goes out. A flush means the response is streamed and
+// its length is not known, it is cut there: half of what was written so far is sent and every later write fails.
+type truncateWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+	cut    bool
+}
+
+func (t *truncateWriter) WriteHeader(status int) {
+	if t.status == 0 {
+		t.status = status
+	}
+}
+
+func (t *truncateWriter) Write(data []byte) (int, error) {
+	if t.cut {
+		return 0, errTruncated
+	}
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	return t.body.Write(data)
+}
+
+func (t *truncateWriter) Flush() {
+	t.sendHalf()
+}
+
+func (t *truncateWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// sendHalf writes the status and the first half of the body held back, once.
+func (t *truncateWriter) sendHalf() {
+	if t.cut {
+		return
+	}
+	t.cut = true
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	t.ResponseWriter.WriteHeader(t.status)
+	t.ResponseWriter.Write(t.body.Bytes()[:t.body.Len()/2])
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// truncateResponse serves the request but sends only half of the body it announces, then drops the connection.
+func truncateResponse(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	truncate := &truncateWriter{ResponseWriter: w}
+	next.ServeHTTP(truncate, r)
+	if !truncate.cut {
+		w.Header().Set("Content-Length", strconv.Itoa(truncate.body.Len()))
+		truncate.sendHalf()
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func injectFault(w http.ResponseWriter, r *http.Request, next http.Handler, fault *Fault) {
+	logging.For(r.Context(), slog.Default()).Info("Injecting fault", "fault", fault.Type, "method", r.Method, "path", r.URL.Path)
+	switch fault.Type {
+	case "latency":
+		select {
+		case <-time.After(time.Duration(fault.LatencyMS) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		next.ServeHTTP(w, r)
+	case "error":
+		status := fault.Status
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		writeFaultError(w, status, http.StatusText(status), "https://docs.github.com/rest")
+	case "reset":
+		resetConnection(w)
+	case "truncate":
+		truncateResponse(w, r, next)
+	case "secondary_rate_limit":
+		w.Header().Set("Retry-After", "60")
+		writeFaultError(w, http.StatusForbidden, "You have exceeded a secondary rate limit. Please wait a few minutes before you try again.",
+			"https://docs.github.com/rest/overview/rate-limits-for-the-rest-api#about-secondary-rate-limits")
+	case "abuse":
+		w.Header().Set("Retry-After", "60")
+		writeFaultError(w, http.StatusForbidden, "You have triggered an abuse detection mechanism. Please wait a few minutes before you try again.",
+			"https://docs.github.com/rest/overview/resources-in-the-rest-api#abuse-rate-limits")
+	}
+}
+
+// FaultMiddleware injects the fault a request asks for with X-Gb-Fault, else one of the configured faults.
+// The admin endpoints are left alone so faults can always be turned off again.
+func FaultMiddleware(faults *Faults) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/_admin/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			fault := faults.pick(r)
+			if value := r.Header.Get(faultHeader); value != "" {
+				headerFault, err := parseFaultHeader(value)
+				if err != nil {
+					writeFaultError(w, http.StatusBadRequest, err.Error(), "https://docs.github.com/rest")
+					return
+				}
+				fault = &headerFault
+			}
+			if fault == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			injectFault(w, r, next, fault)
+		})
+	}
+}
+
+// get, put, delete /_admin/faults
+// put replaces the configured faults, delete clears them.
+func FaultsHandler(faults *Faults) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			var config FaultConfig
+			err := json.NewDecoder(r.Body).Decode(&config)
+			if err != nil {
+				http.Error(w, "Error occurred while decoding the request data", http.StatusBadRequest)
+				return
+			}
+			err = faults.Set(config)
+			if err != nil {
+				writeFaultError(w, http.StatusUnprocessableEntity, err.Error(), "https://docs.github.com/rest")
+				return
+			}
+		case http.MethodDelete:
+			faults.Set(FaultConfig{})
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "Application/json")
+		err := json.NewEncoder(w).Encode(faults.Config())
+		if err != nil {
+			logging.For(r.Context(), slog.Default()).Error("Error occured while encoding the faults", "error", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultMiddleware(t *testing.T) {
+	faults := NewFaults()
+	router := mux.NewRouter()
+	router.Use(FaultMiddleware(faults))
+	router.Path("/repos").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(`[{"name":"gbrepo"}]`)) })
+	streamErr := make(chan error, 1)
+	router.Path("/stream").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("0123456789"))
+		http.NewResponseController(w).Flush()
+		_, err := w.Write([]byte("never sent"))
+		streamErr <- err
+	})
+	router.Path("/_admin/faults").Methods(http.MethodGet, http.MethodPut, http.MethodDelete).HandlerFunc(FaultsHandler(faults))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	call := func(method, path, fault, body string) (*http.Response, string, error) {
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		if fault != "" {
+			req.Header.Set(faultHeader, fault)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		respBody, err := io.ReadAll(resp.Body)
+		return resp, string(respBody), err
+	}
+
+	resp, body, err := call(http.MethodGet, "/repos", "", "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, body, _ = call(http.MethodGet, "/repos", "502", "")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Contains(t, body, "Bad Gateway")
+	resp, body, _ = call(http.MethodGet, "/repos", "200", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "invalid fault status")
+	resp, body, _ = call(http.MethodGet, "/repos", "secondary-rate-limit", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+	assert.Contains(t, body, "secondary rate limit")
+	resp, body, _ = call(http.MethodGet, "/repos", "abuse", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, "abuse detection")
+
+	start := time.Now()
+	resp, body, _ = call(http.MethodGet, "/repos", "latency=50", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, `[{"name":"gbrepo"}]`, body)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	_, _, err = call(http.MethodGet, "/repos", "reset", "")
+	assert.Error(t, err)
+	_, _, err = call(http.MethodGet, "/repos", "truncate", "")
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	// a streamed response is cut at its first flush.
+	resp, body, err = call(http.MethodGet, "/stream", "truncate", "")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "01234", body)
+	assert.ErrorIs(t, <-streamErr, errTruncated)
+
+	// configured faults hit the requests they match, by percentage.
+	resp, body, _ = call(http.MethodPut, "/_admin/faults", "", `{"faults":[{"type":"teapot"}]}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	assert.Contains(t, body, "invalid fault type")
+	resp, _, _ = call(http.MethodPut, "/_admin/faults", "", `{"faults":[{"type":"error","status":503,"method":"POST","path":"^/repos$","percent":50}]}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	setRoll := func(roll float64) {
+		faults.mu.Lock()
+		defer faults.mu.Unlock()
+		faults.roll = func() float64 { return roll }
+	}
+	setRoll(10)
+	resp, _, _ = call(http.MethodGet, "/repos", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _, _ = call(http.MethodPost, "/repos", "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	setRoll(90)
+	resp, _, _ = call(http.MethodPost, "/repos", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// the admin endpoint is never faulted, so the faults can be cleared.
+	call(http.MethodPut, "/_admin/faults", "", `{"faults":[{"type":"error"}]}`)
+	resp, _, _ = call(http.MethodGet, "/repos", "", "")
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	resp, body, _ = call(http.MethodGet, "/_admin/faults", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"faults":[{"type":"error"}]}`, body)
+	resp, _, _ = call(http.MethodDelete, "/_admin/faults", "", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _, _ = call(http.MethodGet, "/repos", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bufio"
+	"gbserver/logging"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// LogLevel is the least severe level logged: debug, info, warn or error.
+var LogLevel = "info"
+
+// LogFormat is text for key=value lines or json for one object per line.
+var LogFormat = "text"
+
+const requestIDHeader = "X-Request-ID"
+
+// uuidMiddleware gives every request an id, the one the client sent in X-Request-ID or a new uuid,
+// and answers with it so log lines can be found from a response.
+func uuidMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if requestID == "" || len(requestID) > 200 {
+			requestID = uuid.New().String()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		ctx, _ := logging.WithRequest(r.Context(), requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// statusRecorder keeps the status and size of a response. It lets the git and fault handlers flush and hijack
+// the connection underneath.
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	bytes    int
+	hijacked bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(data []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(data)
+	s.bytes += n
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	s.hijacked = true
+	return hijacker.Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// routeTemplate is the path template of the route r goes to, empty when none matches.
+func routeTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.Route == nil {
+		return ""
+	}
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+	return template
+}
+
+// loggingMiddleware writes an access log line for every request, with the route it matched in router and the
+// authenticated caller. It goes around the router, after uuidMiddleware, so requests turned away by the
+// router or its middlewares are logged too.
+func loggingMiddleware(logger *slog.Logger, router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			aborted := true
+			// deferred so connections dropped by a panicking handler are logged as well.
+			defer func() {
+				status := recorder.status
+				if status == 0 && !aborted && !recorder.hijacked {
+					status = http.StatusOK
+				}
+				user := ""
+				if request := logging.RequestOf(r.Context()); request != nil {
+					user = request.User
+				}
+				level := slog.LevelInfo
+				if status >= 500 || aborted || recorder.hijacked {
+					level = slog.LevelError
+				}
+				logging.For(r.Context(), logger).Log(r.Context(), level, "Request served",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"route", routeTemplate(router, r),
+					"status", status,
+					"duration_ms", float64(time.Since(start).Microseconds())/1000,
+					"bytes", recorder.bytes,
+					"user", user,
+					"remote_addr", r.RemoteAddr,
+					"user_agent", r.UserAgent(),
+					"aborted", aborted || recorder.hijacked)
+			}()
+			next.ServeHTTP(recorder, r)
+			aborted = false
+		})
+	}
+}
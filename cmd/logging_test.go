@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"gbserver/logging"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	logger, err := logging.New(&out, "info", "json")
+	assert.NoError(t, err)
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			logging.SetUser(r.Context(), "gbuser")
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Path("/repos/{org}/{owner}/{repo}").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.For(r.Context(), logger).Info("Handling")
+		w.Write([]byte("gbrepo"))
+	})
+	router.Path("/boom").HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) })
+	handler := uuidMiddleware(loggingMiddleware(logger, router)(router))
+
+	// lines are returned by message, the access log line is the last one of a request.
+	serve := func(req *http.Request) (*httptest.ResponseRecorder, map[string]map[string]any) {
+		out.Reset()
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		lines := map[string]map[string]any{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var entry map[string]any
+			assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+			lines[entry["msg"].(string)] = entry
+		}
+		return resp, lines
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/repos/gborg/gbuser/gbrepo", nil)
+	req.Header.Set("X-Request-ID", "client-id")
+	req.Header.Set("User-Agent", "gbclient")
+	resp, lines := serve(req)
+	assert.Equal(t, "client-id", resp.Header().Get("X-Request-ID"))
+	assert.Equal(t, "client-id", lines["Handling"]["request_id"])
+	access := lines["Request served"]
+	assert.Equal(t, "client-id", access["request_id"])
+	assert.Equal(t, "INFO", access["level"])
+	assert.Equal(t, "GET", access["method"])
+	assert.Equal(t, "/repos/gborg/gbuser/gbrepo", access["path"])
+	assert.Equal(t, "/repos/{org}/{owner}/{repo}", access["route"])
+	assert.Equal(t, float64(http.StatusOK), access["status"])
+	assert.Equal(t, float64(len("gbrepo")), access["bytes"])
+	assert.Equal(t, "gbuser", access["user"])
+	assert.Equal(t, "gbclient", access["user_agent"])
+	assert.Contains(t, access, "duration_ms")
+
+	// without an id one is made up, unmatched requests and server errors are logged too.
+	resp, lines = serve(httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Len(t, resp.Header().Get("X-Request-ID"), 36)
+	access = lines["Request served"]
+	assert.Equal(t, resp.Header().Get("X-Request-ID"), access["request_id"])
+	assert.Equal(t, float64(http.StatusNotFound), access["status"])
+	assert.Equal(t, "", access["route"])
+	assert.Equal(t, "", access["user"])
+	_, lines = serve(httptest.NewRequest(http.MethodPost, "/boom", nil))
+	assert.Equal(t, "ERROR", lines["Request served"]["level"])
+	assert.Equal(t, "/boom", lines["Request served"]["route"])
+}
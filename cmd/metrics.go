@@ -0,0 +1,131 @@
+package server
+
+import (
+	"gbserver/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/didip/tollbooth/v8/limiter"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsPath = "/metrics"
+
+// Metrics is what /metrics exposes: the requests served, the ones turned away by the rate limit, the state of the
+// store and the go runtime of the server.
+type Metrics struct {
+	handler     http.Handler
+	requests    *prometheus.CounterVec
+	durations   *prometheus.HistogramVec
+	rateLimited *prometheus.CounterVec
+}
+
+// storeCollector reads the store gauges when /metrics is scraped, so they are never stale after a reset or restore.
+type storeCollector struct {
+	stats        func() service.StoreStats
+	orgs         *prometheus.Desc
+	users        *prometheus.Desc
+	repos        *prometheus.Desc
+	branches     *prometheus.Desc
+	pullRequests *prometheus.Desc
+}
+
+func newStoreCollector(stats func() service.StoreStats) *storeCollector {
+	return &storeCollector{
+		stats:        stats,
+		orgs:         prometheus.NewDesc("gbserver_store_orgs", "Organizations in the store.", nil, nil),
+		users:        prometheus.NewDesc("gbserver_store_users", "Users in the store.", nil, nil),
+		repos:        prometheus.NewDesc("gbserver_store_repos", "Repositories in the store.", nil, nil),
+		branches:     prometheus.NewDesc("gbserver_store_branches", "Branches in the store.", nil, nil),
+		pullRequests: prometheus.NewDesc("gbserver_store_pull_requests", "Pull requests in the store by state.", []string{"state"}, nil),
+	}
+}
+
+func (c *storeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.orgs
+	ch <- c.users
+	ch <- c.repos
+	ch <- c.branches
+	ch <- c.pullRequests
+}
+
+func (c *storeCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.orgs, prometheus.GaugeValue, float64(stats.Orgs))
+	ch <- prometheus.MustNewConstMetric(c.users, prometheus.GaugeValue, float64(stats.Users))
+	ch <- prometheus.MustNewConstMetric(c.repos, prometheus.GaugeValue, float64(stats.Repos))
+	ch <- prometheus.MustNewConstMetric(c.branches, prometheus.GaugeValue, float64(stats.Branches))
+	for state, count := range stats.PullRequests {
+		ch <- prometheus.MustNewConstMetric(c.pullRequests, prometheus.GaugeValue, float64(count), state)
+	}
+}
+
+// NewMetrics registers the metrics, stats is read for the store gauges on every scrape.
+func NewMetrics(stats func() service.StoreStats) *Metrics {
+	registry := prometheus.NewRegistry()
+	m := &Metrics{
+		handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gbserver_http_requests_total",
+			Help: "Requests served by route template and status.",
+		}, []string{"method", "route", "status"}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "gbserver_http_request_duration_seconds",
+			Help:    "Time taken to serve requests by route template and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gbserver_rate_limited_requests_total",
+			Help: "Requests rejected by the rate limit by resource.",
+		}, []string{"resource"}),
+	}
+	registry.MustRegister(m.requests, m.durations, m.rateLimited, newStoreCollector(stats),
+		collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// CountRateLimited counts the requests limit turns away, TollboothMiddleware calls it on every rejection.
+func (m *Metrics) CountRateLimited(limit *limiter.Limiter) {
+	limit.SetOnLimitReached(func(w http.ResponseWriter, r *http.Request) {
+		m.rateLimited.WithLabelValues(rateLimitResource(r)).Inc()
+	})
+}
+
+// methodLabel is method for the standard methods and OTHER for any other, clients choose the method so it must not
+// grow the label values unbounded.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+		http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// metricsMiddleware observes every request with the route template it matched in router, empty when none did, so
+// the labels stay bounded whatever paths are asked for. /metrics itself is served here, outside of the router, so
+// scrapes need no token and are not rate limited.
+func metricsMiddleware(m *Metrics, router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == metricsPath && r.Method == http.MethodGet {
+				m.handler.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			status := recorder.status
+			if status == 0 && !recorder.hijacked {
+				status = http.StatusOK
+			}
+			labels := prometheus.Labels{"method": methodLabel(r.Method), "route": routeTemplate(router, r), "status": strconv.Itoa(status)}
+			m.requests.With(labels).Inc()
+			m.durations.With(labels).Observe(time.Since(start).Seconds())
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"gbserver/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/didip/tollbooth/v8"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	stats := service.StoreStats{Orgs: 1, Users: 2, Repos: 1, Branches: 2, PullRequests: map[string]int{"open": 1, "closed": 0}}
+	metrics := NewMetrics(func() service.StoreStats { return stats })
+	limit := tollbooth.NewLimiter(1, nil)
+	metrics.CountRateLimited(limit)
+	router := mux.NewRouter()
+	router.Use(TollboothMiddleware(limit, func(r *http.Request) (string, bool) {
+		login, found := strings.CutPrefix(r.Header.Get("Authorization"), "token ")
+		return login, found
+	}))
+	router.Path("/repos/{org}/{owner}/{repo}").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Path("/repos/{org}/{owner}/{repo}").Methods(http.MethodPost).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler := metricsMiddleware(metrics, router)(router)
+
+	call := func(method, path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", authorization)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+	call(http.MethodGet, "/repos/gborg/gbuser/gbrepo", "token one")
+	call(http.MethodPost, "/repos/gborg/gbuser/other", "token two")
+	assert.Equal(t, http.StatusTooManyRequests, call(http.MethodGet, "/repos/gborg/gbuser/gbrepo", "token one").Code)
+	call(http.MethodGet, "/missing/1", "token three")
+	call(http.MethodGet, "/missing/2", "token four")
+	call("PURGE", "/missing/3", "token five")
+	call("FOO-1", "/missing/4", "token six")
+
+	// scrapes are not limited or counted.
+	call(http.MethodGet, metricsPath, "token one")
+	stats.Repos = 2
+	stats.PullRequests["closed"] = 1
+	resp := call(http.MethodGet, metricsPath, "token one")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	body := resp.Body.String()
+	assert.Contains(t, body, `gbserver_http_requests_total{method="GET",route="/repos/{org}/{owner}/{repo}",status="200"} 1`)
+	assert.Contains(t, body, `gbserver_http_requests_total{method="POST",route="/repos/{org}/{owner}/{repo}",status="201"} 1`)
+	assert.Contains(t, body, `gbserver_http_requests_total{method="GET",route="/repos/{org}/{owner}/{repo}",status="429"} 1`)
+	assert.Contains(t, body, `gbserver_http_requests_total{method="GET",route="",status="404"} 2`)
+	assert.Contains(t, body, `gbserver_http_requests_total{method="OTHER",route="",status="404"} 2`)
+	assert.NotContains(t, body, `method="PURGE"`)
+	assert.Contains(t, body, `gbserver_http_request_duration_seconds_count{method="GET",route="/repos/{org}/{owner}/{repo}",status="200"} 1`)
+	assert.NotContains(t, body, `route="/metrics"`)
+	assert.Contains(t, body, `gbserver_rate_limited_requests_total{resource="core"} 1`)
+
+	// the store gauges are read on every scrape.
+	assert.Contains(t, body, "gbserver_store_orgs 1")
+	assert.Contains(t, body, "gbserver_store_users 2")
+	assert.Contains(t, body, "gbserver_store_repos 2")
+	assert.Contains(t, body, "gbserver_store_branches 2")
+	assert.Contains(t, body, `gbserver_store_pull_requests{state="open"} 1`)
+	assert.Contains(t, body, `gbserver_store_pull_requests{state="closed"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"gbserver/proxy"
+	"log/slog"
+	"net/http"
+)
+
+// ProxyMode is empty to serve the store, "record" to pass requests on and write them to the cassette, or
+// "replay" to answer from the cassette alone.
+var ProxyMode = ""
+
+// Upstream is the API record mode passes requests on to, empty means the store is served and recorded.
+var Upstream = ""
+
+// CassettePath is the JSONL file interactions are recorded to and replayed from.
+var CassettePath = "cassette.jsonl"
+
+// ReplayMatch lists what a request must have in common with a recorded one, of method, path, query and body.
+var ReplayMatch = "method,path,query"
+
+// proxyHandler puts the API behind the configured proxy mode.
+func proxyHandler(api http.Handler) (http.Handler, error) {
+	switch ProxyMode {
+	case "":
+		return api, nil
+	case "record":
+		next := api
+		if Upstream != "" {
+			upstream, err := proxy.NewUpstream(Upstream)
+			if err != nil {
+				return nil, err
+			}
+			slog.Info("Proxying requests", "upstream", Upstream)
+			next = upstream
+		}
+		cassette, err := proxy.NewCassetteWriter(CassettePath)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Recording interactions", "cassette", CassettePath)
+		return proxy.NewRecorder(next, cassette), nil
+	case "replay":
+		match, err := proxy.ParseMatch(ReplayMatch)
+		if err != nil {
+			return nil, err
+		}
+		interactions, err := proxy.LoadCassette(CassettePath)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("Replaying interactions", "count", len(interactions), "cassette", CassettePath)
+		return proxy.NewReplayer(interactions, match), nil
+	default:
+		return nil, fmt.Errorf("unknown proxy mode %q. Specify as record or replay", ProxyMode)
+	}
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyHandler(t *testing.T) {
+	defer func(mode, cassette string) { ProxyMode, CassettePath = mode, cassette }(ProxyMode, CassettePath)
+	api := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { rw.Write([]byte("served")) })
+	CassettePath = filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	ProxyMode = "mirror"
+	_, err := proxyHandler(api)
+	assert.Error(t, err)
+	ProxyMode = "replay"
+	_, err = proxyHandler(api)
+	assert.Error(t, err, "no cassette yet")
+
+	// without an upstream the server records itself.
+	ProxyMode = "record"
+	handler, err := proxyHandler(api)
+	assert.NoError(t, err)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/user", nil))
+
+	ProxyMode = "replay"
+	handler, err = proxyHandler(http.NotFoundHandler())
+	assert.NoError(t, err)
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/user", nil))
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "served", resp.Body.String())
+}
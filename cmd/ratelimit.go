@@ -0,0 +1,142 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"gbserver/logging"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v8"
+	"github.com/didip/tollbooth/v8/limiter"
+	"github.com/gorilla/mux"
+)
+
+// RateLimit is one entry of the /rate_limit response, also sent as X-RateLimit-* headers.
+type RateLimit struct {
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	Reset     int64  `json:"reset"`
+	Used      int    `json:"used"`
+	Resource  string `json:"resource"`
+}
+
+type RateLimitResponse struct {
+	Resources map[string]RateLimit `json:"resources"`
+	Rate      RateLimit            `json:"rate"`
+}
+
+type rateLimitError struct {
+	Message          string `json:"message"`
+	DocumentationURL string `json:"documentation_url"`
+}
+
+// TokenFunc gives the token of a request, false when it has none or an invalid one.
+type TokenFunc func(r *http.Request) (string, bool)
+
+// rateLimitKey gives every valid token its own bucket, keyed by its hash so the limiter holds no tokens.
+// Callers without a valid token share one per address so made up tokens do not get fresh buckets.
+func rateLimitKey(r *http.Request, tokenOf TokenFunc) (string, string) {
+	if token, ok := tokenOf(r); ok {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:]), "token"
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, host
+}
+
+// rateLimitResource is the bucket a request is taken from, like on github searches and graphql queries
+// are counted apart from the rest of the API.
+func rateLimitResource(r *http.Request) string {
+	switch {
+	case r.URL.Path == "/graphql":
+		return "graphql"
+	case r.URL.Path == "/search" || strings.HasPrefix(r.URL.Path, "/search/"):
+		return "search"
+	default:
+		return "core"
+	}
+}
+
+// rateLimitFor works out the github view of a tollbooth bucket: the bucket size is the
+// limit and the reset is when the bucket is full again.
+func rateLimitFor(lmt *limiter.Limiter, resource string, remaining int) RateLimit {
+	limit := lmt.GetBurst()
+	remaining = max(min(remaining, limit), 0)
+	refill := math.Ceil(float64(limit-remaining) / lmt.GetMax())
+	return RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Now().Add(time.Duration(refill) * time.Second).Unix(),
+		Used:      limit - remaining,
+		Resource:  resource,
+	}
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, rateLimit RateLimit) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rateLimit.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(rateLimit.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(rateLimit.Reset, 10))
+	w.Header().Set("X-RateLimit-Used", strconv.Itoa(rateLimit.Used))
+	w.Header().Set("X-RateLimit-Resource", rateLimit.Resource)
+}
+
+// TollboothMiddleware takes every request from the bucket of its token, tokenOf checks the token so it
+// can run ahead of authentication and still limit requests with bad credentials.
+func TollboothMiddleware(limiter *limiter.Limiter, tokenOf TokenFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, caller := rateLimitKey(r, tokenOf)
+			resource := rateLimitResource(r)
+			httpError, remaining := tollbooth.LimitByKeysAndReturn(limiter, []string{resource, key})
+			rateLimit := rateLimitFor(limiter, resource, remaining)
+			setRateLimitHeaders(w, rateLimit)
+
+			if httpError != nil {
+				// If rate limit exceeded
+				limiter.ExecOnLimitReached(w, r)
+				retryAfter := max(rateLimit.Reset-time.Now().Unix(), 1)
+				w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+				w.Header().Set("Content-Type", "Application/json")
+				w.WriteHeader(httpError.StatusCode)
+				message := fmt.Sprintf("API rate limit exceeded for %s.", caller)
+				if caller == "token" {
+					message = "API rate limit exceeded for this token."
+				}
+				json.NewEncoder(w).Encode(rateLimitError{Message: message,
+					DocumentationURL: "https://docs.github.com/rest/overview/resources-in-the-rest-api#rate-limiting"})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// get /rate_limit
+// The call itself goes through TollboothMiddleware, so it is counted like any other request. Only the core
+// bucket is listed, tollbooth cannot tell an unused search or graphql bucket from an empty one.
+func RateLimitHandler(limiter *limiter.Limiter, tokenOf TokenFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, _ := rateLimitKey(r, tokenOf)
+		core := rateLimitFor(limiter, "core", limiter.Tokens(strings.Join([]string{"core", key}, "|")))
+		resp := RateLimitResponse{
+			Resources: map[string]RateLimit{"core": core},
+			Rate:      core,
+		}
+		w.Header().Set("Content-Type", "Application/json")
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			logging.For(r.Context(), slog.Default()).Error("Error occured while encoding the rate limit", "error", err)
+		}
+	}
+}
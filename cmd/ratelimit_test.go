@@ -0,0 +1,80 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/didip/tollbooth/v8"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTollboothMiddleware(t *testing.T) {
+	limit := tollbooth.NewLimiter(2, nil)
+	limit.SetStatusCode(http.StatusTooManyRequests)
+	router := mux.NewRouter()
+	tokens := map[string]bool{"one": true, "again": true, "two": true}
+	tokenOf := func(r *http.Request) (string, bool) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "token ")
+		return token, tokens[token]
+	}
+	router.Use(TollboothMiddleware(limit, tokenOf))
+	router.Path("/rate_limit").Methods(http.MethodGet).HandlerFunc(RateLimitHandler(limit, tokenOf))
+	router.Path("/ping").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router.Path("/graphql").Methods(http.MethodGet).HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	call := func(path, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := call("/ping", "token one")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "2", resp.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", resp.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1", resp.Header().Get("X-RateLimit-Used"))
+	assert.Equal(t, "core", resp.Header().Get("X-RateLimit-Resource"))
+	assert.NotEmpty(t, resp.Header().Get("X-RateLimit-Reset"))
+
+	resp = call("/rate_limit", "token one")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	var rateLimitResp RateLimitResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&rateLimitResp))
+	assert.Equal(t, 0, rateLimitResp.Rate.Remaining)
+	assert.Equal(t, rateLimitResp.Rate, rateLimitResp.Resources["core"])
+
+	resp = call("/ping", "token one")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Equal(t, "0", resp.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"message": "API rate limit exceeded for this token.", "documentation_url": "https://docs.github.com/rest/overview/resources-in-the-rest-api#rate-limiting"}`, resp.Body.String())
+
+	// every token has its own bucket, graphql queries are taken from another one.
+	assert.Equal(t, http.StatusOK, call("/ping", "token again").Code)
+	resp = call("/graphql", "token one")
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "graphql", resp.Header().Get("X-RateLimit-Resource"))
+
+	// other tokens and anonymous callers have their own buckets, bad tokens count as anonymous.
+	assert.Equal(t, http.StatusOK, call("/ping", "token two").Code)
+	assert.Equal(t, http.StatusOK, call("/ping", "").Code)
+	assert.Equal(t, http.StatusOK, call("/ping", "token made-up").Code)
+	resp = call("/ping", "token made-up-too")
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.JSONEq(t, `{"message": "API rate limit exceeded for 192.0.2.1.", "documentation_url": "https://docs.github.com/rest/overview/resources-in-the-rest-api#rate-limiting"}`, resp.Body.String())
+
+	// buckets are keyed by the hash of the token, never the token itself.
+	req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+	req.Header.Set("Authorization", "token one")
+	key, caller := rateLimitKey(req, tokenOf)
+	assert.Equal(t, "token:7692c3ad3540bb803c020b3aee66cd8887123234ea0c6e7143c0add73ff431ed", key)
+	assert.Equal(t, "token", caller)
+}
@@ -1,71 +1,125 @@
 package server
 
 import (
-	"context"
 	"fmt"
+	"gbserver/gitstore"
 	"gbserver/handlers"
+	"gbserver/logging"
+	"gbserver/models"
+	"gbserver/service"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/didip/tollbooth/v8"
-	"github.com/didip/tollbooth/v8/limiter"
-	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
 var BaseURL = " "
 var ServerPort = ":9090"
 
+// ReqLimit is the number of requests per second a token (or client address) may make.
 var ReqLimit float64 = 10
 
-type contextKey string
+// RateLimitStatusCode is sent once the limit is reached, github uses 403 and sometimes 429.
+var RateLimitStatusCode = http.StatusForbidden
 
-const requestIDKey = contextKey("requestID")
+// StorageType selects where the store is kept: "memory" or "file".
+var StorageType = "memory"
 
-func uuidMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		uuid := uuid.New().String()
-		w.Header().Set("X-Request-ID", uuid)
-		ctx := context.WithValue(r.Context(), requestIDKey, uuid)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
-}
+// StoragePath is the JSON file used by the "file" storage.
+var StoragePath = "gbstore.json"
+
+// AuthEnabled requires a token on every request, see handlers.AuthMiddleware.
+var AuthEnabled = true
+
+// FixturePath is the YAML/JSON fixture the store is seeded from, empty means the built in one.
+var FixturePath = ""
+
+// GitEnabled backs every repository with a bare git repo so commits and SHAs are real.
+var GitEnabled = true
 
-// loggingMiddleware prints the request ID and the request details.
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		uuid, ok := r.Context().Value(requestIDKey).(string)
-		if !ok {
-			uuid = "unknown"
+// GitRoot is where the bare repos are kept. Empty means next to the file storage, or a temp dir for memory storage.
+var GitRoot = ""
+
+// AssetRoot is where the content of release assets is kept. Empty means next to the file storage, or a temp dir
+// for memory storage.
+var AssetRoot = ""
+
+func newStorage() (models.Storage, models.Seed, error) {
+	var seed models.Seed
+	if FixturePath != "" {
+		// validate right away so a broken fixture stops the server even if the file storage already has state.
+		_, err := models.LoadFixture(FixturePath)
+		if err != nil {
+			return nil, nil, err
 		}
+		slog.Info("Seeding store from fixture", "path", FixturePath)
+		seed = models.FixtureSeed(FixturePath)
+	}
+	switch StorageType {
+	case "memory":
+		return models.NewMemoryStorage(seed), seed, nil
+	case "file":
+		slog.Info("Using file storage", "path", StoragePath)
+		return models.NewFileStorage(StoragePath, seed), seed, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown storage type %q. Specify as memory or file", StorageType)
+	}
+}
 
-		start := time.Now()
-		log.Printf("Started %s %s (Request ID: %s)", r.Method, r.RequestURI, uuid)
-		next.ServeHTTP(w, r)
-		log.Printf("Completed %s in %v (Request ID: %s)", r.RequestURI, time.Since(start), uuid)
-	})
+// newGitStore opens the git object storage, nil when git is disabled or not installed.
+func newGitStore() *gitstore.Store {
+	if !GitEnabled {
+		return nil
+	}
+	root := GitRoot
+	if root == "" {
+		if StorageType == "file" {
+			root = StoragePath + ".repos"
+		} else {
+			tempDir, err := os.MkdirTemp("", "gbserver-repos-")
+			if err != nil {
+				slog.Warn("Git storage is disabled, could not create a temp dir.", "error", err)
+				return nil
+			}
+			root = tempDir
+		}
+	}
+	gitStore, err := gitstore.New(root)
+	if err != nil {
+		slog.Warn("Git storage is disabled, commit SHAs will be made up.", "error", err)
+		return nil
+	}
+	slog.Info("Keeping git repos", "path", gitStore.Root)
+	return gitStore
 }
 
-func TollboothMiddleware(limiter *limiter.Limiter) mux.MiddlewareFunc {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Println(r.Method, r.URL.Path)
-			httpError := tollbooth.LimitByRequest(limiter, w, r)
-
-			if httpError != nil {
-				// If rate limit exceeded
-				w.Header().Add("Content-Type", limiter.GetMessageContentType())
-				w.WriteHeader(httpError.StatusCode)
-				w.Write([]byte(httpError.Message))
-				return
+// newAssetStore opens the storage of release assets, nil when no directory can be made for it.
+func newAssetStore() *service.AssetStore {
+	root := AssetRoot
+	if root == "" {
+		if StorageType == "file" {
+			root = StoragePath + ".assets"
+		} else {
+			tempDir, err := os.MkdirTemp("", "gbserver-assets-")
+			if err != nil {
+				slog.Warn("Release assets are disabled, could not create a temp dir.", "error", err)
+				return nil
 			}
-			next.ServeHTTP(w, r)
-		})
+			root = tempDir
+		}
+	}
+	assets, err := service.NewAssetStore(root)
+	if err != nil {
+		slog.Warn("Release assets are disabled.", "error", err)
+		return nil
 	}
+	slog.Info("Keeping release assets", "path", assets.Root)
+	return assets
 }
 
 func StartServer() {
@@ -74,23 +128,81 @@ func StartServer() {
 
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 
-	l := log.New(os.Stdout, "gbServer ", log.LstdFlags)
-	gbH := handlers.NewGitRepo(l)
+	logger, err := logging.New(os.Stdout, LogLevel, LogFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// the log package and the service log through logger too.
+	slog.SetDefault(logger)
+	storage, seed, err := newStorage()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gbH, err := handlers.NewGitRepoWithStorage(logger, storage, seed)
+	if err != nil {
+		log.Fatal("Error occurred while loading the store. ", err)
+	}
+	if gitStore := newGitStore(); gitStore != nil {
+		err = gbH.UseGit(gitStore)
+		if err != nil {
+			log.Fatal("Error occurred while setting up the git repos. ", err)
+		}
+	}
+
+	if assets := newAssetStore(); assets != nil {
+		gbH.UseAssets(assets)
+	}
 
+	// buckets are keyed per token or client address by TollboothMiddleware itself.
 	limit := tollbooth.NewLimiter(ReqLimit, nil)
-	limit.SetIPLookup(limiter.IPLookup{
-		Name:           "RemoteAddr",
-		IndexFromRight: 0,
-	})
 
-	limit.SetMessage("Reached maximum request limit.")
+	limit.SetMessage("API rate limit exceeded.")
+	limit.SetStatusCode(RateLimitStatusCode)
+	metrics := NewMetrics(gbH.StoreStats)
+	metrics.CountRateLimited(limit)
 
 	router := mux.NewRouter()
-	//	router.Use(uuidMiddleware)
-	//	router.Use(loggingMiddleware)
-	router.Use(TollboothMiddleware(limit))
+	// faults go first, a request hit by one is not counted or authenticated.
+	faults := NewFaults()
+	if FaultsEnabled {
+		slog.Warn("Fault injection is enabled, see /_admin/faults")
+		router.Use(FaultMiddleware(faults))
+	}
+	router.Use(TollboothMiddleware(limit, gbH.TokenOf))
+	if AuthEnabled {
+		router.Use(gbH.AuthMiddleware)
+	} else {
+		slog.Warn("Authentication is disabled, every request is anonymous")
+	}
 
 	apiRouter := router.PathPrefix("/").Subrouter()
+	// get /rate_limit
+	apiRouter.Path("/rate_limit").Methods(http.MethodGet).HandlerFunc(RateLimitHandler(limit, gbH.TokenOf))
+
+	// orgs, users and org memberships, the members routes go before /orgs/{org}/{owner}/repos.
+	apiRouter.Path("/orgs/{org}").Methods(http.MethodGet).HandlerFunc(gbH.GetOrgHandler)
+	apiRouter.Path("/orgs/{org}/members").Methods(http.MethodGet).HandlerFunc(gbH.ListOrgMembersHandler)
+	apiRouter.Path("/orgs/{org}/members/{username}").Methods(http.MethodGet).HandlerFunc(gbH.CheckOrgMemberHandler)
+	apiRouter.Path("/orgs/{org}/members/{username}").Methods(http.MethodDelete).HandlerFunc(gbH.RemoveOrgMemberHandler)
+	apiRouter.Path("/orgs/{org}/memberships/{username}").Methods(http.MethodGet).HandlerFunc(gbH.GetOrgMembershipHandler)
+	apiRouter.Path("/orgs/{org}/memberships/{username}").Methods(http.MethodPut).HandlerFunc(gbH.SetOrgMembershipHandler)
+	apiRouter.Path("/orgs/{org}/memberships/{username}").Methods(http.MethodDelete).HandlerFunc(gbH.RemoveOrgMemberHandler)
+	apiRouter.Path("/users/{user}").Methods(http.MethodGet).HandlerFunc(gbH.GetUserHandler)
+	apiRouter.Path("/user").Methods(http.MethodGet).HandlerFunc(gbH.GetAuthenticatedUserHandler)
+	apiRouter.Path("/user/orgs").Methods(http.MethodGet).HandlerFunc(gbH.ListAuthenticatedUserOrgsHandler)
+
+	// post /graphql, the v4 API over the same store.
+	apiRouter.Path("/graphql").Methods(http.MethodPost).HandlerFunc(gbH.GraphQLHandler)
+
+	// site admins provision users and orgs, like github enterprise's admin API.
+	siteAdminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	if AuthEnabled {
+		siteAdminRouter.Use(gbH.SiteAdminMiddleware)
+	}
+	siteAdminRouter.Path("/users").Methods(http.MethodPost).HandlerFunc(gbH.CreateUserHandler)
+	siteAdminRouter.Path("/users/{username}/authorizations").Methods(http.MethodPost).HandlerFunc(gbH.CreateUserTokenHandler)
+	siteAdminRouter.Path("/organizations").Methods(http.MethodPost).HandlerFunc(gbH.CreateOrgHandler)
+
 	//get  /orgs/{org}/{owner}/repos
 	apiRouter.Path("/orgs/{org}/{owner}/repos").Methods(http.MethodGet).HandlerFunc(gbH.ListRepoHandler)
 
@@ -102,6 +214,10 @@ func StartServer() {
 
 	// // get /Repos/{org}/{owner}/{Repo}/branches
 	apiRouter.Path("/repos/{org}/{owner}/{repo}/branches").Methods(http.MethodGet).HandlerFunc(gbH.ListBranchesHandler)
+	protectionPath := "/repos/{org}/{owner}/{repo}/branches/{branch}/protection"
+	apiRouter.Path(protectionPath).Methods(http.MethodGet).HandlerFunc(gbH.GetBranchProtectionHandler)
+	apiRouter.Path(protectionPath).Methods(http.MethodPut).HandlerFunc(gbH.UpdateBranchProtectionHandler)
+	apiRouter.Path(protectionPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteBranchProtectionHandler)
 
 	// // post /Repos/{org}/{owner}/{Repo}/git/Refs
 	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/refs").Methods(http.MethodPost).HandlerFunc(gbH.CreateBranchHandler)
@@ -109,20 +225,172 @@ func StartServer() {
 	// //delete /Repos/{org}/{owner}/{Repo}/git/Refs/{Ref}
 	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/refs/{ref}").Methods(http.MethodDelete).HandlerFunc(gbH.DeleteBranchHandler)
 
+	// refs, listed in full or by prefix, branches move with PATCH.
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/refs").Methods(http.MethodGet).HandlerFunc(gbH.ListRefsHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/matching-refs/{ref:.+}").Methods(http.MethodGet).HandlerFunc(gbH.ListRefsHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/ref/{ref:.+}").Methods(http.MethodGet).HandlerFunc(gbH.GetRefHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/refs/heads/{branch}").Methods(http.MethodPatch).HandlerFunc(gbH.UpdateRefHandler)
+
+	// tags are created as refs/tags refs, annotated ones point at a tag object from git/tags.
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/refs/tags/{tag:.+}").Methods(http.MethodDelete).HandlerFunc(gbH.DeleteTagHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/tags").Methods(http.MethodPost).HandlerFunc(gbH.CreateTagObjectHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/tags/{sha}").Methods(http.MethodGet).HandlerFunc(gbH.GetTagObjectHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/tags").Methods(http.MethodGet).HandlerFunc(gbH.ListTagsHandler)
+
+	// releases and their assets, the asset routes are registered before {release_id} so they are not taken for one.
+	releasesPath := "/repos/{org}/{owner}/{repo}/releases"
+	releasePath := releasesPath + "/{release_id:[0-9]+}"
+	assetPath := releasesPath + "/assets/{asset_id:[0-9]+}"
+	apiRouter.Path(releasesPath).Methods(http.MethodGet).HandlerFunc(gbH.ListReleasesHandler)
+	apiRouter.Path(releasesPath).Methods(http.MethodPost).HandlerFunc(gbH.CreateReleaseHandler)
+	apiRouter.Path(releasesPath + "/latest").Methods(http.MethodGet).HandlerFunc(gbH.GetReleaseHandler)
+	apiRouter.Path(releasesPath + "/tags/{tag:.+}").Methods(http.MethodGet).HandlerFunc(gbH.GetReleaseHandler)
+	apiRouter.Path(assetPath).Methods(http.MethodGet).HandlerFunc(gbH.GetReleaseAssetHandler)
+	apiRouter.Path(assetPath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateReleaseAssetHandler)
+	apiRouter.Path(assetPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteReleaseAssetHandler)
+	apiRouter.Path(releasePath).Methods(http.MethodGet).HandlerFunc(gbH.GetReleaseHandler)
+	apiRouter.Path(releasePath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateReleaseHandler)
+	apiRouter.Path(releasePath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteReleaseHandler)
+	apiRouter.Path(releasePath + "/assets").Methods(http.MethodGet).HandlerFunc(gbH.ListReleaseAssetsHandler)
+	apiRouter.Path(releasePath + "/assets").Methods(http.MethodPost).HandlerFunc(gbH.UploadReleaseAssetHandler)
+
 	// // get /repos/{org}/{owner}/{repo}/pulls
 	apiRouter.Path("/repos/{org}/{owner}/{repo}/pulls").Methods(http.MethodGet).HandlerFunc(gbH.ListPRHandler)
 
 	// // post /repos/{org}/{owner}/{Repo}/pulls
 	apiRouter.Path("/repos/{org}/{owner}/{repo}/pulls").Methods(http.MethodPost).HandlerFunc(gbH.CreatePRHandler)
 
+	// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/pulls/{pull_number:[0-9]+}").Methods(http.MethodGet).HandlerFunc(gbH.GetPRHandler)
+
 	// //patch /repos/{org}/{owner}/{repo}/pulls/{pull_number} State - closed
 	apiRouter.Path("/repos/{org}/{owner}/{repo}/pulls/{pull_number}").Methods(http.MethodPatch).HandlerFunc(gbH.UpdatePRHandler)
 
+	// //put /repos/{org}/{owner}/{repo}/pulls/{pull_number}/merge
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/pulls/{pull_number}/merge").Methods(http.MethodPut).HandlerFunc(gbH.MergePRHandler)
+
+	// reviews, review comments and requested reviewers of pull requests.
+	pullPath := "/repos/{org}/{owner}/{repo}/pulls/{pull_number:[0-9]+}"
+	reviewPath := pullPath + "/reviews/{review_id:[0-9]+}"
+	reviewCommentPath := "/repos/{org}/{owner}/{repo}/pulls/comments/{comment_id:[0-9]+}"
+	apiRouter.Path(pullPath + "/reviews").Methods(http.MethodGet).HandlerFunc(gbH.ListReviewsHandler)
+	apiRouter.Path(pullPath + "/reviews").Methods(http.MethodPost).HandlerFunc(gbH.CreateReviewHandler)
+	apiRouter.Path(reviewPath).Methods(http.MethodGet).HandlerFunc(gbH.GetReviewHandler)
+	apiRouter.Path(reviewPath).Methods(http.MethodPut).HandlerFunc(gbH.UpdateReviewHandler)
+	apiRouter.Path(reviewPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteReviewHandler)
+	apiRouter.Path(reviewPath + "/events").Methods(http.MethodPost).HandlerFunc(gbH.SubmitReviewHandler)
+	apiRouter.Path(reviewPath + "/dismissals").Methods(http.MethodPut).HandlerFunc(gbH.DismissReviewHandler)
+	apiRouter.Path(reviewPath + "/comments").Methods(http.MethodGet).HandlerFunc(gbH.ListReviewCommentsOfReviewHandler)
+	apiRouter.Path(pullPath + "/comments").Methods(http.MethodGet).HandlerFunc(gbH.ListReviewCommentsHandler)
+	apiRouter.Path(pullPath + "/comments").Methods(http.MethodPost).HandlerFunc(gbH.CreateReviewCommentHandler)
+	apiRouter.Path(reviewCommentPath).Methods(http.MethodGet).HandlerFunc(gbH.GetReviewCommentHandler)
+	apiRouter.Path(reviewCommentPath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateReviewCommentHandler)
+	apiRouter.Path(reviewCommentPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteReviewCommentHandler)
+	apiRouter.Path(pullPath + "/requested_reviewers").Methods(http.MethodGet).HandlerFunc(gbH.ListRequestedReviewersHandler)
+	apiRouter.Path(pullPath+"/requested_reviewers").Methods(http.MethodPost, http.MethodDelete).HandlerFunc(gbH.RequestReviewersHandler)
+
+	// issues share their numbers with pull requests.
+	issuesPath := "/repos/{org}/{owner}/{repo}/issues"
+	issuePath := issuesPath + "/{issue_number:[0-9]+}"
+	commentPath := issuesPath + "/comments/{comment_id:[0-9]+}"
+	apiRouter.Path(issuesPath).Methods(http.MethodGet).HandlerFunc(gbH.ListIssuesHandler)
+	apiRouter.Path(issuesPath).Methods(http.MethodPost).HandlerFunc(gbH.CreateIssueHandler)
+	apiRouter.Path(issuePath).Methods(http.MethodGet).HandlerFunc(gbH.GetIssueHandler)
+	apiRouter.Path(issuePath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateIssueHandler)
+	apiRouter.Path(issuePath + "/comments").Methods(http.MethodGet).HandlerFunc(gbH.ListIssueCommentsHandler)
+	apiRouter.Path(issuePath + "/comments").Methods(http.MethodPost).HandlerFunc(gbH.CreateIssueCommentHandler)
+	apiRouter.Path(commentPath).Methods(http.MethodGet).HandlerFunc(gbH.GetIssueCommentHandler)
+	apiRouter.Path(commentPath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateIssueCommentHandler)
+	apiRouter.Path(commentPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteIssueCommentHandler)
+	apiRouter.Path(issuePath + "/labels").Methods(http.MethodGet).HandlerFunc(gbH.ListIssueLabelsHandler)
+	apiRouter.Path(issuePath+"/labels").Methods(http.MethodPost, http.MethodPut).HandlerFunc(gbH.AddIssueLabelsHandler)
+	apiRouter.Path(issuePath + "/labels").Methods(http.MethodDelete).HandlerFunc(gbH.ClearIssueLabelsHandler)
+	apiRouter.Path(issuePath + "/labels/{name}").Methods(http.MethodDelete).HandlerFunc(gbH.RemoveIssueLabelHandler)
+	apiRouter.Path(issuePath+"/assignees").Methods(http.MethodPost, http.MethodDelete).HandlerFunc(gbH.IssueAssigneesHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/assignees").Methods(http.MethodGet).HandlerFunc(gbH.ListAssigneesHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/assignees/{assignee}").Methods(http.MethodGet).HandlerFunc(gbH.CheckAssigneeHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/labels").Methods(http.MethodGet).HandlerFunc(gbH.ListLabelsHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/labels").Methods(http.MethodPost).HandlerFunc(gbH.CreateLabelHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/labels/{name}").Methods(http.MethodGet).HandlerFunc(gbH.GetLabelHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/labels/{name}").Methods(http.MethodPatch).HandlerFunc(gbH.UpdateLabelHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/labels/{name}").Methods(http.MethodDelete).HandlerFunc(gbH.DeleteLabelHandler)
+
+	// statuses and checks, registered before the commits so {ref:.+} does not swallow their paths.
+	refPath := "/repos/{org}/{owner}/{repo}/commits/{ref:.+}"
+	checkRunPath := "/repos/{org}/{owner}/{repo}/check-runs/{check_run_id:[0-9]+}"
+	checkSuitePath := "/repos/{org}/{owner}/{repo}/check-suites/{check_suite_id:[0-9]+}"
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/statuses/{sha}").Methods(http.MethodGet).HandlerFunc(gbH.ListStatusesHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/statuses/{sha}").Methods(http.MethodPost).HandlerFunc(gbH.CreateStatusHandler)
+	apiRouter.Path(refPath + "/statuses").Methods(http.MethodGet).HandlerFunc(gbH.ListStatusesHandler)
+	apiRouter.Path(refPath + "/status").Methods(http.MethodGet).HandlerFunc(gbH.GetCombinedStatusHandler)
+	apiRouter.Path(refPath + "/check-runs").Methods(http.MethodGet).HandlerFunc(gbH.ListCheckRunsHandler)
+	apiRouter.Path(refPath + "/check-suites").Methods(http.MethodGet).HandlerFunc(gbH.ListCheckSuitesHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/check-runs").Methods(http.MethodPost).HandlerFunc(gbH.CreateCheckRunHandler)
+	apiRouter.Path(checkRunPath).Methods(http.MethodGet).HandlerFunc(gbH.GetCheckRunHandler)
+	apiRouter.Path(checkRunPath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateCheckRunHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/check-suites").Methods(http.MethodPost).HandlerFunc(gbH.CreateCheckSuiteHandler)
+	apiRouter.Path(checkSuitePath).Methods(http.MethodGet).HandlerFunc(gbH.GetCheckSuiteHandler)
+	apiRouter.Path(checkSuitePath + "/check-runs").Methods(http.MethodGet).HandlerFunc(gbH.ListSuiteCheckRunsHandler)
+
+	// commits, refs may contain slashes.
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/commits").Methods(http.MethodGet).HandlerFunc(gbH.ListCommitsHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/commits/{ref:.+}").Methods(http.MethodGet).HandlerFunc(gbH.GetCommitHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/git/commits/{sha}").Methods(http.MethodGet).HandlerFunc(gbH.GetGitCommitHandler)
+	apiRouter.Path("/repos/{org}/{owner}/{repo}/compare/{basehead:.+}").Methods(http.MethodGet).HandlerFunc(gbH.CompareCommitsHandler)
+
+	// contents, the root directory has no path.
+	for _, contentsPath := range []string{"/repos/{org}/{owner}/{repo}/contents", "/repos/{org}/{owner}/{repo}/contents/{path:.+}"} {
+		apiRouter.Path(contentsPath).Methods(http.MethodGet).HandlerFunc(gbH.GetContentsHandler)
+		apiRouter.Path(contentsPath).Methods(http.MethodPut).HandlerFunc(gbH.PutContentsHandler)
+		apiRouter.Path(contentsPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteContentsHandler)
+	}
+
+	// repo and org webhooks, both served by the same handlers.
+	for _, hooksPath := range []string{"/repos/{org}/{owner}/{repo}/hooks", "/orgs/{org}/hooks"} {
+		hookPath := hooksPath + "/{hook_id:[0-9]+}"
+		deliveryPath := hookPath + "/deliveries/{delivery_id:[0-9]+}"
+		apiRouter.Path(hooksPath).Methods(http.MethodGet).HandlerFunc(gbH.ListHooksHandler)
+		apiRouter.Path(hooksPath).Methods(http.MethodPost).HandlerFunc(gbH.CreateHookHandler)
+		apiRouter.Path(hookPath).Methods(http.MethodGet).HandlerFunc(gbH.GetHookHandler)
+		apiRouter.Path(hookPath).Methods(http.MethodPatch).HandlerFunc(gbH.UpdateHookHandler)
+		apiRouter.Path(hookPath).Methods(http.MethodDelete).HandlerFunc(gbH.DeleteHookHandler)
+		apiRouter.Path(hookPath + "/pings").Methods(http.MethodPost).HandlerFunc(gbH.PingHookHandler)
+		apiRouter.Path(hookPath + "/deliveries").Methods(http.MethodGet).HandlerFunc(gbH.ListHookDeliveriesHandler)
+		apiRouter.Path(deliveryPath).Methods(http.MethodGet).HandlerFunc(gbH.GetHookDeliveryHandler)
+		apiRouter.Path(deliveryPath + "/attempts").Methods(http.MethodPost).HandlerFunc(gbH.RedeliverHookHandler)
+	}
+
+	// git smart HTTP, so repos can be cloned from and pushed to.
+	gitPath := "/{org}/{owner}/{repo}.git"
+	apiRouter.Path(gitPath + "/info/refs").Methods(http.MethodGet).HandlerFunc(gbH.GitInfoRefsHandler)
+	apiRouter.Path(gitPath + "/{service:git-upload-pack|git-receive-pack}").Methods(http.MethodPost).HandlerFunc(gbH.GitServiceHandler)
+
+	// admin endpoints to get the store back to a known state between test cases.
+	adminRouter := router.PathPrefix("/_admin").Subrouter()
+	if AuthEnabled {
+		adminRouter.Use(gbH.SiteAdminMiddleware)
+	}
+	adminRouter.Path("/reset").Methods(http.MethodPost).HandlerFunc(gbH.ResetHandler)
+	adminRouter.Path("/snapshot").Methods(http.MethodPost).HandlerFunc(gbH.SnapshotHandler)
+	adminRouter.Path("/snapshot").Methods(http.MethodGet).HandlerFunc(gbH.ListSnapshotsHandler)
+	adminRouter.Path("/snapshot/{id}").Methods(http.MethodDelete).HandlerFunc(gbH.DeleteSnapshotHandler)
+	adminRouter.Path("/restore/{id}").Methods(http.MethodPost).HandlerFunc(gbH.RestoreHandler)
+	if FaultsEnabled {
+		adminRouter.Path("/faults").Methods(http.MethodGet, http.MethodPut, http.MethodDelete).HandlerFunc(FaultsHandler(faults))
+	}
+
+	handler, err := proxyHandler(router)
+	if err != nil {
+		log.Fatal(err)
+	}
+	handler = uuidMiddleware(loggingMiddleware(logger, router)(metricsMiddleware(metrics, router)(handler)))
+
 	go func() {
-		log.Println("Starting GB server on ..", ServerPort)
-		log.Fatal(http.ListenAndServe(ServerPort, router))
+		slog.Info("Starting GB server", "port", ServerPort)
+		log.Fatal(http.ListenAndServe(ServerPort, handler))
 
 	}()
 	<-sigChan
 	fmt.Println("Stopping GB server..")
+	gbH.Close()
 }
@@ -0,0 +1,377 @@
+// Package gitstore keeps the git object database of every repository as a bare repo on disk.
+// It drives the git command line, so git must be installed where the server runs.
+package gitstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrObjectNotFound = errors.New("object does not exist")
+var ErrMergeConflict = errors.New("merge conflict")
+var ErrInvalidRepoKey = errors.New("repository path is outside of the git root")
+
+var shaRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Signature is the author or committer of a commit.
+type Signature struct {
+	Name  string
+	Email string
+	// When defaults to the time of the commit.
+	When time.Time
+}
+
+// FileChange is one file written to (or, with Delete, removed from) a tree.
+type FileChange struct {
+	Path    string
+	Content []byte
+	Delete  bool
+}
+
+// Store keeps one bare repo per repository key (org/owner/repo) under Root.
+type Store struct {
+	Root string
+
+	mu        sync.Mutex
+	repoLocks map[string]*sync.Mutex
+}
+
+// New makes sure git is installed and root exists.
+func New(root string) (*Store, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is not installed: %w", err)
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(absRoot, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{Root: absRoot}, nil
+}
+
+// LockRepo takes the lock of a repo and returns the function releasing it. A push holds it from its checks
+// until git has updated the refs, changes that decide what a push may do take it too.
+func (s *Store) LockRepo(repoKey string) func() {
+	s.mu.Lock()
+	if s.repoLocks == nil {
+		s.repoLocks = make(map[string]*sync.Mutex)
+	}
+	lock, exists := s.repoLocks[repoKey]
+	if !exists {
+		lock = &sync.Mutex{}
+		s.repoLocks[repoKey] = lock
+	}
+	s.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// IsSHA tells if sha is a full 40 hex object id.
+func IsSHA(sha string) bool {
+	return shaRegexp.MatchString(sha)
+}
+
+// Path is the directory of the bare repo of repoKey, keys that would lead out of Root are refused.
+func (s *Store) Path(repoKey string) (string, error) {
+	path := filepath.Join(s.Root, filepath.FromSlash(repoKey)+".git")
+	relative, err := filepath.Rel(s.Root, path)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", ErrInvalidRepoKey
+	}
+	return path, nil
+}
+
+func (s *Store) command(repoKey string, env []string, args ...string) (*exec.Cmd, error) {
+	path, err := s.Path(repoKey)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command("git", append([]string{"--git-dir", path}, args...)...)
+	// keep the config of whoever runs the server out of the mock.
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull)
+	cmd.Env = append(cmd.Env, env...)
+	return cmd, nil
+}
+
+// runRaw runs git on the repo and returns its untouched stdout.
+func (s *Store) runRaw(repoKey string, env []string, stdin io.Reader, args ...string) ([]byte, error) {
+	cmd, err := s.command(repoKey, env, args...)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return stdout.Bytes(), fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+func (s *Store) run(repoKey string, env []string, stdin io.Reader, args ...string) (string, error) {
+	out, err := s.runRaw(repoKey, env, stdin, args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// Init creates the bare repo of repoKey, an existing one is left alone.
+func (s *Store) Init(repoKey string) error {
+	path, err := s.Path(repoKey)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	cmd := exec.Command("git", "init", "--bare", "--quiet", path)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git init: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Remove deletes the bare repo of repoKey.
+func (s *Store) Remove(repoKey string) error {
+	path, err := s.Path(repoKey)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(path)
+}
+
+// RepoKeys lists the keys of the bare repos under Root.
+func (s *Store) RepoKeys() ([]string, error) {
+	repoKeys := []string{}
+	err := filepath.WalkDir(s.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() || !strings.HasSuffix(path, ".git") {
+			return err
+		}
+		if _, statErr := os.Stat(filepath.Join(path, "HEAD")); statErr != nil {
+			return nil
+		}
+		relative, err := filepath.Rel(s.Root, strings.TrimSuffix(path, ".git"))
+		if err != nil {
+			return err
+		}
+		repoKeys = append(repoKeys, filepath.ToSlash(relative))
+		return filepath.SkipDir
+	})
+	return repoKeys, err
+}
+
+// CommitExists tells if sha is a commit of the repo.
+func (s *Store) CommitExists(repoKey, sha string) bool {
+	if !IsSHA(sha) {
+		return false
+	}
+	_, err := s.run(repoKey, nil, nil, "cat-file", "-e", sha+"^{commit}")
+	return err == nil
+}
+
+// TreeOf returns the tree of a commit.
+func (s *Store) TreeOf(repoKey, commit string) (string, error) {
+	if !IsSHA(commit) {
+		return "", ErrObjectNotFound
+	}
+	tree, err := s.run(repoKey, nil, nil, "rev-parse", "--verify", "--quiet", commit+"^{tree}")
+	if err != nil {
+		return "", ErrObjectNotFound
+	}
+	return tree, nil
+}
+
+// WriteBlob stores data and returns its blob id.
+func (s *Store) WriteBlob(repoKey string, data []byte) (string, error) {
+	return s.run(repoKey, nil, bytes.NewReader(data), "hash-object", "-w", "--stdin")
+}
+
+// WriteTree applies changes on top of baseTree ("" for an empty tree) and returns the new tree.
+func (s *Store) WriteTree(repoKey, baseTree string, changes []FileChange) (string, error) {
+	index, err := os.CreateTemp("", "gbserver-index-*")
+	if err != nil {
+		return "", err
+	}
+	index.Close()
+	os.Remove(index.Name())
+	defer os.Remove(index.Name())
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+
+	if baseTree != "" {
+		_, err = s.run(repoKey, env, nil, "read-tree", baseTree)
+	} else {
+		_, err = s.run(repoKey, env, nil, "read-tree", "--empty")
+	}
+	if err != nil {
+		return "", err
+	}
+	// --index-info works without a work tree, a zero mode removes the path.
+	var indexInfo strings.Builder
+	for _, change := range changes {
+		if change.Delete {
+			fmt.Fprintf(&indexInfo, "0 %s\t%s\n", strings.Repeat("0", 40), change.Path)
+			continue
+		}
+		blob, err := s.WriteBlob(repoKey, change.Content)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&indexInfo, "100644 %s\t%s\n", blob, change.Path)
+	}
+	_, err = s.run(repoKey, env, strings.NewReader(indexInfo.String()), "update-index", "--index-info")
+	if err != nil {
+		return "", err
+	}
+	return s.run(repoKey, env, nil, "write-tree")
+}
+
+// signatureEnv sets who made the commit and when, a zero When means now.
+func signatureEnv(prefix string, sig Signature) []string {
+	if sig.When.IsZero() {
+		sig.When = time.Now()
+	}
+	return []string{
+		"GIT_" + prefix + "_NAME=" + sig.Name,
+		"GIT_" + prefix + "_EMAIL=" + sig.Email,
+		fmt.Sprintf("GIT_%s_DATE=%d +0000", prefix, sig.When.Unix()),
+	}
+}
+
+// CommitTree creates a commit of tree with the given parents.
+func (s *Store) CommitTree(repoKey, tree string, parents []string, message string, author, committer Signature) (string, error) {
+	args := []string{"commit-tree", tree}
+	for _, parent := range parents {
+		args = append(args, "-p", parent)
+	}
+	env := append(signatureEnv("AUTHOR", author), signatureEnv("COMMITTER", committer)...)
+	return s.run(repoKey, env, strings.NewReader(message), args...)
+}
+
+// Commit writes changes on top of parent ("" for a root commit) as a new commit.
+func (s *Store) Commit(repoKey, parent string, changes []FileChange, message string, author, committer Signature) (string, error) {
+	var baseTree string
+	var parents []string
+	if parent != "" {
+		tree, err := s.TreeOf(repoKey, parent)
+		if err != nil {
+			return "", err
+		}
+		baseTree = tree
+		parents = []string{parent}
+	}
+	tree, err := s.WriteTree(repoKey, baseTree, changes)
+	if err != nil {
+		return "", err
+	}
+	return s.CommitTree(repoKey, tree, parents, message, author, committer)
+}
+
+// MergeTree merges two commits without touching any ref and returns the resulting tree.
+func (s *Store) MergeTree(repoKey, ours, theirs string) (string, error) {
+	out, err := s.run(repoKey, nil, nil, "merge-tree", "--write-tree", "--no-messages", ours, theirs)
+	if exitCode(err) == 1 {
+		return "", ErrMergeConflict
+	}
+	if err != nil {
+		return "", err
+	}
+	tree, _, _ := strings.Cut(out, "\n")
+	return tree, nil
+}
+
+// PickTree is the tree a cherry-pick of commit onto onto writes: the tree of onto with the changes commit made
+// to its parent. A commit with onto's tree and commit's parent makes merge-tree take that parent as the base.
+func (s *Store) PickTree(repoKey, onto string, commit Commit) (string, error) {
+	if len(commit.Parents) != 1 {
+		return "", ErrMergeConflict
+	}
+	ontoTree, err := s.TreeOf(repoKey, onto)
+	if err != nil {
+		return "", err
+	}
+	side, err := s.CommitTree(repoKey, ontoTree, commit.Parents, "pick\n", commit.Author, commit.Committer)
+	if err != nil {
+		return "", err
+	}
+	return s.MergeTree(repoKey, side, commit.SHA)
+}
+
+// IsAncestor tells if ancestor is reachable from descendant.
+func (s *Store) IsAncestor(repoKey, ancestor, descendant string) (bool, error) {
+	_, err := s.run(repoKey, nil, nil, "merge-base", "--is-ancestor", ancestor, descendant)
+	if exitCode(err) == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// RevList lists the commits reachable from head but not from base, oldest first.
+func (s *Store) RevList(repoKey, base, head string) ([]string, error) {
+	out, err := s.run(repoKey, nil, nil, "rev-list", "--reverse", "--topo-order", head, "^"+base)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// UpdateRef points ref at sha. With oldSHA set the update only happens when ref is still at oldSHA.
+func (s *Store) UpdateRef(repoKey, ref, sha, oldSHA string) error {
+	args := []string{"update-ref", ref, sha}
+	if oldSHA != "" {
+		args = append(args, oldSHA)
+	}
+	_, err := s.run(repoKey, nil, nil, args...)
+	return err
+}
+
+// DeleteRef removes ref, a missing ref is not an error.
+func (s *Store) DeleteRef(repoKey, ref string) error {
+	_, err := s.run(repoKey, nil, nil, "update-ref", "-d", ref)
+	return err
+}
+
+// Refs returns the refs under prefix (e.g. refs/heads/) with the object they point at.
+func (s *Store) Refs(repoKey, prefix string) (map[string]string, error) {
+	out, err := s.run(repoKey, nil, nil, "for-each-ref", "--format=%(refname) %(objectname)", prefix)
+	if err != nil {
+		return nil, err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if ref, sha, found := strings.Cut(line, " "); found {
+			refs[ref] = sha
+		}
+	}
+	return refs, nil
+}
+
+// SetHead makes branch the default branch of the repo, the one a clone checks out.
+func (s *Store) SetHead(repoKey, branch string) error {
+	_, err := s.run(repoKey, nil, nil, "symbolic-ref", "HEAD", "refs/heads/"+branch)
+	return err
+}
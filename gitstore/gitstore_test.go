@@ -0,0 +1,200 @@
+package gitstore
+
+import (
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T) *Store {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	store, err := New(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, store.Init("gborg/gbuser/gbrepo"))
+	return store
+}
+
+func TestCommitAndMerge(t *testing.T) {
+	store := newTestStore(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := Signature{Name: "gbuser", Email: "gbuser@gbserver.com", When: time.Unix(1700000000, 0)}
+
+	root, err := store.Commit(repoKey, "", []FileChange{{Path: "README.md", Content: []byte("# gbrepo\n")}}, "Initial commit", sig, sig)
+	assert.NoError(t, err)
+	assert.True(t, IsSHA(root))
+	assert.True(t, store.CommitExists(repoKey, root))
+	assert.False(t, store.CommitExists(repoKey, "aa218f56b14c9653891f9e74264a383fa43fefbd"))
+
+	ours, err := store.Commit(repoKey, root, []FileChange{{Path: "a.txt", Content: []byte("a\n")}}, "Add a", sig, sig)
+	assert.NoError(t, err)
+	theirs, err := store.Commit(repoKey, root, []FileChange{{Path: "b.txt", Content: []byte("b\n")}}, "Add b", sig, sig)
+	assert.NoError(t, err)
+	tree, err := store.MergeTree(repoKey, ours, theirs)
+	assert.NoError(t, err)
+	merged, err := store.CommitTree(repoKey, tree, []string{ours, theirs}, "Merge", sig, sig)
+	assert.NoError(t, err)
+	isAncestor, err := store.IsAncestor(repoKey, theirs, merged)
+	assert.NoError(t, err)
+	assert.True(t, isAncestor)
+	commits, err := store.RevList(repoKey, root, merged)
+	assert.NoError(t, err)
+	assert.Len(t, commits, 3)
+	assert.Equal(t, merged, commits[2])
+
+	conflicting, err := store.Commit(repoKey, root, []FileChange{{Path: "a.txt", Content: []byte("not a\n")}}, "Change a", sig, sig)
+	assert.NoError(t, err)
+	_, err = store.MergeTree(repoKey, ours, conflicting)
+	assert.Equal(t, ErrMergeConflict, err)
+
+	// picking theirs onto ours brings b.txt over, what changed a.txt the other way does not apply.
+	theirsCommit, err := store.ReadCommit(repoKey, theirs)
+	assert.NoError(t, err)
+	picked, err := store.PickTree(repoKey, ours, theirsCommit)
+	assert.NoError(t, err)
+	assert.Equal(t, tree, picked)
+	conflictingCommit, _ := store.ReadCommit(repoKey, conflicting)
+	_, err = store.PickTree(repoKey, ours, conflictingCommit)
+	assert.Equal(t, ErrMergeConflict, err)
+}
+
+func TestRefs(t *testing.T) {
+	store := newTestStore(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := Signature{Name: "gbuser", Email: "gbuser@gbserver.com", When: time.Unix(1700000000, 0)}
+	first, _ := store.Commit(repoKey, "", []FileChange{{Path: "README.md", Content: []byte("one\n")}}, "One", sig, sig)
+	second, err := store.Commit(repoKey, first, []FileChange{{Path: "README.md", Delete: true}}, "Two", sig, sig)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.UpdateRef(repoKey, "refs/heads/main", first, ""))
+	// a stale old value must not move the ref.
+	assert.Error(t, store.UpdateRef(repoKey, "refs/heads/main", second, second))
+	assert.NoError(t, store.UpdateRef(repoKey, "refs/heads/main", second, first))
+	assert.NoError(t, store.UpdateRef(repoKey, "refs/heads/dev", first, ""))
+	assert.NoError(t, store.SetHead(repoKey, "main"))
+
+	refs, err := store.Refs(repoKey, "refs/heads/")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/heads/main": second, "refs/heads/dev": first}, refs)
+
+	assert.NoError(t, store.DeleteRef(repoKey, "refs/heads/dev"))
+	refs, _ = store.Refs(repoKey, "refs/heads/")
+	assert.Len(t, refs, 1)
+	assert.NoError(t, store.Init("gborg/gbuser/other"))
+	repoKeys, err := store.RepoKeys()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{repoKey, "gborg/gbuser/other"}, repoKeys)
+	assert.NoError(t, store.Remove(repoKey))
+	repoKeys, _ = store.RepoKeys()
+	assert.Equal(t, []string{"gborg/gbuser/other"}, repoKeys)
+
+	// keys leading out of the root are refused before anything touches the disk.
+	_, err = store.Path("gborg/gbuser/../../../x")
+	assert.ErrorIs(t, err, ErrInvalidRepoKey)
+	assert.ErrorIs(t, store.Init("../x"), ErrInvalidRepoKey)
+	assert.ErrorIs(t, store.Remove("../x"), ErrInvalidRepoKey)
+}
+
+func TestLogAndDiff(t *testing.T) {
+	store := newTestStore(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := Signature{Name: "gbuser", Email: "gbuser@gbserver.com", When: time.Unix(1700000000, 0)}
+	root, _ := store.Commit(repoKey, "", []FileChange{{Path: "a.txt", Content: []byte("one\ntwo\nthree\n")},
+		{Path: "bin.dat", Content: []byte{0, 1, 2}}}, "Root\n\nWith a body", sig, sig)
+	tree, _ := store.WriteTree(repoKey, "", []FileChange{{Path: "b.txt", Content: []byte("one\ntwo\nthree\n")},
+		{Path: "bin.dat", Content: []byte{0, 1, 3}}})
+	later := Signature{Name: "gbuser", Email: "gbuser@gbserver.com", When: time.Unix(1700001000, 0)}
+	renamed, err := store.CommitTree(repoKey, tree, []string{root}, "Rename", later, later)
+	assert.NoError(t, err)
+
+	commits, err := store.Log(repoKey, LogOptions{Head: renamed})
+	assert.NoError(t, err)
+	assert.Len(t, commits, 2)
+	assert.Equal(t, "Root\n\nWith a body", commits[1].Message)
+	assert.Equal(t, []string{root}, commits[0].Parents)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), commits[1].Author.When)
+	commits, _ = store.Log(repoKey, LogOptions{Head: renamed, Since: time.Unix(1700000500, 0)})
+	assert.Len(t, commits, 1)
+	commits, _ = store.Log(repoKey, LogOptions{Head: renamed, Path: "a.txt"})
+	assert.Len(t, commits, 2)
+
+	files, err := store.Diff(repoKey, root, renamed)
+	assert.NoError(t, err)
+	assert.Equal(t, []FileStat{
+		{Path: "b.txt", OldPath: "a.txt", Status: "R", Blob: files[0].Blob},
+		{Path: "bin.dat", Status: "M", Blob: files[1].Blob, Binary: true},
+	}, files)
+	files, _ = store.Diff(repoKey, "", root)
+	assert.Equal(t, 3, files[0].Additions)
+
+	short, err := store.ResolveCommit(repoKey, renamed[:8])
+	assert.NoError(t, err)
+	assert.Equal(t, renamed, short)
+	_, err = store.ResolveCommit(repoKey, "--all")
+	assert.Equal(t, ErrObjectNotFound, err)
+	count, _ := store.CountCommits(repoKey, root, renamed)
+	assert.Equal(t, 1, count)
+}
+
+func TestReadPushCommands(t *testing.T) {
+	zero := strings.Repeat("0", 40)
+	sha := strings.Repeat("a", 40)
+	request := pktLine(zero+" "+sha+" refs/heads/main\x00report-status side-band-64k\n") +
+		pktLine(sha+" "+zero+" refs/heads/dev\n") + "0000" + "PACK..."
+	in := strings.NewReader(request)
+	updates, consumed, err := ReadPushCommands(in)
+	assert.NoError(t, err)
+	assert.Equal(t, []RefUpdate{{OldSHA: zero, NewSHA: sha, Ref: "refs/heads/main"}, {OldSHA: sha, NewSHA: zero, Ref: "refs/heads/dev"}}, updates)
+	// the pack is left for git.
+	rest, _ := io.ReadAll(in)
+	assert.Equal(t, request, string(consumed)+string(rest))
+
+	_, _, err = ReadPushCommands(strings.NewReader("zz"))
+	assert.Error(t, err)
+}
+
+func TestTags(t *testing.T) {
+	store := newTestStore(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := Signature{Name: "gbuser", Email: "gbuser@gbserver.com", When: time.Unix(1700000000, 0).UTC()}
+	commit, err := store.Commit(repoKey, "", []FileChange{{Path: "README.md", Content: []byte("one\n")}}, "One", sig, sig)
+	assert.NoError(t, err)
+
+	tagSHA, err := store.CreateTag(repoKey, "v1.0.0", commit, "commit", "First release", sig)
+	assert.NoError(t, err)
+	objectType, _ := store.ObjectType(repoKey, tagSHA)
+	assert.Equal(t, "tag", objectType)
+	tag, err := store.ReadTag(repoKey, tagSHA)
+	assert.NoError(t, err)
+	assert.Equal(t, Tag{SHA: tagSHA, Name: "v1.0.0", Object: commit, Type: "commit", Tagger: sig, Message: "First release"}, tag)
+
+	_, err = store.ReadTag(repoKey, commit)
+	assert.Equal(t, ErrObjectNotFound, err)
+	_, err = store.ObjectType(repoKey, strings.Repeat("0", 40))
+	assert.Equal(t, ErrObjectNotFound, err)
+}
+
+func TestLockRepo(t *testing.T) {
+	store := &Store{Root: t.TempDir()}
+	unlock := store.LockRepo("gborg/gbuser/gbrepo")
+	// other repos are not held up.
+	store.LockRepo("gborg/gbuser/other")()
+
+	locked := make(chan struct{})
+	go func() {
+		defer store.LockRepo("gborg/gbuser/gbrepo")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("the repo was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	<-locked
+}
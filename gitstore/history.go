@@ -0,0 +1,205 @@
+package gitstore
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emptyTree is the id of the tree without any file, what a root commit is compared against.
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// Commit is a commit object read back from the repo.
+type Commit struct {
+	SHA       string
+	Tree      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Message   string
+}
+
+// LogOptions narrows down Log.
+type LogOptions struct {
+	// Head is where the walk starts, Exclude (optional) stops it at the commits reachable from it.
+	Head    string
+	Exclude string
+	// Path only keeps commits touching the file or directory.
+	Path  string
+	Since time.Time
+	Until time.Time
+	// Max limits the number of commits, 0 means all of them.
+	Max int
+}
+
+// FileStat is one file changed between two commits.
+type FileStat struct {
+	Path    string
+	OldPath string
+	// Status is A(dded), D(eleted), M(odified), R(enamed), C(opied) or T(ype changed).
+	Status    string
+	Blob      string
+	Additions int
+	Deletions int
+	Binary    bool
+}
+
+// the fields of a commit are separated by \x1f, commits by the NUL of -z.
+const logFormat = "--format=%H%x1f%T%x1f%P%x1f%an%x1f%ae%x1f%at%x1f%cn%x1f%ce%x1f%ct%x1f%B"
+
+func parseCommit(record string) Commit {
+	fields := strings.SplitN(record, "\x1f", 10)
+	for len(fields) < 10 {
+		fields = append(fields, "")
+	}
+	unix := func(field string) time.Time {
+		seconds, _ := strconv.ParseInt(field, 10, 64)
+		return time.Unix(seconds, 0).UTC()
+	}
+	return Commit{
+		SHA:       fields[0],
+		Tree:      fields[1],
+		Parents:   strings.Fields(fields[2]),
+		Author:    Signature{Name: fields[3], Email: fields[4], When: unix(fields[5])},
+		Committer: Signature{Name: fields[6], Email: fields[7], When: unix(fields[8])},
+		Message:   strings.TrimRight(fields[9], "\n"),
+	}
+}
+
+// validRev keeps revisions given by clients from being read as options.
+func validRev(rev string) bool {
+	return rev != "" && !strings.HasPrefix(rev, "-")
+}
+
+// ResolveCommit turns a branch, tag or (abbreviated) SHA into the full id of its commit.
+func (s *Store) ResolveCommit(repoKey, rev string) (string, error) {
+	if !validRev(rev) {
+		return "", ErrObjectNotFound
+	}
+	sha, err := s.run(repoKey, nil, nil, "rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		return "", ErrObjectNotFound
+	}
+	return sha, nil
+}
+
+// ReadCommit reads a single commit.
+func (s *Store) ReadCommit(repoKey, sha string) (Commit, error) {
+	if !s.CommitExists(repoKey, sha) {
+		return Commit{}, ErrObjectNotFound
+	}
+	out, err := s.run(repoKey, nil, nil, "log", "-z", "--no-walk", logFormat, sha)
+	if err != nil {
+		return Commit{}, err
+	}
+	return parseCommit(strings.TrimSuffix(out, "\x00")), nil
+}
+
+// Log lists commits newest first.
+func (s *Store) Log(repoKey string, opts LogOptions) ([]Commit, error) {
+	if !validRev(opts.Head) || (opts.Exclude != "" && !validRev(opts.Exclude)) {
+		return nil, ErrObjectNotFound
+	}
+	args := []string{"log", "-z", logFormat}
+	if opts.Max > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.Max))
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since="+strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until="+strconv.FormatInt(opts.Until.Unix(), 10))
+	}
+	args = append(args, "--end-of-options", opts.Head)
+	if opts.Exclude != "" {
+		args = append(args, "^"+opts.Exclude)
+	}
+	if opts.Path != "" {
+		args = append(args, "--", opts.Path)
+	}
+	out, err := s.run(repoKey, nil, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x00") {
+		if record = strings.TrimLeft(record, "\n"); record != "" {
+			commits = append(commits, parseCommit(record))
+		}
+	}
+	return commits, nil
+}
+
+// CountCommits counts the commits reachable from head but not from base.
+func (s *Store) CountCommits(repoKey, base, head string) (int, error) {
+	out, err := s.run(repoKey, nil, nil, "rev-list", "--count", head, "^"+base)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
+// MergeBase is the best common ancestor of two commits, "" when they have none.
+func (s *Store) MergeBase(repoKey, a, b string) (string, error) {
+	out, err := s.run(repoKey, nil, nil, "merge-base", a, b)
+	if exitCode(err) == 1 {
+		return "", nil
+	}
+	return out, err
+}
+
+// Diff lists the files changed from one commit to another, from "" compares against an empty tree.
+func (s *Store) Diff(repoKey, from, to string) ([]FileStat, error) {
+	if from == "" {
+		from = emptyTree
+	}
+	// both runs list the files in the same order, raw has the status and blobs, numstat the line counts.
+	raw, err := s.run(repoKey, nil, nil, "diff-tree", "-r", "-z", "-M", "--raw", from, to)
+	if err != nil {
+		return nil, err
+	}
+	numstat, err := s.run(repoKey, nil, nil, "diff-tree", "-r", "-z", "-M", "--numstat", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileStat
+	rawFields := strings.Split(raw, "\x00")
+	for i := 0; i+1 < len(rawFields); i++ {
+		// :<old mode> <new mode> <old blob> <new blob> <status>, then the path or, for renames and copies, both paths.
+		header := strings.Fields(strings.TrimPrefix(rawFields[i], ":"))
+		if len(header) < 5 {
+			continue
+		}
+		file := FileStat{Status: header[4][:1], Blob: header[3], Path: rawFields[i+1]}
+		i++
+		if (file.Status == "R" || file.Status == "C") && i+1 < len(rawFields) {
+			file.OldPath, file.Path = file.Path, rawFields[i+1]
+			i++
+		}
+		if file.Status == "D" {
+			file.Blob = header[2]
+		}
+		files = append(files, file)
+	}
+
+	numFields := strings.Split(numstat, "\x00")
+	for i, file := 0, 0; i < len(numFields) && file < len(files); i, file = i+1, file+1 {
+		counts := strings.Split(numFields[i], "\t")
+		if len(counts) < 3 {
+			file--
+			continue
+		}
+		if counts[2] == "" {
+			// renamed: the old and the new path follow.
+			i += 2
+		}
+		if counts[0] == "-" {
+			files[file].Binary = true
+			continue
+		}
+		files[file].Additions, _ = strconv.Atoi(counts[0])
+		files[file].Deletions, _ = strconv.Atoi(counts[1])
+	}
+	return files, nil
+}
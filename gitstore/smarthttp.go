@@ -0,0 +1,159 @@
+package gitstore
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Services are the smart HTTP services, git-upload-pack serves clones and fetches, git-receive-pack pushes.
+var Services = []string{"git-upload-pack", "git-receive-pack"}
+
+// pktLine frames data the way the git protocol does, with its length as 4 hex digits in front.
+func pktLine(data string) string {
+	return fmt.Sprintf("%04x%s", len(data)+4, data)
+}
+
+// protocolEnv passes the Git-Protocol header of the client on, it switches upload-pack to protocol v2.
+func protocolEnv(protocol string) []string {
+	if protocol == "" {
+		return nil
+	}
+	return []string{"GIT_PROTOCOL=" + protocol}
+}
+
+// AdvertiseRefs writes the answer to GET info/refs?service=<service>.
+func (s *Store) AdvertiseRefs(repoKey, service, protocol string, out io.Writer) error {
+	path, err := s.Path(repoKey)
+	if err != nil {
+		return err
+	}
+	refs, err := s.runRaw(repoKey, protocolEnv(protocol), nil, strings.TrimPrefix(service, "git-"),
+		"--stateless-rpc", "--advertise-refs", path)
+	if err != nil {
+		return err
+	}
+	// protocol v2 starts with its capabilities, v0 clients expect the service announcement first.
+	if !strings.Contains(protocol, "version=2") {
+		_, err = io.WriteString(out, pktLine("# service="+service+"\n")+"0000")
+		if err != nil {
+			return err
+		}
+	}
+	_, err = out.Write(refs)
+	return err
+}
+
+// noForcePushEnv hands the refs a push may only fast forward to the pre-receive hook.
+const noForcePushEnv = "GB_NO_FORCE_PUSH"
+
+// preReceiveHook turns down non fast forward updates of the refs in GB_NO_FORCE_PUSH. It runs once the pack is
+// in, so the new commits can be looked at, and before any ref moves, so git reports the refs as rejected.
+const preReceiveHook = `#!/bin/sh
+status=0
+while read old new ref; do
+	case " $` + noForcePushEnv + ` " in
+	*" $ref "*) ;;
+	*) continue ;;
+	esac
+	case "$old$new" in
+	*0000000000000000000000000000000000000000*) continue ;;
+	esac
+	if ! git merge-base --is-ancestor "$old" "$new"; then
+		echo "error: GH006: Protected branch update failed for $ref." >&2
+		echo "error: Cannot force-push to this branch" >&2
+		status=1
+	fi
+done
+exit $status
+`
+
+// installHooks writes the hooks of the server into the bare repo, repos made before they existed get them too.
+func installHooks(path string) error {
+	hookPath := filepath.Join(path, "hooks", "pre-receive")
+	if current, err := os.ReadFile(hookPath); err == nil && string(current) == preReceiveHook {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(hookPath, []byte(preReceiveHook), 0o755)
+}
+
+// ServiceRPC answers POST <service> by running it on the request body and streaming its output. A
+// git-receive-pack rejects forced updates of the refs in noForcePush.
+func (s *Store) ServiceRPC(repoKey, service, protocol string, noForcePush []string, in io.Reader, out io.Writer) error {
+	path, err := s.Path(repoKey)
+	if err != nil {
+		return err
+	}
+	env := protocolEnv(protocol)
+	if service == "git-receive-pack" {
+		err = installHooks(path)
+		if err != nil {
+			return err
+		}
+		env = append(env, noForcePushEnv+"="+strings.Join(noForcePush, " "))
+	}
+	cmd, err := s.command(repoKey, env, strings.TrimPrefix(service, "git-"), "--stateless-rpc", path)
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stdin = in
+	cmd.Stdout = out
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", service, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// RefUpdate is a command of a push, an all zero SHA stands for a ref that is created or deleted.
+type RefUpdate struct {
+	OldSHA string
+	NewSHA string
+	Ref    string
+}
+
+// ReadPushCommands reads the ref updates a push sends to git-receive-pack in front of its pack. The bytes
+// it consumed are returned too, ServiceRPC still needs them ahead of the rest of the request.
+func ReadPushCommands(in io.Reader) ([]RefUpdate, []byte, error) {
+	var consumed bytes.Buffer
+	var updates []RefUpdate
+	for {
+		size := make([]byte, 4)
+		_, err := io.ReadFull(in, size)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading push commands: %w", err)
+		}
+		consumed.Write(size)
+		length, err := strconv.ParseUint(string(size), 16, 16)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading push commands: bad pkt-line length %q", size)
+		}
+		if length == 0 {
+			// flush, the pack follows.
+			return updates, consumed.Bytes(), nil
+		}
+		if length < 4 {
+			return nil, nil, fmt.Errorf("reading push commands: bad pkt-line length %q", size)
+		}
+		line := make([]byte, length-4)
+		_, err = io.ReadFull(in, line)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading push commands: %w", err)
+		}
+		consumed.Write(line)
+		// the first command carries the capabilities after a NUL, shallow lines and push certificates are skipped.
+		command, _, _ := strings.Cut(strings.TrimSuffix(string(line), "\n"), "\x00")
+		if fields := strings.Fields(command); len(fields) == 3 && IsSHA(fields[0]) && IsSHA(fields[1]) {
+			updates = append(updates, RefUpdate{OldSHA: fields[0], NewSHA: fields[1], Ref: fields[2]})
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package gitstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tag is an annotated tag object.
+type Tag struct {
+	SHA  string
+	Name string
+	// Object is what the tag points at, Type its kind (commit, tree, blob or tag).
+	Object  string
+	Type    string
+	Tagger  Signature
+	Message string
+}
+
+// ObjectType returns the kind of an object: commit, tree, blob or tag.
+func (s *Store) ObjectType(repoKey, sha string) (string, error) {
+	if !IsSHA(sha) {
+		return "", ErrObjectNotFound
+	}
+	out, err := s.run(repoKey, nil, nil, "cat-file", "-t", sha)
+	if err != nil {
+		return "", ErrObjectNotFound
+	}
+	return out, nil
+}
+
+// CreateTag writes an annotated tag object, no ref is created for it.
+func (s *Store) CreateTag(repoKey, name, object, objectType, message string, tagger Signature) (string, error) {
+	if tagger.When.IsZero() {
+		tagger.When = time.Now()
+	}
+	if !strings.HasSuffix(message, "\n") {
+		message += "\n"
+	}
+	tag := fmt.Sprintf("object %s\ntype %s\ntag %s\ntagger %s <%s> %d +0000\n\n%s",
+		object, objectType, name, tagger.Name, tagger.Email, tagger.When.Unix(), message)
+	return s.run(repoKey, nil, strings.NewReader(tag), "mktag")
+}
+
+// ReadTag reads an annotated tag object back.
+func (s *Store) ReadTag(repoKey, sha string) (Tag, error) {
+	if !IsSHA(sha) {
+		return Tag{}, ErrObjectNotFound
+	}
+	out, err := s.run(repoKey, nil, nil, "cat-file", "tag", sha)
+	if err != nil {
+		return Tag{}, ErrObjectNotFound
+	}
+	tag := Tag{SHA: sha}
+	header, message, _ := strings.Cut(out, "\n\n")
+	tag.Message = message
+	for _, line := range strings.Split(header, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			tag.Object = value
+		case "type":
+			tag.Type = value
+		case "tag":
+			tag.Name = value
+		case "tagger":
+			tag.Tagger = parseTagger(value)
+		}
+	}
+	return tag, nil
+}
+
+// parseTagger reads "Name <email> unix-time zone".
+func parseTagger(value string) Signature {
+	name, rest, _ := strings.Cut(value, " <")
+	email, rest, _ := strings.Cut(rest, "> ")
+	sig := Signature{Name: name, Email: email}
+	when, _, _ := strings.Cut(rest, " ")
+	if unix, err := strconv.ParseInt(when, 10, 64); err == nil {
+		sig.When = time.Unix(unix, 0).UTC()
+	}
+	return sig
+}
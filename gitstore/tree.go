@@ -0,0 +1,89 @@
+package gitstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TreeEntry is a file, directory, symlink or submodule of a tree.
+type TreeEntry struct {
+	Path string
+	Mode string
+	// Type is blob, tree or commit (a submodule).
+	Type string
+	SHA  string
+	// Size is only known for blobs.
+	Size int64
+}
+
+func parseTreeEntries(out string) []TreeEntry {
+	var entries []TreeEntry
+	for _, line := range strings.Split(out, "\x00") {
+		// <mode> SP <type> SP <object> SP <size> TAB <path>
+		meta, path, found := strings.Cut(line, "\t")
+		fields := strings.Fields(meta)
+		if !found || len(fields) < 4 {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		entries = append(entries, TreeEntry{Path: path, Mode: fields[0], Type: fields[1], SHA: fields[2], Size: size})
+	}
+	return entries
+}
+
+// Entry looks up path ("" for the root tree) in the tree of commit.
+func (s *Store) Entry(repoKey, commit, path string) (TreeEntry, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		tree, err := s.TreeOf(repoKey, commit)
+		return TreeEntry{Type: "tree", Mode: "040000", SHA: tree}, err
+	}
+	if !IsSHA(commit) {
+		return TreeEntry{}, ErrObjectNotFound
+	}
+	out, err := s.run(repoKey, nil, nil, "ls-tree", "-l", "-z", "--full-tree", commit, "--", path)
+	if err != nil {
+		return TreeEntry{}, err
+	}
+	for _, entry := range parseTreeEntries(out) {
+		if entry.Path == path {
+			return entry, nil
+		}
+	}
+	return TreeEntry{}, ErrObjectNotFound
+}
+
+// ListTree lists the entries of the directory path ("" for the root) in the tree of commit.
+func (s *Store) ListTree(repoKey, commit, path string) ([]TreeEntry, error) {
+	entry, err := s.Entry(repoKey, commit, path)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Type != "tree" {
+		return nil, ErrObjectNotFound
+	}
+	out, err := s.run(repoKey, nil, nil, "ls-tree", "-l", "-z", entry.SHA)
+	if err != nil {
+		return nil, err
+	}
+	entries := parseTreeEntries(out)
+	prefix := strings.Trim(path, "/")
+	for i := range entries {
+		if prefix != "" {
+			entries[i].Path = prefix + "/" + entries[i].Path
+		}
+	}
+	return entries, nil
+}
+
+// ReadBlob returns the content of a blob.
+func (s *Store) ReadBlob(repoKey, sha string) ([]byte, error) {
+	if !IsSHA(sha) {
+		return nil, ErrObjectNotFound
+	}
+	out, err := s.runRaw(repoKey, nil, nil, "cat-file", "blob", sha)
+	if err != nil {
+		return nil, ErrObjectNotFound
+	}
+	return out, nil
+}
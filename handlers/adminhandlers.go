@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// StoreStats counts the orgs, users, repos, branches and pull requests of the store, for the metrics.
+func (g *GitRepo) StoreStats() service.StoreStats {
+	return g.gbService.StoreStats()
+}
+
+// post /_admin/reset
+func (g *GitRepo) ResetHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing reset Request..")
+	err := g.serviceFor(r).Reset()
+	if err != nil {
+		g.log(r).Error("Error occurred while resetting the store.", "error", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	g.log(r).Info("Store got reset.")
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// post /_admin/snapshot
+func (g *GitRepo) SnapshotHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing snapshot Request..")
+	snapResp, err := g.serviceFor(r).Snapshot()
+	if err != nil {
+		g.log(r).Error("Error occurred while taking the snapshot.", "error", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	g.log(r).Info("Snapshot got created.", "snapshot_id", snapResp.ID)
+	rw.Header().Set("Content-Type", "Application/json")
+	rw.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(rw).Encode(snapResp)
+	if err != nil {
+		g.log(r).Error("Error occurred while encoding the response data", "error", err)
+		http.Error(rw, "Error occurred while encoding the response data", http.StatusInternalServerError)
+		return
+	}
+}
+
+// get /_admin/snapshot
+func (g *GitRepo) ListSnapshotsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list snapshots Request..")
+	snapshotList, err := g.serviceFor(r).ListSnapshots()
+	if err != nil {
+		g.log(r).Error("Error occurred while fetching the snapshot list.", "error", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "Application/json")
+	err = json.NewEncoder(rw).Encode(snapshotList)
+	if err != nil {
+		g.log(r).Error("Error occurred while encoding the response data", "error", err)
+		http.Error(rw, "Error occurred while encoding the response data", http.StatusInternalServerError)
+		return
+	}
+}
+
+// post /_admin/restore/{id}
+func (g *GitRepo) RestoreHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing restore Request..")
+	snapshotID := mux.Vars(r)["id"]
+	err := g.serviceFor(r).Restore(snapshotID)
+	if err != nil {
+		g.log(r).Error("Error occurred while restoring the snapshot.", "snapshot_id", snapshotID, "error", err)
+		if err == service.ErrSnapshotNotFound {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	g.log(r).Info("Snapshot got restored.", "snapshot_id", snapshotID)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// delete /_admin/snapshot/{id}
+func (g *GitRepo) DeleteSnapshotHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete snapshot Request..")
+	snapshotID := mux.Vars(r)["id"]
+	err := g.serviceFor(r).DeleteSnapshot(snapshotID)
+	if err != nil {
+		g.log(r).Error("Error occurred while deleting the snapshot.", "snapshot_id", snapshotID, "error", err)
+		if err == service.ErrSnapshotNotFound {
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -3,56 +3,84 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"gbserver/gitstore"
+	"gbserver/logging"
 	"gbserver/models"
 	"gbserver/service"
-	"log"
+	"log/slog"
 	"net/http"
+	"slices"
 
 	"github.com/gorilla/mux"
 )
 
 type GitRepo struct {
-	l         *log.Logger
-	gbService service.GbService
+	l         *slog.Logger
+	gbService *service.GbService
 }
 
-func NewGitRepo(l *log.Logger) *GitRepo {
-	return &GitRepo{l, service.GbService{GbStoreInstance: models.NewGbStore()}}
+func NewGitRepo(l *slog.Logger) *GitRepo {
+	return &GitRepo{l, service.NewGbService(models.NewGbStore(), nil, nil)}
+}
+
+// NewGitRepoWithStorage loads the store from storage and writes every change back to it.
+// seed is what the admin reset goes back to.
+func NewGitRepoWithStorage(l *slog.Logger, storage models.Storage, seed models.Seed) (*GitRepo, error) {
+	gbStore, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	return &GitRepo{l, service.NewGbService(gbStore, storage, seed)}, nil
+}
+
+// log is the logger of the request, its lines carry the request id.
+func (g *GitRepo) log(r *http.Request) *slog.Logger {
+	return logging.For(r.Context(), g.l)
+}
+
+// Close stops the background work of the service, the webhook deliveries.
+func (g *GitRepo) Close() {
+	g.gbService.Close()
+}
+
+// UseGit backs the repositories with bare git repos kept in gitStore.
+func (g *GitRepo) UseGit(gitStore *gitstore.Store) error {
+	return g.gbService.UseGit(gitStore)
 }
 
 func (g *GitRepo) ListRepoHandler(rw http.ResponseWriter, r *http.Request) {
 
-	g.l.Println("Processing Get request..List Repo handler")
+	g.log(r).Debug("Processing Get request..List Repo handler")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
 	//	g.l.Println("Organization & owner name..", orgName, ownerName)
 
-	repoList, err := g.gbService.ListRepos(orgName, ownerName)
+	repoList, err := g.serviceFor(r).ListRepos(orgName, ownerName)
 	if err != nil {
 		if err == service.ErrOwnerNotFound || err == service.ErrOrgNotFound {
-			g.l.Println("Error occurred while fetching the repo list.", err)
+			g.log(r).Error("Error occurred while fetching the repo list.", "error", err)
 			http.Error(rw, err.Error(), http.StatusNotFound)
 			return
 		}
-		g.l.Println("Error occurred while fetching the repo list.", err)
+		g.log(r).Error("Error occurred while fetching the repo list.", "error", err)
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	//g.l.Println("Retrieved Repo list.", repoList)
-	g.l.Println("Retrieved Repo list.")
+	g.log(r).Debug("Retrieved Repo list.")
 	rw.Header().Set("Content-Type", "Application/json")
 
-	err = json.NewEncoder(rw).Encode(repoList)
+	err = json.NewEncoder(rw).Encode(paginate(rw, r, repoList))
 	if err != nil {
-		g.l.Println("Error occured while decoding the Git repo list", err)
+		g.log(r).Error("Error occured while decoding the Git repo list", "error", err)
 		http.Error(rw, "Error occured while decoding the output", http.StatusInternalServerError)
 		return
 	}
 }
 
 func (g *GitRepo) CreateRepoHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing POST request..Create Repo handler")
+	g.log(r).Debug("Processing POST request..Create Repo handler")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
@@ -61,89 +89,102 @@ func (g *GitRepo) CreateRepoHandler(rw http.ResponseWriter, r *http.Request) {
 	//g.l.Println("receieved models..", r.Body)
 	err := json.NewDecoder(r.Body).Decode(&createRepoReq)
 	if err != nil {
-		g.l.Println("Error occurred while decoding the request data", err)
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	repoStatus, err := g.gbService.CreateRepo(orgName, ownerName, &createRepoReq)
+	repoStatus, err := g.serviceFor(r).CreateRepo(orgName, ownerName, &createRepoReq)
 	if err != nil {
+		if err == service.ErrStoreNotSaved {
+			g.apiError(rw, r, "Error occurred.", err)
+			return
+		}
 		if err == service.ErrOrgNotFound || err == service.ErrOwnerNotFound {
-			g.l.Println("Error occurred.", err)
+			g.log(r).Error("Error occurred.", "error", err)
 			http.Error(rw, err.Error(), http.StatusNotFound)
 			return
 		}
-		g.l.Println("Error occurred.", err)
+		if err == service.ErrInvalidRepoName {
+			g.log(r).Warn("Error occurred.", "error", err)
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		g.log(r).Error("Error occurred.", "error", err)
 		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
-	g.l.Println("Repository got created.")
+	g.log(r).Info("Repository got created.")
 	rw.Header().Set("Content-Type", "Application/json")
 	rw.WriteHeader(http.StatusCreated)
 	err = json.NewEncoder(rw).Encode(repoStatus)
 	if err != nil {
-		g.l.Println("Error occured while decoding the Git repo list", err)
+		g.log(r).Error("Error occured while decoding the Git repo list", "error", err)
 		http.Error(rw, "Error occured while decoding the output", http.StatusInternalServerError)
 		return
 	}
 }
 
 func (g *GitRepo) DeleteRepoHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing Delete Request..")
+	g.log(r).Debug("Processing Delete Request..")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
 	repoName := vars["repo"]
 	//	g.l.Println("Organization & Repo name..", orgName, ownerName, repoName)
 
-	status, err := g.gbService.DeleteRepo(orgName, ownerName, repoName)
+	status, err := g.serviceFor(r).DeleteRepo(orgName, ownerName, repoName)
+	if err == service.ErrStoreNotSaved {
+		g.apiError(rw, r, "Error occurred while deleting the repo.", err)
+		return
+	}
 	if err != nil {
-		g.l.Println("Error occurred while deleting the repo.", err)
+		g.log(r).Error("Error occurred while deleting the repo.", "error", err)
 		http.Error(rw, err.Error(), http.StatusNotFound)
 		return
 	}
 	if status {
 		rw.WriteHeader(http.StatusNoContent)
 		fmt.Fprintln(rw, "Repository got deleted")
-		g.l.Println("Repository got deleted.")
+		g.log(r).Info("Repository got deleted.")
 	}
 }
 
 func (g *GitRepo) ListBranchesHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing Get branch Request..")
+	g.log(r).Debug("Processing Get branch Request..")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
 	repoName := vars["repo"]
 	//	g.l.Println("Owner & Repo name..", ownerName, repoName)
 
-	branchList, err := g.gbService.ListBranches(orgName, ownerName, repoName)
+	branchList, err := g.serviceFor(r).ListBranches(orgName, ownerName, repoName)
 	if err != nil {
 		switch err {
 		case service.ErrOwnerNotFound, service.ErrRepoNotFound, service.ErrBranchesNotFound, service.ErrOrgNotFound:
-			g.l.Println("Error occurred while fetching the branch list.", err)
+			g.log(r).Error("Error occurred while fetching the branch list.", "error", err)
 			http.Error(rw, err.Error(), http.StatusNotFound)
 			return
 		default:
-			g.l.Println("Error occurred while fetching the branch list.", err)
+			g.log(r).Error("Error occurred while fetching the branch list.", "error", err)
 			http.Error(rw, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 	//g.l.Println("Retrieved Branch list..", branchList)
-	g.l.Println("Retrieved Branch list.")
+	g.log(r).Debug("Retrieved Branch list.")
 	rw.Header().Set("Content-Type", "Application/json")
-	err = json.NewEncoder(rw).Encode(branchList)
+	err = json.NewEncoder(rw).Encode(paginate(rw, r, branchList))
 	if err != nil {
-		g.l.Println("Error occured while decoding the Git branch list.", err)
+		g.log(r).Error("Error occured while decoding the Git branch list.", "error", err)
 		http.Error(rw, "Error occured while decoding the output.", http.StatusInternalServerError)
 		return
 	}
 }
 
 func (g *GitRepo) CreateBranchHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing Create branch Request..")
+	g.log(r).Debug("Processing Create branch Request..")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
@@ -152,36 +193,45 @@ func (g *GitRepo) CreateBranchHandler(rw http.ResponseWriter, r *http.Request) {
 	var cbreq service.CreateBranchRequest
 	err := json.NewDecoder(r.Body).Decode(&cbreq)
 	if err != nil {
-		g.l.Println("Error occurred while decoding the request data", err)
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	cbResp, err := g.gbService.CreateBranch(orgName, ownerName, repoName, &cbreq)
+	cbResp, err := g.serviceFor(r).CreateBranch(orgName, ownerName, repoName, &cbreq)
 	if err != nil {
+		if err == service.ErrStoreNotSaved {
+			g.apiError(rw, r, "Error occurred while creating the branch.", err)
+			return
+		}
 		if err == service.ErrBranchesAlreadyExists || err == service.ErrInvalidBranchName {
-			g.l.Println("Error occurred while decoding the request data", err)
+			g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 			http.Error(rw, err.Error(), http.StatusBadRequest)
 			return
 		}
-		g.l.Println("Error occurred while decoding the request data", err)
+		if err == service.ErrCommitNotFound || err == service.ErrTagAlreadyExists || err == service.ErrInvalidTagName {
+			g.log(r).Error("Error occurred while creating the branch.", "error", err)
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 		http.Error(rw, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	g.l.Println("Received reponse for create branch", cbResp)
+	g.log(r).Debug("Received reponse for create branch", "ref", cbResp.Ref, "sha", cbResp.Object.SHA)
 	rw.Header().Set("Content-Type", "Application/json")
 	err = json.NewEncoder(rw).Encode(cbResp)
 	if err != nil {
-		g.l.Println("Error occurred while decoding the request data", err)
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
 		return
 	}
 }
 
 func (g *GitRepo) DeleteBranchHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing Delete branch Request..")
+	g.log(r).Debug("Processing Delete branch Request..")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
@@ -189,19 +239,25 @@ func (g *GitRepo) DeleteBranchHandler(rw http.ResponseWriter, r *http.Request) {
 	refName := vars["ref"]
 	//	g.l.Println("Organization, Repo & branch name..", orgName, ownerName, repoName, refName)
 
-	resp, err := g.gbService.DeleteBranch(orgName, ownerName, repoName, refName)
+	resp, err := g.serviceFor(r).DeleteBranch(orgName, ownerName, repoName, refName)
 	if err != nil {
-		g.l.Println("Error occurred while decoding the request data", err)
-		http.Error(rw, err.Error(), http.StatusNotFound)
-		return
+		switch err {
+		case service.ErrProtectedBranchDeletion, service.ErrBranchPushRestricted, service.ErrStoreNotSaved:
+			g.apiError(rw, r, "Error occurred while deleting the branch.", err)
+			return
+		default:
+			g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		}
 	}
 
 	if resp {
 		rw.WriteHeader(http.StatusNoContent)
 		fmt.Fprintln(rw, "Repo got deleted")
-		g.l.Println("Repo got deleted")
+		g.log(r).Info("Repo got deleted")
 	} else {
-		g.l.Println("Error occurred while deleting the branch.", repoName, refName)
+		g.log(r).Error("Error occurred while deleting the branch.", "repo", repoName, "ref", refName)
 		http.Error(rw, "Error occurred while deleting the repo.", http.StatusBadRequest)
 		return
 	}
@@ -209,33 +265,33 @@ func (g *GitRepo) DeleteBranchHandler(rw http.ResponseWriter, r *http.Request) {
 
 // get /repos/{org}/{owner}/{Repo}/pulls
 func (g *GitRepo) ListPRHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing list PR Request..")
+	g.log(r).Debug("Processing list PR Request..")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
 	repoName := vars["repo"]
 	//	g.l.Println("Organization, Repo name..", ownerName, repoName)
 
-	listPRs, err := g.gbService.ListPRs(orgName, ownerName, repoName)
+	listPRs, err := g.serviceFor(r).ListPRs(orgName, ownerName, repoName)
 
 	if err != nil {
-		g.l.Println("Error occurred while fetching PRs list.", err)
+		g.log(r).Error("Error occurred while fetching PRs list.", "error", err)
 		http.Error(rw, err.Error(), http.StatusNotFound)
 		return
 	}
 	//g.l.Println("Retrieved PRs list..", listPRs)
-	g.l.Println("Retrieved PRs list.")
+	g.log(r).Debug("Retrieved PRs list.")
 	rw.Header().Set("Content-Type", "Application/json")
-	err = json.NewEncoder(rw).Encode(listPRs)
+	err = json.NewEncoder(rw).Encode(paginate(rw, r, listPRs))
 	if err != nil {
-		g.l.Println("Error occured while decoding the Git branch list.", err)
+		g.log(r).Error("Error occured while decoding the Git branch list.", "error", err)
 		http.Error(rw, "Error occured while decoding the output.", http.StatusInternalServerError)
 		return
 	}
 }
 
 func (g *GitRepo) CreatePRHandler(rw http.ResponseWriter, r *http.Request) {
-	g.l.Println("Processing Create PR Request..")
+	g.log(r).Debug("Processing Create PR Request..")
 	vars := mux.Vars(r)
 	orgName := vars["org"]
 	ownerName := vars["owner"]
@@ -244,32 +300,201 @@ func (g *GitRepo) CreatePRHandler(rw http.ResponseWriter, r *http.Request) {
 	var prReq service.PRRequest
 	err := json.NewDecoder(r.Body).Decode(&prReq)
 	if err != nil {
-		g.l.Println("Error occurred while decoding the request data", err)
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
 		return
 	}
 	defer r.Body.Close()
 
-	prResp, err := g.gbService.CreatePR(orgName, ownerName, repoName, &prReq)
+	prResp, err := g.serviceFor(r).CreatePR(orgName, ownerName, repoName, &prReq)
 	if err != nil {
 		switch err {
 		case service.ErrOwnerNotFound, service.ErrRepoNotFound, service.ErrBranchesNotFound, service.ErrOrgNotFound:
-			g.l.Println("Error occurred while fetching the branch list.", err)
+			g.log(r).Error("Error occurred while fetching the branch list.", "error", err)
 			http.Error(rw, err.Error(), http.StatusNotFound)
 			return
+		case service.ErrStoreNotSaved:
+			g.apiError(rw, r, "Error occurred while creating the PR.", err)
+			return
+		case service.ErrPRSameHeadBase:
+			g.log(r).Error("Error occurred while creating the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
 		default:
-			g.l.Println("Error occurred while fetching the branch list.", err)
+			g.log(r).Error("Error occurred while fetching the branch list.", "error", err)
 			http.Error(rw, err.Error(), http.StatusBadRequest)
 			return
 		}
 	}
 
-	g.l.Println("Received reponse for create PR.", prResp)
+	g.log(r).Debug("Received reponse for create PR.", "number", prResp.Number, "state", prResp.State)
 	rw.Header().Set("Content-Type", "Application/json")
 	err = json.NewEncoder(rw).Encode(prResp)
 	if err != nil {
-		g.l.Println("Error occurred while decoding the request data", err)
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}
+func (g *GitRepo) GetPRHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get PR Request..")
+	vars := mux.Vars(r)
+	prResp, err := g.serviceFor(r).GetPR(vars["org"], vars["owner"], vars["repo"], vars["pull_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the PR.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, prResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/pulls/{pull_number}
+func (g *GitRepo) UpdatePRHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing Update PR Request..")
+	vars := mux.Vars(r)
+	orgName := vars["org"]
+	ownerName := vars["owner"]
+	repoName := vars["repo"]
+	pullNumber := vars["pull_number"]
+	var prReq service.PRRequest
+	err := json.NewDecoder(r.Body).Decode(&prReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
 		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
 		return
 	}
+	defer r.Body.Close()
+
+	prResp, err := g.serviceFor(r).UpdatePR(orgName, ownerName, repoName, pullNumber, &prReq)
+	if err != nil {
+		switch err {
+		case service.ErrOwnerNotFound, service.ErrRepoNotFound, service.ErrOrgNotFound, service.ErrPRNotFound:
+			g.log(r).Error("Error occurred while updating the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		case service.ErrStoreNotSaved:
+			g.apiError(rw, r, "Error occurred while updating the PR.", err)
+			return
+		default:
+			g.log(r).Error("Error occurred while updating the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	g.log(r).Debug("Received reponse for update PR.", "number", prResp.Number, "state", prResp.State)
+	rw.Header().Set("Content-Type", "Application/json")
+	err = json.NewEncoder(rw).Encode(prResp)
+	if err != nil {
+		g.log(r).Error("Error occurred while encoding the response data", "error", err)
+		http.Error(rw, "Error occurred while encoding the response data", http.StatusInternalServerError)
+		return
+	}
+}
+
+// put /repos/{org}/{owner}/{repo}/pulls/{pull_number}/merge
+func (g *GitRepo) MergePRHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing Merge PR Request..")
+	vars := mux.Vars(r)
+	orgName := vars["org"]
+	ownerName := vars["owner"]
+	repoName := vars["repo"]
+	pullNumber := vars["pull_number"]
+	var mergeReq service.MergePRRequest
+	// github accepts an empty body, every field is optional.
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&mergeReq)
+		if err != nil {
+			g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+			http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	mergeResp, err := g.serviceFor(r).MergePR(orgName, ownerName, repoName, pullNumber, &mergeReq)
+	if err != nil {
+		switch err {
+		case service.ErrOwnerNotFound, service.ErrRepoNotFound, service.ErrOrgNotFound, service.ErrPRNotFound:
+			g.log(r).Error("Error occurred while merging the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusNotFound)
+			return
+		case service.ErrPRAlreadyMerged, service.ErrPRAlreadyClosed, service.ErrPRNotMergeable:
+			g.log(r).Error("Error occurred while merging the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusMethodNotAllowed)
+			return
+		case service.ErrPRHeadModified:
+			g.log(r).Error("Error occurred while merging the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusConflict)
+			return
+		case service.ErrBranchPushRestricted:
+			g.log(r).Error("Error occurred while merging the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusForbidden)
+			return
+		case service.ErrStoreNotSaved:
+			g.apiError(rw, r, "Error occurred while merging the PR.", err)
+			return
+		default:
+			g.log(r).Error("Error occurred while merging the PR.", "error", err)
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	g.log(r).Debug("Received reponse for merge PR.", "sha", mergeResp.SHA)
+	rw.Header().Set("Content-Type", "Application/json")
+	err = json.NewEncoder(rw).Encode(mergeResp)
+	if err != nil {
+		g.log(r).Error("Error occurred while encoding the response data", "error", err)
+		http.Error(rw, "Error occurred while encoding the response data", http.StatusInternalServerError)
+		return
+	}
+}
+
+// notFoundErrors are answered with 404 by apiError, conflictErrors with 409, forbiddenErrors with 403, every other
+// service error with 422.
+var notFoundErrors = []error{service.ErrOwnerNotFound, service.ErrRepoNotFound, service.ErrOrgNotFound,
+	service.ErrHookNotFound, service.ErrHookDeliveryNotFound, service.ErrIssueNotFound, service.ErrIssueCommentNotFound,
+	service.ErrLabelNotFound, service.ErrCommitNotFound, service.ErrGitDisabled, service.ErrContentNotFound,
+	service.ErrPRNotFound, service.ErrReviewNotFound, service.ErrReviewCommentNotFound, service.ErrCheckRunNotFound,
+	service.ErrCheckSuiteNotFound, service.ErrBranchesNotFound, service.ErrBranchNotProtected, service.ErrRefNotFound,
+	service.ErrTagNotFound, service.ErrReleaseNotFound, service.ErrReleaseAssetNotFound, service.ErrUserNotFound,
+	service.ErrMembershipNotFound}
+var conflictErrors = []error{service.ErrRepoEmpty, service.ErrContentSHAMismatch}
+
+// forbiddenErrors are answered with 403, they come from restricted branches and admin only calls.
+var forbiddenErrors = []error{service.ErrBranchPushRestricted, service.ErrOrgAdminRequired, service.ErrRepoAdminRequired}
+
+// apiError answers with the 4xx status of err, so it is logged as a warning. A change the store could not
+// save is the server's fault, it gets 500.
+func (g *GitRepo) apiError(rw http.ResponseWriter, r *http.Request, msg string, err error) {
+	if err == service.ErrStoreNotSaved {
+		g.log(r).Error(msg, "error", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	g.log(r).Warn(msg, "error", err)
+	if slices.Contains(notFoundErrors, err) {
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	if slices.Contains(conflictErrors, err) {
+		http.Error(rw, err.Error(), http.StatusConflict)
+		return
+	}
+	if slices.Contains(forbiddenErrors, err) {
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+}
+
+func (g *GitRepo) writeJSON(rw http.ResponseWriter, r *http.Request, status int, data any) {
+	rw.Header().Set("Content-Type", "Application/json")
+	rw.WriteHeader(status)
+	err := json.NewEncoder(rw).Encode(data)
+	if err != nil {
+		g.log(r).Error("Error occurred while encoding the response data", "error", err)
+	}
 }
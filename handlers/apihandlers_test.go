@@ -1,19 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"gbserver/models"
 	"gbserver/service"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
-var l = log.New(os.Stdout, "gbTestServer ", log.LstdFlags)
+var l = slog.New(slog.NewTextHandler(os.Stdout, nil))
 var gitTestRepo = NewGitRepo(l)
 
 func TestListRepoHandler(t *testing.T) {
@@ -86,3 +89,28 @@ func generateRequest(_ *testing.T, testType string) *http.Request {
 	return httpReq
 
 }
+
+// failingStorage loads the default fixture and fails every save.
+type failingStorage struct{}
+
+func (failingStorage) Load() (*models.GbStore, error) { return models.NewGbStore(), nil }
+func (failingStorage) Save(_ *models.GbStore) error   { return errors.New("disk full") }
+
+func TestStoreNotSaved(t *testing.T) {
+	failingRepo, err := NewGitRepoWithStorage(l, failingStorage{}, nil)
+	assert.NoError(t, err)
+	defer failingRepo.Close()
+	router := mux.NewRouter()
+	router.Path("/repos/{org}/{owner}/{repo}/git/refs").Methods(http.MethodPost).HandlerFunc(failingRepo.CreateBranchHandler)
+	router.Path("/repos/{org}/{owner}/{repo}/issues").Methods(http.MethodPost).HandlerFunc(failingRepo.CreateIssueHandler)
+
+	for path, body := range map[string]string{
+		"/repos/gborg/gbuser/gbrepo/git/refs": `{"ref": "refs/heads/unsaved", "sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}`,
+		"/repos/gborg/gbuser/gbrepo/issues":   `{"title": "Unsaved"}`,
+	} {
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		assert.Equal(t, http.StatusInternalServerError, resp.Code, path)
+		assert.Contains(t, resp.Body.String(), service.ErrStoreNotSaved.Error(), path)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"gbserver/logging"
+	"gbserver/service"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type contextKey string
+
+const authUserKey = contextKey("authUser")
+
+// ErrorResponse is the json error body github sends.
+type ErrorResponse struct {
+	Message          string `json:"message"`
+	DocumentationURL string `json:"documentation_url"`
+}
+
+func writeJSONError(rw http.ResponseWriter, statusCode int, message string) {
+	rw.Header().Set("Content-Type", "Application/json")
+	rw.WriteHeader(statusCode)
+	json.NewEncoder(rw).Encode(ErrorResponse{Message: message, DocumentationURL: "https://docs.github.com/rest"})
+}
+
+// tokenFromRequest reads "Authorization: token <pat>", "Authorization: Bearer <pat>" or, for git
+// clients, basic auth with the token as password.
+func tokenFromRequest(r *http.Request) string {
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found {
+		return ""
+	}
+	if !strings.EqualFold(scheme, "token") && !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// AuthUserFromContext returns the caller stored by AuthMiddleware.
+func AuthUserFromContext(ctx context.Context) (service.AuthUser, bool) {
+	authUser, ok := ctx.Value(authUserKey).(service.AuthUser)
+	return authUser, ok
+}
+
+// AuthMiddleware rejects requests without a valid token and, on routes with an {org},
+// callers that are not a member of it. Reads answer 404 so org content is not leaked, writes 403.
+func (g *GitRepo) AuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			g.log(r).Info("Rejected request without credentials.", "method", r.Method, "path", r.URL.Path)
+			// git only sends credentials after being asked for them.
+			rw.Header().Set("WWW-Authenticate", `Basic realm="GbServer"`)
+			writeJSONError(rw, http.StatusUnauthorized, "Requires authentication")
+			return
+		}
+		authUser, err := g.gbService.Authenticate(tokenFromRequest(r))
+		if err != nil {
+			g.log(r).Info("Rejected request with invalid credentials.", "method", r.Method, "path", r.URL.Path)
+			writeJSONError(rw, http.StatusUnauthorized, "Bad credentials")
+			return
+		}
+
+		orgName, hasOrg := mux.Vars(r)["org"]
+		if hasOrg && !authUser.SiteAdmin && !g.gbService.IsOrgMember(orgName, authUser.Login) {
+			g.log(r).Info("Rejected request of non member.", "user", authUser.Login, "org", orgName)
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				writeJSONError(rw, http.StatusNotFound, "Not Found")
+				return
+			}
+			writeJSONError(rw, http.StatusForbidden, "Must be a member of the organization")
+			return
+		}
+		logging.SetUser(r.Context(), authUser.Login)
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), authUserKey, authUser)))
+	})
+}
+
+// SiteAdminMiddleware only lets site admins through, it expects AuthMiddleware to run first.
+func (g *GitRepo) SiteAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		authUser, ok := AuthUserFromContext(r.Context())
+		if !ok || !authUser.SiteAdmin {
+			writeJSONError(rw, http.StatusForbidden, "Must be a site administrator")
+			return
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// TokenOf is the token of r, false when the request has none or the token belongs to nobody.
+func (g *GitRepo) TokenOf(r *http.Request) (string, bool) {
+	token := tokenFromRequest(r)
+	_, err := g.gbService.Authenticate(token)
+	return token, err == nil
+}
+
+// serviceFor acts as the authenticated caller when there is one, and logs with the id of the request.
+func (g *GitRepo) serviceFor(r *http.Request) *service.GbService {
+	requestService := g.gbService.WithLogger(g.log(r))
+	if authUser, ok := AuthUserFromContext(r.Context()); ok {
+		return requestService.WithActor(authUser.Login)
+	}
+	return requestService
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	authRepo := NewGitRepo(l)
+	router := mux.NewRouter()
+	router.Use(authRepo.AuthMiddleware)
+	router.Path("/orgs/{org}/{owner}/repos").Methods(http.MethodGet).HandlerFunc(authRepo.ListRepoHandler)
+	router.Path("/repos/{org}/{owner}/{repo}/pulls").Methods(http.MethodPost).HandlerFunc(authRepo.CreatePRHandler)
+	adminRouter := router.PathPrefix("/_admin").Subrouter()
+	adminRouter.Use(authRepo.SiteAdminMiddleware)
+	adminRouter.Path("/snapshot").Methods(http.MethodPost).HandlerFunc(authRepo.SnapshotHandler)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		authHeader string
+		statusCode int
+		wantBody   string
+	}{
+		{name: "Test missing token", method: http.MethodGet, url: "/orgs/gborg/gbuser/repos", statusCode: 401, wantBody: "Requires authentication"},
+		{name: "Test invalid token", method: http.MethodGet, url: "/orgs/gborg/gbuser/repos", authHeader: "token nope", statusCode: 401, wantBody: "Bad credentials"},
+		{name: "Test unknown scheme", method: http.MethodGet, url: "/orgs/gborg/gbuser/repos", authHeader: "Basic gbuser-token", statusCode: 401, wantBody: "Bad credentials"},
+		{name: "Test token scheme", method: http.MethodGet, url: "/orgs/gborg/gbuser/repos", authHeader: "token gbuser-token", statusCode: 200},
+		{name: "Test basic scheme", method: http.MethodGet, url: "/orgs/gborg/gbuser/repos", authHeader: "Basic Z2J1c2VyOmdidXNlci10b2tlbg==", statusCode: 200},
+		{name: "Test bearer scheme", method: http.MethodGet, url: "/orgs/gborg/gbuser/repos", authHeader: "Bearer gbuser-token", statusCode: 200},
+		{name: "Test read in other org", method: http.MethodGet, url: "/orgs/otherorg/gbuser/repos", authHeader: "token gbuser-token", statusCode: 404, wantBody: "Not Found"},
+		{name: "Test write in other org", method: http.MethodPost, url: "/repos/otherorg/gbuser/gbrepo/pulls", authHeader: "token gbuser-token", statusCode: 403},
+		{name: "Test admin route as user", method: http.MethodPost, url: "/_admin/snapshot", authHeader: "token gbuser-token", statusCode: 403},
+		{name: "Test admin route as site admin", method: http.MethodPost, url: "/_admin/snapshot", authHeader: "token gbadmin-token", statusCode: 201},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.url, strings.NewReader("{}"))
+		if tt.authHeader != "" {
+			req.Header.Set("Authorization", tt.authHeader)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.statusCode, resp.Code, tt.name)
+		assert.Contains(t, resp.Body.String(), tt.wantBody, tt.name)
+	}
+}
+
+func TestTokenOf(t *testing.T) {
+	authRepo := NewGitRepo(l)
+	token := func(authHeader string) (string, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/rate_limit", nil)
+		if authHeader != "" {
+			req.Header.Set("Authorization", authHeader)
+		}
+		return authRepo.TokenOf(req)
+	}
+	_, ok := token("")
+	assert.False(t, ok)
+	_, ok = token("token nope")
+	assert.False(t, ok)
+	gotToken, ok := token("Bearer gbadmin-token")
+	assert.True(t, ok)
+	assert.Equal(t, "gbadmin-token", gotToken)
+}
+
+func TestGraphQLHandler(t *testing.T) {
+	authRepo := NewGitRepo(l)
+	router := mux.NewRouter()
+	router.Use(authRepo.AuthMiddleware)
+	router.Path("/graphql").Methods(http.MethodPost).HandlerFunc(authRepo.GraphQLHandler)
+
+	tests := []struct {
+		name       string
+		body       string
+		authHeader string
+		statusCode int
+		wantBody   string
+	}{
+		{name: "Test missing token", body: `{"query":"{ viewer { login } }"}`, statusCode: 401, wantBody: "Requires authentication"},
+		{name: "Test viewer", body: `{"query":"{ viewer { login } }"}`, authHeader: "token gbuser-token", statusCode: 200, wantBody: `{"data":{"viewer":{"login":"gbuser"}}}`},
+		{name: "Test field error", body: `{"query":"{ repository(owner: \"gbuser\", name: \"nope\") { id } }"}`, authHeader: "token gbuser-token", statusCode: 200, wantBody: "repo not found"},
+		{name: "Test bad body", body: `query`, authHeader: "token gbuser-token", statusCode: 400, wantBody: "Error occurred while decoding the request data"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(tt.body))
+		if tt.authHeader != "" {
+			req.Header.Set("Authorization", tt.authHeader)
+		}
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+
+		assert.Equal(t, tt.statusCode, resp.Code, tt.name)
+		assert.Contains(t, resp.Body.String(), tt.wantBody, tt.name)
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// post /repos/{org}/{owner}/{repo}/statuses/{sha}
+func (g *GitRepo) CreateStatusHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create status Request..")
+	vars := mux.Vars(r)
+	var statusReq service.StatusRequest
+	err := json.NewDecoder(r.Body).Decode(&statusReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	statusResp, err := g.serviceFor(r).CreateStatus(vars["org"], vars["owner"], vars["repo"], vars["sha"], &statusReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the status.", err)
+		return
+	}
+	g.log(r).Info("Status got created.", "context", statusResp.Context, "state", statusResp.State)
+	g.writeJSON(rw, r, http.StatusCreated, statusResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/statuses, get /repos/{org}/{owner}/{repo}/statuses/{sha}
+func (g *GitRepo) ListStatusesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list statuses Request..")
+	vars := mux.Vars(r)
+	ref := vars["ref"]
+	if ref == "" {
+		ref = vars["sha"]
+	}
+	statusList, err := g.serviceFor(r).ListStatuses(vars["org"], vars["owner"], vars["repo"], ref)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the status list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, statusList))
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/status
+func (g *GitRepo) GetCombinedStatusHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get combined status Request..")
+	vars := mux.Vars(r)
+	combinedResp, err := g.serviceFor(r).GetCombinedStatus(vars["org"], vars["owner"], vars["repo"], vars["ref"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the combined status.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, combinedResp)
+}
+
+// post /repos/{org}/{owner}/{repo}/check-runs
+func (g *GitRepo) CreateCheckRunHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create check run Request..")
+	vars := mux.Vars(r)
+	var checkReq service.CheckRunRequest
+	err := json.NewDecoder(r.Body).Decode(&checkReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	checkRunResp, err := g.serviceFor(r).CreateCheckRun(vars["org"], vars["owner"], vars["repo"], &checkReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the check run.", err)
+		return
+	}
+	g.log(r).Info("Check run got created.", "check_run_id", checkRunResp.ID, "name", checkRunResp.Name)
+	g.writeJSON(rw, r, http.StatusCreated, checkRunResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/check-runs/{check_run_id}
+func (g *GitRepo) GetCheckRunHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get check run Request..")
+	vars := mux.Vars(r)
+	checkRunID, _ := strconv.Atoi(vars["check_run_id"])
+	checkRunResp, err := g.serviceFor(r).GetCheckRun(vars["org"], vars["owner"], vars["repo"], checkRunID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the check run.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, checkRunResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/check-runs/{check_run_id}
+func (g *GitRepo) UpdateCheckRunHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update check run Request..")
+	vars := mux.Vars(r)
+	checkRunID, _ := strconv.Atoi(vars["check_run_id"])
+	var checkReq service.CheckRunRequest
+	err := json.NewDecoder(r.Body).Decode(&checkReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	checkRunResp, err := g.serviceFor(r).UpdateCheckRun(vars["org"], vars["owner"], vars["repo"], checkRunID, &checkReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the check run.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, checkRunResp)
+}
+
+func checkRunFilter(r *http.Request) service.CheckRunFilter {
+	return service.CheckRunFilter{CheckName: r.URL.Query().Get("check_name"), Status: r.URL.Query().Get("status")}
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/check-runs
+func (g *GitRepo) ListCheckRunsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list check runs Request..")
+	vars := mux.Vars(r)
+	checkRunList, err := g.serviceFor(r).ListCheckRuns(vars["org"], vars["owner"], vars["repo"], vars["ref"], checkRunFilter(r))
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the check run list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, checkRunList)
+}
+
+// get /repos/{org}/{owner}/{repo}/check-suites/{check_suite_id}/check-runs
+func (g *GitRepo) ListSuiteCheckRunsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list check suite runs Request..")
+	vars := mux.Vars(r)
+	checkSuiteID, _ := strconv.Atoi(vars["check_suite_id"])
+	checkRunList, err := g.serviceFor(r).ListSuiteCheckRuns(vars["org"], vars["owner"], vars["repo"], checkSuiteID, checkRunFilter(r))
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the check run list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, checkRunList)
+}
+
+// post /repos/{org}/{owner}/{repo}/check-suites
+func (g *GitRepo) CreateCheckSuiteHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create check suite Request..")
+	vars := mux.Vars(r)
+	var suiteReq service.CheckSuiteRequest
+	err := json.NewDecoder(r.Body).Decode(&suiteReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	suiteResp, created, err := g.serviceFor(r).CreateCheckSuite(vars["org"], vars["owner"], vars["repo"], &suiteReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the check suite.", err)
+		return
+	}
+	// github answers 200 with the existing suite of the commit.
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	g.writeJSON(rw, r, status, suiteResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/check-suites/{check_suite_id}
+func (g *GitRepo) GetCheckSuiteHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get check suite Request..")
+	vars := mux.Vars(r)
+	checkSuiteID, _ := strconv.Atoi(vars["check_suite_id"])
+	suiteResp, err := g.serviceFor(r).GetCheckSuite(vars["org"], vars["owner"], vars["repo"], checkSuiteID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the check suite.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, suiteResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/check-suites
+func (g *GitRepo) ListCheckSuitesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list check suites Request..")
+	vars := mux.Vars(r)
+	suiteList, err := g.serviceFor(r).ListCheckSuites(vars["org"], vars["owner"], vars["repo"], vars["ref"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the check suite list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, suiteList)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"gbserver/service"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// get /repos/{org}/{owner}/{repo}/commits?sha=&path=&since=&until=
+func (g *GitRepo) ListCommitsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list commits Request..")
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	filter := service.CommitFilter{SHA: query.Get("sha"), Path: query.Get("path"), Since: query.Get("since"), Until: query.Get("until")}
+	commitList, err := g.serviceFor(r).ListCommits(vars["org"], vars["owner"], vars["repo"], filter)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the commit list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, commitList))
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}
+func (g *GitRepo) GetCommitHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get commit Request..")
+	vars := mux.Vars(r)
+	commitResp, err := g.serviceFor(r).GetCommit(vars["org"], vars["owner"], vars["repo"], vars["ref"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the commit.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, commitResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/git/commits/{sha}
+func (g *GitRepo) GetGitCommitHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get git commit Request..")
+	vars := mux.Vars(r)
+	gitCommitResp, err := g.serviceFor(r).GetGitCommit(vars["org"], vars["owner"], vars["repo"], vars["sha"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the git commit.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, gitCommitResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/compare/{base}...{head}
+func (g *GitRepo) CompareCommitsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing compare commits Request..")
+	vars := mux.Vars(r)
+	compareResp, err := g.serviceFor(r).CompareCommits(vars["org"], vars["owner"], vars["repo"], vars["basehead"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while comparing the commits.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, compareResp)
+}
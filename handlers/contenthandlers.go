@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// get /repos/{org}/{owner}/{repo}/contents/{path}?ref=
+func (g *GitRepo) GetContentsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get contents Request..")
+	vars := mux.Vars(r)
+	contentResp, err := g.serviceFor(r).GetContents(vars["org"], vars["owner"], vars["repo"], vars["path"], r.URL.Query().Get("ref"))
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the contents.", err)
+		return
+	}
+	// a directory is answered with the list of its entries.
+	if contentResp.Type == "dir" {
+		g.writeJSON(rw, r, http.StatusOK, contentResp.Entries)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, contentResp)
+}
+
+// put /repos/{org}/{owner}/{repo}/contents/{path}
+func (g *GitRepo) PutContentsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing put contents Request..")
+	vars := mux.Vars(r)
+	var fileReq service.FileCommitRequest
+	err := json.NewDecoder(r.Body).Decode(&fileReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	fileResp, created, err := g.serviceFor(r).PutContents(vars["org"], vars["owner"], vars["repo"], vars["path"], &fileReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while writing the file.", err)
+		return
+	}
+	g.log(r).Info("File got committed.", "path", vars["path"], "sha", fileResp.Commit.SHA)
+	if created {
+		g.writeJSON(rw, r, http.StatusCreated, fileResp)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, fileResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/contents/{path}
+func (g *GitRepo) DeleteContentsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete contents Request..")
+	vars := mux.Vars(r)
+	var fileReq service.FileCommitRequest
+	err := json.NewDecoder(r.Body).Decode(&fileReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	fileResp, err := g.serviceFor(r).DeleteContents(vars["org"], vars["owner"], vars["repo"], vars["path"], &fileReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the file.", err)
+		return
+	}
+	g.log(r).Info("File got deleted.", "path", vars["path"], "sha", fileResp.Commit.SHA)
+	g.writeJSON(rw, r, http.StatusOK, fileResp)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"gbserver/service"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// the git smart HTTP protocol, what `git clone http://<host>/{org}/{owner}/{repo}.git` talks to.
+// Credentials are sent with basic auth, any user name and a token as the password.
+
+// get /{org}/{owner}/{repo}.git/info/refs?service=git-upload-pack|git-receive-pack
+func (g *GitRepo) GitInfoRefsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing git info refs Request..")
+	vars := mux.Vars(r)
+	gitService := r.URL.Query().Get("service")
+	var refs bytes.Buffer
+	err := g.serviceFor(r).AdvertiseRefs(vars["org"], vars["owner"], vars["repo"], gitService, r.Header.Get("Git-Protocol"), &refs)
+	if err != nil {
+		g.gitError(rw, r, "Error occurred while advertising the refs.", err)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/x-"+gitService+"-advertisement")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.Write(refs.Bytes())
+}
+
+// post /{org}/{owner}/{repo}.git/git-upload-pack, post /{org}/{owner}/{repo}.git/git-receive-pack
+func (g *GitRepo) GitServiceHandler(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	gitService := vars["service"]
+	g.log(r).Debug("Processing git Request..", "service", gitService)
+	defer r.Body.Close()
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzipBody, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+			return
+		}
+		defer gzipBody.Close()
+		body = gzipBody
+	}
+
+	// the pack is streamed, so the headers have to be out before git writes anything.
+	rw.Header().Set("Content-Type", "application/x-"+gitService+"-result")
+	rw.Header().Set("Cache-Control", "no-cache")
+	err := g.serviceFor(r).ServeGitRPC(vars["org"], vars["owner"], vars["repo"], gitService, r.Header.Get("Git-Protocol"), body, rw)
+	if err != nil {
+		g.gitError(rw, r, "Error occurred while running "+gitService+".", err)
+	}
+}
+
+// gitError answers a failed git request, once git has started writing only the log is left.
+func (g *GitRepo) gitError(rw http.ResponseWriter, r *http.Request, msg string, err error) {
+	g.log(r).Error(msg, "error", err)
+	switch err {
+	case service.ErrOrgNotFound, service.ErrOwnerNotFound, service.ErrRepoNotFound, service.ErrGitDisabled:
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case service.ErrInvalidGitService, service.ErrBranchPushRestricted, service.ErrProtectedBranchDeletion,
+		service.ErrProtectedBranchForcePush, service.ErrProtectedBranchPullRequest, service.ErrRequiredChecksFailing:
+		// a push breaking branch protection, git shows the message of the 403.
+		http.Error(rw, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"gbserver/gitstore"
+	"gbserver/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// runGit runs the git client the way CI would, without any of the config of the machine.
+func runGit(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_TERMINAL_PROMPT=0",
+		"GIT_AUTHOR_NAME=gbuser", "GIT_AUTHOR_EMAIL=gbuser@gbserver.com", "GIT_COMMITTER_NAME=gbuser", "GIT_COMMITTER_EMAIL=gbuser@gbserver.com")
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitSmartHTTP(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitStore, err := gitstore.New(t.TempDir())
+	assert.NoError(t, err)
+	gitHTTPRepo := NewGitRepo(l)
+	assert.NoError(t, gitHTTPRepo.UseGit(gitStore))
+
+	router := mux.NewRouter()
+	router.Use(gitHTTPRepo.AuthMiddleware)
+	router.Path("/repos/{org}/{owner}/{repo}/branches").Methods(http.MethodGet).HandlerFunc(gitHTTPRepo.ListBranchesHandler)
+	router.Path("/{org}/{owner}/{repo}.git/info/refs").Methods(http.MethodGet).HandlerFunc(gitHTTPRepo.GitInfoRefsHandler)
+	router.Path("/{org}/{owner}/{repo}.git/{service:git-upload-pack|git-receive-pack}").Methods(http.MethodPost).HandlerFunc(gitHTTPRepo.GitServiceHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/gborg/gbuser/gbrepo.git/info/refs?service=git-upload-pack")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Basic")
+
+	req := httptest.NewRequest(http.MethodGet, "/gborg/gbuser/gbrepo.git/info/refs", nil)
+	req.Header.Set("Authorization", "token gbuser-token")
+	dumbResp := httptest.NewRecorder()
+	router.ServeHTTP(dumbResp, req)
+	assert.Equal(t, http.StatusForbidden, dumbResp.Code)
+
+	workDir := t.TempDir()
+	remote := strings.Replace(server.URL, "http://", "http://gbuser:gbuser-token@", 1) + "/gborg/gbuser/gbrepo.git"
+	runGit(t, workDir, "clone", "--quiet", remote, "clone")
+	cloneDir := filepath.Join(workDir, "clone")
+	assert.FileExists(t, filepath.Join(cloneDir, "README.md"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(cloneDir, "feature.txt"), []byte("new\n"), 0o644))
+	runGit(t, cloneDir, "checkout", "--quiet", "-b", "pushed")
+	runGit(t, cloneDir, "add", "feature.txt")
+	runGit(t, cloneDir, "commit", "--quiet", "-m", "Add feature")
+	pushedSHA := runGit(t, cloneDir, "rev-parse", "HEAD")
+	runGit(t, cloneDir, "push", "--quiet", "origin", "pushed", ":gbbranch")
+
+	req = httptest.NewRequest(http.MethodGet, "/repos/gborg/gbuser/gbrepo/branches", nil)
+	req.Header.Set("Authorization", "token gbuser-token")
+	branchResp := httptest.NewRecorder()
+	router.ServeHTTP(branchResp, req)
+	assert.Equal(t, http.StatusOK, branchResp.Code)
+	assert.Contains(t, branchResp.Body.String(), pushedSHA)
+	assert.NotContains(t, branchResp.Body.String(), `"gbbranch"`)
+}
+
+func TestGitPushBranchProtection(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitStore, err := gitstore.New(t.TempDir())
+	assert.NoError(t, err)
+	gitHTTPRepo := NewGitRepo(l)
+	assert.NoError(t, gitHTTPRepo.UseGit(gitStore))
+	_, err = gitHTTPRepo.gbService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &service.BranchProtectionRequest{})
+	assert.NoError(t, err)
+	masterSHA := gitHTTPRepo.gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/master"].CommitInfo.SHA
+
+	router := mux.NewRouter()
+	router.Use(gitHTTPRepo.AuthMiddleware)
+	router.Path("/{org}/{owner}/{repo}.git/info/refs").Methods(http.MethodGet).HandlerFunc(gitHTTPRepo.GitInfoRefsHandler)
+	router.Path("/{org}/{owner}/{repo}.git/{service:git-upload-pack|git-receive-pack}").Methods(http.MethodPost).HandlerFunc(gitHTTPRepo.GitServiceHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	workDir := t.TempDir()
+	remote := strings.Replace(server.URL, "http://", "http://gbuser:gbuser-token@", 1) + "/gborg/gbuser/gbrepo.git"
+	runGit(t, workDir, "clone", "--quiet", remote, "clone")
+	cloneDir := filepath.Join(workDir, "clone")
+
+	deleteCmd := exec.Command("git", "push", "--quiet", "origin", ":master")
+	deleteCmd.Dir = cloneDir
+	deleteCmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_TERMINAL_PROMPT=0")
+	out, err := deleteCmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "403")
+
+	// a forced push is turned down by receive-pack once its pack is in, the branch never moves.
+	runGit(t, cloneDir, "checkout", "--quiet", "--orphan", "rewritten")
+	runGit(t, cloneDir, "commit", "--quiet", "-m", "Rewrite history")
+	forceCmd := exec.Command("git", "push", "--force", "origin", "rewritten:master")
+	forceCmd.Dir = cloneDir
+	forceCmd.Env = deleteCmd.Env
+	out, err = forceCmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "remote rejected")
+	assert.Contains(t, string(out), "Cannot force-push to this branch")
+	assert.Equal(t, masterSHA, gitHTTPRepo.gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/master"].CommitInfo.SHA)
+	refs, _ := gitStore.Refs("gborg/gbuser/gbrepo", "refs/heads/master")
+	assert.Equal(t, masterSHA, refs["refs/heads/master"])
+
+	runGit(t, cloneDir, "checkout", "--quiet", "master")
+	runGit(t, cloneDir, "commit", "--quiet", "--allow-empty", "-m", "Fast forward")
+	runGit(t, cloneDir, "push", "--quiet", "origin", "master")
+	assert.Equal(t, runGit(t, cloneDir, "rev-parse", "HEAD"), gitHTTPRepo.gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/master"].CommitInfo.SHA)
+}
+
+func TestGitPushTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitStore, err := gitstore.New(t.TempDir())
+	assert.NoError(t, err)
+	gitHTTPRepo := NewGitRepo(l)
+	assert.NoError(t, gitHTTPRepo.UseGit(gitStore))
+
+	router := mux.NewRouter()
+	router.Use(gitHTTPRepo.AuthMiddleware)
+	router.Path("/{org}/{owner}/{repo}.git/info/refs").Methods(http.MethodGet).HandlerFunc(gitHTTPRepo.GitInfoRefsHandler)
+	router.Path("/{org}/{owner}/{repo}.git/{service:git-upload-pack|git-receive-pack}").Methods(http.MethodPost).HandlerFunc(gitHTTPRepo.GitServiceHandler)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	workDir := t.TempDir()
+	remote := strings.Replace(server.URL, "http://", "http://gbuser:gbuser-token@", 1) + "/gborg/gbuser/gbrepo.git"
+	runGit(t, workDir, "clone", "--quiet", remote, "clone")
+	cloneDir := filepath.Join(workDir, "clone")
+
+	runGit(t, cloneDir, "-c", "user.name=gbuser", "-c", "user.email=gbuser@gbserver.com", "tag", "-a", "v1.0.0", "-m", "First release")
+	runGit(t, cloneDir, "tag", "light")
+	runGit(t, cloneDir, "push", "--quiet", "origin", "--tags")
+	store := gitHTTPRepo.gbService.GbStoreInstance
+	assert.ElementsMatch(t, []string{"light", "v1.0.0"}, store.Repos["gborg/gbuser/gbrepo"].Tags)
+	annotated := store.Tags["gborg/gbuser/gbrepo/v1.0.0"].SHA
+	assert.Equal(t, "First release", store.TagObjects["gborg/gbuser/gbrepo/"+annotated].Message)
+
+	runGit(t, cloneDir, "push", "--quiet", "origin", ":refs/tags/light")
+	assert.Equal(t, []string{"v1.0.0"}, store.Repos["gborg/gbuser/gbrepo"].Tags)
+}
+
+// failingWriter stands for a client that went away while its push was answered.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestGitPushSyncedOnError(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitStore, err := gitstore.New(t.TempDir())
+	assert.NoError(t, err)
+	gitHTTPRepo := NewGitRepo(l)
+	assert.NoError(t, gitHTTPRepo.UseGit(gitStore))
+	t.Cleanup(gitHTTPRepo.Close)
+
+	// the push is only captured here, it reaches git below with nobody left to read the answer.
+	var pushBody []byte
+	router := mux.NewRouter()
+	router.Use(gitHTTPRepo.AuthMiddleware)
+	router.Path("/{org}/{owner}/{repo}.git/info/refs").Methods(http.MethodGet).HandlerFunc(gitHTTPRepo.GitInfoRefsHandler)
+	router.Path("/{org}/{owner}/{repo}.git/{service:git-upload-pack}").Methods(http.MethodPost).HandlerFunc(gitHTTPRepo.GitServiceHandler)
+	router.Path("/{org}/{owner}/{repo}.git/git-receive-pack").Methods(http.MethodPost).HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gzipBody, err := gzip.NewReader(r.Body)
+			assert.NoError(t, err)
+			body = gzipBody
+		}
+		pushBody, _ = io.ReadAll(body)
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	workDir := t.TempDir()
+	remote := strings.Replace(server.URL, "http://", "http://gbuser:gbuser-token@", 1) + "/gborg/gbuser/gbrepo.git"
+	runGit(t, workDir, "clone", "--quiet", remote, "clone")
+	cloneDir := filepath.Join(workDir, "clone")
+	runGit(t, cloneDir, "checkout", "--quiet", "-b", "dropped")
+	runGit(t, cloneDir, "commit", "--quiet", "--allow-empty", "-m", "Dropped answer")
+	pushCmd := exec.Command("git", "push", "--quiet", "origin", "dropped")
+	pushCmd.Dir = cloneDir
+	pushCmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL="+os.DevNull, "GIT_TERMINAL_PROMPT=0")
+	assert.Error(t, pushCmd.Run())
+	assert.NotEmpty(t, pushBody)
+
+	err = gitHTTPRepo.gbService.ServeGitRPC("gborg", "gbuser", "gbrepo", "git-receive-pack", "", bytes.NewReader(pushBody), failingWriter{})
+	assert.Error(t, err)
+	refs, _ := gitStore.Refs("gborg/gbuser/gbrepo", "refs/heads/dropped")
+	pushedSHA := runGit(t, cloneDir, "rev-parse", "HEAD")
+	assert.Equal(t, pushedSHA, refs["refs/heads/dropped"])
+	branch := gitHTTPRepo.gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/dropped"]
+	if assert.NotNil(t, branch) {
+		assert.Equal(t, pushedSHA, branch.CommitInfo.SHA)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+)
+
+// post /graphql
+// The answer is 200 even when the query fails, the errors come in the body next to whatever data resolved.
+func (g *GitRepo) GraphQLHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing graphql Request..")
+	var gqlReq service.GraphQLRequest
+	err := json.NewDecoder(r.Body).Decode(&gqlReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	g.writeJSON(rw, r, http.StatusOK, g.serviceFor(r).GraphQL(r.Context(), &gqlReq))
+}
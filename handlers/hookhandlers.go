@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// the same handlers serve repo hooks (/repos/{org}/{owner}/{repo}/hooks) and org hooks (/orgs/{org}/hooks),
+// org routes simply have no owner and repo.
+
+// get /repos/{org}/{owner}/{repo}/hooks, get /orgs/{org}/hooks
+func (g *GitRepo) ListHooksHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list hooks Request..")
+	vars := mux.Vars(r)
+	hookList, err := g.serviceFor(r).ListHooks(vars["org"], vars["owner"], vars["repo"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the hook list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, hookList))
+}
+
+// post /repos/{org}/{owner}/{repo}/hooks, post /orgs/{org}/hooks
+func (g *GitRepo) CreateHookHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create hook Request..")
+	vars := mux.Vars(r)
+	var hookReq service.HookRequest
+	err := json.NewDecoder(r.Body).Decode(&hookReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	hookResp, err := g.serviceFor(r).CreateHook(vars["org"], vars["owner"], vars["repo"], &hookReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the hook.", err)
+		return
+	}
+	g.log(r).Info("Hook got created.", "hook_id", hookResp.ID)
+	rw.Header().Set("Location", hookResp.URL)
+	g.writeJSON(rw, r, http.StatusCreated, hookResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks/{hook_id}, get /orgs/{org}/hooks/{hook_id}
+func (g *GitRepo) GetHookHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get hook Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	hookResp, err := g.serviceFor(r).GetHook(vars["org"], vars["owner"], vars["repo"], hookID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the hook.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, hookResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/hooks/{hook_id}, patch /orgs/{org}/hooks/{hook_id}
+func (g *GitRepo) UpdateHookHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update hook Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	var hookReq service.HookRequest
+	err := json.NewDecoder(r.Body).Decode(&hookReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	hookResp, err := g.serviceFor(r).UpdateHook(vars["org"], vars["owner"], vars["repo"], hookID, &hookReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the hook.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, hookResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/hooks/{hook_id}, delete /orgs/{org}/hooks/{hook_id}
+func (g *GitRepo) DeleteHookHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete hook Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	_, err := g.serviceFor(r).DeleteHook(vars["org"], vars["owner"], vars["repo"], hookID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the hook.", err)
+		return
+	}
+	g.log(r).Info("Hook got deleted.", "hook_id", hookID)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// post /repos/{org}/{owner}/{repo}/hooks/{hook_id}/pings, post /orgs/{org}/hooks/{hook_id}/pings
+func (g *GitRepo) PingHookHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing ping hook Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	err := g.serviceFor(r).PingHook(vars["org"], vars["owner"], vars["repo"], hookID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while pinging the hook.", err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks/{hook_id}/deliveries
+func (g *GitRepo) ListHookDeliveriesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list hook deliveries Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	deliveryList, err := g.serviceFor(r).ListHookDeliveries(vars["org"], vars["owner"], vars["repo"], hookID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the hook deliveries.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, deliveryList))
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks/{hook_id}/deliveries/{delivery_id}
+func (g *GitRepo) GetHookDeliveryHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get hook delivery Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	deliveryID, _ := strconv.Atoi(vars["delivery_id"])
+	deliveryResp, err := g.serviceFor(r).GetHookDelivery(vars["org"], vars["owner"], vars["repo"], hookID, deliveryID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the hook delivery.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, deliveryResp)
+}
+
+// post /repos/{org}/{owner}/{repo}/hooks/{hook_id}/deliveries/{delivery_id}/attempts
+func (g *GitRepo) RedeliverHookHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing redeliver hook Request..")
+	vars := mux.Vars(r)
+	hookID, _ := strconv.Atoi(vars["hook_id"])
+	deliveryID, _ := strconv.Atoi(vars["delivery_id"])
+	err := g.serviceFor(r).RedeliverHook(vars["org"], vars["owner"], vars["repo"], hookID, deliveryID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while redelivering the hook.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusAccepted, map[string]string{})
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gbserver/service"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// get /repos/{org}/{owner}/{repo}/issues?state=&labels=&assignee=&creator=
+func (g *GitRepo) ListIssuesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list issues Request..")
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+	filter := service.IssueFilter{State: query.Get("state"), Assignee: query.Get("assignee"), Creator: query.Get("creator")}
+	if labels := query.Get("labels"); labels != "" {
+		filter.Labels = strings.Split(labels, ",")
+	}
+	issueList, err := g.serviceFor(r).ListIssues(vars["org"], vars["owner"], vars["repo"], filter)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the issue list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, issueList))
+}
+
+// post /repos/{org}/{owner}/{repo}/issues
+func (g *GitRepo) CreateIssueHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create issue Request..")
+	vars := mux.Vars(r)
+	var issueReq service.IssueRequest
+	err := json.NewDecoder(r.Body).Decode(&issueReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	issueResp, err := g.serviceFor(r).CreateIssue(vars["org"], vars["owner"], vars["repo"], &issueReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the issue.", err)
+		return
+	}
+	g.log(r).Info("Issue got created.", "number", issueResp.Number)
+	rw.Header().Set("Location", issueResp.URL)
+	g.writeJSON(rw, r, http.StatusCreated, issueResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/{issue_number}
+func (g *GitRepo) GetIssueHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get issue Request..")
+	vars := mux.Vars(r)
+	issueResp, err := g.serviceFor(r).GetIssue(vars["org"], vars["owner"], vars["repo"], vars["issue_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the issue.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, issueResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/issues/{issue_number}
+func (g *GitRepo) UpdateIssueHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update issue Request..")
+	vars := mux.Vars(r)
+	var issueReq service.IssueRequest
+	err := json.NewDecoder(r.Body).Decode(&issueReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	issueResp, err := g.serviceFor(r).UpdateIssue(vars["org"], vars["owner"], vars["repo"], vars["issue_number"], &issueReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the issue.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, issueResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/{issue_number}/comments
+func (g *GitRepo) ListIssueCommentsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list issue comments Request..")
+	vars := mux.Vars(r)
+	commentList, err := g.serviceFor(r).ListIssueComments(vars["org"], vars["owner"], vars["repo"], vars["issue_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the issue comments.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, commentList))
+}
+
+// post /repos/{org}/{owner}/{repo}/issues/{issue_number}/comments
+func (g *GitRepo) CreateIssueCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create issue comment Request..")
+	vars := mux.Vars(r)
+	var commentReq service.IssueCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&commentReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	commentResp, err := g.serviceFor(r).CreateIssueComment(vars["org"], vars["owner"], vars["repo"], vars["issue_number"], &commentReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the issue comment.", err)
+		return
+	}
+	rw.Header().Set("Location", commentResp.URL)
+	g.writeJSON(rw, r, http.StatusCreated, commentResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/comments/{comment_id}
+func (g *GitRepo) GetIssueCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get issue comment Request..")
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+	commentResp, err := g.serviceFor(r).GetIssueComment(vars["org"], vars["owner"], vars["repo"], commentID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the issue comment.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, commentResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/issues/comments/{comment_id}
+func (g *GitRepo) UpdateIssueCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update issue comment Request..")
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+	var commentReq service.IssueCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&commentReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	commentResp, err := g.serviceFor(r).UpdateIssueComment(vars["org"], vars["owner"], vars["repo"], commentID, &commentReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the issue comment.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, commentResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/issues/comments/{comment_id}
+func (g *GitRepo) DeleteIssueCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete issue comment Request..")
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+	_, err := g.serviceFor(r).DeleteIssueComment(vars["org"], vars["owner"], vars["repo"], commentID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the issue comment.", err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// get /repos/{org}/{owner}/{repo}/labels
+func (g *GitRepo) ListLabelsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list labels Request..")
+	vars := mux.Vars(r)
+	labelList, err := g.serviceFor(r).ListLabels(vars["org"], vars["owner"], vars["repo"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the label list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, labelList))
+}
+
+// post /repos/{org}/{owner}/{repo}/labels
+func (g *GitRepo) CreateLabelHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create label Request..")
+	vars := mux.Vars(r)
+	var labelReq service.LabelRequest
+	err := json.NewDecoder(r.Body).Decode(&labelReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	labelResp, err := g.serviceFor(r).CreateLabel(vars["org"], vars["owner"], vars["repo"], &labelReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the label.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusCreated, labelResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/labels/{name}
+func (g *GitRepo) GetLabelHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get label Request..")
+	vars := mux.Vars(r)
+	labelResp, err := g.serviceFor(r).GetLabel(vars["org"], vars["owner"], vars["repo"], vars["name"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the label.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, labelResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/labels/{name}
+func (g *GitRepo) UpdateLabelHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update label Request..")
+	vars := mux.Vars(r)
+	var labelReq service.LabelRequest
+	err := json.NewDecoder(r.Body).Decode(&labelReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	labelResp, err := g.serviceFor(r).UpdateLabel(vars["org"], vars["owner"], vars["repo"], vars["name"], &labelReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the label.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, labelResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/labels/{name}
+func (g *GitRepo) DeleteLabelHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete label Request..")
+	vars := mux.Vars(r)
+	_, err := g.serviceFor(r).DeleteLabel(vars["org"], vars["owner"], vars["repo"], vars["name"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the label.", err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// decodeLabelNames reads the labels of an issue labels request, github takes {"labels": [...]} as well as a bare list.
+func decodeLabelNames(r *http.Request) ([]string, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	names := []string{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return names, nil
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &names)
+		return names, err
+	}
+	var labelsReq struct {
+		Labels []string `json:"labels"`
+	}
+	err = json.Unmarshal(data, &labelsReq)
+	if labelsReq.Labels != nil {
+		names = labelsReq.Labels
+	}
+	return names, err
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels
+func (g *GitRepo) ListIssueLabelsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list issue labels Request..")
+	vars := mux.Vars(r)
+	labelList, err := g.serviceFor(r).ListIssueLabels(vars["org"], vars["owner"], vars["repo"], vars["issue_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the issue labels.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, labelList))
+}
+
+// post (add) and put (replace) /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels
+func (g *GitRepo) AddIssueLabelsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing issue labels Request..", "method", r.Method)
+	vars := mux.Vars(r)
+	names, err := decodeLabelNames(r)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	labelList, err := g.serviceFor(r).AddIssueLabels(vars["org"], vars["owner"], vars["repo"], vars["issue_number"], names, r.Method == http.MethodPut)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while labelling the issue.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, labelList)
+}
+
+// delete /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels
+func (g *GitRepo) ClearIssueLabelsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing clear issue labels Request..")
+	vars := mux.Vars(r)
+	_, err := g.serviceFor(r).AddIssueLabels(vars["org"], vars["owner"], vars["repo"], vars["issue_number"], []string{}, true)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while clearing the issue labels.", err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// delete /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels/{name}
+func (g *GitRepo) RemoveIssueLabelHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing remove issue label Request..")
+	vars := mux.Vars(r)
+	labelList, err := g.serviceFor(r).RemoveIssueLabel(vars["org"], vars["owner"], vars["repo"], vars["issue_number"], vars["name"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while removing the issue label.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, labelList)
+}
+
+// get /repos/{org}/{owner}/{repo}/assignees
+func (g *GitRepo) ListAssigneesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list assignees Request..")
+	vars := mux.Vars(r)
+	assigneeList, err := g.serviceFor(r).ListAssignees(vars["org"], vars["owner"], vars["repo"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the assignees.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, assigneeList))
+}
+
+// get /repos/{org}/{owner}/{repo}/assignees/{assignee}, 204 when the user can be assigned.
+func (g *GitRepo) CheckAssigneeHandler(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := g.serviceFor(r).CheckAssignee(vars["org"], vars["owner"], vars["repo"], vars["assignee"])
+	if err != nil {
+		g.log(r).Info("User cannot be assigned.", "assignee", vars["assignee"], "error", err)
+		http.Error(rw, err.Error(), http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// post (add) and delete (remove) /repos/{org}/{owner}/{repo}/issues/{issue_number}/assignees
+func (g *GitRepo) IssueAssigneesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing issue assignees Request..", "method", r.Method)
+	vars := mux.Vars(r)
+	var assigneesReq struct {
+		Assignees []string `json:"assignees"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&assigneesReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	issueResp, err := g.serviceFor(r).AddAssignees(vars["org"], vars["owner"], vars["repo"], vars["issue_number"],
+		assigneesReq.Assignees, r.Method == http.MethodDelete)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the issue assignees.", err)
+		return
+	}
+	status := http.StatusCreated
+	if r.Method == http.MethodDelete {
+		status = http.StatusOK
+	}
+	g.writeJSON(rw, r, status, issueResp)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// get /orgs/{org}
+func (g *GitRepo) GetOrgHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get org Request..")
+	orgResp, err := g.serviceFor(r).GetOrg(mux.Vars(r)["org"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the org.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, orgResp)
+}
+
+// get /users/{user}
+func (g *GitRepo) GetUserHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get user Request..")
+	userResp, err := g.serviceFor(r).GetUser(mux.Vars(r)["user"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the user.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, userResp)
+}
+
+// get /user
+func (g *GitRepo) GetAuthenticatedUserHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get authenticated user Request..")
+	authUser, ok := AuthUserFromContext(r.Context())
+	if !ok {
+		writeJSONError(rw, http.StatusUnauthorized, "Requires authentication")
+		return
+	}
+	userResp, err := g.serviceFor(r).GetUser(authUser.Login)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the user.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, userResp)
+}
+
+// get /user/orgs
+func (g *GitRepo) ListAuthenticatedUserOrgsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list user orgs Request..")
+	authUser, ok := AuthUserFromContext(r.Context())
+	if !ok {
+		writeJSONError(rw, http.StatusUnauthorized, "Requires authentication")
+		return
+	}
+	orgList, err := g.serviceFor(r).ListUserOrgs(authUser.Login)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the org list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, orgList))
+}
+
+// get /orgs/{org}/members
+func (g *GitRepo) ListOrgMembersHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list org members Request..")
+	memberList, err := g.serviceFor(r).ListOrgMembers(mux.Vars(r)["org"], r.URL.Query().Get("role"))
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the member list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, memberList))
+}
+
+// get /orgs/{org}/members/{username}
+// Answers 204 for a member and 404 otherwise, without a body.
+func (g *GitRepo) CheckOrgMemberHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing check org member Request..")
+	vars := mux.Vars(r)
+	_, err := g.serviceFor(r).GetOrgMembership(vars["org"], vars["username"])
+	if err != nil {
+		g.log(r).Info("Not a member of the org.", "username", vars["username"], "error", err)
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// get /orgs/{org}/memberships/{username}
+func (g *GitRepo) GetOrgMembershipHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get org membership Request..")
+	vars := mux.Vars(r)
+	membershipResp, err := g.serviceFor(r).GetOrgMembership(vars["org"], vars["username"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the membership.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, membershipResp)
+}
+
+// put /orgs/{org}/memberships/{username}
+func (g *GitRepo) SetOrgMembershipHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing set org membership Request..")
+	vars := mux.Vars(r)
+	var membershipReq service.MembershipRequest
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&membershipReq)
+		if err != nil {
+			g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+			http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+			return
+		}
+	}
+	defer r.Body.Close()
+
+	membershipResp, err := g.serviceFor(r).SetOrgMembership(vars["org"], vars["username"], &membershipReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while setting the membership.", err)
+		return
+	}
+	g.log(r).Info("Membership got set.", "org", vars["org"], "username", vars["username"], "role", membershipResp.Role)
+	g.writeJSON(rw, r, http.StatusOK, membershipResp)
+}
+
+// delete /orgs/{org}/memberships/{username}, delete /orgs/{org}/members/{username}
+func (g *GitRepo) RemoveOrgMemberHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing remove org member Request..")
+	vars := mux.Vars(r)
+	err := g.serviceFor(r).RemoveOrgMember(vars["org"], vars["username"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while removing the member.", err)
+		return
+	}
+	g.log(r).Info("Member got removed.", "org", vars["org"], "username", vars["username"])
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// post /admin/users
+func (g *GitRepo) CreateUserHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create user Request..")
+	var userReq service.CreateUserRequest
+	err := json.NewDecoder(r.Body).Decode(&userReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	userResp, err := g.serviceFor(r).CreateUser(&userReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the user.", err)
+		return
+	}
+	g.log(r).Info("User got created.", "login", userResp.Login, "id", userResp.ID)
+	g.writeJSON(rw, r, http.StatusCreated, userResp)
+}
+
+// post /admin/users/{username}/authorizations
+func (g *GitRepo) CreateUserTokenHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create user token Request..")
+	authResp, err := g.serviceFor(r).CreateUserToken(mux.Vars(r)["username"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the token.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusCreated, authResp)
+}
+
+// post /admin/organizations
+func (g *GitRepo) CreateOrgHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create org Request..")
+	var orgReq service.CreateOrgRequest
+	err := json.NewDecoder(r.Body).Decode(&orgReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	orgResp, err := g.serviceFor(r).CreateOrg(&orgReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the org.", err)
+		return
+	}
+	g.log(r).Info("Org got created.", "org", orgResp.Login, "id", orgResp.ID)
+	g.writeJSON(rw, r, http.StatusCreated, orgResp)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultPerPage = 30
+const maxPerPage = 100
+
+// paginate cuts items down to the page asked for with ?per_page= and ?page= and sets
+// the Link header with the next, prev, first and last pages the way github does.
+func paginate[T any](rw http.ResponseWriter, r *http.Request, items []T) []T {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	lastPage := max((len(items)+perPage-1)/perPage, 1)
+	var links []string
+	pageLink := func(pageNumber int, rel string) {
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", pageURL(r, perPage, pageNumber), rel))
+	}
+	if page > 1 {
+		pageLink(min(page-1, lastPage), "prev")
+	}
+	if page < lastPage {
+		pageLink(page+1, "next")
+		pageLink(lastPage, "last")
+	}
+	if page > 1 {
+		pageLink(1, "first")
+	}
+	if len(links) > 0 {
+		rw.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	start := (page - 1) * perPage
+	if start >= len(items) {
+		return []T{}
+	}
+	return items[start:min(start+perPage, len(items))]
+}
+
+func pageURL(r *http.Request, perPage, page int) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if forwardedProto := r.Header.Get("X-Forwarded-Proto"); forwardedProto != "" {
+		scheme = forwardedProto
+	}
+	query := r.URL.Query()
+	query.Set("per_page", strconv.Itoa(perPage))
+	query.Set("page", strconv.Itoa(page))
+	pageURL := url.URL{Scheme: scheme, Host: r.Host, Path: r.URL.Path, RawQuery: query.Encode()}
+	return pageURL.String()
+}
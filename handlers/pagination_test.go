@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5, 6, 7}
+	tests := []struct {
+		name     string
+		query    string
+		wantResp []int
+		wantLink string
+	}{
+		{
+			name:     "Test default page size",
+			query:    "",
+			wantResp: items,
+		},
+		{
+			name:     "Test first page",
+			query:    "?per_page=3",
+			wantResp: []int{1, 2, 3},
+			wantLink: `<http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=2&per_page=3>; rel="next", <http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=3&per_page=3>; rel="last"`,
+		},
+		{
+			name:     "Test middle page keeps other query params",
+			query:    "?state=open&per_page=3&page=2",
+			wantResp: []int{4, 5, 6},
+			wantLink: `<http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=1&per_page=3&state=open>; rel="prev", <http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=3&per_page=3&state=open>; rel="next", <http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=3&per_page=3&state=open>; rel="last", <http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=1&per_page=3&state=open>; rel="first"`,
+		},
+		{
+			name:     "Test last page",
+			query:    "?per_page=3&page=3",
+			wantResp: []int{7},
+			wantLink: `<http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=2&per_page=3>; rel="prev", <http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=1&per_page=3>; rel="first"`,
+		},
+		{
+			name:     "Test page past the end",
+			query:    "?per_page=3&page=9",
+			wantResp: []int{},
+			wantLink: `<http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=3&per_page=3>; rel="prev", <http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls?page=1&per_page=3>; rel="first"`,
+		},
+		{
+			name:     "Test invalid params fall back to defaults",
+			query:    "?per_page=-1&page=abc",
+			wantResp: items,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://gbserver.local/repos/gborg/gbuser/gbrepo/pulls"+tt.query, nil)
+		resp := httptest.NewRecorder()
+
+		assert.Equal(t, tt.wantResp, paginate(resp, req, items), tt.name)
+		assert.Equal(t, tt.wantLink, resp.Header().Get("Link"), tt.name)
+	}
+
+	// per_page is capped at 100 like github.
+	manyItems := make([]int, 250)
+	req := httptest.NewRequest(http.MethodGet, "http://gbserver.local/orgs/gborg/gbuser/repos?per_page=500", nil)
+	assert.Len(t, paginate(httptest.NewRecorder(), req, manyItems), 100)
+}
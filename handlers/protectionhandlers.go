@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// get /repos/{org}/{owner}/{repo}/branches/{branch}/protection
+func (g *GitRepo) GetBranchProtectionHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get branch protection Request..")
+	vars := mux.Vars(r)
+	protectionResp, err := g.serviceFor(r).GetBranchProtection(vars["org"], vars["owner"], vars["repo"], vars["branch"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the branch protection.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, protectionResp)
+}
+
+// put /repos/{org}/{owner}/{repo}/branches/{branch}/protection
+func (g *GitRepo) UpdateBranchProtectionHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update branch protection Request..")
+	vars := mux.Vars(r)
+	var protectionReq service.BranchProtectionRequest
+	err := json.NewDecoder(r.Body).Decode(&protectionReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	protectionResp, err := g.serviceFor(r).UpdateBranchProtection(vars["org"], vars["owner"], vars["repo"], vars["branch"], &protectionReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the branch protection.", err)
+		return
+	}
+	g.log(r).Info("Branch got protected.", "branch", vars["branch"])
+	g.writeJSON(rw, r, http.StatusOK, protectionResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/branches/{branch}/protection
+func (g *GitRepo) DeleteBranchProtectionHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete branch protection Request..")
+	vars := mux.Vars(r)
+	err := g.serviceFor(r).DeleteBranchProtection(vars["org"], vars["owner"], vars["repo"], vars["branch"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the branch protection.", err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// get /repos/{org}/{owner}/{repo}/git/refs, get /repos/{org}/{owner}/{repo}/git/matching-refs/{ref}
+func (g *GitRepo) ListRefsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list refs Request..")
+	vars := mux.Vars(r)
+	refList, err := g.serviceFor(r).ListRefs(vars["org"], vars["owner"], vars["repo"], vars["ref"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the ref list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, refList))
+}
+
+// get /repos/{org}/{owner}/{repo}/git/ref/{ref}
+func (g *GitRepo) GetRefHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get ref Request..")
+	vars := mux.Vars(r)
+	refResp, err := g.serviceFor(r).GetRef(vars["org"], vars["owner"], vars["repo"], vars["ref"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the ref.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, refResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/git/refs/heads/{branch}
+func (g *GitRepo) UpdateRefHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update ref Request..")
+	vars := mux.Vars(r)
+	var refReq service.UpdateRefRequest
+	err := json.NewDecoder(r.Body).Decode(&refReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	refResp, err := g.serviceFor(r).UpdateRef(vars["org"], vars["owner"], vars["repo"], "heads/"+vars["branch"], &refReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the ref.", err)
+		return
+	}
+	g.log(r).Info("Ref got updated.", "ref", refResp.Ref, "sha", refResp.Object.SHA)
+	g.writeJSON(rw, r, http.StatusOK, refResp)
+}
+
+// post /repos/{org}/{owner}/{repo}/git/tags
+func (g *GitRepo) CreateTagObjectHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create tag object Request..")
+	vars := mux.Vars(r)
+	var tagReq service.TagRequest
+	err := json.NewDecoder(r.Body).Decode(&tagReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	tagResp, err := g.serviceFor(r).CreateTagObject(vars["org"], vars["owner"], vars["repo"], &tagReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the tag object.", err)
+		return
+	}
+	g.log(r).Info("Tag object got created.", "tag", tagResp.Tag, "sha", tagResp.SHA)
+	g.writeJSON(rw, r, http.StatusCreated, tagResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/git/tags/{sha}
+func (g *GitRepo) GetTagObjectHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get tag object Request..")
+	vars := mux.Vars(r)
+	tagResp, err := g.serviceFor(r).GetTagObject(vars["org"], vars["owner"], vars["repo"], vars["sha"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the tag object.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, tagResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/tags
+func (g *GitRepo) ListTagsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list tags Request..")
+	vars := mux.Vars(r)
+	tagList, err := g.serviceFor(r).ListTags(vars["org"], vars["owner"], vars["repo"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the tag list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, tagList))
+}
+
+// delete /repos/{org}/{owner}/{repo}/git/refs/tags/{tag}
+func (g *GitRepo) DeleteTagHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete tag Request..")
+	vars := mux.Vars(r)
+	err := g.serviceFor(r).DeleteTag(vars["org"], vars["owner"], vars["repo"], vars["tag"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the tag.", err)
+		return
+	}
+	g.log(r).Info("Tag got deleted.", "tag", vars["tag"])
+	rw.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// UseAssets keeps the content of release assets in assets.
+func (g *GitRepo) UseAssets(assets *service.AssetStore) {
+	g.gbService.Assets = assets
+}
+
+// post /repos/{org}/{owner}/{repo}/releases
+func (g *GitRepo) CreateReleaseHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create release Request..")
+	vars := mux.Vars(r)
+	var releaseReq service.ReleaseRequest
+	err := json.NewDecoder(r.Body).Decode(&releaseReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	releaseResp, err := g.serviceFor(r).CreateRelease(vars["org"], vars["owner"], vars["repo"], &releaseReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the release.", err)
+		return
+	}
+	g.log(r).Info("Release got created.", "release_id", releaseResp.ID, "tag", releaseResp.TagName)
+	g.writeJSON(rw, r, http.StatusCreated, releaseResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/releases
+func (g *GitRepo) ListReleasesHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list releases Request..")
+	vars := mux.Vars(r)
+	releaseList, err := g.serviceFor(r).ListReleases(vars["org"], vars["owner"], vars["repo"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the release list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, releaseList))
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/{release_id}, get /repos/{org}/{owner}/{repo}/releases/latest,
+// get /repos/{org}/{owner}/{repo}/releases/tags/{tag}
+func (g *GitRepo) GetReleaseHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get release Request..")
+	vars := mux.Vars(r)
+	var releaseResp service.ReleaseResponse
+	var err error
+	switch {
+	case vars["tag"] != "":
+		releaseResp, err = g.serviceFor(r).GetReleaseByTag(vars["org"], vars["owner"], vars["repo"], vars["tag"])
+	case vars["release_id"] != "":
+		releaseID, _ := strconv.Atoi(vars["release_id"])
+		releaseResp, err = g.serviceFor(r).GetRelease(vars["org"], vars["owner"], vars["repo"], releaseID)
+	default:
+		releaseResp, err = g.serviceFor(r).GetLatestRelease(vars["org"], vars["owner"], vars["repo"])
+	}
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the release.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, releaseResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/releases/{release_id}
+func (g *GitRepo) UpdateReleaseHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update release Request..")
+	vars := mux.Vars(r)
+	releaseID, _ := strconv.Atoi(vars["release_id"])
+	var releaseReq service.ReleaseRequest
+	err := json.NewDecoder(r.Body).Decode(&releaseReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	releaseResp, err := g.serviceFor(r).UpdateRelease(vars["org"], vars["owner"], vars["repo"], releaseID, &releaseReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the release.", err)
+		return
+	}
+	g.log(r).Info("Release got updated.", "release_id", releaseResp.ID)
+	g.writeJSON(rw, r, http.StatusOK, releaseResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/releases/{release_id}
+func (g *GitRepo) DeleteReleaseHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete release Request..")
+	vars := mux.Vars(r)
+	releaseID, _ := strconv.Atoi(vars["release_id"])
+	err := g.serviceFor(r).DeleteRelease(vars["org"], vars["owner"], vars["repo"], releaseID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the release.", err)
+		return
+	}
+	g.log(r).Info("Release got deleted.", "release_id", releaseID)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// post /repos/{org}/{owner}/{repo}/releases/{release_id}/assets?name=&label=
+// The body is the content of the asset, its Content-Type header becomes the content_type.
+func (g *GitRepo) UploadReleaseAssetHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing upload release asset Request..")
+	vars := mux.Vars(r)
+	releaseID, _ := strconv.Atoi(vars["release_id"])
+	defer r.Body.Close()
+	query := r.URL.Query()
+	assetResp, err := g.serviceFor(r).UploadReleaseAsset(vars["org"], vars["owner"], vars["repo"], releaseID,
+		query.Get("name"), query.Get("label"), r.Header.Get("Content-Type"), r.Body)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while uploading the release asset.", err)
+		return
+	}
+	g.log(r).Info("Release asset got uploaded.", "asset_id", assetResp.ID, "name", assetResp.Name, "size", assetResp.Size)
+	g.writeJSON(rw, r, http.StatusCreated, assetResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/{release_id}/assets
+func (g *GitRepo) ListReleaseAssetsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list release assets Request..")
+	vars := mux.Vars(r)
+	releaseID, _ := strconv.Atoi(vars["release_id"])
+	assetList, err := g.serviceFor(r).ListReleaseAssets(vars["org"], vars["owner"], vars["repo"], releaseID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the release asset list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, assetList))
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/assets/{asset_id}
+// Accept: application/octet-stream answers the content instead of the json.
+func (g *GitRepo) GetReleaseAssetHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get release asset Request..")
+	vars := mux.Vars(r)
+	assetID, _ := strconv.Atoi(vars["asset_id"])
+	if !strings.Contains(r.Header.Get("Accept"), "application/octet-stream") {
+		assetResp, err := g.serviceFor(r).GetReleaseAsset(vars["org"], vars["owner"], vars["repo"], assetID)
+		if err != nil {
+			g.apiError(rw, r, "Error occurred while fetching the release asset.", err)
+			return
+		}
+		g.writeJSON(rw, r, http.StatusOK, assetResp)
+		return
+	}
+	assetResp, content, err := g.serviceFor(r).DownloadReleaseAsset(vars["org"], vars["owner"], vars["repo"], assetID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while downloading the release asset.", err)
+		return
+	}
+	defer content.Close()
+	rw.Header().Set("Content-Type", assetResp.ContentType)
+	rw.Header().Set("Content-Length", strconv.FormatInt(assetResp.Size, 10))
+	rw.Header().Set("Content-Disposition", "attachment; filename="+strconv.Quote(assetResp.Name))
+	_, err = io.Copy(rw, content)
+	if err != nil {
+		g.log(r).Error("Error occurred while sending the release asset", "error", err)
+	}
+}
+
+// patch /repos/{org}/{owner}/{repo}/releases/assets/{asset_id}
+func (g *GitRepo) UpdateReleaseAssetHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update release asset Request..")
+	vars := mux.Vars(r)
+	assetID, _ := strconv.Atoi(vars["asset_id"])
+	var assetReq service.ReleaseAssetRequest
+	err := json.NewDecoder(r.Body).Decode(&assetReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	assetResp, err := g.serviceFor(r).UpdateReleaseAsset(vars["org"], vars["owner"], vars["repo"], assetID, &assetReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the release asset.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, assetResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/releases/assets/{asset_id}
+func (g *GitRepo) DeleteReleaseAssetHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete release asset Request..")
+	vars := mux.Vars(r)
+	assetID, _ := strconv.Atoi(vars["asset_id"])
+	err := g.serviceFor(r).DeleteReleaseAsset(vars["org"], vars["owner"], vars["repo"], assetID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the release asset.", err)
+		return
+	}
+	g.log(r).Info("Release asset got deleted.", "asset_id", assetID)
+	rw.WriteHeader(http.StatusNoContent)
+}
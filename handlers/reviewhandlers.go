@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gbserver/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// Pending reviews are only visible to their author, so even the reads of reviews go through serviceFor.
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews
+func (g *GitRepo) ListReviewsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list reviews Request..")
+	vars := mux.Vars(r)
+	reviewList, err := g.serviceFor(r).ListReviews(vars["org"], vars["owner"], vars["repo"], vars["pull_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the review list.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, reviewList))
+}
+
+// post /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews
+func (g *GitRepo) CreateReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create review Request..")
+	vars := mux.Vars(r)
+	var reviewReq service.ReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&reviewReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	reviewResp, err := g.serviceFor(r).CreateReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], &reviewReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the review.", err)
+		return
+	}
+	g.log(r).Info("Review got created.", "review_id", reviewResp.ID, "state", reviewResp.State)
+	g.writeJSON(rw, r, http.StatusOK, reviewResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}
+func (g *GitRepo) GetReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get review Request..")
+	vars := mux.Vars(r)
+	reviewID, _ := strconv.Atoi(vars["review_id"])
+	reviewResp, err := g.serviceFor(r).GetReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], reviewID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the review.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, reviewResp)
+}
+
+// put /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}
+func (g *GitRepo) UpdateReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update review Request..")
+	vars := mux.Vars(r)
+	reviewID, _ := strconv.Atoi(vars["review_id"])
+	var reviewReq service.ReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&reviewReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	reviewResp, err := g.serviceFor(r).UpdateReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], reviewID, &reviewReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the review.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, reviewResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}
+func (g *GitRepo) DeleteReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete review Request..")
+	vars := mux.Vars(r)
+	reviewID, _ := strconv.Atoi(vars["review_id"])
+	reviewResp, err := g.serviceFor(r).DeleteReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], reviewID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the review.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, reviewResp)
+}
+
+// post /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}/events
+func (g *GitRepo) SubmitReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing submit review Request..")
+	vars := mux.Vars(r)
+	reviewID, _ := strconv.Atoi(vars["review_id"])
+	var reviewReq service.ReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&reviewReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	reviewResp, err := g.serviceFor(r).SubmitReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], reviewID, &reviewReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while submitting the review.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, reviewResp)
+}
+
+// put /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}/dismissals
+func (g *GitRepo) DismissReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing dismiss review Request..")
+	vars := mux.Vars(r)
+	reviewID, _ := strconv.Atoi(vars["review_id"])
+	var dismissReq service.DismissReviewRequest
+	err := json.NewDecoder(r.Body).Decode(&dismissReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	reviewResp, err := g.serviceFor(r).DismissReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], reviewID, &dismissReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while dismissing the review.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, reviewResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}/comments
+func (g *GitRepo) ListReviewCommentsOfReviewHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list comments of review Request..")
+	vars := mux.Vars(r)
+	reviewID, _ := strconv.Atoi(vars["review_id"])
+	commentList, err := g.serviceFor(r).ListReviewCommentsOfReview(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], reviewID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the review comments.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, commentList))
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/comments
+func (g *GitRepo) ListReviewCommentsHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list review comments Request..")
+	vars := mux.Vars(r)
+	commentList, err := g.serviceFor(r).ListReviewComments(vars["org"], vars["owner"], vars["repo"], vars["pull_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the review comments.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, paginate(rw, r, commentList))
+}
+
+// post /repos/{org}/{owner}/{repo}/pulls/{pull_number}/comments
+func (g *GitRepo) CreateReviewCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing create review comment Request..")
+	vars := mux.Vars(r)
+	var commentReq service.ReviewCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&commentReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	commentResp, err := g.serviceFor(r).CreateReviewComment(vars["org"], vars["owner"], vars["repo"], vars["pull_number"], &commentReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while creating the review comment.", err)
+		return
+	}
+	rw.Header().Set("Location", commentResp.URL)
+	g.writeJSON(rw, r, http.StatusCreated, commentResp)
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/comments/{comment_id}
+func (g *GitRepo) GetReviewCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing get review comment Request..")
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+	commentResp, err := g.serviceFor(r).GetReviewComment(vars["org"], vars["owner"], vars["repo"], commentID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the review comment.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, commentResp)
+}
+
+// patch /repos/{org}/{owner}/{repo}/pulls/comments/{comment_id}
+func (g *GitRepo) UpdateReviewCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing update review comment Request..")
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+	var commentReq service.ReviewCommentRequest
+	err := json.NewDecoder(r.Body).Decode(&commentReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	commentResp, err := g.serviceFor(r).UpdateReviewComment(vars["org"], vars["owner"], vars["repo"], commentID, &commentReq)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the review comment.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, commentResp)
+}
+
+// delete /repos/{org}/{owner}/{repo}/pulls/comments/{comment_id}
+func (g *GitRepo) DeleteReviewCommentHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing delete review comment Request..")
+	vars := mux.Vars(r)
+	commentID, _ := strconv.Atoi(vars["comment_id"])
+	_, err := g.serviceFor(r).DeleteReviewComment(vars["org"], vars["owner"], vars["repo"], commentID)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while deleting the review comment.", err)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/requested_reviewers
+func (g *GitRepo) ListRequestedReviewersHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing list requested reviewers Request..")
+	vars := mux.Vars(r)
+	reviewersResp, err := g.serviceFor(r).ListRequestedReviewers(vars["org"], vars["owner"], vars["repo"], vars["pull_number"])
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while fetching the requested reviewers.", err)
+		return
+	}
+	g.writeJSON(rw, r, http.StatusOK, reviewersResp)
+}
+
+// post (request) and delete (remove) /repos/{org}/{owner}/{repo}/pulls/{pull_number}/requested_reviewers
+func (g *GitRepo) RequestReviewersHandler(rw http.ResponseWriter, r *http.Request) {
+	g.log(r).Debug("Processing requested reviewers Request..", "method", r.Method)
+	vars := mux.Vars(r)
+	var reviewersReq service.ReviewersRequest
+	err := json.NewDecoder(r.Body).Decode(&reviewersReq)
+	if err != nil {
+		g.log(r).Warn("Error occurred while decoding the request data", "error", err)
+		http.Error(rw, "Error occurred while decoding the request data", http.StatusBadRequest)
+		return
+	}
+	defer r.Body.Close()
+
+	prResp, err := g.serviceFor(r).RequestReviewers(vars["org"], vars["owner"], vars["repo"], vars["pull_number"],
+		&reviewersReq, r.Method == http.MethodDelete)
+	if err != nil {
+		g.apiError(rw, r, "Error occurred while updating the requested reviewers.", err)
+		return
+	}
+	status := http.StatusCreated
+	if r.Method == http.MethodDelete {
+		status = http.StatusOK
+	}
+	g.writeJSON(rw, r, status, prResp)
+}
@@ -0,0 +1,67 @@
+// Package logging sets up the structured logs of the server and carries the id and caller of a request
+// through its context, so every line logged while serving a request can be tied back to it.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type contextKey string
+
+const requestKey = contextKey("request")
+
+// Request is what the logs know of the request a context belongs to. User is filled in once the caller is
+// authenticated, the access log line is written after that.
+type Request struct {
+	ID   string
+	User string
+}
+
+// WithRequest starts the log context of a request.
+func WithRequest(ctx context.Context, id string) (context.Context, *Request) {
+	request := &Request{ID: id}
+	return context.WithValue(ctx, requestKey, request), request
+}
+
+// RequestOf is the request ctx belongs to, nil outside of one.
+func RequestOf(ctx context.Context) *Request {
+	request, _ := ctx.Value(requestKey).(*Request)
+	return request
+}
+
+// SetUser records the authenticated caller of the request ctx belongs to.
+func SetUser(ctx context.Context, login string) {
+	if request := RequestOf(ctx); request != nil {
+		request.User = login
+	}
+}
+
+// For tags the lines of logger with the id of the request ctx belongs to.
+func For(ctx context.Context, logger *slog.Logger) *slog.Logger {
+	if request := RequestOf(ctx); request != nil {
+		return logger.With("request_id", request.ID)
+	}
+	return logger
+}
+
+// New makes a logger writing to w, level is debug, info, warn or error and format text or json.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var logLevel slog.Level
+	err := logLevel.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("unknown log level %q. Specify as debug, info, warn or error", level)
+	}
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q. Specify as text or json", format)
+	}
+}
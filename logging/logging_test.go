@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogging(t *testing.T) {
+	_, err := New(&bytes.Buffer{}, "verbose", "text")
+	assert.Error(t, err)
+	_, err = New(&bytes.Buffer{}, "info", "xml")
+	assert.Error(t, err)
+
+	var out bytes.Buffer
+	logger, err := New(&out, "warn", "json")
+	assert.NoError(t, err)
+	logger.Info("dropped below warn")
+	assert.Empty(t, out.String())
+
+	// outside of a request the logger is left alone.
+	assert.Nil(t, RequestOf(context.Background()))
+	SetUser(context.Background(), "gbuser")
+	assert.Equal(t, logger, For(context.Background(), logger))
+
+	ctx, request := WithRequest(context.Background(), "req-1")
+	SetUser(ctx, "gbuser")
+	assert.Equal(t, &Request{ID: "req-1", User: "gbuser"}, RequestOf(ctx))
+	For(ctx, logger).Warn("Tagged", "repo", "gbrepo")
+	var line map[string]any
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "WARN", line["level"])
+	assert.Equal(t, "Tagged", line["msg"])
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "gbrepo", line["repo"])
+	assert.Equal(t, "gbuser", request.User)
+}
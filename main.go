@@ -1,9 +1,35 @@
 package main
 
 import (
+	"flag"
 	server "gbserver/cmd"
+	"os"
 )
 
 func main() {
+	flag.StringVar(&server.StorageType, "storage", envOrDefault("GB_STORAGE", server.StorageType), "store backend: memory or file")
+	flag.StringVar(&server.StoragePath, "storage-path", envOrDefault("GB_STORAGE_PATH", server.StoragePath), "path of the JSON file used by the file storage")
+	flag.StringVar(&server.FixturePath, "fixture", envOrDefault("GB_FIXTURE", server.FixturePath), "YAML or JSON fixture to seed the store from")
+	flag.BoolVar(&server.AuthEnabled, "auth", envOrDefault("GB_AUTH", "true") != "false", "require an Authorization token on every request")
+	flag.Float64Var(&server.ReqLimit, "rate-limit", server.ReqLimit, "requests per second allowed per token or client address")
+	flag.IntVar(&server.RateLimitStatusCode, "rate-limit-status", server.RateLimitStatusCode, "status code sent once the rate limit is reached, 403 or 429")
+	flag.BoolVar(&server.GitEnabled, "git", envOrDefault("GB_GIT", "true") != "false", "back repositories with bare git repos, needs git installed")
+	flag.StringVar(&server.GitRoot, "git-root", envOrDefault("GB_GIT_ROOT", server.GitRoot), "directory of the bare git repos")
+	flag.StringVar(&server.AssetRoot, "asset-root", envOrDefault("GB_ASSET_ROOT", server.AssetRoot), "directory of the release asset files")
+	flag.StringVar(&server.ProxyMode, "proxy-mode", envOrDefault("GB_PROXY_MODE", server.ProxyMode), "record to pass requests on and write them to the cassette, replay to answer from it")
+	flag.StringVar(&server.Upstream, "upstream", envOrDefault("GB_UPSTREAM", server.Upstream), "API recorded in record mode, empty records the server itself")
+	flag.StringVar(&server.CassettePath, "cassette", envOrDefault("GB_CASSETTE", server.CassettePath), "JSONL file interactions are recorded to and replayed from")
+	flag.StringVar(&server.ReplayMatch, "replay-match", envOrDefault("GB_REPLAY_MATCH", server.ReplayMatch), "what replayed requests are matched on, of method, path, query and body")
+	flag.BoolVar(&server.FaultsEnabled, "faults", envOrDefault("GB_FAULTS", "false") == "true", "inject the faults configured at /_admin/faults or asked for with X-Gb-Fault")
+	flag.StringVar(&server.LogLevel, "log-level", envOrDefault("GB_LOG_LEVEL", server.LogLevel), "least severe level logged: debug, info, warn or error")
+	flag.StringVar(&server.LogFormat, "log-format", envOrDefault("GB_LOG_FORMAT", server.LogFormat), "log format: text or json")
+	flag.Parse()
 	server.StartServer()
 }
+
+func envOrDefault(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,336 @@
+package models
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed fixtures/default.yaml
+var defaultFixture []byte
+
+// shaRegexp is the form of a full git object id.
+var shaRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Fixture is the declarative description of the world the server starts with.
+// It is read from YAML, JSON documents of the same shape work too.
+type Fixture struct {
+	Orgs []OrgFixture `yaml:"orgs"`
+}
+
+type OrgFixture struct {
+	ID    int           `yaml:"id"`
+	Name  string        `yaml:"name"`
+	Users []UserFixture `yaml:"users"`
+	Repos []RepoFixture `yaml:"repos"`
+}
+
+type UserFixture struct {
+	ID        int      `yaml:"id"`
+	Login     string   `yaml:"login"`
+	NodeID    string   `yaml:"node_id"`
+	Type      string   `yaml:"type"`
+	SiteAdmin bool     `yaml:"site_admin"`
+	Tokens    []string `yaml:"tokens"`
+	Email     string   `yaml:"email"`
+	// Role in the org, admin or member (the default).
+	Role string `yaml:"role"`
+}
+
+type RepoFixture struct {
+	ID          int             `yaml:"id"`
+	Name        string          `yaml:"name"`
+	Owner       string          `yaml:"owner"`
+	NodeID      string          `yaml:"node_id"`
+	Description string          `yaml:"description"`
+	Branches    []BranchFixture `yaml:"branches"`
+	Pulls       []PullFixture   `yaml:"pulls"`
+}
+
+type BranchFixture struct {
+	ID        int    `yaml:"id"`
+	Name      string `yaml:"name"`
+	NodeID    string `yaml:"node_id"`
+	SHA       string `yaml:"sha"`
+	Protected bool   `yaml:"protected"`
+}
+
+type PullFixture struct {
+	Number       int    `yaml:"number"`
+	NodeID       string `yaml:"node_id"`
+	Head         string `yaml:"head"`
+	Base         string `yaml:"base"`
+	Author       string `yaml:"author"`
+	State        string `yaml:"state"`
+	Title        string `yaml:"title"`
+	Body         string `yaml:"body"`
+	Commits      int    `yaml:"commits"`
+	Additions    int    `yaml:"additions"`
+	Deletions    int    `yaml:"deletions"`
+	ChangedFiles int    `yaml:"changed_files"`
+}
+
+// Seed builds the store a storage starts from when it has no saved state.
+type Seed func() (*GbStore, error)
+
+// FixtureSeed seeds from the fixture file at path.
+func FixtureSeed(path string) Seed {
+	return func() (*GbStore, error) {
+		return LoadFixture(path)
+	}
+}
+
+func seedOrDefault(seed Seed) (*GbStore, error) {
+	if seed == nil {
+		return NewGbStore(), nil
+	}
+	return seed()
+}
+
+// HashID is the id scheme used for pull requests: a hash of org/owner/repo/number.
+func HashID(data string) string {
+	h := fnv.New64a()
+	h.Write([]byte(data))
+	return strconv.FormatUint(h.Sum64(), 10)
+}
+
+// legacyNodeID builds an id the way github's v3 node ids look, e.g. "010:Repository1".
+func legacyNodeID(typeName string, id string) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%03d:%s%s", len(typeName), typeName, id)))
+}
+
+// LoadFixture reads and validates the fixture file at path.
+func LoadFixture(path string) (*GbStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	gbStore, err := ParseFixture(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return gbStore, nil
+}
+
+// ParseFixture decodes a fixture and builds a store from it. Keys the fixture does not know, e.g.
+// misspelled ones, are refused. Every referential problem found is reported, not only the first one.
+func ParseFixture(data []byte) (*GbStore, error) {
+	var fixture Fixture
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&fixture)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return fixture.Build()
+}
+
+// Build validates the fixture and turns it into a store.
+func (f *Fixture) Build() (*GbStore, error) {
+	gbStore := &GbStore{}
+	gbStore.initMaps()
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	orgIDs := map[int]string{}
+	userIDs := map[int]string{}
+	tokens := map[string]string{}
+	for _, orgFixture := range f.Orgs {
+		if orgFixture.Name == "" {
+			fail("org with id %d has no name", orgFixture.ID)
+			continue
+		}
+		if _, exists := gbStore.Orgs[orgFixture.Name]; exists {
+			fail("org %s is defined twice", orgFixture.Name)
+			continue
+		}
+		if other, exists := orgIDs[orgFixture.ID]; exists {
+			fail("org %s reuses id %d of org %s", orgFixture.Name, orgFixture.ID, other)
+		}
+		orgIDs[orgFixture.ID] = orgFixture.Name
+		org := &Organization{ID: orgFixture.ID, NodeID: legacyNodeID("Organization", strconv.Itoa(orgFixture.ID)),
+			Name: orgFixture.Name, Users: []string{}, Repos: []string{}}
+		gbStore.Orgs[org.Name] = org
+
+		for _, userFixture := range orgFixture.Users {
+			userKey := org.Name + "/" + userFixture.Login
+			if userFixture.Login == "" {
+				fail("org %s: user with id %d has no login", org.Name, userFixture.ID)
+				continue
+			}
+			if _, exists := gbStore.Users[userKey]; exists {
+				fail("org %s: user %s is defined twice", org.Name, userFixture.Login)
+				continue
+			}
+			// the same login may be a member of several orgs, but an id belongs to one login.
+			if other, exists := userIDs[userFixture.ID]; exists && other != userFixture.Login {
+				fail("org %s: user %s reuses id %d of user %s", org.Name, userFixture.Login, userFixture.ID, other)
+			}
+			userIDs[userFixture.ID] = userFixture.Login
+			for _, token := range userFixture.Tokens {
+				if other, exists := tokens[token]; exists && other != userFixture.Login {
+					fail("org %s: user %s reuses a token of user %s", org.Name, userFixture.Login, other)
+				}
+				tokens[token] = userFixture.Login
+			}
+			if userFixture.Role != "" && userFixture.Role != "admin" && userFixture.Role != "member" {
+				fail("org %s: user %s has invalid role %q, use admin or member", org.Name, userFixture.Login, userFixture.Role)
+			}
+			user := &User{ID: userFixture.ID, LoginName: userFixture.Login, OrgID: org.ID, NodeID: userFixture.NodeID,
+				UserType: userFixture.Type, Repos: []string{}, SiteAdmin: userFixture.SiteAdmin, Tokens: userFixture.Tokens,
+				Email: userFixture.Email, Role: userFixture.Role}
+			if user.NodeID == "" {
+				user.NodeID = legacyNodeID("User", strconv.Itoa(user.ID))
+			}
+			if user.UserType == "" {
+				user.UserType = "User"
+			}
+			gbStore.Users[userKey] = user
+			org.Users = append(org.Users, user.LoginName)
+			if _, exists := gbStore.Accounts[user.LoginName]; !exists {
+				gbStore.Accounts[user.LoginName] = &User{ID: user.ID, LoginName: user.LoginName, NodeID: user.NodeID,
+					UserType: user.UserType, Repos: []string{}, SiteAdmin: user.SiteAdmin, Tokens: user.Tokens, Email: user.Email}
+			}
+		}
+
+		repoIDs := map[int]string{}
+		for _, repoFixture := range orgFixture.Repos {
+			owner, ownerExists := gbStore.Users[org.Name+"/"+repoFixture.Owner]
+			if !ownerExists {
+				fail("org %s: repo %s is owned by %q who is not a user of the org", org.Name, repoFixture.Name, repoFixture.Owner)
+				continue
+			}
+			repoKey := org.Name + "/" + owner.LoginName + "/" + repoFixture.Name
+			if repoFixture.Name == "" {
+				fail("org %s: repo with id %d has no name", org.Name, repoFixture.ID)
+				continue
+			}
+			if _, exists := gbStore.Repos[repoKey]; exists {
+				fail("repo %s is defined twice", repoKey)
+				continue
+			}
+			if other, exists := repoIDs[repoFixture.ID]; exists {
+				fail("repo %s reuses id %d of repo %s", repoKey, repoFixture.ID, other)
+			}
+			repoIDs[repoFixture.ID] = repoFixture.Name
+			repo := &Repository{ID: repoFixture.ID, Node_ID: repoFixture.NodeID, Name: repoFixture.Name,
+				Description: repoFixture.Description, OrgName: org.Name, UserName: owner.LoginName, Branches: []string{}}
+			if repo.Node_ID == "" {
+				repo.Node_ID = legacyNodeID("Repository", strconv.Itoa(repo.ID))
+			}
+			gbStore.Repos[repoKey] = repo
+			owner.Repos = append(owner.Repos, repo.Name)
+			org.Repos = append(org.Repos, repo.Name)
+			org.ReposCount = max(org.ReposCount, repo.ID)
+
+			repoURL := "https://api.gbserver.com/repos/" + owner.LoginName + "/" + repo.Name
+			for i, branchFixture := range repoFixture.Branches {
+				branchKey := repoKey + "/" + branchFixture.Name
+				if branchFixture.Name == "" {
+					fail("repo %s: branch %d has no name", repoKey, i+1)
+					continue
+				}
+				if _, exists := gbStore.Branches[branchKey]; exists {
+					fail("repo %s: branch %s is defined twice", repoKey, branchFixture.Name)
+					continue
+				}
+				if branchFixture.SHA == "" {
+					fail("repo %s: branch %s has no sha", repoKey, branchFixture.Name)
+				} else if !shaRegexp.MatchString(branchFixture.SHA) {
+					fail("repo %s: branch %s has sha %q, it must be 40 lowercase hex characters", repoKey, branchFixture.Name, branchFixture.SHA)
+				}
+				branch := &Branch{ID: branchFixture.ID, RepoName: repo.Name, Name: branchFixture.Name, NodeID: branchFixture.NodeID,
+					URL:       repoURL + "/git/refs/heads/" + branchFixture.Name,
+					Protected: branchFixture.Protected,
+					CommitInfo: CommitDetails{SHA: branchFixture.SHA,
+						URL: repoURL + "/git/commits/" + branchFixture.SHA},
+				}
+				if branch.Protected {
+					// protected fixture branches only get github's defaults, no deletions and no force pushes.
+					branch.Protection = &BranchProtection{}
+				}
+				if branch.ID == 0 {
+					branch.ID = i + 1
+				}
+				if branch.NodeID == "" {
+					branch.NodeID = legacyNodeID("Ref", "refs/heads/"+branch.Name)
+				}
+				gbStore.Branches[branchKey] = branch
+				repo.Branches = append(repo.Branches, branch.Name)
+			}
+
+			for _, pullFixture := range repoFixture.Pulls {
+				if pullFixture.Number <= 0 {
+					fail("repo %s: pull request numbers start at 1, got %d", repoKey, pullFixture.Number)
+					continue
+				}
+				prID := HashID(repoKey + "/" + strconv.Itoa(pullFixture.Number))
+				if _, exists := gbStore.PullRequests[prID]; exists {
+					fail("repo %s: pull request #%d is defined twice", repoKey, pullFixture.Number)
+					continue
+				}
+				headBranch, headExists := gbStore.Branches[repoKey+"/"+pullFixture.Head]
+				if !headExists {
+					fail("repo %s: pull request #%d head branch %q does not exist", repoKey, pullFixture.Number, pullFixture.Head)
+				}
+				if _, exists := gbStore.Branches[repoKey+"/"+pullFixture.Base]; !exists {
+					fail("repo %s: pull request #%d base branch %q does not exist", repoKey, pullFixture.Number, pullFixture.Base)
+				}
+				if pullFixture.Head == pullFixture.Base {
+					fail("repo %s: pull request #%d has the same head and base branch", repoKey, pullFixture.Number)
+				}
+				authorLogin := pullFixture.Author
+				if authorLogin == "" {
+					authorLogin = owner.LoginName
+				}
+				author, authorExists := gbStore.Users[org.Name+"/"+authorLogin]
+				if !authorExists {
+					fail("repo %s: pull request #%d author %q is not a user of org %s", repoKey, pullFixture.Number, authorLogin, org.Name)
+					continue
+				}
+				state := pullFixture.State
+				if state == "" {
+					state = "open"
+				}
+				if state != "open" && state != "closed" {
+					fail("repo %s: pull request #%d has invalid state %q", repoKey, pullFixture.Number, state)
+				}
+				if state == "open" && headExists {
+					if headBranch.PullRequestID != "" {
+						fail("repo %s: branch %s already has an open pull request", repoKey, headBranch.Name)
+					}
+					headBranch.PullRequestID = prID
+				}
+				pr := &PullRequest{NodeID: pullFixture.NodeID, URL: repoURL + "/pulls/" + strconv.Itoa(pullFixture.Number),
+					ID: prID, Number: pullFixture.Number, RepoName: repo.Name,
+					FromBranch: owner.LoginName + ":" + pullFixture.Head, ToBranch: pullFixture.Base,
+					AuthorID: author.ID, State: state, Title: pullFixture.Title, Body: pullFixture.Body,
+					Commits: pullFixture.Commits, Additions: pullFixture.Additions, Deletions: pullFixture.Deletions,
+					ChangedFiles: pullFixture.ChangedFiles,
+				}
+				if pr.NodeID == "" {
+					pr.NodeID = legacyNodeID("PullRequest", strconv.Itoa(pr.Number))
+				}
+				gbStore.PullRequests[prID] = pr
+				repo.PrIDs = append(repo.PrIDs, prID)
+				repo.TotalPRs = max(repo.TotalPRs, pr.Number)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return gbStore, nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		wantErr []string
+	}{
+		{
+			name: "Test valid json fixture",
+			fixture: `{"orgs": [{"id": 7, "name": "acme", "users": [{"id": 3, "login": "dev"}],
+				"repos": [{"id": 4, "name": "api", "owner": "dev",
+					"branches": [{"name": "main", "sha": "aa218f56b14c9653891f9e74264a383fa43fefbd"}, {"name": "fix", "sha": "bb218f56b14c9653891f9e74264a383fa43fefbd"}],
+					"pulls": [{"number": 2, "head": "fix", "base": "main", "title": "Fix it"}]}]}]}`,
+		},
+		{
+			name: "Test broken references are all reported",
+			fixture: `
+orgs:
+  - id: 1
+    name: acme
+    users:
+      - id: 1
+        login: dev
+      - id: 1
+        login: ops
+    repos:
+      - id: 1
+        name: api
+        owner: nobody
+      - id: 2
+        name: web
+        owner: dev
+        branches:
+          - name: main
+            sha: aa218f56b14c9653891f9e74264a383fa43fefbd
+          - name: main
+            sha: aa218f56b14c9653891f9e74264a383fa43fefbd
+        pulls:
+          - number: 1
+            head: missing
+            base: main
+            state: merged
+`,
+			wantErr: []string{
+				"user ops reuses id 1 of user dev",
+				"repo api is owned by \"nobody\" who is not a user of the org",
+				"branch main is defined twice",
+				"head branch \"missing\" does not exist",
+				"invalid state \"merged\"",
+			},
+		},
+		{
+			name: "Test misspelled key",
+			fixture: `
+orgs:
+  - id: 1
+    name: acme
+    usrs:
+      - id: 1
+        login: dev
+`,
+			wantErr: []string{"field usrs not found"},
+		},
+		{
+			name: "Test sha that is not hex",
+			fixture: `
+orgs:
+  - id: 1
+    name: acme
+    users:
+      - id: 1
+        login: dev
+    repos:
+      - id: 1
+        name: api
+        owner: dev
+        branches:
+          - name: main
+            sha: bchdjsd9jdowjd29ejiwd8y3hd3a383fa43fefbd
+          - name: short
+            sha: aa218f56
+`,
+			wantErr: []string{
+				"branch main has sha \"bchdjsd9jdowjd29ejiwd8y3hd3a383fa43fefbd\"",
+				"branch short has sha \"aa218f56\"",
+			},
+		},
+	}
+	for _, tt := range tests {
+		gbStore, err := ParseFixture([]byte(tt.fixture))
+		if tt.wantErr != nil {
+			assert.Error(t, err, tt.name)
+			for _, wantErr := range tt.wantErr {
+				assert.Contains(t, err.Error(), wantErr, tt.name)
+			}
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, 4, gbStore.Orgs["acme"].ReposCount)
+		assert.Equal(t, []string{"api"}, gbStore.Users["acme/dev"].Repos)
+		assert.Equal(t, 2, gbStore.Repos["acme/dev/api"].TotalPRs)
+		prID := HashID("acme/dev/api/2")
+		assert.Equal(t, prID, gbStore.Branches["acme/dev/api/fix"].PullRequestID)
+		assert.Equal(t, "dev:fix", gbStore.PullRequests[prID].FromBranch)
+		assert.Equal(t, 3, gbStore.PullRequests[prID].AuthorID)
+	}
+}
+
+func TestLoadFixture(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("orgs:\n  - id: 1\n    name: solo\n"), 0o644))
+
+	gbStore, err := LoadFixture(path)
+	assert.NoError(t, err)
+	assert.Contains(t, gbStore.Orgs, "solo")
+
+	_, err = LoadFixture(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
@@ -2,6 +2,8 @@ package models
 
 import "sync"
 
+// User is the membership of a login in an org, keyed org/login in the store. The same login may be a
+// member of several orgs, GbStore.Accounts holds it once whether or not it belongs to any.
 type User struct {
 	ID        int      `json:"id"`
 	LoginName string   `json:"name"`
@@ -9,10 +11,16 @@ type User struct {
 	NodeID    string   `json:"nodeId"`
 	UserType  string   `json:"type"`
 	Repos     []string `json:"repos"`
+	SiteAdmin bool     `json:"site_admin"`
+	Tokens    []string `json:"tokens"`
+	Email     string   `json:"email"`
+	// Role in the org, admin or member. Empty is a member.
+	Role string `json:"role"`
 }
 
 type Organization struct {
 	ID         int      `json:"id"`
+	NodeID     string   `json:"node_id"`
 	Name       string   `json:"name"`
 	Users      []string `json:"users"`
 	Repos      []string `json:"repos"`
@@ -27,8 +35,11 @@ type Repository struct {
 	OrgName     string   `json:"org_name"`
 	UserName    string   `json:"user_name"`
 	Branches    []string `json:"branches"`
-	TotalPRs    int
-	PrIDs       []string
+	// TotalPRs is the last number handed out, issues and pull requests share the sequence.
+	TotalPRs int
+	PrIDs    []string
+	IssueIDs []string `json:"issue_ids"`
+	Tags     []string `json:"tags"`
 }
 
 type CommitDetails struct {
@@ -45,63 +56,394 @@ type Branch struct {
 	Protected     bool   `json:"protected"`
 	CommitInfo    CommitDetails
 	PullRequestID string // should be random characters encoded characters of orgname + owner+reponame + prid
+	// Protection holds the rules of a protected branch, it is set exactly when Protected is.
+	Protection *BranchProtection `json:"protection,omitempty"`
+}
+
+// BranchProtection is what github enforces on a protected branch. Deletions and force pushes are refused
+// unless allowed, admins are let through unless EnforceAdmins.
+type BranchProtection struct {
+	// RequiredContexts are the statuses or check runs that have to pass on the commit.
+	RequireStatusChecks bool     `json:"require_status_checks"`
+	RequiredContexts    []string `json:"required_contexts"`
+	// Strict requires the head of a pull request to be up to date with the branch.
+	Strict bool `json:"strict"`
+	// RequirePullRequestReviews only lets changes in through reviewed pull requests.
+	RequirePullRequestReviews    bool `json:"require_pull_request_reviews"`
+	RequiredApprovingReviewCount int  `json:"required_approving_review_count"`
+	EnforceAdmins                bool `json:"enforce_admins"`
+	// PushUsers are the only logins allowed to push when RestrictPushes is set.
+	RestrictPushes   bool     `json:"restrict_pushes"`
+	PushUsers        []string `json:"push_users"`
+	AllowForcePushes bool     `json:"allow_force_pushes"`
+	AllowDeletions   bool     `json:"allow_deletions"`
 }
 
 type PullRequest struct {
-	NodeID       string `json:"nodeID"`
-	URL          string `json:"url"`
-	ID           string `json:"id"`
-	RepoName     string `json:"repo_name"`
-	FromBranch   string `json:"from_branch"`
-	ToBranch     string `json:"to_branch"`
-	AuthorID     int    `json:"author_id"`
-	State        string `json:"status"`
-	Title        string `json:"title"`
-	Body         string `json:"body"`
-	Commits      int    `json:"commits"`
-	Additions    int    `json:"additions"`
-	Deletions    int    `json:"deletions"`
-	ChangedFiles int    `json:"changed_files"`
+	NodeID         string `json:"nodeID"`
+	URL            string `json:"url"`
+	ID             string `json:"id"`
+	Number         int    `json:"number"`
+	RepoName       string `json:"repo_name"`
+	FromBranch     string `json:"from_branch"`
+	ToBranch       string `json:"to_branch"`
+	AuthorID       int    `json:"author_id"`
+	State          string `json:"status"`
+	Title          string `json:"title"`
+	Body           string `json:"body"`
+	Commits        int    `json:"commits"`
+	Additions      int    `json:"additions"`
+	Deletions      int    `json:"deletions"`
+	ChangedFiles   int    `json:"changed_files"`
+	Merged         bool   `json:"merged"`
+	MergedAt       string `json:"merged_at"`
+	MergedByID     int    `json:"merged_by_id"`
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	// RequestedReviewers are logins, a reviewer leaves the list by submitting a review.
+	RequestedReviewers []string `json:"requested_reviewers"`
+	ReviewIDs          []int    `json:"review_ids"`
+	ReviewCommentIDs   []int    `json:"review_comment_ids"`
+}
+
+// Issue ids are built like pull request ids, from org/owner/repo/number.
+type Issue struct {
+	ID          string   `json:"id"`
+	NodeID      string   `json:"node_id"`
+	URL         string   `json:"url"`
+	Number      int      `json:"number"`
+	RepoKey     string   `json:"repo_key"`
+	Title       string   `json:"title"`
+	Body        string   `json:"body"`
+	State       string   `json:"state"`
+	StateReason string   `json:"state_reason"`
+	AuthorID    int      `json:"author_id"`
+	Labels      []string `json:"labels"`
+	Assignees   []string `json:"assignees"`
+	CommentIDs  []int    `json:"comment_ids"`
+	Locked      bool     `json:"locked"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+	ClosedAt    string   `json:"closed_at"`
+	ClosedByID  int      `json:"closed_by_id"`
+}
+
+type IssueComment struct {
+	ID        int    `json:"id"`
+	NodeID    string `json:"node_id"`
+	IssueID   string `json:"issue_id"`
+	AuthorID  int    `json:"author_id"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Review is a pull request review, State is PENDING until it is submitted.
+type Review struct {
+	ID            int    `json:"id"`
+	NodeID        string `json:"node_id"`
+	PullRequestID string `json:"pull_request_id"`
+	AuthorID      int    `json:"author_id"`
+	Body          string `json:"body"`
+	State         string `json:"state"`
+	CommitID      string `json:"commit_id"`
+	SubmittedAt   string `json:"submitted_at"`
+}
+
+// ReviewComment is a comment on a line of a pull request's diff, ReviewID is 0 for a reply outside of a review.
+type ReviewComment struct {
+	ID            int    `json:"id"`
+	NodeID        string `json:"node_id"`
+	PullRequestID string `json:"pull_request_id"`
+	ReviewID      int    `json:"review_id"`
+	AuthorID      int    `json:"author_id"`
+	Body          string `json:"body"`
+	Path          string `json:"path"`
+	Position      int    `json:"position"`
+	Line          int    `json:"line"`
+	Side          string `json:"side"`
+	CommitID      string `json:"commit_id"`
+	InReplyToID   int    `json:"in_reply_to_id"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// CommitStatus is a status posted for a commit, the latest one per Context counts.
+type CommitStatus struct {
+	ID          int    `json:"id"`
+	NodeID      string `json:"node_id"`
+	RepoKey     string `json:"repo_key"`
+	SHA         string `json:"sha"`
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+	CreatorID   int    `json:"creator_id"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// CheckSuite groups the check runs of a commit, its status and conclusion follow from the runs.
+type CheckSuite struct {
+	ID         int    `json:"id"`
+	NodeID     string `json:"node_id"`
+	RepoKey    string `json:"repo_key"`
+	HeadSHA    string `json:"head_sha"`
+	HeadBranch string `json:"head_branch"`
+	CreatedAt  string `json:"created_at"`
+	UpdatedAt  string `json:"updated_at"`
+}
+
+type CheckRun struct {
+	ID            int    `json:"id"`
+	NodeID        string `json:"node_id"`
+	RepoKey       string `json:"repo_key"`
+	CheckSuiteID  int    `json:"check_suite_id"`
+	HeadSHA       string `json:"head_sha"`
+	Name          string `json:"name"`
+	Status        string `json:"status"`
+	Conclusion    string `json:"conclusion"`
+	ExternalID    string `json:"external_id"`
+	DetailsURL    string `json:"details_url"`
+	StartedAt     string `json:"started_at"`
+	CompletedAt   string `json:"completed_at"`
+	OutputTitle   string `json:"output_title"`
+	OutputSummary string `json:"output_summary"`
+	OutputText    string `json:"output_text"`
+}
+
+// Label belongs to a repository, it is keyed by org/owner/repo/lowercased name.
+type Label struct {
+	ID          int    `json:"id"`
+	NodeID      string `json:"node_id"`
+	RepoKey     string `json:"repo_key"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// Tag is a ref under refs/tags, SHA is a commit for a lightweight tag and a TagObject for an annotated one.
+type Tag struct {
+	Name   string `json:"name"`
+	NodeID string `json:"node_id"`
+	SHA    string `json:"sha"`
+}
+
+// TagObject is an annotated tag, keyed by repo and SHA in the store.
+type TagObject struct {
+	SHA         string `json:"sha"`
+	NodeID      string `json:"node_id"`
+	Tag         string `json:"tag"`
+	Message     string `json:"message"`
+	Object      string `json:"object"`
+	Type        string `json:"type"`
+	TaggerName  string `json:"tagger_name"`
+	TaggerEmail string `json:"tagger_email"`
+	TaggedAt    string `json:"tagged_at"`
+}
+
+// Release is published for a tag, its tag is only created once it stops being a draft.
+type Release struct {
+	ID              int    `json:"id"`
+	NodeID          string `json:"node_id"`
+	RepoKey         string `json:"repo_key"`
+	TagName         string `json:"tag_name"`
+	TargetCommitish string `json:"target_commitish"`
+	Name            string `json:"name"`
+	Body            string `json:"body"`
+	Draft           bool   `json:"draft"`
+	Prerelease      bool   `json:"prerelease"`
+	AuthorID        int    `json:"author_id"`
+	CreatedAt       string `json:"created_at"`
+	PublishedAt     string `json:"published_at"`
+	AssetIDs        []int  `json:"asset_ids"`
+}
+
+// ReleaseAsset is a file of a release, its content is kept outside the store, see service.AssetStore.
+type ReleaseAsset struct {
+	ID            int    `json:"id"`
+	NodeID        string `json:"node_id"`
+	ReleaseID     int    `json:"release_id"`
+	Name          string `json:"name"`
+	Label         string `json:"label"`
+	ContentType   string `json:"content_type"`
+	Size          int64  `json:"size"`
+	DownloadCount int    `json:"download_count"`
+	UploaderID    int    `json:"uploader_id"`
+	CreatedAt     string `json:"created_at"`
+	UpdatedAt     string `json:"updated_at"`
+}
+
+// Hook is a webhook on a repository, or on a whole organization when RepoKey is empty.
+type Hook struct {
+	ID          int      `json:"id"`
+	OrgName     string   `json:"org_name"`
+	RepoKey     string   `json:"repo_key"`
+	URL         string   `json:"url"`
+	ContentType string   `json:"content_type"`
+	Secret      string   `json:"secret"`
+	InsecureSSL string   `json:"insecure_ssl"`
+	Events      []string `json:"events"`
+	Active      bool     `json:"active"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+// HookDelivery is one event sent to a hook, with every retry folded into Attempts.
+type HookDelivery struct {
+	ID              int               `json:"id"`
+	GUID            string            `json:"guid"`
+	HookID          int               `json:"hook_id"`
+	Event           string            `json:"event"`
+	Action          string            `json:"action"`
+	DeliveredAt     string            `json:"delivered_at"`
+	Redelivery      bool              `json:"redelivery"`
+	Duration        float64           `json:"duration"`
+	Status          string            `json:"status"`
+	StatusCode      int               `json:"status_code"`
+	Attempts        int               `json:"attempts"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	Payload         string            `json:"payload"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body"`
 }
 
 type GbStore struct {
-	MU           sync.RWMutex
-	Users        map[string]*User
-	Orgs         map[string]*Organization
-	Repos        map[string]*Repository
-	Branches     map[string]*Branch
-	PullRequests map[string]*PullRequest
+	MU             sync.RWMutex               `json:"-"`
+	Users          map[string]*User           `json:"users"`
+	Accounts       map[string]*User           `json:"accounts"`
+	Orgs           map[string]*Organization   `json:"orgs"`
+	Repos          map[string]*Repository     `json:"repos"`
+	Branches       map[string]*Branch         `json:"branches"`
+	PullRequests   map[string]*PullRequest    `json:"pull_requests"`
+	Hooks          map[string]*Hook           `json:"hooks"`
+	HookDeliveries map[string][]*HookDelivery `json:"hook_deliveries"`
+	HooksCount     int                        `json:"hooks_count"`
+	DeliveryCount  int                        `json:"delivery_count"`
+	Issues         map[string]*Issue          `json:"issues"`
+	IssueComments  map[string]*IssueComment   `json:"issue_comments"`
+	Labels         map[string]*Label          `json:"labels"`
+	CommentsCount  int                        `json:"comments_count"`
+	LabelsCount    int                        `json:"labels_count"`
+	Reviews        map[string]*Review         `json:"reviews"`
+	ReviewComments map[string]*ReviewComment  `json:"review_comments"`
+	ReviewsCount   int                        `json:"reviews_count"`
+	Statuses       map[string]*CommitStatus   `json:"statuses"`
+	CheckSuites    map[string]*CheckSuite     `json:"check_suites"`
+	CheckRuns      map[string]*CheckRun       `json:"check_runs"`
+	StatusesCount  int                        `json:"statuses_count"`
+	ChecksCount    int                        `json:"checks_count"`
+	Tags           map[string]*Tag            `json:"tags"`
+	TagObjects     map[string]*TagObject      `json:"tag_objects"`
+	Releases       map[string]*Release        `json:"releases"`
+	ReleaseAssets  map[string]*ReleaseAsset   `json:"release_assets"`
+	ReleasesCount  int                        `json:"releases_count"`
+	AssetsCount    int                        `json:"assets_count"`
 }
 
-func NewGbStore() *GbStore {
-	gbStore := &GbStore{
-		Users:        make(map[string]*User),
-		Orgs:         make(map[string]*Organization),
-		Repos:        make(map[string]*Repository),
-		Branches:     make(map[string]*Branch),
-		PullRequests: make(map[string]*PullRequest),
-	}
-
-	gbStore.Users["gborg/gbuser"] = &User{ID: 1, LoginName: "gbuser", OrgID: 1, NodeID: "MDQ6VXNlcjE=", UserType: "User", Repos: []string{"gbrepo"}}
-	gbStore.Orgs["gborg"] = &Organization{ID: 1, Name: "gborg", Users: []string{"gbuser"}, Repos: []string{"gbrepo"}, ReposCount: 1}
-	gbStore.Repos["gborg/gbuser/gbrepo"] = &Repository{ID: 1, Name: "gbrepo", Node_ID: "MDEwOlJlcG9zaXRvcnkxMjk2MjY5", Description: "gbuser repo",
-		OrgName: "gborg", UserName: "gbuser", Branches: []string{"master", "gbbranch"}, TotalPRs: 1, PrIDs: []string{"1534407926273468195"}}
-	gbStore.Branches["gborg/gbuser/gbrepo/gbbranch"] = &Branch{ID: 1, RepoName: "gbrepo", Name: "gbbranch", NodeID: "NOSKDK8SDJSDHSD92KDkcy9mZWF0dXJlQQ==", URL: "https://api.gbserver.com/repos/gbuser/gbrepo/git/refs/heads/gbbranch",
-		CommitInfo:    CommitDetails{SHA: "bchdjsd9jdowjd29ejiwd8y3hd3a383fa43fefbd", URL: "https://api.gbserver.com/repos/gbuser/gbrepo/git/commits/bchdjsd9jdowjd29ejiwd8y3hd3a383fa43fefbd"},
-		PullRequestID: "1534407926273468195",
-	}
-	gbStore.Branches["gborg/gbuser/gbrepo/master"] = &Branch{ID: 2, RepoName: "gbrepo", Name: "master", NodeID: "MDM6UmVmcmVmcy9oZWFkcy9mZWF0dXJlQQ==", URL: "https://api.gbserver.com/repos/gbuser/gbrepo/git/refs/heads/master",
-		CommitInfo: CommitDetails{SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd", URL: "https://api.gbserver.com/repos/gbuser/gbrepo/git/commits/aa218f56b14c9653891f9e74264a383fa43fefbd"}}
-	gbStore.PullRequests["1534407926273468195"] = &PullRequest{ID: "1534407926273468195", NodeID: "MDExOlB1bGxSZXF1ZXN0MQ==",
-		URL:      "https://api.gbserver.com/repos/gbuser/gbrepo/pulls/1",
-		RepoName: "gbrepo", FromBranch: "gbuser:gbbranch",
-		ToBranch: "master", AuthorID: 1, State: "open", Commits: 10,
-		Title:        "Amazing new feature",
-		Body:         "Please pull these awesome changes in!",
-		Additions:    100,
-		Deletions:    7,
-		ChangedFiles: 23,
+// initMaps makes sure a decoded store has no nil maps.
+func (s *GbStore) initMaps() {
+	if s.Users == nil {
+		s.Users = make(map[string]*User)
+	}
+	if s.Accounts == nil {
+		s.Accounts = make(map[string]*User)
+	}
+	if s.Orgs == nil {
+		s.Orgs = make(map[string]*Organization)
+	}
+	if s.Repos == nil {
+		s.Repos = make(map[string]*Repository)
+	}
+	if s.Branches == nil {
+		s.Branches = make(map[string]*Branch)
+	}
+	if s.PullRequests == nil {
+		s.PullRequests = make(map[string]*PullRequest)
+	}
+	if s.Hooks == nil {
+		s.Hooks = make(map[string]*Hook)
+	}
+	if s.HookDeliveries == nil {
+		s.HookDeliveries = make(map[string][]*HookDelivery)
+	}
+	if s.Issues == nil {
+		s.Issues = make(map[string]*Issue)
+	}
+	if s.IssueComments == nil {
+		s.IssueComments = make(map[string]*IssueComment)
 	}
+	if s.Labels == nil {
+		s.Labels = make(map[string]*Label)
+	}
+	if s.Reviews == nil {
+		s.Reviews = make(map[string]*Review)
+	}
+	if s.ReviewComments == nil {
+		s.ReviewComments = make(map[string]*ReviewComment)
+	}
+	if s.Statuses == nil {
+		s.Statuses = make(map[string]*CommitStatus)
+	}
+	if s.CheckSuites == nil {
+		s.CheckSuites = make(map[string]*CheckSuite)
+	}
+	if s.CheckRuns == nil {
+		s.CheckRuns = make(map[string]*CheckRun)
+	}
+	if s.Tags == nil {
+		s.Tags = make(map[string]*Tag)
+	}
+	if s.TagObjects == nil {
+		s.TagObjects = make(map[string]*TagObject)
+	}
+	if s.Releases == nil {
+		s.Releases = make(map[string]*Release)
+	}
+	if s.ReleaseAssets == nil {
+		s.ReleaseAssets = make(map[string]*ReleaseAsset)
+	}
+}
 
+// Replace swaps the content of the store for the content of other.
+// Caller must hold the write lock of s, other must not be in use anymore.
+func (s *GbStore) Replace(other *GbStore) {
+	other.initMaps()
+	s.Users = other.Users
+	s.Orgs = other.Orgs
+	s.Accounts = other.Accounts
+	s.Repos = other.Repos
+	s.Branches = other.Branches
+	s.PullRequests = other.PullRequests
+	s.Hooks = other.Hooks
+	s.HookDeliveries = other.HookDeliveries
+	s.HooksCount = other.HooksCount
+	s.DeliveryCount = other.DeliveryCount
+	s.Issues = other.Issues
+	s.IssueComments = other.IssueComments
+	s.Labels = other.Labels
+	s.CommentsCount = other.CommentsCount
+	s.LabelsCount = other.LabelsCount
+	s.Reviews = other.Reviews
+	s.ReviewComments = other.ReviewComments
+	s.ReviewsCount = other.ReviewsCount
+	s.Statuses = other.Statuses
+	s.CheckSuites = other.CheckSuites
+	s.CheckRuns = other.CheckRuns
+	s.StatusesCount = other.StatusesCount
+	s.ChecksCount = other.ChecksCount
+	s.Tags = other.Tags
+	s.TagObjects = other.TagObjects
+	s.Releases = other.Releases
+	s.ReleaseAssets = other.ReleaseAssets
+	s.ReleasesCount = other.ReleasesCount
+	s.AssetsCount = other.AssetsCount
+}
+
+// NewGbStore returns a store seeded with the built in default fixture.
+func NewGbStore() *GbStore {
+	gbStore, err := ParseFixture(defaultFixture)
+	if err != nil {
+		panic("default fixture is invalid: " + err.Error())
+	}
 	return gbStore
 }
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Storage keeps a GbStore across server restarts as a write-through snapshot: the service works on
+// the in-memory maps and hands the whole store to Save after every change, Load is only read on start.
+type Storage interface {
+	Load() (*GbStore, error)
+	Save(gbStore *GbStore) error
+}
+
+// MemoryStorage is the default storage, state lives only as long as the process.
+type MemoryStorage struct {
+	Seed Seed
+}
+
+// NewMemoryStorage starts every run from seed, nil means the default fixture.
+func NewMemoryStorage(seed Seed) *MemoryStorage {
+	return &MemoryStorage{Seed: seed}
+}
+
+func (m *MemoryStorage) Load() (*GbStore, error) {
+	return seedOrDefault(m.Seed)
+}
+
+func (m *MemoryStorage) Save(_ *GbStore) error {
+	return nil
+}
+
+// FileStorage writes the store as a single JSON document. Writes go to a temp
+// file which is renamed over the old one, so a crash never leaves half a file.
+type FileStorage struct {
+	mu   sync.Mutex
+	Path string
+	Seed Seed
+}
+
+// NewFileStorage keeps the store at path, seed is only used while the file does not exist yet.
+func NewFileStorage(path string, seed Seed) *FileStorage {
+	return &FileStorage{Path: path, Seed: seed}
+}
+
+// Load reads the store from disk. A missing file starts from the seed data.
+func (f *FileStorage) Load() (*GbStore, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return seedOrDefault(f.Seed)
+	}
+	if err != nil {
+		return nil, err
+	}
+	gbStore := &GbStore{}
+	err = json.Unmarshal(data, gbStore)
+	if err != nil {
+		return nil, err
+	}
+	gbStore.initMaps()
+	return gbStore, nil
+}
+
+// Save must be called with at least a read lock held on gbStore.MU.
+func (f *FileStorage) Save(gbStore *GbStore) error {
+	data, err := json.Marshal(gbStore)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if dir := filepath.Dir(f.Path); dir != "" {
+		err = os.MkdirAll(dir, 0o755)
+		if err != nil {
+			return err
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
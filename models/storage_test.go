@@ -0,0 +1,28 @@
+package models
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStorage(t *testing.T) {
+	storage := NewFileStorage(filepath.Join(t.TempDir(), "data", "gbstore.json"), nil)
+
+	// missing file starts from the seed data.
+	gbStore, err := storage.Load()
+	assert.NoError(t, err)
+	assert.Contains(t, gbStore.Repos, "gborg/gbuser/gbrepo")
+
+	gbStore.Repos["gborg/gbuser/newrepo"] = &Repository{ID: 2, Name: "newrepo", OrgName: "gborg", UserName: "gbuser", TotalPRs: 3, PrIDs: []string{"1"}}
+	delete(gbStore.Branches, "gborg/gbuser/gbrepo/gbbranch")
+	assert.NoError(t, storage.Save(gbStore))
+
+	reloaded, err := NewFileStorage(storage.Path, nil).Load()
+	assert.NoError(t, err)
+	assert.Equal(t, gbStore.Repos["gborg/gbuser/newrepo"], reloaded.Repos["gborg/gbuser/newrepo"])
+	assert.NotContains(t, reloaded.Branches, "gborg/gbuser/gbrepo/gbbranch")
+	assert.Equal(t, gbStore.PullRequests, reloaded.PullRequests)
+	assert.Equal(t, gbStore.Users, reloaded.Users)
+}
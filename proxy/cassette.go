@@ -0,0 +1,121 @@
+// Package proxy records the traffic of an API into a cassette and replays it, so the behavior of a real
+// upstream can be frozen into offline fixtures. A cassette is a JSONL file, one interaction per line.
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// redactedHeaders carry credentials, they are not written to cassettes, neither for requests nor for responses.
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// Interaction is a request and the response it got.
+type Interaction struct {
+	Request    Request  `json:"request"`
+	Response   Response `json:"response"`
+	RecordedAt string   `json:"recorded_at"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	// BodyEncoding is base64 for bodies that are not text, e.g. git packs.
+	BodyEncoding string `json:"body_encoding,omitempty"`
+}
+
+type Response struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// encodeBody keeps text bodies readable in the cassette.
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
+
+func redact(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range redactedHeaders {
+		if header.Get(name) != "" {
+			header.Set(name, "REDACTED")
+		}
+	}
+	return header
+}
+
+// LoadCassette reads every interaction of a cassette.
+func LoadCassette(path string) ([]Interaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	interactions := []Interaction{}
+	decoder := json.NewDecoder(file)
+	for {
+		var interaction Interaction
+		err := decoder.Decode(&interaction)
+		if errors.Is(err, io.EOF) {
+			return interactions, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cassette %s, interaction %d: %w", path, len(interactions)+1, err)
+		}
+		interactions = append(interactions, interaction)
+	}
+}
+
+// CassetteWriter appends interactions to a cassette, it is safe for concurrent use.
+type CassetteWriter struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewCassetteWriter opens path for appending, so recording sessions add up.
+func NewCassetteWriter(path string) (*CassetteWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &CassetteWriter{file: file}, nil
+}
+
+// Write adds an interaction as one line.
+func (c *CassetteWriter) Write(interaction Interaction) error {
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+func (c *CassetteWriter) Close() error {
+	return c.file.Close()
+}
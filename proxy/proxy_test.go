@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// record sends the requests through a recorder in front of upstream and hands back the cassette.
+func record(t *testing.T, upstream http.Handler, requests []*http.Request) []Interaction {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	cassette, err := NewCassetteWriter(path)
+	assert.NoError(t, err)
+	recorder := NewRecorder(upstream, cassette)
+	for _, req := range requests {
+		recorder.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.NoError(t, cassette.Close())
+	interactions, err := LoadCassette(path)
+	assert.NoError(t, err)
+	return interactions
+}
+
+func replay(replayer *Replayer, req *http.Request) (int, string) {
+	resp := httptest.NewRecorder()
+	replayer.ServeHTTP(resp, req)
+	return resp.Code, resp.Body.String()
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	// the upstream counts, so repeated requests get different answers.
+	count := 0
+	upstreamServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		count++
+		rw.Header().Set("X-Count", strings.Repeat("+", count))
+		rw.Header().Set("Set-Cookie", "session=secret")
+		if r.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+		rw.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + string(body)))
+	}))
+	defer upstreamServer.Close()
+	upstream, err := NewUpstream(upstreamServer.URL)
+	assert.NoError(t, err)
+	_, err = NewUpstream("localhost")
+	assert.Error(t, err)
+
+	authorized := httptest.NewRequest(http.MethodGet, "/repos?page=1&per_page=2", nil)
+	authorized.Header.Set("Authorization", "token secret")
+	authorized.Header.Set("Accept-Encoding", "gzip")
+	interactions := record(t, upstream, []*http.Request{
+		authorized,
+		httptest.NewRequest(http.MethodGet, "/repos?page=1&per_page=2", nil),
+		httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"name":"a","private":false}`)),
+		httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"name":"b"}`)),
+		httptest.NewRequest(http.MethodGet, "/missing", nil),
+		httptest.NewRequest(http.MethodPost, "/pack", strings.NewReader("\xff\xfe")),
+	})
+	assert.Len(t, interactions, 6)
+	assert.Equal(t, "REDACTED", interactions[0].Request.Header.Get("Authorization"))
+	assert.Equal(t, "page=1&per_page=2", interactions[0].Request.Query)
+	assert.Equal(t, "GET /repos?page=1&per_page=2 ", interactions[0].Response.Body)
+	assert.Equal(t, "+", interactions[0].Response.Header.Get("X-Count"))
+	assert.Equal(t, "REDACTED", interactions[0].Response.Header.Get("Set-Cookie"))
+	assert.Equal(t, http.StatusNotFound, interactions[4].Response.Status)
+	assert.Equal(t, "base64", interactions[5].Request.BodyEncoding)
+
+	_, err = ParseMatch("method,headers")
+	assert.Error(t, err)
+	match, err := ParseMatch("method, path,query")
+	assert.NoError(t, err)
+	replayer := NewReplayer(interactions, match)
+
+	// matches are served in recorded order, the last one over again, whatever the order of the query.
+	resp := httptest.NewRecorder()
+	replayer.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/repos?per_page=2&page=1", nil))
+	assert.Equal(t, "+", resp.Header().Get("X-Count"))
+	resp = httptest.NewRecorder()
+	replayer.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/repos?per_page=2&page=1", nil))
+	assert.Equal(t, "++", resp.Header().Get("X-Count"))
+	resp = httptest.NewRecorder()
+	replayer.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/repos?per_page=2&page=1", nil))
+	assert.Equal(t, "++", resp.Header().Get("X-Count"))
+
+	code, body := replay(replayer, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "GET /missing ", body)
+	code, body = replay(replayer, httptest.NewRequest(http.MethodGet, "/repos?page=2", nil))
+	assert.Equal(t, http.StatusNotImplemented, code)
+	assert.Contains(t, body, "No recorded interaction matches GET /repos?page=2")
+	code, body = replay(replayer, httptest.NewRequest(http.MethodPost, "/pack", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "POST /pack \xff\xfe", body)
+
+	// with the body in the match json bodies are compared by value.
+	match, _ = ParseMatch("method,path,body")
+	replayer = NewReplayer(interactions, match)
+	_, body = replay(replayer, httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"name": "b"}`)))
+	assert.Equal(t, `POST /repos {"name":"b"}`, body)
+	_, body = replay(replayer, httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"private":false,"name":"a"}`)))
+	assert.Equal(t, `POST /repos {"name":"a","private":false}`, body)
+	code, _ = replay(replayer, httptest.NewRequest(http.MethodPost, "/repos", strings.NewReader(`{"name":"c"}`)))
+	assert.Equal(t, http.StatusNotImplemented, code)
+}
+
+func TestRecorderPassesFlushes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	cassette, err := NewCassetteWriter(path)
+	assert.NoError(t, err)
+	recorder := NewRecorder(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Set-Cookie", "session=secret")
+		rw.Write([]byte("streamed"))
+		rw.(http.Flusher).Flush()
+		// the test recorder cannot be hijacked, the error comes from underneath.
+		_, _, err := http.NewResponseController(rw).Hijack()
+		assert.ErrorIs(t, err, http.ErrNotSupported)
+	}), cassette)
+	resp := httptest.NewRecorder()
+	recorder.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.True(t, resp.Flushed)
+	// only the cassette is redacted, the client still gets its cookie.
+	assert.Equal(t, "session=secret", resp.Header().Get("Set-Cookie"))
+	assert.NoError(t, cassette.Close())
+	interactions, err := LoadCassette(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "streamed", interactions[0].Response.Body)
+	assert.Equal(t, "REDACTED", interactions[0].Response.Header.Get("Set-Cookie"))
+}
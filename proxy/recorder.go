@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"gbserver/logging"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+)
+
+// NewUpstream is a reverse proxy to the API at upstream, e.g. https://api.github.com.
+func NewUpstream(upstream string) (http.Handler, error) {
+	upstreamURL, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if upstreamURL.Scheme == "" || upstreamURL.Host == "" {
+		return nil, fmt.Errorf("invalid upstream %q. Specify as scheme://host", upstream)
+	}
+	reverseProxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	director := reverseProxy.Director
+	reverseProxy.Director = func(r *http.Request) {
+		director(r)
+		// virtual hosted APIs answer by Host.
+		r.Host = upstreamURL.Host
+		// the transport asks for gzip itself and unpacks it, so the cassette gets plain bodies.
+		r.Header.Del("Accept-Encoding")
+	}
+	return reverseProxy, nil
+}
+
+// captureWriter keeps a copy of the response while it is written. Flushes and hijacks go through to the
+// connection underneath, so streamed responses are not held back by the recorder.
+type captureWriter struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	hijacked bool
+}
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *captureWriter) Write(data []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(data)
+	return c.ResponseWriter.Write(data)
+}
+
+func (c *captureWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (c *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := c.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	c.hijacked = true
+	return hijacker.Hijack()
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Recorder passes every request on to Next and writes it to the cassette along with the response.
+type Recorder struct {
+	Next     http.Handler
+	Cassette *CassetteWriter
+}
+
+func NewRecorder(next http.Handler, cassette *CassetteWriter) *Recorder {
+	return &Recorder{Next: next, Cassette: cassette}
+}
+
+func (rec *Recorder) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	requestBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, "Error occurred while reading the request data", http.StatusBadRequest)
+		return
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(requestBody))
+	recorded := Request{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: redact(r.Header)}
+	recorded.Body, recorded.BodyEncoding = encodeBody(requestBody)
+
+	capture := &captureWriter{ResponseWriter: rw}
+	rec.Next.ServeHTTP(capture, r)
+	if capture.hijacked {
+		// the connection left HTTP, there is no response to replay.
+		logging.For(r.Context(), slog.Default()).Warn("Not recording a hijacked connection.", "method", r.Method, "path", r.URL.Path)
+		return
+	}
+	if capture.status == 0 {
+		capture.status = http.StatusOK
+	}
+
+	response := Response{Status: capture.status, Header: redact(rw.Header())}
+	response.Body, response.BodyEncoding = encodeBody(capture.body.Bytes())
+	err = rec.Cassette.Write(Interaction{Request: recorded, Response: response, RecordedAt: time.Now().UTC().Format(time.RFC3339)})
+	if err != nil {
+		logging.For(r.Context(), slog.Default()).Error("Error occurred while writing the cassette.", "error", err)
+	}
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Match selects the parts of a request that must equal the recorded one for its response to be served.
+type Match struct {
+	Method bool
+	Path   bool
+	Query  bool
+	Body   bool
+}
+
+// ParseMatch reads a comma separated list of method, path, query and body.
+func ParseMatch(fields string) (Match, error) {
+	var match Match
+	for _, field := range strings.Split(fields, ",") {
+		switch strings.TrimSpace(field) {
+		case "method":
+			match.Method = true
+		case "path":
+			match.Path = true
+		case "query":
+			match.Query = true
+		case "body":
+			match.Body = true
+		case "":
+		default:
+			return Match{}, fmt.Errorf("unknown match field %q. Specify as method, path, query or body", field)
+		}
+	}
+	return match, nil
+}
+
+// sameQuery ignores the order of the parameters.
+func sameQuery(a, b string) bool {
+	aValues, aErr := url.ParseQuery(a)
+	bValues, bErr := url.ParseQuery(b)
+	if aErr != nil || bErr != nil {
+		return a == b
+	}
+	return aValues.Encode() == bValues.Encode()
+}
+
+// sameBody compares json bodies by value, so key order and spacing do not matter.
+func sameBody(a, b []byte) bool {
+	var aValue, bValue interface{}
+	if json.Unmarshal(a, &aValue) == nil && json.Unmarshal(b, &bValue) == nil {
+		return reflect.DeepEqual(aValue, bValue)
+	}
+	return bytes.Equal(a, b)
+}
+
+func (m Match) matches(recorded Request, r *http.Request, body []byte) bool {
+	if m.Method && recorded.Method != r.Method {
+		return false
+	}
+	if m.Path && recorded.Path != r.URL.Path {
+		return false
+	}
+	if m.Query && !sameQuery(recorded.Query, r.URL.RawQuery) {
+		return false
+	}
+	if m.Body {
+		recordedBody, err := decodeBody(recorded.Body, recorded.BodyEncoding)
+		if err != nil || !sameBody(recordedBody, body) {
+			return false
+		}
+	}
+	return true
+}
+
+// Replayer answers requests from the interactions of a cassette. Interactions matching the same request are
+// served in the order they were recorded, the last of them again once they are used up.
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	match        Match
+}
+
+func NewReplayer(interactions []Interaction, match Match) *Replayer {
+	return &Replayer{interactions: interactions, used: make([]bool, len(interactions)), match: match}
+}
+
+// next picks the interaction to answer with, nil when none matches.
+func (rp *Replayer) next(r *http.Request, body []byte) *Interaction {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	lastMatch := -1
+	for i := range rp.interactions {
+		if !rp.match.matches(rp.interactions[i].Request, r, body) {
+			continue
+		}
+		if !rp.used[i] {
+			rp.used[i] = true
+			return &rp.interactions[i]
+		}
+		lastMatch = i
+	}
+	if lastMatch < 0 {
+		return nil
+	}
+	return &rp.interactions[lastMatch]
+}
+
+func (rp *Replayer) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, "Error occurred while reading the request data", http.StatusBadRequest)
+		return
+	}
+	interaction := rp.next(r, body)
+	if interaction == nil {
+		// 501 so a miss cannot be taken for a recorded 404.
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(rw).Encode(map[string]string{"message": "No recorded interaction matches " + r.Method + " " + r.URL.RequestURI()})
+		return
+	}
+	responseBody, err := decodeBody(interaction.Response.Body, interaction.Response.BodyEncoding)
+	if err != nil {
+		http.Error(rw, "Error occurred while reading the cassette", http.StatusInternalServerError)
+		return
+	}
+	for name, values := range interaction.Response.Header {
+		rw.Header()[name] = values
+	}
+	rw.WriteHeader(interaction.Response.Status)
+	rw.Write(responseBody)
+}
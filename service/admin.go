@@ -0,0 +1,336 @@
+package service
+
+import (
+	"encoding/json"
+	"gbserver/models"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SnapshotResponse struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+}
+
+type snapshot struct {
+	SnapshotResponse
+	data []byte
+	// dir holds copies of the git repos and asset files, empty when the service keeps neither.
+	dir string
+}
+
+// Snapshots keeps point in time copies of the store, its git repos and its asset files, so test suites can go
+// back to a known state.
+type Snapshots struct {
+	mu    sync.Mutex
+	items map[string]*snapshot
+}
+
+func NewSnapshots() *Snapshots {
+	return &Snapshots{items: make(map[string]*snapshot)}
+}
+
+// NewGbService wires a service with its storage, seed, snapshot registry and webhook delivery. The delivery
+// workers record into the service returned, Close stops them.
+func NewGbService(gbStore *models.GbStore, storage models.Storage, seed models.Seed) *GbService {
+	gbService := &GbService{GbStoreInstance: gbStore, Storage: storage, Seed: seed, Snapshots: NewSnapshots(), Webhooks: NewWebhooks()}
+	gbService.Webhooks.start(gbService, 4)
+	return gbService
+}
+
+// Close stops the webhook delivery workers, deliveries still queued are dropped, saves the deliveries
+// recorded since the last change and removes the files of the snapshots.
+func (g *GbService) Close() {
+	if g.Webhooks != nil {
+		g.Webhooks.Close()
+	}
+	g.GbStoreInstance.MU.Lock()
+	// nobody is left to answer, a failed save is only logged by persist.
+	g.persist()
+	g.GbStoreInstance.MU.Unlock()
+	if g.Snapshots != nil {
+		g.Snapshots.mu.Lock()
+		defer g.Snapshots.mu.Unlock()
+		for snapshotID, snap := range g.Snapshots.items {
+			removeSnapshotFiles(snap)
+			delete(g.Snapshots.items, snapshotID)
+		}
+	}
+}
+
+// post /_admin/reset
+func (g *GbService) Reset() error {
+	var gbStore *models.GbStore
+	var err error
+	if g.Seed != nil {
+		gbStore, err = g.Seed()
+		if err != nil {
+			return err
+		}
+	} else {
+		gbStore = models.NewGbStore()
+	}
+
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	g.GbStoreInstance.Replace(gbStore)
+	err = g.syncFiles()
+	saveErr := g.persist()
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// post /_admin/snapshot
+func (g *GbService) Snapshot() (SnapshotResponse, error) {
+	if g.Snapshots == nil {
+		return SnapshotResponse{}, ErrSnapshotsDisabled
+	}
+	g.GbStoreInstance.MU.RLock()
+	data, err := json.Marshal(g.GbStoreInstance)
+	var dir string
+	if err == nil {
+		dir, err = g.saveFiles()
+	}
+	g.GbStoreInstance.MU.RUnlock()
+	if err != nil {
+		return SnapshotResponse{}, err
+	}
+
+	snap := &snapshot{
+		SnapshotResponse: SnapshotResponse{ID: uuid.New().String(), CreatedAt: time.Now().UTC().Format(time.RFC3339Nano)},
+		data:             data,
+		dir:              dir,
+	}
+	g.Snapshots.mu.Lock()
+	g.Snapshots.items[snap.ID] = snap
+	g.Snapshots.mu.Unlock()
+	return snap.SnapshotResponse, nil
+}
+
+// get /_admin/snapshot
+func (g *GbService) ListSnapshots() ([]SnapshotResponse, error) {
+	if g.Snapshots == nil {
+		return nil, ErrSnapshotsDisabled
+	}
+	g.Snapshots.mu.Lock()
+	defer g.Snapshots.mu.Unlock()
+	snapshotList := []SnapshotResponse{}
+	for _, snap := range g.Snapshots.items {
+		snapshotList = append(snapshotList, snap.SnapshotResponse)
+	}
+	sort.Slice(snapshotList, func(i, j int) bool { return snapshotList[i].CreatedAt < snapshotList[j].CreatedAt })
+	return snapshotList, nil
+}
+
+// post /_admin/restore/{id}
+func (g *GbService) Restore(snapshotID string) error {
+	if g.Snapshots == nil {
+		return ErrSnapshotsDisabled
+	}
+	g.Snapshots.mu.Lock()
+	snap, exists := g.Snapshots.items[snapshotID]
+	g.Snapshots.mu.Unlock()
+	if !exists {
+		return ErrSnapshotNotFound
+	}
+
+	// decode into a fresh store so a snapshot can be restored any number of times.
+	gbStore := &models.GbStore{}
+	err := json.Unmarshal(snap.data, gbStore)
+	if err != nil {
+		return err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	g.GbStoreInstance.Replace(gbStore)
+	err = g.restoreFiles(snap.dir)
+	if err == nil {
+		err = g.syncFiles()
+	}
+	saveErr := g.persist()
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// delete /_admin/snapshot/{id}
+func (g *GbService) DeleteSnapshot(snapshotID string) error {
+	if g.Snapshots == nil {
+		return ErrSnapshotsDisabled
+	}
+	g.Snapshots.mu.Lock()
+	defer g.Snapshots.mu.Unlock()
+	snap, exists := g.Snapshots.items[snapshotID]
+	if !exists {
+		return ErrSnapshotNotFound
+	}
+	delete(g.Snapshots.items, snapshotID)
+	return removeSnapshotFiles(snap)
+}
+
+func removeSnapshotFiles(snap *snapshot) error {
+	if snap.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(snap.dir)
+}
+
+// saveFiles copies the git repos and the asset files into a new directory and returns it, empty when the
+// service keeps neither. Caller must hold the store lock.
+func (g *GbService) saveFiles() (string, error) {
+	if g.Git == nil && g.Assets == nil {
+		return "", nil
+	}
+	dir, err := os.MkdirTemp("", "gbserver-snapshot-")
+	if err != nil {
+		return "", err
+	}
+	if g.Git != nil {
+		err = copyTree(g.Git.Root, filepath.Join(dir, "git"))
+	}
+	if err == nil && g.Assets != nil {
+		err = copyTree(g.Assets.Root, filepath.Join(dir, "assets"))
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// restoreFiles puts back the git repos and the asset files saveFiles copied into dir. Caller must hold the
+// store lock.
+func (g *GbService) restoreFiles(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if g.Git != nil {
+		err := replaceTree(filepath.Join(dir, "git"), g.Git.Root)
+		if err != nil {
+			return err
+		}
+	}
+	if g.Assets != nil {
+		return replaceTree(filepath.Join(dir, "assets"), g.Assets.Root)
+	}
+	return nil
+}
+
+// syncFiles brings the git repos and the asset files in line with the store, the ones of repos and assets
+// it does not have are removed. Caller must hold the store lock.
+func (g *GbService) syncFiles() error {
+	err := g.syncGit()
+	if err != nil || g.Assets == nil {
+		return err
+	}
+	names, err := g.Assets.Names()
+	if err != nil {
+		return err
+	}
+	kept := map[string]bool{}
+	for _, asset := range g.GbStoreInstance.ReleaseAssets {
+		kept[asset.NodeID] = true
+	}
+	for _, name := range names {
+		if !kept[name] {
+			err = g.Assets.Remove(name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyTree copies the files under src to dst.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		closeErr := out.Close()
+		if err != nil {
+			return err
+		}
+		return closeErr
+	})
+}
+
+// replaceTree empties dst and copies the files under src into it, a missing src leaves dst alone.
+func replaceTree(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		err = os.RemoveAll(filepath.Join(dst, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return copyTree(src, dst)
+}
+
+// StoreStats counts what the store holds, PullRequests is keyed by state.
+type StoreStats struct {
+	Orgs         int
+	Users        int
+	Repos        int
+	Branches     int
+	PullRequests map[string]int
+}
+
+// StoreStats is read for the /metrics gauges.
+func (g *GbService) StoreStats() StoreStats {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	// users are the accounts, plus the members of stores written before accounts existed.
+	logins := map[string]bool{}
+	for login := range g.GbStoreInstance.Accounts {
+		logins[login] = true
+	}
+	for _, user := range g.GbStoreInstance.Users {
+		logins[user.LoginName] = true
+	}
+	stats := StoreStats{Orgs: len(g.GbStoreInstance.Orgs), Users: len(logins), Repos: len(g.GbStoreInstance.Repos),
+		Branches: len(g.GbStoreInstance.Branches), PullRequests: map[string]int{"open": 0, "closed": 0}}
+	for _, pr := range g.GbStoreInstance.PullRequests {
+		stats.PullRequests[pr.State]++
+	}
+	return stats
+}
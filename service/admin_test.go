@@ -0,0 +1,121 @@
+package service
+
+import (
+	"gbserver/models"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	adminService := newTestService(t)
+
+	snap, err := adminService.Snapshot()
+	assert.NoError(t, err)
+
+	_, err = adminService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "scratch", Description: "thrown away"})
+	assert.NoError(t, err)
+	_, err = adminService.DeleteBranch("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.NoError(t, err)
+
+	assert.NoError(t, adminService.Restore(snap.ID))
+	repoList, _ := adminService.ListRepos("gborg", "gbuser")
+	assert.Len(t, repoList, 1)
+	branchList, _ := adminService.ListBranches("gborg", "gbuser", "gbrepo")
+	assert.Len(t, branchList, 2)
+	prList, _ := adminService.ListPRs("gborg", "gbuser", "gbrepo")
+	assert.Len(t, prList, 1)
+
+	// restoring does not consume the snapshot.
+	adminService.DeleteRepo("gborg", "gbuser", "gbrepo")
+	assert.NoError(t, adminService.Restore(snap.ID))
+	repoList, _ = adminService.ListRepos("gborg", "gbuser")
+	assert.Len(t, repoList, 1)
+
+	assert.Equal(t, ErrSnapshotNotFound, adminService.Restore("unknown"))
+	snapshotList, _ := adminService.ListSnapshots()
+	assert.Equal(t, []SnapshotResponse{snap}, snapshotList)
+	assert.NoError(t, adminService.DeleteSnapshot(snap.ID))
+	assert.Equal(t, ErrSnapshotNotFound, adminService.DeleteSnapshot(snap.ID))
+}
+
+func TestReset(t *testing.T) {
+	seed := func() (*models.GbStore, error) {
+		return models.ParseFixture([]byte("orgs:\n  - id: 1\n    name: resetorg\n    users:\n      - id: 5\n        login: resetuser\n"))
+	}
+	adminService := NewGbService(models.NewGbStore(), nil, seed)
+	t.Cleanup(adminService.Close)
+
+	assert.NoError(t, adminService.Reset())
+	_, err := adminService.ListRepos("gborg", "gbuser")
+	assert.Equal(t, ErrOrgNotFound, err)
+	repoList, err := adminService.ListRepos("resetorg", "resetuser")
+	assert.NoError(t, err)
+	assert.Empty(t, repoList)
+}
+
+func TestStoreStats(t *testing.T) {
+	statsService := newTestService(t)
+	assert.Equal(t, StoreStats{Orgs: 1, Users: 2, Repos: 1, Branches: 2, PullRequests: map[string]int{"open": 1, "closed": 0}},
+		statsService.StoreStats())
+
+	_, err := statsService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/stats", SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd"})
+	assert.NoError(t, err)
+	_, err = statsService.UpdatePR("gborg", "gbuser", "gbrepo", "1", &PRRequest{State: "closed"})
+	assert.NoError(t, err)
+	stats := statsService.StoreStats()
+	assert.Equal(t, 3, stats.Branches)
+	assert.Equal(t, map[string]int{"open": 0, "closed": 1}, stats.PullRequests)
+}
+
+func TestSnapshotRestoreFiles(t *testing.T) {
+	gitService := newGitService(t)
+	assets, err := NewAssetStore(t.TempDir())
+	assert.NoError(t, err)
+	gitService.Assets = assets
+	tagName := "v1.0.0"
+	release, err := gitService.CreateRelease("gborg", "gbuser", "gbrepo", &ReleaseRequest{TagName: &tagName})
+	assert.NoError(t, err)
+	asset, err := gitService.UploadReleaseAsset("gborg", "gbuser", "gbrepo", release.ID, "app.tar.gz", "", "", strings.NewReader("content"))
+	assert.NoError(t, err)
+	master := gitService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/master"].CommitInfo.SHA
+
+	snap, err := gitService.Snapshot()
+	assert.NoError(t, err)
+	_, err = gitService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "scratch", AutoInit: true})
+	assert.NoError(t, err)
+	assert.NoError(t, gitService.DeleteReleaseAsset("gborg", "gbuser", "gbrepo", asset.ID))
+	_, err = gitService.DeleteRepo("gborg", "gbuser", "gbrepo")
+	assert.NoError(t, err)
+
+	// the git repo and the asset deleted after the snapshot come back, the repo created after it goes away.
+	assert.NoError(t, gitService.Restore(snap.ID))
+	assert.Equal(t, master, gitService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/master"].CommitInfo.SHA)
+	assert.True(t, gitService.Git.CommitExists("gborg/gbuser/gbrepo", master))
+	repoKeys, err := gitService.Git.RepoKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gborg/gbuser/gbrepo"}, repoKeys)
+	_, content, err := gitService.DownloadReleaseAsset("gborg", "gbuser", "gbrepo", asset.ID)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "content", string(data))
+
+	// a reset leaves no git repo or asset file behind for what the seed does not have.
+	_, err = gitService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "scratch", AutoInit: true})
+	assert.NoError(t, err)
+	gitService.Seed = func() (*models.GbStore, error) { return models.NewGbStore(), nil }
+	assert.NoError(t, gitService.Reset())
+	repoKeys, _ = gitService.Git.RepoKeys()
+	assert.Equal(t, []string{"gborg/gbuser/gbrepo"}, repoKeys)
+	names, err := assets.Names()
+	assert.NoError(t, err)
+	assert.Empty(t, names)
+
+	dir := gitService.Snapshots.items[snap.ID].dir
+	assert.DirExists(t, dir)
+	assert.NoError(t, gitService.DeleteSnapshot(snap.ID))
+	assert.NoDirExists(t, dir)
+}
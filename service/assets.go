@@ -0,0 +1,79 @@
+package service
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// uploadPrefix names the temp files of uploads that are not complete yet.
+const uploadPrefix = "upload-"
+
+// AssetStore keeps the content of release assets as files under Root, named by the node id of the asset.
+type AssetStore struct {
+	Root string
+}
+
+// NewAssetStore creates root if needed.
+func NewAssetStore(root string) (*AssetStore, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &AssetStore{Root: root}, nil
+}
+
+func (a *AssetStore) path(name string) string {
+	return filepath.Join(a.Root, name)
+}
+
+// Names lists the assets kept, uploads still in progress are left out.
+func (a *AssetStore) Names() ([]string, error) {
+	entries, err := os.ReadDir(a.Root)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), uploadPrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// Save writes the content of an asset and returns its size. The file only shows up once it is complete.
+func (a *AssetStore) Save(name string, content io.Reader) (int64, error) {
+	tempFile, err := os.CreateTemp(a.Root, uploadPrefix)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(tempFile, content)
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempFile.Name(), a.path(name))
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return 0, err
+	}
+	return size, nil
+}
+
+// Open reads the content of an asset back.
+func (a *AssetStore) Open(name string) (io.ReadCloser, error) {
+	return os.Open(a.path(name))
+}
+
+// Remove deletes the content of an asset, one that is already gone is not an error.
+func (a *AssetStore) Remove(name string) error {
+	err := os.Remove(a.path(name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"gbserver/models"
+	"slices"
+)
+
+// AuthUser is the caller a token belongs to.
+type AuthUser struct {
+	Login     string
+	ID        int
+	SiteAdmin bool
+}
+
+// Authenticate resolves a personal access token to its user.
+func (g *GbService) Authenticate(token string) (AuthUser, error) {
+	if token == "" {
+		return AuthUser{}, ErrBadCredentials
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	for _, account := range g.GbStoreInstance.Accounts {
+		if slices.Contains(account.Tokens, token) {
+			return AuthUser{Login: account.LoginName, ID: account.ID, SiteAdmin: account.SiteAdmin}, nil
+		}
+	}
+	for _, user := range g.GbStoreInstance.Users {
+		if slices.Contains(user.Tokens, token) {
+			return AuthUser{Login: user.LoginName, ID: user.ID, SiteAdmin: user.SiteAdmin}, nil
+		}
+	}
+	return AuthUser{}, ErrBadCredentials
+}
+
+// IsOrgMember tells if login is listed in the users of the organization.
+func (g *GbService) IsOrgMember(orgName, login string) bool {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	return exists && slices.Contains(org.Users, login)
+}
+
+// WithActor returns a copy of the service acting on behalf of login, e.g. as the author of new pull requests.
+func (g *GbService) WithActor(login string) *GbService {
+	actorService := *g
+	actorService.Actor = login
+	return &actorService
+}
+
+// actingUser is the authenticated caller when it belongs to the org, else the owner from the path.
+// Caller must hold the store lock.
+func (g *GbService) actingUser(orgName, owner string) *models.User {
+	if actor, exists := g.GbStoreInstance.Users[orgName+"/"+g.Actor]; g.Actor != "" && exists {
+		return actor
+	}
+	return g.GbStoreInstance.Users[orgName+"/"+owner]
+}
+
+// userByID finds a user of the org. Caller must hold the store lock.
+func (g *GbService) userByID(orgName string, userID int) *models.User {
+	if org, exists := g.GbStoreInstance.Orgs[orgName]; exists {
+		for _, login := range org.Users {
+			if user := g.GbStoreInstance.Users[orgName+"/"+login]; user != nil && user.ID == userID {
+				return user
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,719 @@
+package service
+
+import (
+	"gbserver/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var statusStates = []string{"error", "failure", "pending", "success"}
+var checkStatuses = []string{"queued", "in_progress", "completed"}
+
+// checkConclusions are in the order they decide the conclusion of a suite, the first one any run has wins.
+var checkConclusions = []string{"action_required", "timed_out", "cancelled", "failure", "stale", "success", "neutral", "skipped"}
+
+// passingConclusions are the conclusions that do not make a commit fail its checks.
+var passingConclusions = []string{"success", "neutral", "skipped"}
+
+type StatusRequest struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url"`
+	Description string `json:"description"`
+	Context     string `json:"context"`
+	//'{"state":"success","target_url":"https://ci.example.com/build/1","description":"Build passed","context":"ci/build"}'
+}
+
+type StatusResponse struct {
+	URL         string     `json:"url"`
+	ID          int        `json:"id"`
+	NodeID      string     `json:"node_id"`
+	State       string     `json:"state"`
+	Description string     `json:"description"`
+	TargetURL   string     `json:"target_url"`
+	Context     string     `json:"context"`
+	CreatedAt   string     `json:"created_at"`
+	UpdatedAt   string     `json:"updated_at"`
+	Creator     *OwnerInfo `json:"creator"`
+}
+
+// CombinedStatusResponse holds the latest status of every context of a commit.
+type CombinedStatusResponse struct {
+	State      string           `json:"state"`
+	Statuses   []StatusResponse `json:"statuses"`
+	SHA        string           `json:"sha"`
+	TotalCount int              `json:"total_count"`
+	CommitURL  string           `json:"commit_url"`
+	URL        string           `json:"url"`
+}
+
+type CheckRunOutput struct {
+	Title            string `json:"title"`
+	Summary          string `json:"summary"`
+	Text             string `json:"text"`
+	AnnotationsCount int    `json:"annotations_count"`
+}
+
+// CheckRunRequest creates or updates a check run, empty fields are left unchanged on update.
+// A conclusion completes the run.
+type CheckRunRequest struct {
+	Name        string          `json:"name"`
+	HeadSHA     string          `json:"head_sha"`
+	Status      string          `json:"status"`
+	Conclusion  string          `json:"conclusion"`
+	ExternalID  string          `json:"external_id"`
+	DetailsURL  string          `json:"details_url"`
+	StartedAt   string          `json:"started_at"`
+	CompletedAt string          `json:"completed_at"`
+	Output      *CheckRunOutput `json:"output"`
+	//'{"name":"build","head_sha":"7638417db6d59f3c431d3e1f261cc637155684cd","status":"in_progress"}'
+}
+
+type CheckRef struct {
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+}
+
+// CheckPullRequest is an open pull request whose head is the commit of a check.
+type CheckPullRequest struct {
+	URL    string   `json:"url"`
+	ID     string   `json:"id"`
+	Number int      `json:"number"`
+	Head   CheckRef `json:"head"`
+	Base   CheckRef `json:"base"`
+}
+
+type CheckSuiteRef struct {
+	ID int `json:"id"`
+}
+
+type CheckRunResponse struct {
+	ID           int                `json:"id"`
+	NodeID       string             `json:"node_id"`
+	HeadSHA      string             `json:"head_sha"`
+	ExternalID   string             `json:"external_id"`
+	URL          string             `json:"url"`
+	HTMLURL      string             `json:"html_url"`
+	DetailsURL   string             `json:"details_url"`
+	Status       string             `json:"status"`
+	Conclusion   *string            `json:"conclusion"`
+	StartedAt    string             `json:"started_at"`
+	CompletedAt  *string            `json:"completed_at"`
+	Output       CheckRunOutput     `json:"output"`
+	Name         string             `json:"name"`
+	CheckSuite   CheckSuiteRef      `json:"check_suite"`
+	PullRequests []CheckPullRequest `json:"pull_requests"`
+}
+
+type CheckRunList struct {
+	TotalCount int                `json:"total_count"`
+	CheckRuns  []CheckRunResponse `json:"check_runs"`
+}
+
+type CheckSuiteRequest struct {
+	HeadSHA string `json:"head_sha"`
+}
+
+type CheckSuiteResponse struct {
+	ID                   int                `json:"id"`
+	NodeID               string             `json:"node_id"`
+	HeadBranch           string             `json:"head_branch"`
+	HeadSHA              string             `json:"head_sha"`
+	Status               string             `json:"status"`
+	Conclusion           *string            `json:"conclusion"`
+	URL                  string             `json:"url"`
+	CheckRunsURL         string             `json:"check_runs_url"`
+	LatestCheckRunsCount int                `json:"latest_check_runs_count"`
+	CreatedAt            string             `json:"created_at"`
+	UpdatedAt            string             `json:"updated_at"`
+	PullRequests         []CheckPullRequest `json:"pull_requests"`
+}
+
+type CheckSuiteList struct {
+	TotalCount  int                  `json:"total_count"`
+	CheckSuites []CheckSuiteResponse `json:"check_suites"`
+}
+
+// CheckRunFilter holds the query parameters of the check run lists.
+type CheckRunFilter struct {
+	CheckName string
+	// Status is queued, in_progress or completed, empty means all.
+	Status string
+}
+
+// commitOf resolves a branch name or SHA to a commit of the repo. Without git only the commits branches point at
+// are known. Caller must hold the store lock.
+func (g *GbService) commitOf(repoKey, ref string) (string, error) {
+	if g.Git != nil {
+		return g.resolveRef(repoKey, ref)
+	}
+	if branch, exists := g.GbStoreInstance.Branches[repoKey+"/"+ref]; exists {
+		return branch.CommitInfo.SHA, nil
+	}
+	for _, branchName := range g.GbStoreInstance.Repos[repoKey].Branches {
+		if branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]; branch != nil && branch.CommitInfo.SHA == ref {
+			return ref, nil
+		}
+	}
+	return "", ErrCommitNotFound
+}
+
+// pullRequestsAt lists the open pull requests whose head is at sha. Caller must hold the store lock.
+func (g *GbService) pullRequestsAt(orgName, owner, repoName, sha string) []CheckPullRequest {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	prList := []CheckPullRequest{}
+	for _, prID := range g.GbStoreInstance.Repos[repoKey].PrIDs {
+		pr := g.GbStoreInstance.PullRequests[prID]
+		if pr == nil || pr.State != "open" || g.headSHA(repoKey, pr) != sha {
+			continue
+		}
+		checkPR := CheckPullRequest{URL: pr.URL, ID: pr.ID, Number: pr.Number,
+			Head: CheckRef{Ref: headBranchOf(pr), SHA: sha}, Base: CheckRef{Ref: pr.ToBranch}}
+		if baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+pr.ToBranch]; baseBranch != nil {
+			checkPR.Base.SHA = baseBranch.CommitInfo.SHA
+		}
+		prList = append(prList, checkPR)
+	}
+	return prList
+}
+
+// branchAt is the name of a branch pointing at sha, "" if there is none. Caller must hold the store lock.
+func (g *GbService) branchAt(repoKey, sha string) string {
+	for _, branchName := range g.GbStoreInstance.Repos[repoKey].Branches {
+		if branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]; branch != nil && branch.CommitInfo.SHA == sha {
+			return branchName
+		}
+	}
+	return ""
+}
+
+// buildStatusResponse renders a stored status. Caller must hold the store lock.
+func (g *GbService) buildStatusResponse(orgName, owner, repoName string, status *models.CommitStatus) StatusResponse {
+	statusResp := StatusResponse{URL: repoAPIURL(owner, repoName) + "/statuses/" + status.SHA, ID: status.ID,
+		NodeID: status.NodeID, State: status.State, Description: status.Description, TargetURL: status.TargetURL,
+		Context: status.Context, CreatedAt: status.CreatedAt, UpdatedAt: status.UpdatedAt}
+	if creator := g.userByID(orgName, status.CreatorID); creator != nil {
+		creatorInfo := ownerInfoOf(creator)
+		statusResp.Creator = &creatorInfo
+	}
+	return statusResp
+}
+
+// statusesOf lists the statuses of a commit, newest first. Caller must hold the store lock.
+func (g *GbService) statusesOf(repoKey, sha string) []*models.CommitStatus {
+	statuses := []*models.CommitStatus{}
+	for _, status := range g.GbStoreInstance.Statuses {
+		if status.RepoKey == repoKey && status.SHA == sha {
+			statuses = append(statuses, status)
+		}
+	}
+	slices.SortFunc(statuses, func(a, b *models.CommitStatus) int { return b.ID - a.ID })
+	return statuses
+}
+
+// combinedState sums up the latest statuses of the contexts of a commit the way github does: failure when any
+// failed, pending while any is pending (or there are none), else success.
+func combinedState(latest []*models.CommitStatus) string {
+	state := "success"
+	if len(latest) == 0 {
+		state = "pending"
+	}
+	for _, status := range latest {
+		switch status.State {
+		case "error", "failure":
+			return "failure"
+		case "pending":
+			state = "pending"
+		}
+	}
+	return state
+}
+
+// latestStatuses keeps the newest status of every context. Caller must hold the store lock.
+func (g *GbService) latestStatuses(repoKey, sha string) []*models.CommitStatus {
+	latest := []*models.CommitStatus{}
+	contexts := []string{}
+	for _, status := range g.statusesOf(repoKey, sha) {
+		if !slices.Contains(contexts, status.Context) {
+			contexts = append(contexts, status.Context)
+			latest = append(latest, status)
+		}
+	}
+	return latest
+}
+
+// checksOf lists the check runs of a commit, newest first. Caller must hold the store lock.
+func (g *GbService) checksOf(repoKey, sha string) []*models.CheckRun {
+	checkRuns := []*models.CheckRun{}
+	for _, checkRun := range g.GbStoreInstance.CheckRuns {
+		if checkRun.RepoKey == repoKey && checkRun.HeadSHA == sha {
+			checkRuns = append(checkRuns, checkRun)
+		}
+	}
+	slices.SortFunc(checkRuns, func(a, b *models.CheckRun) int { return b.ID - a.ID })
+	return checkRuns
+}
+
+// commitPassing tells if a commit has no failing or unfinished statuses and check runs. A commit nobody
+// reported on passes. Caller must hold the store lock.
+func (g *GbService) commitPassing(repoKey, sha string) bool {
+	if latest := g.latestStatuses(repoKey, sha); len(latest) > 0 && combinedState(latest) != "success" {
+		return false
+	}
+	for _, checkRun := range g.checksOf(repoKey, sha) {
+		if checkRun.Status != "completed" || !slices.Contains(passingConclusions, checkRun.Conclusion) {
+			return false
+		}
+	}
+	return true
+}
+
+// post /repos/{org}/{owner}/{repo}/statuses/{sha}
+func (g *GbService) CreateStatus(orgName, owner, repoName, sha string, statusReq *StatusRequest) (StatusResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return StatusResponse{}, err
+	}
+	if !slices.Contains(statusStates, statusReq.State) {
+		return StatusResponse{}, ErrInvalidStatusState
+	}
+	context := statusReq.Context
+	if context == "" {
+		context = "default"
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	commit, err := g.commitOf(repoKey, sha)
+	if err != nil || commit != sha {
+		// statuses go on a commit, not on a branch.
+		return StatusResponse{}, ErrNoCommitForSHA
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	g.GbStoreInstance.StatusesCount++
+	status := &models.CommitStatus{ID: g.GbStoreInstance.StatusesCount, NodeID: generateCustomID("NODEID"), RepoKey: repoKey,
+		SHA: sha, State: statusReq.State, TargetURL: statusReq.TargetURL, Description: statusReq.Description,
+		Context: context, CreatorID: g.actingUser(orgName, owner).ID, CreatedAt: now, UpdatedAt: now}
+	g.GbStoreInstance.Statuses[strconv.Itoa(status.ID)] = status
+
+	statusResp := g.buildStatusResponse(orgName, owner, repoName, status)
+	g.emitStatus(orgName, owner, repoName, statusResp, sha)
+	return statusResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/statuses, get /repos/{org}/{owner}/{repo}/statuses/{sha}
+func (g *GbService) ListStatuses(orgName, owner, repoName, ref string) ([]StatusResponse, error) {
+	statusList := []StatusResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return statusList, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	sha, err := g.commitOf(repoKey, ref)
+	if err != nil {
+		return statusList, err
+	}
+	for _, status := range g.statusesOf(repoKey, sha) {
+		statusList = append(statusList, g.buildStatusResponse(orgName, owner, repoName, status))
+	}
+	return statusList, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/status
+func (g *GbService) GetCombinedStatus(orgName, owner, repoName, ref string) (CombinedStatusResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CombinedStatusResponse{}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	sha, err := g.commitOf(repoKey, ref)
+	if err != nil {
+		return CombinedStatusResponse{}, err
+	}
+	latest := g.latestStatuses(repoKey, sha)
+	combinedResp := CombinedStatusResponse{State: combinedState(latest), Statuses: []StatusResponse{}, SHA: sha,
+		TotalCount: len(latest), CommitURL: repoAPIURL(owner, repoName) + "/commits/" + sha,
+		URL: repoAPIURL(owner, repoName) + "/commits/" + sha + "/status"}
+	for _, status := range latest {
+		combinedResp.Statuses = append(combinedResp.Statuses, g.buildStatusResponse(orgName, owner, repoName, status))
+	}
+	return combinedResp, nil
+}
+
+// suiteState derives the status and conclusion of a suite from its runs. Caller must hold the store lock.
+func (g *GbService) suiteState(suite *models.CheckSuite) (string, string, int) {
+	var runs []*models.CheckRun
+	for _, checkRun := range g.checksOf(suite.RepoKey, suite.HeadSHA) {
+		if checkRun.CheckSuiteID == suite.ID {
+			runs = append(runs, checkRun)
+		}
+	}
+	if len(runs) == 0 {
+		return "queued", "", 0
+	}
+	// the suite is queued until a run started and completed once all runs are.
+	queued := !slices.ContainsFunc(runs, func(checkRun *models.CheckRun) bool { return checkRun.Status != "queued" })
+	completed := !slices.ContainsFunc(runs, func(checkRun *models.CheckRun) bool { return checkRun.Status != "completed" })
+	if queued {
+		return "queued", "", len(runs)
+	}
+	if !completed {
+		return "in_progress", "", len(runs)
+	}
+	status := "completed"
+	for _, conclusion := range checkConclusions {
+		if slices.ContainsFunc(runs, func(checkRun *models.CheckRun) bool { return checkRun.Conclusion == conclusion }) {
+			return status, conclusion, len(runs)
+		}
+	}
+	return status, "", len(runs)
+}
+
+// buildCheckSuiteResponse renders a stored check suite. Caller must hold the store lock.
+func (g *GbService) buildCheckSuiteResponse(orgName, owner, repoName string, suite *models.CheckSuite) CheckSuiteResponse {
+	status, conclusion, runCount := g.suiteState(suite)
+	suiteURL := repoAPIURL(owner, repoName) + "/check-suites/" + strconv.Itoa(suite.ID)
+	suiteResp := CheckSuiteResponse{ID: suite.ID, NodeID: suite.NodeID, HeadBranch: suite.HeadBranch, HeadSHA: suite.HeadSHA,
+		Status: status, URL: suiteURL, CheckRunsURL: suiteURL + "/check-runs", LatestCheckRunsCount: runCount,
+		CreatedAt: suite.CreatedAt, UpdatedAt: suite.UpdatedAt, PullRequests: g.pullRequestsAt(orgName, owner, repoName, suite.HeadSHA)}
+	if conclusion != "" {
+		suiteResp.Conclusion = &conclusion
+	}
+	return suiteResp
+}
+
+// buildCheckRunResponse renders a stored check run. Caller must hold the store lock.
+func (g *GbService) buildCheckRunResponse(orgName, owner, repoName string, checkRun *models.CheckRun) CheckRunResponse {
+	checkRunResp := CheckRunResponse{ID: checkRun.ID, NodeID: checkRun.NodeID, HeadSHA: checkRun.HeadSHA,
+		ExternalID: checkRun.ExternalID, URL: repoAPIURL(owner, repoName) + "/check-runs/" + strconv.Itoa(checkRun.ID),
+		HTMLURL:    htmlURL(owner, repoName) + "/runs/" + strconv.Itoa(checkRun.ID),
+		DetailsURL: checkRun.DetailsURL, Status: checkRun.Status, StartedAt: checkRun.StartedAt, Name: checkRun.Name,
+		Output:       CheckRunOutput{Title: checkRun.OutputTitle, Summary: checkRun.OutputSummary, Text: checkRun.OutputText},
+		CheckSuite:   CheckSuiteRef{ID: checkRun.CheckSuiteID},
+		PullRequests: g.pullRequestsAt(orgName, owner, repoName, checkRun.HeadSHA)}
+	if checkRun.Status == "completed" {
+		conclusion, completedAt := checkRun.Conclusion, checkRun.CompletedAt
+		checkRunResp.Conclusion, checkRunResp.CompletedAt = &conclusion, &completedAt
+	}
+	return checkRunResp
+}
+
+// suiteFor returns the check suite of a commit, creating it on first use. Caller must hold the store lock.
+func (g *GbService) suiteFor(repoKey, sha string) (*models.CheckSuite, bool) {
+	for _, suite := range g.GbStoreInstance.CheckSuites {
+		if suite.RepoKey == repoKey && suite.HeadSHA == sha {
+			return suite, false
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	g.GbStoreInstance.ChecksCount++
+	suite := &models.CheckSuite{ID: g.GbStoreInstance.ChecksCount, NodeID: generateCustomID("NODEID"), RepoKey: repoKey,
+		HeadSHA: sha, HeadBranch: g.branchAt(repoKey, sha), CreatedAt: now, UpdatedAt: now}
+	g.GbStoreInstance.CheckSuites[strconv.Itoa(suite.ID)] = suite
+	return suite, true
+}
+
+// applyCheckRun copies the set fields of a request onto a run, completing it when it gets a conclusion.
+func applyCheckRun(checkRun *models.CheckRun, checkReq *CheckRunRequest, now string) error {
+	if checkReq.Status != "" && !slices.Contains(checkStatuses, checkReq.Status) {
+		return ErrInvalidCheckStatus
+	}
+	if checkReq.Conclusion != "" && !slices.Contains(checkConclusions, checkReq.Conclusion) {
+		return ErrInvalidCheckConclusion
+	}
+	for _, timestamp := range []string{checkReq.StartedAt, checkReq.CompletedAt} {
+		if _, err := parseTimestamp(timestamp); err != nil {
+			return err
+		}
+	}
+	if checkReq.Name != "" {
+		checkRun.Name = checkReq.Name
+	}
+	if checkReq.Status != "" {
+		checkRun.Status = checkReq.Status
+	}
+	if checkReq.Conclusion != "" {
+		checkRun.Status = "completed"
+		checkRun.Conclusion = checkReq.Conclusion
+	}
+	if checkRun.Status == "completed" && checkRun.Conclusion == "" {
+		return ErrCheckConclusionRequired
+	}
+	if checkRun.Status != "completed" {
+		checkRun.Conclusion = ""
+		checkRun.CompletedAt = ""
+	}
+	if checkReq.ExternalID != "" {
+		checkRun.ExternalID = checkReq.ExternalID
+	}
+	if checkReq.DetailsURL != "" {
+		checkRun.DetailsURL = checkReq.DetailsURL
+	}
+	if checkReq.StartedAt != "" {
+		checkRun.StartedAt = checkReq.StartedAt
+	}
+	if checkRun.StartedAt == "" {
+		checkRun.StartedAt = now
+	}
+	if checkReq.CompletedAt != "" {
+		checkRun.CompletedAt = checkReq.CompletedAt
+	}
+	if checkRun.Status == "completed" && checkRun.CompletedAt == "" {
+		checkRun.CompletedAt = now
+	}
+	if checkReq.Output != nil {
+		checkRun.OutputTitle, checkRun.OutputSummary, checkRun.OutputText = checkReq.Output.Title, checkReq.Output.Summary, checkReq.Output.Text
+	}
+	return nil
+}
+
+// post /repos/{org}/{owner}/{repo}/check-runs
+func (g *GbService) CreateCheckRun(orgName, owner, repoName string, checkReq *CheckRunRequest) (CheckRunResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	if strings.TrimSpace(checkReq.Name) == "" || checkReq.HeadSHA == "" {
+		return CheckRunResponse{}, ErrInvalidCheckRun
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	commit, err := g.commitOf(repoKey, checkReq.HeadSHA)
+	if err != nil || commit != checkReq.HeadSHA {
+		return CheckRunResponse{}, ErrNoCommitForSHA
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	checkRun := &models.CheckRun{NodeID: generateCustomID("NODEID"), RepoKey: repoKey, HeadSHA: checkReq.HeadSHA, Status: "queued"}
+	err = applyCheckRun(checkRun, checkReq, now)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	suite, _ := g.suiteFor(repoKey, checkReq.HeadSHA)
+	suiteStatus, _, _ := g.suiteState(suite)
+	g.GbStoreInstance.ChecksCount++
+	checkRun.ID = g.GbStoreInstance.ChecksCount
+	checkRun.CheckSuiteID = suite.ID
+	g.GbStoreInstance.CheckRuns[strconv.Itoa(checkRun.ID)] = checkRun
+	suite.UpdatedAt = now
+
+	checkRunResp := g.buildCheckRunResponse(orgName, owner, repoName, checkRun)
+	g.emitCheckRun("created", orgName, owner, repoName, checkRunResp)
+	if checkRun.Status == "completed" {
+		g.emitCheckRun("completed", orgName, owner, repoName, checkRunResp)
+	}
+	g.emitSuiteCompletion(orgName, owner, repoName, suite, suiteStatus)
+	return checkRunResp, g.persist()
+}
+
+// emitSuiteCompletion sends the completed event of a suite that was not complete before. Caller must hold the store lock.
+func (g *GbService) emitSuiteCompletion(orgName, owner, repoName string, suite *models.CheckSuite, oldStatus string) {
+	if status, _, _ := g.suiteState(suite); status == "completed" && oldStatus != "completed" {
+		g.emitCheckSuite("completed", orgName, owner, repoName, g.buildCheckSuiteResponse(orgName, owner, repoName, suite))
+	}
+}
+
+// findCheckRun looks a check run of the repo up. Caller must hold the store lock.
+func (g *GbService) findCheckRun(repoKey string, checkRunID int) (*models.CheckRun, error) {
+	checkRun, exists := g.GbStoreInstance.CheckRuns[strconv.Itoa(checkRunID)]
+	if !exists || checkRun.RepoKey != repoKey {
+		return nil, ErrCheckRunNotFound
+	}
+	return checkRun, nil
+}
+
+// findCheckSuite looks a check suite of the repo up. Caller must hold the store lock.
+func (g *GbService) findCheckSuite(repoKey string, checkSuiteID int) (*models.CheckSuite, error) {
+	suite, exists := g.GbStoreInstance.CheckSuites[strconv.Itoa(checkSuiteID)]
+	if !exists || suite.RepoKey != repoKey {
+		return nil, ErrCheckSuiteNotFound
+	}
+	return suite, nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/check-runs/{check_run_id}
+func (g *GbService) UpdateCheckRun(orgName, owner, repoName string, checkRunID int, checkReq *CheckRunRequest) (CheckRunResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	checkRun, err := g.findCheckRun(repoKey, checkRunID)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	suite := g.GbStoreInstance.CheckSuites[strconv.Itoa(checkRun.CheckSuiteID)]
+	suiteStatus, _, _ := g.suiteState(suite)
+	// the run is only changed once the whole request turned out valid.
+	updated := *checkRun
+	now := time.Now().UTC().Format(time.RFC3339)
+	err = applyCheckRun(&updated, checkReq, now)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	wasCompleted := checkRun.Status == "completed"
+	*checkRun = updated
+	suite.UpdatedAt = now
+
+	checkRunResp := g.buildCheckRunResponse(orgName, owner, repoName, checkRun)
+	if checkRun.Status == "completed" && !wasCompleted {
+		g.emitCheckRun("completed", orgName, owner, repoName, checkRunResp)
+	}
+	g.emitSuiteCompletion(orgName, owner, repoName, suite, suiteStatus)
+	return checkRunResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/check-runs/{check_run_id}
+func (g *GbService) GetCheckRun(orgName, owner, repoName string, checkRunID int) (CheckRunResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	checkRun, err := g.findCheckRun(orgName+"/"+owner+"/"+repoName, checkRunID)
+	if err != nil {
+		return CheckRunResponse{}, err
+	}
+	return g.buildCheckRunResponse(orgName, owner, repoName, checkRun), nil
+}
+
+// filterCheckRuns renders the runs the filter lets through. Caller must hold the store lock.
+func (g *GbService) filterCheckRuns(orgName, owner, repoName string, checkRuns []*models.CheckRun, filter CheckRunFilter) (CheckRunList, error) {
+	checkRunList := CheckRunList{CheckRuns: []CheckRunResponse{}}
+	if filter.Status != "" && !slices.Contains(checkStatuses, filter.Status) {
+		return checkRunList, ErrInvalidCheckStatus
+	}
+	for _, checkRun := range checkRuns {
+		if (filter.CheckName != "" && checkRun.Name != filter.CheckName) || (filter.Status != "" && checkRun.Status != filter.Status) {
+			continue
+		}
+		checkRunList.CheckRuns = append(checkRunList.CheckRuns, g.buildCheckRunResponse(orgName, owner, repoName, checkRun))
+	}
+	checkRunList.TotalCount = len(checkRunList.CheckRuns)
+	return checkRunList, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/check-runs
+func (g *GbService) ListCheckRuns(orgName, owner, repoName, ref string, filter CheckRunFilter) (CheckRunList, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckRunList{CheckRuns: []CheckRunResponse{}}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	sha, err := g.commitOf(repoKey, ref)
+	if err != nil {
+		return CheckRunList{CheckRuns: []CheckRunResponse{}}, err
+	}
+	return g.filterCheckRuns(orgName, owner, repoName, g.checksOf(repoKey, sha), filter)
+}
+
+// get /repos/{org}/{owner}/{repo}/check-suites/{check_suite_id}/check-runs
+func (g *GbService) ListSuiteCheckRuns(orgName, owner, repoName string, checkSuiteID int, filter CheckRunFilter) (CheckRunList, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckRunList{CheckRuns: []CheckRunResponse{}}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	suite, err := g.findCheckSuite(repoKey, checkSuiteID)
+	if err != nil {
+		return CheckRunList{CheckRuns: []CheckRunResponse{}}, err
+	}
+	checkRuns := slices.DeleteFunc(g.checksOf(repoKey, suite.HeadSHA), func(checkRun *models.CheckRun) bool {
+		return checkRun.CheckSuiteID != suite.ID
+	})
+	return g.filterCheckRuns(orgName, owner, repoName, checkRuns, filter)
+}
+
+// post /repos/{org}/{owner}/{repo}/check-suites, an existing suite of the commit is returned as is.
+func (g *GbService) CreateCheckSuite(orgName, owner, repoName string, suiteReq *CheckSuiteRequest) (CheckSuiteResponse, bool, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckSuiteResponse{}, false, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	commit, err := g.commitOf(repoKey, suiteReq.HeadSHA)
+	if err != nil || commit != suiteReq.HeadSHA {
+		return CheckSuiteResponse{}, false, ErrNoCommitForSHA
+	}
+	suite, created := g.suiteFor(repoKey, suiteReq.HeadSHA)
+	if created {
+		err = g.persist()
+		if err != nil {
+			return CheckSuiteResponse{}, false, err
+		}
+	}
+	return g.buildCheckSuiteResponse(orgName, owner, repoName, suite), created, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/check-suites/{check_suite_id}
+func (g *GbService) GetCheckSuite(orgName, owner, repoName string, checkSuiteID int) (CheckSuiteResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CheckSuiteResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	suite, err := g.findCheckSuite(orgName+"/"+owner+"/"+repoName, checkSuiteID)
+	if err != nil {
+		return CheckSuiteResponse{}, err
+	}
+	return g.buildCheckSuiteResponse(orgName, owner, repoName, suite), nil
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}/check-suites
+func (g *GbService) ListCheckSuites(orgName, owner, repoName, ref string) (CheckSuiteList, error) {
+	suiteList := CheckSuiteList{CheckSuites: []CheckSuiteResponse{}}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return suiteList, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	sha, err := g.commitOf(repoKey, ref)
+	if err != nil {
+		return suiteList, err
+	}
+	for _, suite := range g.GbStoreInstance.CheckSuites {
+		if suite.RepoKey == repoKey && suite.HeadSHA == sha {
+			suiteList.CheckSuites = append(suiteList.CheckSuites, g.buildCheckSuiteResponse(orgName, owner, repoName, suite))
+		}
+	}
+	suiteList.TotalCount = len(suiteList.CheckSuites)
+	return suiteList, nil
+}
+
+// deleteRepoChecks drops the statuses, check suites and check runs of a deleted repo. Caller must hold the store lock.
+func (g *GbService) deleteRepoChecks(repoKey string) {
+	for statusID, status := range g.GbStoreInstance.Statuses {
+		if status.RepoKey == repoKey {
+			delete(g.GbStoreInstance.Statuses, statusID)
+		}
+	}
+	for suiteID, suite := range g.GbStoreInstance.CheckSuites {
+		if suite.RepoKey == repoKey {
+			delete(g.GbStoreInstance.CheckSuites, suiteID)
+		}
+	}
+	for checkRunID, checkRun := range g.GbStoreInstance.CheckRuns {
+		if checkRun.RepoKey == repoKey {
+			delete(g.GbStoreInstance.CheckRuns, checkRunID)
+		}
+	}
+}
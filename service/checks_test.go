@@ -0,0 +1,133 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatuses(t *testing.T) {
+	gbService := newTestService(t)
+	head := gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/gbbranch"].CommitInfo.SHA
+
+	invalid := []struct {
+		name    string
+		sha     string
+		request *StatusRequest
+		wantErr error
+	}{
+		{name: "Test unknown state", sha: head, request: &StatusRequest{State: "done"}, wantErr: ErrInvalidStatusState},
+		{name: "Test branch instead of sha", sha: "gbbranch", request: &StatusRequest{State: "success"}, wantErr: ErrNoCommitForSHA},
+	}
+	for _, tt := range invalid {
+		_, err := gbService.CreateStatus("gborg", "gbuser", "gbrepo", tt.sha, tt.request)
+		assert.Equal(t, tt.wantErr, err, tt.name)
+	}
+	_, err := gbService.GetCombinedStatus("gborg", "gbuser", "gbrepo", "nosuchref")
+	assert.Equal(t, ErrCommitNotFound, err)
+
+	combined, err := gbService.GetCombinedStatus("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", combined.State)
+	assert.Equal(t, head, combined.SHA)
+
+	status, err := gbService.CreateStatus("gborg", "gbuser", "gbrepo", head, &StatusRequest{State: "failure", Context: "ci/build"})
+	assert.NoError(t, err)
+	assert.Equal(t, "gbuser", status.Creator.Login)
+	_, err = gbService.CreateStatus("gborg", "gbuser", "gbrepo", head, &StatusRequest{State: "success"})
+	assert.NoError(t, err)
+	pr, _ := gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "unstable", pr.MergeableState)
+	assert.Contains(t, pr.StatusesURL, "/statuses/"+head)
+
+	// only the latest status of a context counts.
+	_, err = gbService.CreateStatus("gborg", "gbuser", "gbrepo", head, &StatusRequest{State: "success", Context: "ci/build"})
+	assert.NoError(t, err)
+	combined, _ = gbService.GetCombinedStatus("gborg", "gbuser", "gbrepo", head)
+	assert.Equal(t, "success", combined.State)
+	assert.Equal(t, 2, combined.TotalCount)
+	statuses, _ := gbService.ListStatuses("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.Len(t, statuses, 3)
+	assert.Equal(t, "ci/build", statuses[0].Context)
+	assert.Equal(t, "success", statuses[0].State)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "clean", pr.MergeableState)
+
+	_, err = gbService.DeleteRepo("gborg", "gbuser", "gbrepo")
+	assert.NoError(t, err)
+	assert.Empty(t, gbService.GbStoreInstance.Statuses)
+}
+
+func TestCheckRuns(t *testing.T) {
+	gbService := newTestService(t)
+	head := gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/gbbranch"].CommitInfo.SHA
+
+	invalid := []struct {
+		name    string
+		request *CheckRunRequest
+		wantErr error
+	}{
+		{name: "Test missing name", request: &CheckRunRequest{HeadSHA: head}, wantErr: ErrInvalidCheckRun},
+		{name: "Test completed without conclusion", request: &CheckRunRequest{Name: "build", HeadSHA: head, Status: "completed"}, wantErr: ErrCheckConclusionRequired},
+		{name: "Test unknown conclusion", request: &CheckRunRequest{Name: "build", HeadSHA: head, Conclusion: "passed"}, wantErr: ErrInvalidCheckConclusion},
+	}
+	for _, tt := range invalid {
+		_, err := gbService.CreateCheckRun("gborg", "gbuser", "gbrepo", tt.request)
+		assert.Equal(t, tt.wantErr, err, tt.name)
+	}
+
+	build, err := gbService.CreateCheckRun("gborg", "gbuser", "gbrepo", &CheckRunRequest{Name: "build", HeadSHA: head, Status: "in_progress"})
+	assert.NoError(t, err)
+	assert.Nil(t, build.Conclusion)
+	assert.Equal(t, 1, build.PullRequests[0].Number)
+	lint, err := gbService.CreateCheckRun("gborg", "gbuser", "gbrepo", &CheckRunRequest{Name: "lint", HeadSHA: head, Conclusion: "success"})
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", lint.Status)
+	assert.NotNil(t, lint.CompletedAt)
+	assert.Equal(t, build.CheckSuite.ID, lint.CheckSuite.ID)
+
+	suite, err := gbService.GetCheckSuite("gborg", "gbuser", "gbrepo", build.CheckSuite.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "in_progress", suite.Status)
+	assert.Equal(t, "gbbranch", suite.HeadBranch)
+	pr, _ := gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "unstable", pr.MergeableState)
+
+	checkRuns, err := gbService.ListCheckRuns("gborg", "gbuser", "gbrepo", "gbbranch", CheckRunFilter{Status: "completed"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, checkRuns.TotalCount)
+	assert.Equal(t, "lint", checkRuns.CheckRuns[0].Name)
+	_, err = gbService.ListCheckRuns("gborg", "gbuser", "gbrepo", "gbbranch", CheckRunFilter{Status: "done"})
+	assert.Equal(t, ErrInvalidCheckStatus, err)
+
+	// a failed update leaves the run as it was.
+	_, err = gbService.UpdateCheckRun("gborg", "gbuser", "gbrepo", build.ID, &CheckRunRequest{Status: "completed"})
+	assert.Equal(t, ErrCheckConclusionRequired, err)
+	build, err = gbService.UpdateCheckRun("gborg", "gbuser", "gbrepo", build.ID, &CheckRunRequest{Conclusion: "failure",
+		Output: &CheckRunOutput{Title: "Build failed"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "failure", *build.Conclusion)
+	assert.Equal(t, "Build failed", build.Output.Title)
+	suites, _ := gbService.ListCheckSuites("gborg", "gbuser", "gbrepo", head)
+	assert.Equal(t, 1, suites.TotalCount)
+	assert.Equal(t, "completed", suites.CheckSuites[0].Status)
+	assert.Equal(t, "failure", *suites.CheckSuites[0].Conclusion)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "unstable", pr.MergeableState)
+
+	build, err = gbService.UpdateCheckRun("gborg", "gbuser", "gbrepo", build.ID, &CheckRunRequest{Conclusion: "success"})
+	assert.NoError(t, err)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "clean", pr.MergeableState)
+	suiteRuns, _ := gbService.ListSuiteCheckRuns("gborg", "gbuser", "gbrepo", build.CheckSuite.ID, CheckRunFilter{CheckName: "build"})
+	assert.Equal(t, 1, suiteRuns.TotalCount)
+
+	sameSuite, created, err := gbService.CreateCheckSuite("gborg", "gbuser", "gbrepo", &CheckSuiteRequest{HeadSHA: head})
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, build.CheckSuite.ID, sameSuite.ID)
+	_, err = gbService.GetCheckRun("gborg", "gbuser", "gbrepo", 99)
+	assert.Equal(t, ErrCheckRunNotFound, err)
+	_, err = gbService.GetCheckSuite("gborg", "gbuser", "gbrepo", build.ID)
+	assert.Equal(t, ErrCheckSuiteNotFound, err)
+}
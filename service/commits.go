@@ -0,0 +1,474 @@
+package service
+
+import (
+	"encoding/base64"
+	"gbserver/gitstore"
+	"gbserver/models"
+	"strings"
+	"time"
+)
+
+type GitActor struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Date  string `json:"date"`
+}
+
+type ObjectRef struct {
+	SHA     string `json:"sha"`
+	URL     string `json:"url"`
+	HTMLURL string `json:"html_url,omitempty"`
+}
+
+type CommitData struct {
+	URL       string    `json:"url"`
+	Author    GitActor  `json:"author"`
+	Committer GitActor  `json:"committer"`
+	Message   string    `json:"message"`
+	Tree      ObjectRef `json:"tree"`
+}
+
+type CommitStats struct {
+	Total     int `json:"total"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+}
+
+type CommitFile struct {
+	SHA              string `json:"sha"`
+	Filename         string `json:"filename"`
+	Status           string `json:"status"`
+	Additions        int    `json:"additions"`
+	Deletions        int    `json:"deletions"`
+	Changes          int    `json:"changes"`
+	BlobURL          string `json:"blob_url"`
+	RawURL           string `json:"raw_url"`
+	ContentsURL      string `json:"contents_url"`
+	PreviousFilename string `json:"previous_filename,omitempty"`
+}
+
+// CommitResponse is a commit of the commits API, Stats and Files are only set for a single commit.
+type CommitResponse struct {
+	SHA       string       `json:"sha"`
+	NodeID    string       `json:"node_id"`
+	URL       string       `json:"url"`
+	HTMLURL   string       `json:"html_url"`
+	Commit    CommitData   `json:"commit"`
+	Author    *OwnerInfo   `json:"author"`
+	Committer *OwnerInfo   `json:"committer"`
+	Parents   []ObjectRef  `json:"parents"`
+	Stats     *CommitStats `json:"stats,omitempty"`
+	Files     []CommitFile `json:"files,omitempty"`
+}
+
+// GitCommitResponse is a commit of the git database API, /git/commits/{sha}.
+type GitCommitResponse struct {
+	SHA          string             `json:"sha"`
+	NodeID       string             `json:"node_id"`
+	URL          string             `json:"url"`
+	HTMLURL      string             `json:"html_url"`
+	Author       GitActor           `json:"author"`
+	Committer    GitActor           `json:"committer"`
+	Message      string             `json:"message"`
+	Tree         ObjectRef          `json:"tree"`
+	Parents      []ObjectRef        `json:"parents"`
+	Verification CommitVerification `json:"verification"`
+}
+
+type CommitVerification struct {
+	Verified bool   `json:"verified"`
+	Reason   string `json:"reason"`
+}
+
+type CompareResponse struct {
+	URL             string           `json:"url"`
+	HTMLURL         string           `json:"html_url"`
+	Status          string           `json:"status"`
+	AheadBy         int              `json:"ahead_by"`
+	BehindBy        int              `json:"behind_by"`
+	TotalCommits    int              `json:"total_commits"`
+	BaseCommit      CommitResponse   `json:"base_commit"`
+	MergeBaseCommit CommitResponse   `json:"merge_base_commit"`
+	Commits         []CommitResponse `json:"commits"`
+	Files           []CommitFile     `json:"files"`
+}
+
+// CommitFilter holds the query parameters of the commit list, Since and Until are ISO 8601 timestamps.
+type CommitFilter struct {
+	SHA   string
+	Path  string
+	Since string
+	Until string
+}
+
+// compareLimit is the number of commits github returns at most from a comparison.
+const compareLimit = 250
+
+var fileStatuses = map[string]string{"A": "added", "D": "removed", "M": "modified", "R": "renamed", "C": "copied", "T": "changed"}
+
+func htmlURL(owner, repoName string) string {
+	return "https://gbserver.com/" + owner + "/" + repoName
+}
+
+// commitNodeID is the global id of a commit, made from its SHA the way github's legacy ids are.
+func commitNodeID(sha string) string {
+	return base64.StdEncoding.EncodeToString([]byte("006:Commit" + sha))
+}
+
+func gitActor(sig gitstore.Signature) GitActor {
+	return GitActor{Name: sig.Name, Email: sig.Email, Date: sig.When.UTC().Format(time.RFC3339)}
+}
+
+// userOfSignature finds the org user who made a commit, by the noreply address signatureOf gives them
+// or by their login. Caller must hold the store lock.
+func (g *GbService) userOfSignature(orgName string, sig gitstore.Signature) *OwnerInfo {
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	if !exists {
+		return nil
+	}
+	for _, login := range org.Users {
+		user := g.GbStoreInstance.Users[orgName+"/"+login]
+		if user != nil && (sig.Email == signatureOf(user, sig.When).Email || sig.Name == user.LoginName) {
+			ownerInfo := ownerInfoOf(user)
+			return &ownerInfo
+		}
+	}
+	return nil
+}
+
+// resolveRef turns a branch name or (abbreviated) SHA into a commit SHA. Caller must hold the store lock.
+func (g *GbService) resolveRef(repoKey, ref string) (string, error) {
+	if branch, exists := g.GbStoreInstance.Branches[repoKey+"/"+ref]; exists {
+		return branch.CommitInfo.SHA, nil
+	}
+	sha, err := g.Git.ResolveCommit(repoKey, ref)
+	if err != nil {
+		return "", ErrCommitNotFound
+	}
+	return sha, nil
+}
+
+// gitRepoKey validates the repo of a commits API call. Caller must not hold the store lock.
+func (g *GbService) gitRepoKey(orgName, owner, repoName string) (string, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return "", err
+	}
+	if g.Git == nil {
+		return "", ErrGitDisabled
+	}
+	return orgName + "/" + owner + "/" + repoName, nil
+}
+
+func (g *GbService) commitResponse(orgName, owner, repoName string, commit gitstore.Commit) CommitResponse {
+	apiURL := repoAPIURL(owner, repoName)
+	commitResp := CommitResponse{
+		SHA:     commit.SHA,
+		NodeID:  commitNodeID(commit.SHA),
+		URL:     apiURL + "/commits/" + commit.SHA,
+		HTMLURL: htmlURL(owner, repoName) + "/commit/" + commit.SHA,
+		Commit: CommitData{
+			URL:       apiURL + "/git/commits/" + commit.SHA,
+			Author:    gitActor(commit.Author),
+			Committer: gitActor(commit.Committer),
+			Message:   commit.Message,
+			Tree:      ObjectRef{SHA: commit.Tree, URL: apiURL + "/git/trees/" + commit.Tree},
+		},
+		Author:    g.userOfSignature(orgName, commit.Author),
+		Committer: g.userOfSignature(orgName, commit.Committer),
+		Parents:   parentRefs(owner, repoName, commit.Parents),
+	}
+	return commitResp
+}
+
+func parentRefs(owner, repoName string, parents []string) []ObjectRef {
+	parentList := []ObjectRef{}
+	for _, parent := range parents {
+		parentList = append(parentList, ObjectRef{SHA: parent, URL: repoAPIURL(owner, repoName) + "/commits/" + parent,
+			HTMLURL: htmlURL(owner, repoName) + "/commit/" + parent})
+	}
+	return parentList
+}
+
+func commitFiles(owner, repoName, ref string, files []gitstore.FileStat) []CommitFile {
+	fileList := []CommitFile{}
+	for _, file := range files {
+		fileList = append(fileList, CommitFile{
+			SHA:              file.Blob,
+			Filename:         file.Path,
+			Status:           fileStatuses[file.Status],
+			Additions:        file.Additions,
+			Deletions:        file.Deletions,
+			Changes:          file.Additions + file.Deletions,
+			BlobURL:          htmlURL(owner, repoName) + "/blob/" + ref + "/" + file.Path,
+			RawURL:           htmlURL(owner, repoName) + "/raw/" + ref + "/" + file.Path,
+			ContentsURL:      repoAPIURL(owner, repoName) + "/contents/" + file.Path + "?ref=" + ref,
+			PreviousFilename: file.OldPath,
+		})
+	}
+	return fileList
+}
+
+func commitStats(files []gitstore.FileStat) CommitStats {
+	var stats CommitStats
+	for _, file := range files {
+		stats.Additions += file.Additions
+		stats.Deletions += file.Deletions
+	}
+	stats.Total = stats.Additions + stats.Deletions
+	return stats
+}
+
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+	return timestamp, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/commits
+func (g *GbService) ListCommits(orgName, owner, repoName string, filter CommitFilter) ([]CommitResponse, error) {
+	commitList := []CommitResponse{}
+	repoKey, err := g.gitRepoKey(orgName, owner, repoName)
+	if err != nil {
+		return commitList, err
+	}
+	since, err := parseTimestamp(filter.Since)
+	if err != nil {
+		return commitList, err
+	}
+	until, err := parseTimestamp(filter.Until)
+	if err != nil {
+		return commitList, err
+	}
+
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	ref := filter.SHA
+	if ref == "" {
+		ref = g.defaultBranch(repoKey)
+		if _, exists := g.GbStoreInstance.Branches[repoKey+"/"+ref]; !exists {
+			// github answers 409 for an empty repo, there is nothing to list.
+			return commitList, ErrRepoEmpty
+		}
+	}
+	head, err := g.resolveRef(repoKey, ref)
+	if err != nil {
+		return commitList, err
+	}
+	commits, err := g.Git.Log(repoKey, gitstore.LogOptions{Head: head, Path: filter.Path, Since: since, Until: until})
+	if err != nil {
+		return commitList, err
+	}
+	for _, commit := range commits {
+		commitList = append(commitList, g.commitResponse(orgName, owner, repoName, commit))
+	}
+	return commitList, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/commits/{ref}
+func (g *GbService) GetCommit(orgName, owner, repoName, ref string) (CommitResponse, error) {
+	var commitResp CommitResponse
+	repoKey, err := g.gitRepoKey(orgName, owner, repoName)
+	if err != nil {
+		return commitResp, err
+	}
+
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	sha, err := g.resolveRef(repoKey, ref)
+	if err != nil {
+		return commitResp, err
+	}
+	commit, err := g.Git.ReadCommit(repoKey, sha)
+	if err != nil {
+		return commitResp, ErrCommitNotFound
+	}
+	// like github, a merge commit shows what it changed compared to its first parent.
+	var parent string
+	if len(commit.Parents) > 0 {
+		parent = commit.Parents[0]
+	}
+	files, err := g.Git.Diff(repoKey, parent, commit.SHA)
+	if err != nil {
+		return commitResp, err
+	}
+	commitResp = g.commitResponse(orgName, owner, repoName, commit)
+	stats := commitStats(files)
+	commitResp.Stats = &stats
+	commitResp.Files = commitFiles(owner, repoName, commit.SHA, files)
+	return commitResp, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/git/commits/{sha}
+func (g *GbService) GetGitCommit(orgName, owner, repoName, sha string) (GitCommitResponse, error) {
+	repoKey, err := g.gitRepoKey(orgName, owner, repoName)
+	if err != nil {
+		return GitCommitResponse{}, err
+	}
+	commit, err := g.Git.ReadCommit(repoKey, sha)
+	if err != nil {
+		return GitCommitResponse{}, ErrCommitNotFound
+	}
+	return gitCommitResponse(owner, repoName, commit), nil
+}
+
+func gitCommitResponse(owner, repoName string, commit gitstore.Commit) GitCommitResponse {
+	apiURL := repoAPIURL(owner, repoName)
+	gitCommitResp := GitCommitResponse{
+		SHA:          commit.SHA,
+		NodeID:       commitNodeID(commit.SHA),
+		URL:          apiURL + "/git/commits/" + commit.SHA,
+		HTMLURL:      htmlURL(owner, repoName) + "/commit/" + commit.SHA,
+		Author:       gitActor(commit.Author),
+		Committer:    gitActor(commit.Committer),
+		Message:      commit.Message,
+		Tree:         ObjectRef{SHA: commit.Tree, URL: apiURL + "/git/trees/" + commit.Tree},
+		Parents:      []ObjectRef{},
+		Verification: CommitVerification{Verified: false, Reason: "unsigned"},
+	}
+	for _, parent := range commit.Parents {
+		gitCommitResp.Parents = append(gitCommitResp.Parents, ObjectRef{SHA: parent, URL: apiURL + "/git/commits/" + parent,
+			HTMLURL: htmlURL(owner, repoName) + "/commit/" + parent})
+	}
+	return gitCommitResp
+}
+
+// splitBaseHead splits "base...head" (or "base..head"), an "owner:" in front of a ref is dropped.
+func splitBaseHead(basehead string) (string, string, bool) {
+	base, head, found := strings.Cut(basehead, "...")
+	if !found {
+		base, head, found = strings.Cut(basehead, "..")
+	}
+	if !found || base == "" || head == "" {
+		return "", "", false
+	}
+	if _, ref, found := strings.Cut(base, ":"); found {
+		base = ref
+	}
+	if _, ref, found := strings.Cut(head, ":"); found {
+		head = ref
+	}
+	return base, head, true
+}
+
+// get /repos/{org}/{owner}/{repo}/compare/{base}...{head}
+func (g *GbService) CompareCommits(orgName, owner, repoName, basehead string) (CompareResponse, error) {
+	var compareResp CompareResponse
+	repoKey, err := g.gitRepoKey(orgName, owner, repoName)
+	if err != nil {
+		return compareResp, err
+	}
+	baseRef, headRef, ok := splitBaseHead(basehead)
+	if !ok {
+		return compareResp, ErrInvalidBaseHead
+	}
+
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	base, err := g.resolveRef(repoKey, baseRef)
+	if err != nil {
+		return compareResp, err
+	}
+	head, err := g.resolveRef(repoKey, headRef)
+	if err != nil {
+		return compareResp, err
+	}
+	mergeBase, err := g.Git.MergeBase(repoKey, base, head)
+	if err != nil {
+		return compareResp, err
+	}
+	if mergeBase == "" {
+		return compareResp, ErrNoCommonAncestor
+	}
+	aheadBy, err := g.Git.CountCommits(repoKey, base, head)
+	if err != nil {
+		return compareResp, err
+	}
+	behindBy, err := g.Git.CountCommits(repoKey, head, base)
+	if err != nil {
+		return compareResp, err
+	}
+	commits, err := g.Git.Log(repoKey, gitstore.LogOptions{Head: head, Exclude: base})
+	if err != nil {
+		return compareResp, err
+	}
+	files, err := g.Git.Diff(repoKey, mergeBase, head)
+	if err != nil {
+		return compareResp, err
+	}
+	baseCommit, _ := g.Git.ReadCommit(repoKey, base)
+	mergeBaseCommit, _ := g.Git.ReadCommit(repoKey, mergeBase)
+
+	compareResp = CompareResponse{
+		URL:             repoAPIURL(owner, repoName) + "/compare/" + basehead,
+		HTMLURL:         htmlURL(owner, repoName) + "/compare/" + basehead,
+		Status:          "diverged",
+		AheadBy:         aheadBy,
+		BehindBy:        behindBy,
+		TotalCommits:    aheadBy,
+		BaseCommit:      g.commitResponse(orgName, owner, repoName, baseCommit),
+		MergeBaseCommit: g.commitResponse(orgName, owner, repoName, mergeBaseCommit),
+		Commits:         []CommitResponse{},
+		Files:           commitFiles(owner, repoName, head, files),
+	}
+	switch {
+	case aheadBy == 0 && behindBy == 0:
+		compareResp.Status = "identical"
+	case behindBy == 0:
+		compareResp.Status = "ahead"
+	case aheadBy == 0:
+		compareResp.Status = "behind"
+	}
+	// the oldest commits come first and github stops after 250 of them.
+	for i := len(commits) - 1; i >= 0 && len(compareResp.Commits) < compareLimit; i-- {
+		compareResp.Commits = append(compareResp.Commits, g.commitResponse(orgName, owner, repoName, commits[i]))
+	}
+	return compareResp, nil
+}
+
+// refreshPRStats recounts the commits and changed lines of an open pull request from git.
+// Caller must hold the store lock.
+func (g *GbService) refreshPRStats(repoKey string, pr *models.PullRequest) {
+	if g.Git == nil || pr.State != "open" {
+		return
+	}
+	headBranch := g.GbStoreInstance.Branches[repoKey+"/"+headBranchOf(pr)]
+	baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+pr.ToBranch]
+	if headBranch == nil || baseBranch == nil {
+		return
+	}
+	head, base := headBranch.CommitInfo.SHA, baseBranch.CommitInfo.SHA
+	commits, err := g.Git.CountCommits(repoKey, base, head)
+	if err != nil {
+		g.logger().Error("Error occurred while counting the commits of pull request", "repo", repoKey, "number", pr.Number, "error", err)
+		return
+	}
+	mergeBase, err := g.Git.MergeBase(repoKey, base, head)
+	if err != nil {
+		g.logger().Error("Error occurred while diffing pull request", "repo", repoKey, "number", pr.Number, "error", err)
+		return
+	}
+	files, err := g.Git.Diff(repoKey, mergeBase, head)
+	if err != nil {
+		g.logger().Error("Error occurred while diffing pull request", "repo", repoKey, "number", pr.Number, "error", err)
+		return
+	}
+	stats := commitStats(files)
+	pr.Commits, pr.Additions, pr.Deletions, pr.ChangedFiles = commits, stats.Additions, stats.Deletions, len(files)
+}
+
+// refreshBranchPRStats recounts the open pull requests a moved branch is the head or base of.
+// Caller must hold the store lock.
+func (g *GbService) refreshBranchPRStats(repoKey, branchName string) {
+	for _, prID := range g.GbStoreInstance.Repos[repoKey].PrIDs {
+		pr := g.GbStoreInstance.PullRequests[prID]
+		if pr != nil && (pr.ToBranch == branchName || headBranchOf(pr) == branchName) {
+			g.refreshPRStats(repoKey, pr)
+		}
+	}
+}
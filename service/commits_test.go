@@ -0,0 +1,70 @@
+package service
+
+import (
+	"gbserver/gitstore"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommitsAndCompare(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := gitstore.Signature{Name: "gbuser", Email: "gbuser@gbserver.com"}
+	root := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	head, _ := gitService.Git.Commit(repoKey, root, []gitstore.FileChange{{Path: "README.md", Content: []byte("# gbrepo\nmore\n")},
+		{Path: "src/feature.go", Content: []byte("package src\n\nfunc Feature() {}\n")}}, "Add feature", sig, sig)
+	base, _ := gitService.Git.Commit(repoKey, root, []gitstore.FileChange{{Path: "other.txt", Content: []byte("other\n")}}, "Add other", sig, sig)
+	gitService.GbStoreInstance.Branches[repoKey+"/gbbranch"].CommitInfo = commitDetails("gbuser", "gbrepo", head)
+	gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo = commitDetails("gbuser", "gbrepo", base)
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+
+	commitList, err := gitService.ListCommits("gborg", "gbuser", "gbrepo", CommitFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, commitList, 2)
+	assert.Equal(t, base, commitList[0].SHA)
+	assert.Equal(t, "gbuser", commitList[0].Author.Login)
+	commitList, _ = gitService.ListCommits("gborg", "gbuser", "gbrepo", CommitFilter{SHA: "gbbranch", Path: "src"})
+	assert.Len(t, commitList, 1)
+	commitList, _ = gitService.ListCommits("gborg", "gbuser", "gbrepo", CommitFilter{Since: time.Now().Add(time.Hour).UTC().Format(time.RFC3339)})
+	assert.Empty(t, commitList)
+	_, err = gitService.ListCommits("gborg", "gbuser", "gbrepo", CommitFilter{Since: "yesterday"})
+	assert.Equal(t, ErrInvalidTimestamp, err)
+
+	commit, err := gitService.GetCommit("gborg", "gbuser", "gbrepo", head[:7])
+	assert.NoError(t, err)
+	assert.Equal(t, head, commit.SHA)
+	assert.Equal(t, []string{root}, []string{commit.Parents[0].SHA})
+	assert.Equal(t, CommitStats{Total: 5, Additions: 4, Deletions: 1}, *commit.Stats)
+	assert.Equal(t, "modified", commit.Files[0].Status)
+	assert.Equal(t, "added", commit.Files[1].Status)
+	_, err = gitService.GetCommit("gborg", "gbuser", "gbrepo", "nope")
+	assert.Equal(t, ErrCommitNotFound, err)
+
+	gitCommit, err := gitService.GetGitCommit("gborg", "gbuser", "gbrepo", head)
+	assert.NoError(t, err)
+	assert.Equal(t, "Add feature", gitCommit.Message)
+
+	compare, err := gitService.CompareCommits("gborg", "gbuser", "gbrepo", "master...gbuser:gbbranch")
+	assert.NoError(t, err)
+	assert.Equal(t, "diverged", compare.Status)
+	assert.Equal(t, 1, compare.AheadBy)
+	assert.Equal(t, 1, compare.BehindBy)
+	assert.Equal(t, root, compare.MergeBaseCommit.SHA)
+	assert.Len(t, compare.Files, 2)
+	compare, _ = gitService.CompareCommits("gborg", "gbuser", "gbrepo", root+"..."+head)
+	assert.Equal(t, "ahead", compare.Status)
+	_, err = gitService.CompareCommits("gborg", "gbuser", "gbrepo", "master")
+	assert.Equal(t, ErrInvalidBaseHead, err)
+
+	// pull requests count their commits and lines from git.
+	_, err = gitService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/second", SHA: head})
+	assert.NoError(t, err)
+	title := "Second"
+	pr, err := gitService.CreatePR("gborg", "gbuser", "gbrepo", &PRRequest{Title: &title, Head: "gbuser:second", Base: "master"})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pr.Commits)
+	assert.Equal(t, 4, pr.Additions)
+	assert.Equal(t, 2, pr.ChangedFiles)
+}
@@ -0,0 +1,321 @@
+package service
+
+import (
+	"encoding/base64"
+	"gbserver/gitstore"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type ContentLinks struct {
+	Self string `json:"self"`
+	Git  string `json:"git"`
+	HTML string `json:"html"`
+}
+
+// ContentResponse is a file, directory, symlink or submodule of the contents API. Only files carry
+// their content, directories list their Entries instead.
+type ContentResponse struct {
+	Type        string            `json:"type"`
+	Encoding    string            `json:"encoding,omitempty"`
+	Size        int64             `json:"size"`
+	Name        string            `json:"name"`
+	Path        string            `json:"path"`
+	Content     string            `json:"content,omitempty"`
+	SHA         string            `json:"sha"`
+	URL         string            `json:"url"`
+	GitURL      string            `json:"git_url"`
+	HTMLURL     string            `json:"html_url"`
+	DownloadURL *string           `json:"download_url"`
+	Links       ContentLinks      `json:"_links"`
+	Entries     []ContentResponse `json:"-"`
+}
+
+// CommitIdentity overrides the author or committer of a contents write.
+type CommitIdentity struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Date  string `json:"date"`
+}
+
+type FileCommitRequest struct {
+	Message string `json:"message"`
+	// Content is base64 encoded, DeleteContents ignores it.
+	Content   string          `json:"content"`
+	SHA       string          `json:"sha"`
+	Branch    string          `json:"branch"`
+	Committer *CommitIdentity `json:"committer"`
+	Author    *CommitIdentity `json:"author"`
+}
+
+type FileCommitResponse struct {
+	Content *ContentResponse  `json:"content"`
+	Commit  GitCommitResponse `json:"commit"`
+}
+
+var entryTypes = map[string]string{"blob": "file", "tree": "dir", "commit": "submodule"}
+
+// cleanContentPath rejects paths that would leave the repo or write into its .git directory, whatever the case
+// of the file system, as well as control characters git's index cannot take. "" is the root directory.
+func cleanContentPath(contentPath string) (string, error) {
+	contentPath = strings.Trim(contentPath, "/")
+	if contentPath == "" {
+		return "", nil
+	}
+	if strings.ContainsFunc(contentPath, unicode.IsControl) {
+		return "", ErrInvalidContentPath
+	}
+	for _, segment := range strings.Split(contentPath, "/") {
+		if segment == "" || segment == "." || segment == ".." || strings.EqualFold(segment, ".git") {
+			return "", ErrInvalidContentPath
+		}
+	}
+	return contentPath, nil
+}
+
+// encodeContent base64 encodes file content in lines of 60 characters, the way github sends it.
+func encodeContent(data []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var lines strings.Builder
+	for len(encoded) > 60 {
+		lines.WriteString(encoded[:60] + "\n")
+		encoded = encoded[60:]
+	}
+	lines.WriteString(encoded + "\n")
+	return lines.String()
+}
+
+func contentResponse(owner, repoName, ref string, entry gitstore.TreeEntry) ContentResponse {
+	entryType := entryTypes[entry.Type]
+	if entry.Mode == "120000" {
+		entryType = "symlink"
+	}
+	apiURL := repoAPIURL(owner, repoName)
+	contentResp := ContentResponse{
+		Type:    entryType,
+		Size:    entry.Size,
+		Name:    path.Base(entry.Path),
+		Path:    entry.Path,
+		SHA:     entry.SHA,
+		URL:     apiURL + "/contents/" + entry.Path + "?ref=" + ref,
+		GitURL:  apiURL + "/git/blobs/" + entry.SHA,
+		HTMLURL: htmlURL(owner, repoName) + "/blob/" + ref + "/" + entry.Path,
+	}
+	if entry.Type == "tree" {
+		contentResp.GitURL = apiURL + "/git/trees/" + entry.SHA
+		contentResp.HTMLURL = htmlURL(owner, repoName) + "/tree/" + ref + "/" + entry.Path
+	}
+	if entryType == "file" {
+		downloadURL := "https://raw.gbserver.com/" + owner + "/" + repoName + "/" + ref + "/" + entry.Path
+		contentResp.DownloadURL = &downloadURL
+	}
+	contentResp.Links = ContentLinks{Self: contentResp.URL, Git: contentResp.GitURL, HTML: contentResp.HTMLURL}
+	return contentResp
+}
+
+// get /repos/{org}/{owner}/{repo}/contents/{path}
+func (g *GbService) GetContents(orgName, owner, repoName, contentPath, ref string) (ContentResponse, error) {
+	var contentResp ContentResponse
+	repoKey, err := g.gitRepoKey(orgName, owner, repoName)
+	if err != nil {
+		return contentResp, err
+	}
+	contentPath, err = cleanContentPath(contentPath)
+	if err != nil {
+		return contentResp, err
+	}
+
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	if ref == "" {
+		ref = g.defaultBranch(repoKey)
+	}
+	commit, err := g.resolveRef(repoKey, ref)
+	if err != nil {
+		if len(g.GbStoreInstance.Repos[repoKey].Branches) == 0 {
+			return contentResp, ErrContentNotFound
+		}
+		return contentResp, err
+	}
+	entry, err := g.Git.Entry(repoKey, commit, contentPath)
+	if err == gitstore.ErrObjectNotFound {
+		return contentResp, ErrContentNotFound
+	}
+	if err != nil {
+		return contentResp, err
+	}
+	entry.Path = contentPath
+	contentResp = contentResponse(owner, repoName, ref, entry)
+	switch entry.Type {
+	case "blob":
+		data, err := g.Git.ReadBlob(repoKey, entry.SHA)
+		if err != nil {
+			return contentResp, err
+		}
+		contentResp.Encoding = "base64"
+		contentResp.Content = encodeContent(data)
+	case "tree":
+		entries, err := g.Git.ListTree(repoKey, commit, contentPath)
+		if err != nil {
+			return contentResp, err
+		}
+		contentResp.Entries = []ContentResponse{}
+		for _, child := range entries {
+			contentResp.Entries = append(contentResp.Entries, contentResponse(owner, repoName, ref, child))
+		}
+	}
+	return contentResp, nil
+}
+
+// put /repos/{org}/{owner}/{repo}/contents/{path}
+// Creates or updates a file with a new commit on the branch, created tells which one it was.
+func (g *GbService) PutContents(orgName, owner, repoName, contentPath string, fileReq *FileCommitRequest) (FileCommitResponse, bool, error) {
+	content, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(fileReq.Content, "\n", ""))
+	if err != nil {
+		return FileCommitResponse{}, false, ErrInvalidContent
+	}
+	return g.commitContents(orgName, owner, repoName, contentPath, fileReq, gitstore.FileChange{Content: content})
+}
+
+// delete /repos/{org}/{owner}/{repo}/contents/{path}
+func (g *GbService) DeleteContents(orgName, owner, repoName, contentPath string, fileReq *FileCommitRequest) (FileCommitResponse, error) {
+	fileResp, _, err := g.commitContents(orgName, owner, repoName, contentPath, fileReq, gitstore.FileChange{Delete: true})
+	return fileResp, err
+}
+
+// commitContents writes or deletes one file with a commit on top of the branch of the request.
+func (g *GbService) commitContents(orgName, owner, repoName, contentPath string, fileReq *FileCommitRequest, change gitstore.FileChange) (FileCommitResponse, bool, error) {
+	var fileResp FileCommitResponse
+	repoKey, err := g.gitRepoKey(orgName, owner, repoName)
+	if err != nil {
+		return fileResp, false, err
+	}
+	contentPath, err = cleanContentPath(contentPath)
+	if err != nil {
+		return fileResp, false, err
+	}
+	if contentPath == "" {
+		return fileResp, false, ErrInvalidContentPath
+	}
+	if strings.TrimSpace(fileReq.Message) == "" {
+		return fileResp, false, ErrCommitMessageRequired
+	}
+	change.Path = contentPath
+
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	repo := g.GbStoreInstance.Repos[repoKey]
+	branchName := fileReq.Branch
+	if branchName == "" {
+		branchName = g.defaultBranch(repoKey)
+		if len(repo.Branches) == 0 {
+			// the first file of an empty repo starts its default branch, same as auto_init.
+			branchName = "main"
+		}
+	}
+	branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+	if branch == nil && len(repo.Branches) > 0 {
+		return fileResp, false, ErrBranchesNotFound
+	}
+
+	var parent string
+	exists := false
+	if branch != nil {
+		parent = branch.CommitInfo.SHA
+		entry, err := g.Git.Entry(repoKey, parent, contentPath)
+		if err != nil && err != gitstore.ErrObjectNotFound {
+			return fileResp, false, err
+		}
+		exists = err == nil
+		if exists && entry.Type != "blob" {
+			return fileResp, false, ErrContentIsDirectory
+		}
+		if exists && fileReq.SHA == "" {
+			return fileResp, false, ErrContentSHARequired
+		}
+		if exists && fileReq.SHA != entry.SHA {
+			return fileResp, false, ErrContentSHAMismatch
+		}
+	}
+	if change.Delete && !exists {
+		return fileResp, false, ErrContentNotFound
+	}
+	if !exists && fileReq.SHA != "" {
+		// the caller expects a file that is not there (any more).
+		return fileResp, false, ErrContentSHAMismatch
+	}
+
+	now := time.Now().UTC()
+	committerSig := signatureOf(g.actingUser(orgName, owner), now)
+	if fileReq.Committer != nil {
+		committerSig, err = identitySignature(fileReq.Committer, now)
+		if err != nil {
+			return fileResp, false, err
+		}
+	}
+	authorSig := committerSig
+	if fileReq.Author != nil {
+		authorSig, err = identitySignature(fileReq.Author, now)
+		if err != nil {
+			return fileResp, false, err
+		}
+	}
+	sha, err := g.Git.Commit(repoKey, parent, []gitstore.FileChange{change}, fileReq.Message, authorSig, committerSig)
+	if err != nil {
+		return fileResp, false, err
+	}
+	err = g.checkRefUpdate(orgName, owner, repoName, branchName, parent, sha)
+	if err != nil {
+		return fileResp, false, err
+	}
+	err = g.Git.UpdateRef(repoKey, "refs/heads/"+branchName, sha, parent)
+	if err != nil {
+		return fileResp, false, err
+	}
+	if branch == nil {
+		g.addBranch(orgName, owner, repoName, branchName, sha)
+		g.Git.SetHead(repoKey, branchName)
+		g.emitBranch("create", orgName, owner, repoName, branchName, sha)
+	} else {
+		branch.CommitInfo = commitDetails(owner, repoName, sha)
+		g.refreshBranchPRStats(repoKey, branchName)
+		g.emitPush(orgName, owner, repoName, branchName, parent, sha)
+	}
+	err = g.persist()
+	if err != nil {
+		return fileResp, false, err
+	}
+
+	commit, err := g.Git.ReadCommit(repoKey, sha)
+	if err != nil {
+		return fileResp, false, err
+	}
+	fileResp.Commit = gitCommitResponse(owner, repoName, commit)
+	if !change.Delete {
+		entry, err := g.Git.Entry(repoKey, sha, contentPath)
+		if err != nil {
+			return fileResp, false, err
+		}
+		contentResp := contentResponse(owner, repoName, branchName, entry)
+		fileResp.Content = &contentResp
+	}
+	return fileResp, !exists, nil
+}
+
+// identitySignature turns the author or committer of a contents request into a git signature.
+func identitySignature(identity *CommitIdentity, now time.Time) (gitstore.Signature, error) {
+	if identity.Name == "" || identity.Email == "" {
+		return gitstore.Signature{}, ErrInvalidCommitIdentity
+	}
+	when := now
+	if identity.Date != "" {
+		date, err := time.Parse(time.RFC3339, identity.Date)
+		if err != nil {
+			return gitstore.Signature{}, ErrInvalidTimestamp
+		}
+		when = date
+	}
+	return gitstore.Signature{Name: identity.Name, Email: identity.Email, When: when}, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContents(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	encode := func(content string) string { return base64.StdEncoding.EncodeToString([]byte(content)) }
+
+	readme, err := gitService.GetContents("gborg", "gbuser", "gbrepo", "README.md", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "file", readme.Type)
+	content, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(readme.Content, "\n", ""))
+	assert.Equal(t, "# gbrepo\ngbuser repo\n", string(content))
+
+	created, isNew, err := gitService.PutContents("gborg", "gbuser", "gbrepo", "config/app.yaml", &FileCommitRequest{Message: "Add config", Content: encode("a: 1\n")})
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, "app.yaml", created.Content.Name)
+	assert.Equal(t, created.Commit.SHA, gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA)
+
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "config/app.yaml", &FileCommitRequest{Message: "Change config", Content: encode("a: 2\n")})
+	assert.Equal(t, ErrContentSHARequired, err)
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "config/app.yaml", &FileCommitRequest{Message: "Change config", Content: encode("a: 2\n"), SHA: readme.SHA})
+	assert.Equal(t, ErrContentSHAMismatch, err)
+	updated, isNew, err := gitService.PutContents("gborg", "gbuser", "gbrepo", "config/app.yaml", &FileCommitRequest{Message: "Change config", Content: encode("a: 2\n"),
+		SHA: created.Content.SHA, Branch: "gbbranch", Author: &CommitIdentity{Name: "Bot", Email: "bot@example.com"}})
+	assert.Equal(t, ErrContentSHAMismatch, err)
+	assert.Nil(t, updated.Content)
+	updated, isNew, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "config/app.yaml", &FileCommitRequest{Message: "Change config", Content: encode("a: 2\n"),
+		SHA: created.Content.SHA, Author: &CommitIdentity{Name: "Bot", Email: "bot@example.com"}})
+	assert.NoError(t, err)
+	assert.False(t, isNew)
+	assert.Equal(t, "Bot", updated.Commit.Author.Name)
+	assert.Equal(t, "gbuser", updated.Commit.Committer.Name)
+	assert.Equal(t, []string{created.Commit.SHA}, []string{updated.Commit.Parents[0].SHA})
+
+	dir, err := gitService.GetContents("gborg", "gbuser", "gbrepo", "config", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "dir", dir.Type)
+	assert.Equal(t, "config/app.yaml", dir.Entries[0].Path)
+	old, err := gitService.GetContents("gborg", "gbuser", "gbrepo", "config/app.yaml", created.Commit.SHA)
+	assert.NoError(t, err)
+	assert.Equal(t, encode("a: 1\n")+"\n", old.Content)
+
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "config", &FileCommitRequest{Message: "x", Content: encode("x")})
+	assert.Equal(t, ErrContentIsDirectory, err)
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "../escape", &FileCommitRequest{Message: "x", Content: encode("x")})
+	assert.Equal(t, ErrInvalidContentPath, err)
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "x.txt", &FileCommitRequest{Message: "x", Content: "not base64!"})
+	assert.Equal(t, ErrInvalidContent, err)
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "x.txt", &FileCommitRequest{Content: encode("x")})
+	assert.Equal(t, ErrCommitMessageRequired, err)
+
+	deleted, err := gitService.DeleteContents("gborg", "gbuser", "gbrepo", "config/app.yaml", &FileCommitRequest{Message: "Remove config", SHA: updated.Content.SHA})
+	assert.NoError(t, err)
+	assert.Nil(t, deleted.Content)
+	_, err = gitService.GetContents("gborg", "gbuser", "gbrepo", "config/app.yaml", "")
+	assert.Equal(t, ErrContentNotFound, err)
+
+	// the first file of an empty repo starts the main branch.
+	_, err = gitService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "empty"})
+	assert.NoError(t, err)
+	_, err = gitService.GetContents("gborg", "gbuser", "empty", "", "")
+	assert.Equal(t, ErrContentNotFound, err)
+	_, isNew, err = gitService.PutContents("gborg", "gbuser", "empty", "README.md", &FileCommitRequest{Message: "Start", Content: encode("hi\n")})
+	assert.NoError(t, err)
+	assert.True(t, isNew)
+	assert.Equal(t, []string{"main"}, gitService.GbStoreInstance.Repos["gborg/gbuser/empty"].Branches)
+}
+
+func TestCleanContentPath(t *testing.T) {
+	for contentPath, want := range map[string]string{"": "", "/": "", "/docs/README.md/": "docs/README.md", "a.gitignore": "a.gitignore"} {
+		cleaned, err := cleanContentPath(contentPath)
+		assert.NoError(t, err, contentPath)
+		assert.Equal(t, want, cleaned, contentPath)
+	}
+	for _, contentPath := range []string{"a//b", "./a", "a/../../b", ".git/config", "docs/.GIT/hooks/pre-receive", ".Git",
+		"line\nbreak.txt", "carriage\rreturn.txt", "nul\x00.txt", "tab\t.txt", "del\x7f.txt"} {
+		_, err := cleanContentPath(contentPath)
+		assert.Equal(t, ErrInvalidContentPath, err, contentPath)
+	}
+}
@@ -1,13 +1,15 @@
 package service
 
 import (
+	"gbserver/gitstore"
 	"gbserver/models"
-	"hash/fnv"
+	"log/slog"
 	"math/rand"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type OwnerInfo struct {
@@ -28,6 +30,8 @@ type RepoResponse struct {
 type CreateRepoRequest struct {
 	Name        string
 	Description string
+	// AutoInit creates the repo with an initial commit on main, like github's auto_init.
+	AutoInit bool `json:"auto_init"`
 	//{"Name":"Hello-World","Description":"This is your first Repository",
 	//"homepage":"https://github.com","private":false,"has_issues":true,"has_projects":true,"has_wiki":true}'
 }
@@ -86,6 +90,7 @@ type baseHeadPRResponse struct {
 type PRResponse struct {
 	URL          string
 	ID           string
+	Number       int
 	NodeID       string
 	Title        string
 	Body         string
@@ -97,14 +102,24 @@ type PRResponse struct {
 	ChangedFiles int
 	Head         baseHeadPRResponse
 	Base         baseHeadPRResponse
+	Merged       bool       `json:"merged"`
+	MergedAt     string     `json:"merged_at,omitempty"`
+	MergedBy     *OwnerInfo `json:"merged_by,omitempty"`
+	MergeCommit  string     `json:"merge_commit_sha,omitempty"`
+	// Mergeable is null while github would still be computing it, i.e. for closed pull requests.
+	Mergeable          *bool       `json:"mergeable"`
+	MergeableState     string      `json:"mergeable_state"`
+	RequestedReviewers []OwnerInfo `json:"requested_reviewers"`
+	StatusesURL        string      `json:"statuses_url"`
 }
 
+// PRRequest opens a pull request, on update title and body are applied when present, so an empty body clears it.
 type PRRequest struct {
-	Title string `json:"title"`
-	Body  string `json:"body"`
-	Head  string `json:"head"`
-	Base  string `json:"base"`
-	State string `json:"state"`
+	Title *string `json:"title"`
+	Body  *string `json:"body"`
+	Head  string  `json:"head"`
+	Base  string  `json:"base"`
+	State string  `json:"state"`
 	// '{"Title":"Amazing new feature",
 	// "Body":"Please pull these awesome changes in!","head":"admin:new-feature","base":"master"}'
 
@@ -112,6 +127,20 @@ type PRRequest struct {
 	//'{"Title":"new Title","Body":"updated Body","State":"open","base":"master"}'
 }
 
+type MergePRRequest struct {
+	CommitTitle   string `json:"commit_title"`
+	CommitMessage string `json:"commit_message"`
+	SHA           string `json:"sha"`
+	MergeMethod   string `json:"merge_method"`
+	//'{"commit_title":"Expand enum","commit_message":"Add a new value to the merge_method enum","merge_method":"squash"}'
+}
+
+type MergePRResponse struct {
+	SHA     string `json:"sha"`
+	Merged  bool   `json:"merged"`
+	Message string `json:"message"`
+}
+
 type Gbservice interface {
 	ListRepos(orgName string) []RepoResponse                                         //get  /orgs/{org}/Repos
 	CreateRepo(orgName string, RepoRequest *CreateRepoRequest) (RepoResponse, error) // post   /orgs/{org}/Repos
@@ -123,20 +152,62 @@ type Gbservice interface {
 	DeleteBranch(owner, repoName, Ref string) (bool, error)                                        //delete /Repos/{owner}/{Repo}/git/refs/{Ref}
 
 	// Pull request
-	ListPRs(owner, repoName string) ([]PRResponse, error)                                       // get /Repos/{owner}/{Repo}/pulls
-	CreatePR(owner, repoName string, cPRReq *PRRequest) (PRResponse, error)                     // post /Repos/{owner}/{Repo}/pulls
-	UpdatePR(owner, repoName string, pull_number int, prRequest *PRRequest) (PRResponse, error) //patch /Repos/{owner}/{Repo}/pulls/{pull_number} State - closed
+	ListPRs(owner, repoName string) ([]PRResponse, error)                                               // get /Repos/{owner}/{Repo}/pulls
+	CreatePR(owner, repoName string, cPRReq *PRRequest) (PRResponse, error)                             // post /Repos/{owner}/{Repo}/pulls
+	UpdatePR(owner, repoName string, pull_number int, prRequest *PRRequest) (PRResponse, error)         //patch /Repos/{owner}/{Repo}/pulls/{pull_number}
+	MergePR(owner, repoName string, pull_number int, mergeReq *MergePRRequest) (MergePRResponse, error) //put /Repos/{owner}/{Repo}/pulls/{pull_number}/merge
 
 }
 
 type GbService struct {
 	GbStoreInstance *models.GbStore
+	// Storage is optional, when set every change to the store is written through it.
+	Storage models.Storage
+	// Seed is what Reset goes back to, nil means the default fixture.
+	Seed      models.Seed
+	Snapshots *Snapshots
+	// Git holds the object database of every repo, nil means commit SHAs are made up.
+	Git *gitstore.Store
+	// Webhooks delivers events to the configured hooks, nil means events are dropped.
+	Webhooks *Webhooks
+	// Assets keeps the content of release assets, nil means assets cannot be uploaded.
+	Assets *AssetStore
+	// Actor is the login of the authenticated caller, see WithActor.
+	Actor string
+	// Log is where the service logs, slog.Default() when nil, see WithLogger.
+	Log *slog.Logger
+}
+
+// WithLogger returns a copy of the service logging to logger, e.g. one tagged with the id of the request served.
+func (g *GbService) WithLogger(logger *slog.Logger) *GbService {
+	loggerService := *g
+	loggerService.Log = logger
+	return &loggerService
+}
+
+func (g *GbService) logger() *slog.Logger {
+	if g.Log == nil {
+		return slog.Default()
+	}
+	return g.Log
+}
+
+// persist writes the store through the configured storage. A failure is logged and comes back as
+// ErrStoreNotSaved, the change stays in memory. Caller must hold the store lock.
+func (g *GbService) persist() error {
+	if g.Storage == nil {
+		return nil
+	}
+	err := g.Storage.Save(g.GbStoreInstance)
+	if err != nil {
+		g.logger().Error("Error occurred while saving the store.", "error", err)
+		return ErrStoreNotSaved
+	}
+	return nil
 }
 
 func hasher(data string) string {
-	h := fnv.New64a()
-	h.Write([]byte(data))
-	return strconv.FormatUint(h.Sum64(), 10)
+	return models.HashID(data)
 }
 func (g *GbService) ListRepos(orgName, ownerName string) ([]RepoResponse, error) {
 	var outputResp []RepoResponse
@@ -172,18 +243,28 @@ func generateCustomID(IDType string) string {
 		IDLen = 23
 	}
 	if IDType == "SHA" {
-		randomChar = "abcdefghijklmnopqrstuvwxyz0123456789"
+		randomChar = "0123456789abcdef"
 		IDLen = 40
 	}
 	var NodeID string
 	length := len(randomChar)
-	for k := range IDLen {
-		NodeID += string(randomChar[rand.Intn(length-k)])
+	for range IDLen {
+		NodeID += string(randomChar[rand.Intn(length)])
 	}
 	return NodeID
 }
 
+// repoNameRegexp is github's rule for repository names, they also name the bare repo on disk.
+var repoNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func validRepoName(name string) bool {
+	return repoNameRegexp.MatchString(name) && name != "." && name != ".."
+}
+
 func (g *GbService) CreateRepo(orgName, ownerName string, RepoRequest *CreateRepoRequest) (RepoResponse, error) {
+	if !validRepoName(RepoRequest.Name) {
+		return RepoResponse{}, ErrInvalidRepoName
+	}
 
 	g.GbStoreInstance.MU.RLock()
 	if _, exists := g.GbStoreInstance.Orgs[orgName]; !exists {
@@ -207,13 +288,37 @@ func (g *GbService) CreateRepo(orgName, ownerName string, RepoRequest *CreateRep
 	nodeID := generateCustomID("NODEID")
 	g.GbStoreInstance.MU.Lock()
 
-	g.GbStoreInstance.Repos[orgName+"/"+ownerName+"/"+RepoRequest.Name] = &models.Repository{ID: repoID, Name: RepoRequest.Name, Node_ID: nodeID,
+	repoKey := orgName + "/" + ownerName + "/" + RepoRequest.Name
+	initialSHA := ""
+	if g.Git != nil {
+		err := g.Git.Init(repoKey)
+		if err == nil && RepoRequest.AutoInit {
+			initialSHA, err = g.initialCommit(repoKey, RepoRequest.Name, RepoRequest.Description, g.actingUser(orgName, ownerName))
+		}
+		if err != nil {
+			g.GbStoreInstance.MU.Unlock()
+			return RepoResponse{}, err
+		}
+	} else if RepoRequest.AutoInit {
+		initialSHA = generateCustomID("SHA")
+	}
+
+	g.GbStoreInstance.Repos[repoKey] = &models.Repository{ID: repoID, Name: RepoRequest.Name, Node_ID: nodeID,
 		Description: RepoRequest.Description,
 		OrgName:     orgName, UserName: ownerName, Branches: []string{}}
+	if initialSHA != "" {
+		g.addBranch(orgName, ownerName, RepoRequest.Name, "main", initialSHA)
+		if g.Git != nil {
+			g.Git.UpdateRef(repoKey, "refs/heads/main", initialSHA, "")
+			g.Git.SetHead(repoKey, "main")
+		}
+	}
 
 	g.GbStoreInstance.Users[orgName+"/"+ownerName].Repos = append(g.GbStoreInstance.Users[orgName+"/"+ownerName].Repos, RepoRequest.Name)
 	g.GbStoreInstance.Orgs[orgName].Repos = append(g.GbStoreInstance.Orgs[orgName].Repos, RepoRequest.Name)
 	g.GbStoreInstance.Orgs[orgName].ReposCount = repoID
+	g.emitRepository("created", orgName, ownerName, RepoRequest.Name)
+	saveErr := g.persist()
 	g.GbStoreInstance.MU.Unlock()
 	g.GbStoreInstance.MU.RLock()
 
@@ -225,7 +330,7 @@ func (g *GbService) CreateRepo(orgName, ownerName string, RepoRequest *CreateRep
 		}}
 	g.GbStoreInstance.MU.RUnlock()
 
-	return resp, nil
+	return resp, saveErr
 }
 
 func removeElementByValue(slice []string, value string) []string {
@@ -244,12 +349,39 @@ func (g *GbService) DeleteRepo(orgName, owner, repoName string) (bool, error) {
 		return false, err
 	}
 	g.GbStoreInstance.MU.Lock()
-	delete(g.GbStoreInstance.Repos, orgName+"/"+owner+"/"+repoName)
+	repoKey := orgName + "/" + owner + "/" + repoName
+	// the payload is built while the repo still exists, its own hooks go away with it.
+	repository := g.hookRepository(orgName, owner, repoName)
+	for hookID, hook := range g.GbStoreInstance.Hooks {
+		if hook.RepoKey == repoKey {
+			delete(g.GbStoreInstance.Hooks, hookID)
+			delete(g.GbStoreInstance.HookDeliveries, hookID)
+		}
+	}
+	g.deleteRepoIssues(repoKey)
+	g.deleteRepoChecks(repoKey)
+	g.deleteRepoReleases(repoKey)
+	g.deleteRepoTags(repoKey)
+	for _, prID := range g.GbStoreInstance.Repos[repoKey].PrIDs {
+		if pr := g.GbStoreInstance.PullRequests[prID]; pr != nil {
+			g.deletePRReviews(pr)
+		}
+	}
+	delete(g.GbStoreInstance.Repos, repoKey)
 	g.GbStoreInstance.Users[orgName+"/"+owner].Repos = removeElementByValue(g.GbStoreInstance.Users[orgName+"/"+owner].Repos, repoName)
 	g.GbStoreInstance.Orgs[orgName].Repos = removeElementByValue(g.GbStoreInstance.Orgs[orgName].Repos, repoName)
+	if g.Git != nil {
+		err = g.Git.Remove(repoKey)
+		if err != nil {
+			g.logger().Error("Error occurred while removing the git repo.", "repo", repoKey, "error", err)
+		}
+	}
+	g.emit("repository", "deleted", orgName, repoKey, RepositoryEvent{Action: "deleted", Repository: repository,
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+	err = g.persist()
 	g.GbStoreInstance.MU.Unlock()
 
-	return true, nil
+	return true, err
 }
 
 func (g *GbService) ListBranches(orgName, owner, repoName string) ([]ListBranchresponse, error) {
@@ -275,6 +407,8 @@ func (g *GbService) ListBranches(orgName, owner, repoName string) ([]ListBranchr
 		branchresponse := ListBranchresponse{Name: branchName, Commit: commitDetails, Protected: branchData.Protected}
 		outputResp = append(outputResp, branchresponse)
 	}
+	// github lists branches by name, keeps pages stable while branches come and go.
+	slices.SortFunc(outputResp, func(a, b ListBranchresponse) int { return strings.Compare(a.Name, b.Name) })
 
 	return outputResp, nil
 }
@@ -287,45 +421,70 @@ func (g *GbService) CreateBranch(orgName, owner, repoName string, cbreq *CreateB
 	if err != nil {
 		return createBranchResp, err
 	}
-	re := regexp.MustCompile(`refs/heads/([^/]+)$`)
-	matches := re.FindStringSubmatch(cbreq.Ref)
-	var branch string
-	if len(matches) >= 2 {
-		branch = matches[1]
-		//	fmt.Println(branch)
-	} else {
-		//	fmt.Println("Invalid branch name found in refs")
-		return CreateBranchResponse{}, ErrInvalidBranchName
+	if tagName, isTag := tagOfRef(cbreq.Ref); isTag {
+		g.GbStoreInstance.MU.Lock()
+		defer g.GbStoreInstance.MU.Unlock()
+		return g.createTagRef(orgName, owner, repoName, tagName, cbreq.SHA)
 	}
-	g.GbStoreInstance.MU.RLock()
-	branchList := g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches
-	branchID := len(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches) + 1
-	g.GbStoreInstance.MU.RUnlock()
-	if slices.Contains(branchList, branch) {
+	branch, err := branchOfRef(cbreq.Ref)
+	if err != nil {
+		return CreateBranchResponse{}, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	repoKey := orgName + "/" + owner + "/" + repoName
+	if slices.Contains(g.GbStoreInstance.Repos[repoKey].Branches, branch) {
 		return createBranchResp, ErrBranchesAlreadyExists
 	}
+	if g.Git != nil {
+		if !g.Git.CommitExists(repoKey, cbreq.SHA) {
+			return createBranchResp, ErrCommitNotFound
+		}
+		err = g.Git.UpdateRef(repoKey, "refs/heads/"+branch, cbreq.SHA, "")
+		if err != nil {
+			return createBranchResp, err
+		}
+	}
 
-	nodeID := generateCustomID("NODEID")
-	url := "https://api.gbserver.com/repos/" + owner + "/" + repoName + "/git/commits/" + cbreq.SHA
-	commit := models.CommitDetails{SHA: cbreq.SHA, URL: url}
-	fullBranchName := orgName + "/" + owner + "/" + repoName + "/" + branch
-
-	g.GbStoreInstance.MU.Lock()
-	g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches = append(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches, branch)
+	newBranch := g.addBranch(orgName, owner, repoName, branch, cbreq.SHA)
+	g.emitBranch("create", orgName, owner, repoName, branch, cbreq.SHA)
+	createBranchResp = CreateBranchResponse{Ref: cbreq.Ref, NodeID: newBranch.NodeID, URL: newBranch.URL,
+		Object: CreateBranchObjectResponse{Type: "commit", SHA: cbreq.SHA, URL: newBranch.CommitInfo.URL}}
+	return createBranchResp, g.persist()
+}
 
-	g.GbStoreInstance.Branches[fullBranchName] = &models.Branch{
-		ID:         branchID,
+// addBranch stores a new branch of the repo pointing at sha. Caller must hold the store lock.
+func (g *GbService) addBranch(orgName, owner, repoName, branch, sha string) *models.Branch {
+	repo := g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName]
+	newBranch := &models.Branch{
+		ID:         len(repo.Branches) + 1,
 		RepoName:   repoName,
 		Name:       branch,
-		NodeID:     nodeID,
-		URL:        url,
-		Protected:  true,
-		CommitInfo: commit,
+		NodeID:     generateCustomID("NODEID"),
+		URL:        repoAPIURL(owner, repoName) + "/git/refs/heads/" + branch,
+		Protected:  false,
+		CommitInfo: commitDetails(owner, repoName, sha),
+	}
+	repo.Branches = append(repo.Branches, branch)
+	g.GbStoreInstance.Branches[orgName+"/"+owner+"/"+repoName+"/"+branch] = newBranch
+	return newBranch
+}
+
+// removeBranch drops a branch of the repo along with its pull request. Caller must hold the store lock.
+func (g *GbService) removeBranch(orgName, owner, repoName, branch string) {
+	fullBranchName := orgName + "/" + owner + "/" + repoName + "/" + branch
+	if g.GbStoreInstance.Branches[fullBranchName].PullRequestID != "" {
+		if pr := g.GbStoreInstance.PullRequests[g.GbStoreInstance.Branches[fullBranchName].PullRequestID]; pr != nil {
+			g.deletePRReviews(pr)
+		}
+		delete(g.GbStoreInstance.PullRequests, g.GbStoreInstance.Branches[fullBranchName].PullRequestID)
+		g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs = removeElementByValue(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs, g.GbStoreInstance.Branches[fullBranchName].PullRequestID)
 	}
-	g.GbStoreInstance.MU.Unlock()
-	createBranchResp = CreateBranchResponse{Ref: cbreq.Ref, NodeID: nodeID, URL: url,
-		Object: CreateBranchObjectResponse{Type: "commit", SHA: cbreq.SHA, URL: url}}
-	return createBranchResp, nil
+	deletedSHA := g.GbStoreInstance.Branches[fullBranchName].CommitInfo.SHA
+	delete(g.GbStoreInstance.Branches, fullBranchName)
+
+	g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches = removeElementByValue(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches, branch)
+	g.emitBranch("delete", orgName, owner, repoName, branch, deletedSHA)
 }
 
 func (g *GbService) DeleteBranch(orgName, owner, repoName, branch string) (bool, error) {
@@ -335,24 +494,25 @@ func (g *GbService) DeleteBranch(orgName, owner, repoName, branch string) (bool,
 	if err != nil {
 		return false, err
 	}
-	g.GbStoreInstance.MU.RLock()
-	if _, branchExists := g.GbStoreInstance.Branches[fullBranchName]; !branchExists {
-		g.GbStoreInstance.MU.RUnlock()
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	branchInfo, branchExists := g.GbStoreInstance.Branches[fullBranchName]
+	if !branchExists {
 		return false, ErrBranchesNotFound
 	}
-	g.GbStoreInstance.MU.RUnlock()
-	g.GbStoreInstance.MU.Lock()
-	if g.GbStoreInstance.Branches[fullBranchName].PullRequestID != "" {
-
-		delete(g.GbStoreInstance.PullRequests, g.GbStoreInstance.Branches[fullBranchName].PullRequestID)
-		g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs = removeElementByValue(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs, g.GbStoreInstance.Branches[fullBranchName].PullRequestID)
+	err = g.checkRefUpdate(orgName, owner, repoName, branch, branchInfo.CommitInfo.SHA, "")
+	if err != nil {
+		return false, err
 	}
-	delete(g.GbStoreInstance.Branches, fullBranchName)
-
-	g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches = removeElementByValue(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].Branches, branch)
-	g.GbStoreInstance.MU.Unlock()
+	if g.Git != nil {
+		err = g.Git.DeleteRef(orgName+"/"+owner+"/"+repoName, "refs/heads/"+branch)
+		if err != nil {
+			return false, err
+		}
+	}
+	g.removeBranch(orgName, owner, repoName, branch)
 	//fmt.Println("After delete branch", g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName])
-	return true, nil
+	return true, g.persist()
 }
 
 func (g *GbService) validateOrgOwnerRepo(orgName, owner, repoName string) error {
@@ -384,128 +544,258 @@ func (g *GbService) ListPRs(orgName, owner, repoName string) ([]PRResponse, erro
 	prsList := g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs
 	g.GbStoreInstance.MU.RUnlock()
 	for _, prID := range prsList {
-		//	prID := g.GbStoreInstance.Branches[branchName].PullRequestID
-
-		//	fmt.Println("PRS...", g.GbStoreInstance.PullRequests[prID])
 		g.GbStoreInstance.MU.RLock()
-		prDetails := g.GbStoreInstance.PullRequests[prID]
+		prResp := g.buildPRResponse(orgName, owner, repoName, g.GbStoreInstance.PullRequests[prID])
 		g.GbStoreInstance.MU.RUnlock()
-
-		featureBranch := strings.Split(prDetails.FromBranch, ":")
-		//featureBranchUser := featureBranch[0]
-		featureBranchName := featureBranch[1]
-		fullfeatureBranchName := orgName + "/" + owner + "/" + repoName + "/" + featureBranchName
-		fullbaseBranchName := orgName + "/" + owner + "/" + repoName + "/" + prDetails.ToBranch
-
-		g.GbStoreInstance.MU.RLock()
-		prOwner := OwnerInfo{Login: g.GbStoreInstance.Users[orgName+"/"+owner].LoginName,
-			NodeID: g.GbStoreInstance.Users[orgName+"/"+owner].NodeID, ID: g.GbStoreInstance.Users[orgName+"/"+owner].ID,
-			UserType: g.GbStoreInstance.Users[orgName+"/"+owner].UserType}
-
-		prHeadResp := baseHeadPRResponse{
-			Ref: g.GbStoreInstance.Branches[fullfeatureBranchName].Name, SHA: g.GbStoreInstance.Branches[fullfeatureBranchName].CommitInfo.SHA,
-			User: prOwner, Repo: repoName}
-		prBaseResp := baseHeadPRResponse{
-			Ref: g.GbStoreInstance.Branches[fullbaseBranchName].Name, SHA: g.GbStoreInstance.Branches[fullbaseBranchName].CommitInfo.SHA,
-			User: prOwner, Repo: repoName}
-		g.GbStoreInstance.MU.RUnlock()
-
-		prResp := PRResponse{
-			URL: prDetails.URL, ID: prDetails.ID, NodeID: prDetails.NodeID,
-			Title: prDetails.Title, Body: prDetails.Body, State: prDetails.State,
-			User: prOwner, Commits: prDetails.Commits, Additions: prDetails.Additions, Deletions: prDetails.Deletions,
-			ChangedFiles: prDetails.ChangedFiles,
-			Head:         prHeadResp,
-			Base:         prBaseResp,
-		}
 		listPRresponse = append(listPRresponse, prResp)
-
 	}
+	// newest first, same as github's default sort=created&direction=desc.
+	slices.SortFunc(listPRresponse, func(a, b PRResponse) int { return b.Number - a.Number })
 	return listPRresponse, nil
 }
 
-// // patch /Repos/{owner}/{Repo}/pulls/{pull_number} State - closed
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}
+func (g *GbService) GetPR(orgName, owner, repoName, pullNumber string) (PRResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return PRResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return PRResponse{}, err
+	}
+	return g.buildPRResponse(orgName, owner, repoName, pr), nil
+}
+
+// // patch /Repos/{owner}/{Repo}/pulls/{pull_number}
 func (g *GbService) UpdatePR(orgName, owner, repoName, pull_number string, prRequest *PRRequest) (PRResponse, error) {
 	//'{"Title":"new Title","Body":"updated Body","State":"open","base":"master"}'
-	//closePRRequest := PRRequest{State: "closed"}
-	var closedPR PRResponse
+	var updatedPR PRResponse
 
 	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
 	if err != nil {
-		return closedPR, err
+		return updatedPR, err
 	}
-	//pullNumberStr := strconv.Itoa(pull_number)
-	//fullPRID := orgName + "/" + owner + "/" + repoName + pullNumberStr
-	g.GbStoreInstance.MU.RLock()
-	if !slices.Contains(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs, pull_number) {
-		g.GbStoreInstance.MU.RUnlock()
-		return closedPR, ErrPRNotFound
-	}
-	prDetails := g.GbStoreInstance.PullRequests[pull_number]
-	if g.GbStoreInstance.PullRequests[pull_number].State == "closed" {
-		g.GbStoreInstance.MU.RUnlock()
-		return closedPR, ErrPRAlreadyClosed
+	if prRequest.State != "" && prRequest.State != "open" && prRequest.State != "closed" {
+		return updatedPR, ErrInvalidPRState
 	}
-	g.GbStoreInstance.MU.RUnlock()
-	branchInfo := prDetails.FromBranch
-	branchName := strings.Split(branchInfo, ":")[1]
-	fullBranchName := orgName + "/" + owner + "/" + repoName + "/" + branchName
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	prID := hasher(repoKey + "/" + pull_number)
+
 	g.GbStoreInstance.MU.Lock()
-	g.GbStoreInstance.Branches[fullBranchName].PullRequestID = ""
-	//	g.GbStoreInstance.PullRequests[pull_number].Title = prRequest.Title
-	//	g.GbStoreInstance.PullRequests[pull_number].Body = prRequest.Body
-	g.GbStoreInstance.PullRequests[pull_number].State = prRequest.State
-	closedPRData := g.GbStoreInstance.PullRequests[pull_number]
-	g.GbStoreInstance.MU.Unlock()
+	defer g.GbStoreInstance.MU.Unlock()
+	if !slices.Contains(g.GbStoreInstance.Repos[repoKey].PrIDs, prID) {
+		return updatedPR, ErrPRNotFound
+	}
+	prDetails := g.GbStoreInstance.PullRequests[prID]
+	headBranchName := repoKey + "/" + headBranchOf(prDetails)
+	headBranch := g.GbStoreInstance.Branches[headBranchName]
+
+	newState := prDetails.State
+	if prRequest.State != "" {
+		newState = prRequest.State
+	}
+	newBase := prDetails.ToBranch
+	if prRequest.Base != "" && prRequest.Base != prDetails.ToBranch {
+		// retargeting is only allowed on open pull requests, the same way github does it.
+		if newState != "open" {
+			return updatedPR, ErrPRBaseOnClosed
+		}
+		if _, exists := g.GbStoreInstance.Branches[repoKey+"/"+prRequest.Base]; !exists {
+			return updatedPR, ErrBaseBranchNotFound
+		}
+		if headBranch != nil && headBranch.Name == prRequest.Base {
+			return updatedPR, ErrPRSameHeadBase
+		}
+		newBase = prRequest.Base
+	}
 
-	featureBranch := strings.Split(closedPRData.FromBranch, ":")
+	if prDetails.State == "closed" && newState == "open" {
+		if prDetails.Merged {
+			return updatedPR, ErrPRAlreadyMerged
+		}
+		// reopen: the head branch must still exist and must not carry another open PR.
+		if headBranch == nil {
+			return updatedPR, ErrPRHeadBranchMissing
+		}
+		if headBranch.PullRequestID != "" && headBranch.PullRequestID != prID {
+			return updatedPR, ErrPRAlreadyExists
+		}
+		headBranch.PullRequestID = prID
+	}
+	if prDetails.State == "open" && newState == "closed" && headBranch != nil {
+		headBranch.PullRequestID = ""
+	}
 
-	featureBranchName := featureBranch[1]
-	fullFeatureBranchName := orgName + "/" + owner + "/" + repoName + "/" + featureBranchName
-	fullBaseBranchName := orgName + "/" + owner + "/" + repoName + "/" + closedPRData.ToBranch
+	changes := map[string]any{}
+	if prRequest.Title != nil && *prRequest.Title != prDetails.Title {
+		changes["title"] = map[string]string{"from": prDetails.Title}
+		prDetails.Title = *prRequest.Title
+	}
+	if prRequest.Body != nil && *prRequest.Body != prDetails.Body {
+		changes["body"] = map[string]string{"from": prDetails.Body}
+		prDetails.Body = *prRequest.Body
+	}
+	if newBase != prDetails.ToBranch {
+		changes["base"] = map[string]any{"ref": map[string]string{"from": prDetails.ToBranch}}
+	}
+	oldState := prDetails.State
+	prDetails.ToBranch = newBase
+	prDetails.State = newState
+	if changes["base"] != nil {
+		g.refreshPRStats(repoKey, prDetails)
+	}
 
-	g.GbStoreInstance.MU.RLock()
-	ownerDetails := OwnerInfo{
-		Login:    g.GbStoreInstance.Users[orgName+"/"+owner].LoginName,
-		ID:       g.GbStoreInstance.Users[orgName+"/"+owner].ID,
-		UserType: g.GbStoreInstance.Users[orgName+"/"+owner].UserType,
-		NodeID:   g.GbStoreInstance.Users[orgName+"/"+owner].NodeID,
+	updatedPR = g.buildPRResponse(orgName, owner, repoName, prDetails)
+	if len(changes) > 0 {
+		g.emitPullRequest("edited", orgName, owner, repoName, updatedPR, changes)
+	}
+	if oldState == "open" && newState == "closed" {
+		g.emitPullRequest("closed", orgName, owner, repoName, updatedPR, nil)
 	}
+	if oldState == "closed" && newState == "open" {
+		g.emitPullRequest("reopened", orgName, owner, repoName, updatedPR, nil)
+	}
+	return updatedPR, g.persist()
+}
 
-	prHeadResp := baseHeadPRResponse{
+// // put /Repos/{owner}/{Repo}/pulls/{pull_number}/merge
+func (g *GbService) MergePR(orgName, owner, repoName, pull_number string, mergeReq *MergePRRequest) (MergePRResponse, error) {
+	var mergeResp MergePRResponse
 
-		Ref:  closedPRData.FromBranch,
-		SHA:  g.GbStoreInstance.Branches[fullFeatureBranchName].CommitInfo.SHA,
-		User: ownerDetails,
-		Repo: repoName,
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return mergeResp, err
+	}
+	mergeMethod := mergeReq.MergeMethod
+	if mergeMethod == "" {
+		mergeMethod = "merge"
+	}
+	if mergeMethod != "merge" && mergeMethod != "squash" && mergeMethod != "rebase" {
+		return mergeResp, ErrInvalidMergeMethod
 	}
 
-	prBaseResp := baseHeadPRResponse{
+	repoKey := orgName + "/" + owner + "/" + repoName
+	prID := hasher(repoKey + "/" + pull_number)
 
-		Ref:  closedPRData.ToBranch,
-		SHA:  g.GbStoreInstance.Branches[fullBaseBranchName].CommitInfo.SHA,
-		User: ownerDetails,
-		Repo: repoName,
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	if !slices.Contains(g.GbStoreInstance.Repos[repoKey].PrIDs, prID) {
+		return mergeResp, ErrPRNotFound
 	}
-	g.GbStoreInstance.MU.RUnlock()
+	prDetails := g.GbStoreInstance.PullRequests[prID]
+	if prDetails.Merged {
+		return mergeResp, ErrPRAlreadyMerged
+	}
+	if prDetails.State == "closed" {
+		return mergeResp, ErrPRAlreadyClosed
+	}
+	headBranch := g.GbStoreInstance.Branches[repoKey+"/"+headBranchOf(prDetails)]
+	baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+prDetails.ToBranch]
+	if headBranch == nil || baseBranch == nil {
+		return mergeResp, ErrPRNotMergeable
+	}
+	if mergeReq.SHA != "" && mergeReq.SHA != headBranch.CommitInfo.SHA {
+		return mergeResp, ErrPRHeadModified
+	}
+	err = g.checkMerge(orgName, owner, repoName, prDetails, headBranch.CommitInfo.SHA, baseBranch.CommitInfo.SHA)
+	if err != nil {
+		return mergeResp, err
+	}
+
+	// merge, squash & rebase all end up moving the base branch to a commit that did not exist before.
+	baseSHA := baseBranch.CommitInfo.SHA
+	mergeSHA := generateCustomID("SHA")
+	if g.Git != nil {
+		mergeSHA, err = g.gitMerge(repoKey, prDetails, headBranch.CommitInfo.SHA, baseSHA, mergeMethod, mergeReq, g.actingUser(orgName, owner))
+		if err != nil {
+			return mergeResp, err
+		}
+		err = g.Git.UpdateRef(repoKey, "refs/heads/"+baseBranch.Name, mergeSHA, baseSHA)
+		if err != nil {
+			return mergeResp, err
+		}
+	}
+	baseBranch.CommitInfo = commitDetails(owner, repoName, mergeSHA)
+	headBranch.PullRequestID = ""
+
+	prDetails.State = "closed"
+	prDetails.Merged = true
+	prDetails.MergedAt = time.Now().UTC().Format(time.RFC3339)
+	prDetails.MergedByID = g.actingUser(orgName, owner).ID
+	prDetails.MergeCommitSHA = mergeSHA
+
+	g.refreshBranchPRStats(repoKey, baseBranch.Name)
+
+	mergeResp = MergePRResponse{SHA: mergeSHA, Merged: true, Message: "Pull Request successfully merged"}
+	g.emitPush(orgName, owner, repoName, baseBranch.Name, baseSHA, mergeSHA)
+	g.emitPullRequest("closed", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, prDetails), nil)
+	return mergeResp, g.persist()
+}
 
-	closedPR = PRResponse{
-		URL:          closedPRData.URL,
-		ID:           closedPRData.ID,
-		NodeID:       closedPRData.NodeID,
-		Title:        closedPRData.Title,
-		Body:         closedPRData.Body,
-		State:        closedPRData.State,
-		User:         ownerDetails,
-		Commits:      closedPRData.Commits,
-		Additions:    closedPRData.Additions,
-		Deletions:    closedPRData.Deletions,
-		ChangedFiles: closedPRData.ChangedFiles,
+// buildPRResponse renders a stored pull request. Caller must hold the store lock.
+func (g *GbService) buildPRResponse(orgName, owner, repoName string, prDetails *models.PullRequest) PRResponse {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	ownerDetails := OwnerInfo{
+		Login:    g.GbStoreInstance.Users[orgName+"/"+owner].LoginName,
+		ID:       g.GbStoreInstance.Users[orgName+"/"+owner].ID,
+		UserType: g.GbStoreInstance.Users[orgName+"/"+owner].UserType,
+		NodeID:   g.GbStoreInstance.Users[orgName+"/"+owner].NodeID,
+	}
+	authorDetails := ownerDetails
+	if author := g.userByID(orgName, prDetails.AuthorID); author != nil {
+		authorDetails = OwnerInfo{Login: author.LoginName, ID: author.ID, NodeID: author.NodeID, UserType: author.UserType}
+	}
+
+	featureBranchName := headBranchOf(prDetails)
+	prHeadResp := baseHeadPRResponse{Ref: featureBranchName, User: ownerDetails, Repo: repoName}
+	if headBranch, exists := g.GbStoreInstance.Branches[repoKey+"/"+featureBranchName]; exists {
+		prHeadResp.SHA = headBranch.CommitInfo.SHA
+	}
+	prBaseResp := baseHeadPRResponse{Ref: prDetails.ToBranch, User: ownerDetails, Repo: repoName}
+	if baseBranch, exists := g.GbStoreInstance.Branches[repoKey+"/"+prDetails.ToBranch]; exists {
+		prBaseResp.SHA = baseBranch.CommitInfo.SHA
+	}
+
+	prResp := PRResponse{
+		URL:          prDetails.URL,
+		ID:           prDetails.ID,
+		Number:       prDetails.Number,
+		NodeID:       prDetails.NodeID,
+		Title:        prDetails.Title,
+		Body:         prDetails.Body,
+		State:        prDetails.State,
+		User:         authorDetails,
+		Commits:      prDetails.Commits,
+		Additions:    prDetails.Additions,
+		Deletions:    prDetails.Deletions,
+		ChangedFiles: prDetails.ChangedFiles,
 		Head:         prHeadResp,
 		Base:         prBaseResp,
+		Merged:       prDetails.Merged,
+		MergedAt:     prDetails.MergedAt,
+		MergeCommit:  prDetails.MergeCommitSHA,
 	}
+	prResp.Mergeable, prResp.MergeableState = g.mergeableState(repoKey, prDetails)
+	prResp.RequestedReviewers = g.requestedReviewers(orgName, prDetails)
+	if prHeadResp.SHA != "" {
+		prResp.StatusesURL = repoAPIURL(owner, repoName) + "/statuses/" + prHeadResp.SHA
+	}
+	if mergedBy := g.userByID(orgName, prDetails.MergedByID); prDetails.Merged && mergedBy != nil {
+		prResp.MergedBy = &OwnerInfo{Login: mergedBy.LoginName, ID: mergedBy.ID, NodeID: mergedBy.NodeID, UserType: mergedBy.UserType}
+	}
+	return prResp
+}
 
-	return closedPR, nil
+// headBranchOf is the name of the head branch of a pull request, FromBranch is kept as owner:branch.
+func headBranchOf(pr *models.PullRequest) string {
+	if _, branchName, found := strings.Cut(pr.FromBranch, ":"); found {
+		return branchName
+	}
+	return pr.FromBranch
 }
 
 // // post /Repos/{owner}/{Repo}/pulls
@@ -519,109 +809,68 @@ func (g *GbService) CreatePR(orgName, owner, repoName string, cPRReq *PRRequest)
 		return createPRresponse, err
 	}
 
-	featureBranch := strings.Split(cPRReq.Head, ":")
-	//featureBranchUser := featureBranch[0]
-	featureBranchName := featureBranch[1]
+	// head is owner:branch or, as github takes it too, a bare branch of the repo.
+	featureBranchName := cPRReq.Head
+	if _, branchName, found := strings.Cut(cPRReq.Head, ":"); found {
+		featureBranchName = branchName
+	}
+	if featureBranchName == cPRReq.Base {
+		return createPRresponse, ErrPRSameHeadBase
+	}
 	fullFeatureBranchName := orgName + "/" + owner + "/" + repoName + "/" + featureBranchName
 	fullBaseBranchName := orgName + "/" + owner + "/" + repoName + "/" + cPRReq.Base
 
-	g.GbStoreInstance.MU.RLock()
+	// the number is shared with issues, it is taken and used under the same lock so none is handed out twice.
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
 	if _, branchExists := g.GbStoreInstance.Branches[fullFeatureBranchName]; !branchExists {
-		g.GbStoreInstance.MU.RUnlock()
 		return createPRresponse, ErrBranchesNotFound
 	}
 	if _, branchExists := g.GbStoreInstance.Branches[fullBaseBranchName]; !branchExists {
-		g.GbStoreInstance.MU.RUnlock()
 		return createPRresponse, ErrBranchesNotFound
 	}
 
 	if g.GbStoreInstance.Branches[fullFeatureBranchName].PullRequestID != "" {
-		g.GbStoreInstance.MU.RUnlock()
 		return createPRresponse, ErrPRAlreadyExists
 	}
 
 	prCount := g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].TotalPRs + 1
-	g.GbStoreInstance.MU.RUnlock()
 
 	fullPRName := orgName + "/" + owner + "/" + repoName + "/" + strconv.Itoa(prCount)
 	prID := hasher(fullPRName)
 
-	g.GbStoreInstance.MU.Lock()
 	g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].TotalPRs = prCount
 
 	g.GbStoreInstance.Branches[fullFeatureBranchName].PullRequestID = prID
 
 	g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs = append(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs, prID)
 
-	g.GbStoreInstance.MU.Unlock()
 	nodeId := generateCustomID("NODEID")
 	//prIDAsString := strconv.Itoa(prID)
 
-	commits := rand.Intn(50)
-	additions := rand.Intn(50)
-	deletions := rand.Intn(50)
-	changedFiles := rand.Intn(50)
+	url := "https://api.gbserver.com/repos/" + owner + "/" + repoName + "/pulls/" + strconv.Itoa(prCount)
 
-	url := "https://api.gbserver.com/repos/" + owner + "/" + repoName + "/" + prID
-
-	g.GbStoreInstance.MU.RLock()
 	g.GbStoreInstance.PullRequests[prID] = &models.PullRequest{
-		NodeID:       nodeId,
-		URL:          url,
-		ID:           prID,
-		RepoName:     repoName,
-		FromBranch:   cPRReq.Head,
-		ToBranch:     cPRReq.Base,
-		AuthorID:     g.GbStoreInstance.Users[orgName+"/"+owner].ID,
-		State:        "open",
-		Title:        cPRReq.Title,
-		Body:         cPRReq.Body,
-		Commits:      commits,
-		Additions:    additions,
-		Deletions:    deletions,
-		ChangedFiles: changedFiles,
-	}
-
-	ownerDetails := OwnerInfo{
-		Login:    g.GbStoreInstance.Users[orgName+"/"+owner].LoginName,
-		ID:       g.GbStoreInstance.Users[orgName+"/"+owner].ID,
-		UserType: g.GbStoreInstance.Users[orgName+"/"+owner].UserType,
-		NodeID:   g.GbStoreInstance.Users[orgName+"/"+owner].NodeID,
+		NodeID:     nodeId,
+		URL:        url,
+		ID:         prID,
+		Number:     prCount,
+		RepoName:   repoName,
+		FromBranch: owner + ":" + featureBranchName,
+		ToBranch:   cPRReq.Base,
+		AuthorID:   g.actingUser(orgName, owner).ID,
+		State:      "open",
 	}
-
-	prHeadResp := baseHeadPRResponse{
-
-		Ref:  cPRReq.Base,
-		SHA:  g.GbStoreInstance.Branches[fullBaseBranchName].CommitInfo.SHA,
-		User: ownerDetails,
-		Repo: repoName,
+	if cPRReq.Title != nil {
+		g.GbStoreInstance.PullRequests[prID].Title = *cPRReq.Title
 	}
-
-	prBaseResp := baseHeadPRResponse{
-
-		Ref:  featureBranchName,
-		SHA:  g.GbStoreInstance.Branches[fullFeatureBranchName].CommitInfo.SHA,
-		User: ownerDetails,
-		Repo: repoName,
-	}
-	g.GbStoreInstance.MU.RUnlock()
-	//fmt.Println(g.GbStoreInstance.PullRequests)
-	createPRresponse = PRResponse{
-		URL:          url,
-		ID:           prID,
-		NodeID:       nodeId,
-		Title:        cPRReq.Title,
-		Body:         cPRReq.Body,
-		State:        "open",
-		User:         ownerDetails,
-		Commits:      commits,
-		Additions:    additions,
-		Deletions:    deletions,
-		ChangedFiles: changedFiles,
-		Head:         prHeadResp,
-		Base:         prBaseResp,
+	if cPRReq.Body != nil {
+		g.GbStoreInstance.PullRequests[prID].Body = *cPRReq.Body
 	}
+	g.refreshPRStats(orgName+"/"+owner+"/"+repoName, g.GbStoreInstance.PullRequests[prID])
 
-	return createPRresponse, nil
+	createPRresponse = g.buildPRResponse(orgName, owner, repoName, g.GbStoreInstance.PullRequests[prID])
+	g.emitPullRequest("opened", orgName, owner, repoName, createPRresponse, nil)
+	return createPRresponse, g.persist()
 
 }
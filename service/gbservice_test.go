@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"gbserver/models"
+	"strconv"
 
 	"testing"
 
@@ -19,6 +20,13 @@ import (
 
 var gbService = GbService{GbStoreInstance: models.NewGbStore()}
 
+// newTestService is a service on the default fixture, closed with the test so its webhook workers do not outlive it.
+func newTestService(t *testing.T) *GbService {
+	testService := NewGbService(models.NewGbStore(), nil, nil)
+	t.Cleanup(testService.Close)
+	return testService
+}
+
 func TestListRepo(t *testing.T) {
 	type input struct {
 		orgName string
@@ -91,6 +99,7 @@ func TestCreateRepo(t *testing.T) {
 			input: input{
 				orgName: "gborg",
 				owner:   "gbInvalidUser",
+				repoReq: CreateRepoRequest{Name: "testrepo"},
 			},
 			wantErr: ErrOwnerNotFound,
 		},
@@ -99,10 +108,26 @@ func TestCreateRepo(t *testing.T) {
 			input: input{
 				orgName: "gbInvalidorg",
 				owner:   "gbUser",
+				repoReq: CreateRepoRequest{Name: "testrepo"},
 			},
 
 			wantErr: ErrOrgNotFound,
 		},
+		{
+			name:    "Path traversal in the name",
+			input:   input{orgName: "gborg", owner: "gbuser", repoReq: CreateRepoRequest{Name: "../../x"}},
+			wantErr: ErrInvalidRepoName,
+		},
+		{
+			name:    "Dot dot name",
+			input:   input{orgName: "gborg", owner: "gbuser", repoReq: CreateRepoRequest{Name: ".."}},
+			wantErr: ErrInvalidRepoName,
+		},
+		{
+			name:    "Empty name",
+			input:   input{orgName: "gborg", owner: "gbuser"},
+			wantErr: ErrInvalidRepoName,
+		},
 		{
 			name: "Existing repo name",
 			input: input{
@@ -453,6 +478,7 @@ func TestDeleteBranch(t *testing.T) {
 }
 
 func TestCreatePRs(t *testing.T) {
+	title, body := "Amazing new feature", "Please pull these awesome changes in!"
 	type input struct {
 		orgName  string
 		owner    string
@@ -501,7 +527,7 @@ func TestCreatePRs(t *testing.T) {
 				orgName:  "gborg",
 				owner:    "gbuser",
 				repoName: "testrepo",
-				prReq:    PRRequest{Title: "Amazing new feature", Body: "Please pull these awesome changes in!", Head: "gbuser:featureD", Base: "master"},
+				prReq:    PRRequest{Title: &title, Body: &body, Head: "gbuser:featureD", Base: "master"},
 			},
 
 			wantErr: ErrBranchesNotFound,
@@ -512,7 +538,7 @@ func TestCreatePRs(t *testing.T) {
 				orgName:  "gborg",
 				owner:    "gbuser",
 				repoName: "testrepo",
-				prReq:    PRRequest{Title: "Amazing new feature", Body: "Please pull these awesome changes in!", Head: "gbuser:featureCD", Base: "master"},
+				prReq:    PRRequest{Title: &title, Body: &body, Head: "gbuser:featureCD", Base: "master"},
 			},
 
 			wantResp: PRResponse{
@@ -574,6 +600,7 @@ func TestCreatePRs(t *testing.T) {
 }
 
 func TestListPRs(t *testing.T) {
+	title, body := "Amazing new feature", "Please pull these awesome changes in!"
 	type input struct {
 		orgName  string
 		owner    string
@@ -671,7 +698,7 @@ func TestListPRs(t *testing.T) {
 		resp1, _ := gbService.CreateRepo(tt.input.orgName, tt.input.owner, &CreateRepoRequest{Name: "testrepo", Description: "Test repo request"})
 		resp2, _ := gbService.CreateBranch(tt.input.orgName, tt.input.owner, tt.input.repoName, &CreateBranchRequest{Ref: "refs/heads/featureCD", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
 		resp3, _ := gbService.CreateBranch(tt.input.orgName, tt.input.owner, tt.input.repoName, &CreateBranchRequest{Ref: "refs/heads/master", SHA: "abcgsd2esdf56b14c9653891f9e74264a383fa43fefbd"})
-		resp4, _ := gbService.CreatePR(tt.input.orgName, tt.input.owner, tt.input.repoName, &PRRequest{Title: "Amazing new feature", Body: "Please pull these awesome changes in!", Head: "gbuser:featureCD", Base: "master"})
+		resp4, _ := gbService.CreatePR(tt.input.orgName, tt.input.owner, tt.input.repoName, &PRRequest{Title: &title, Body: &body, Head: "gbuser:featureCD", Base: "master"})
 		resp, err := gbService.ListPRs(tt.input.orgName, tt.input.owner, tt.input.repoName)
 		fmt.Println(tt.name, "..", resp, err)
 		if err != nil {
@@ -684,83 +711,258 @@ func TestListPRs(t *testing.T) {
 	}
 }
 
-func TestApprovePRs(t *testing.T) {
+func TestUpdatePRs(t *testing.T) {
 	type input struct {
-		orgName  string
-		owner    string
-		repoName string
+		orgName    string
+		owner      string
+		repoName   string
+		pullNumber string
+		prReq      PRRequest
 	}
+	gbService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "testrepo", Description: "Test repo request"})
+	gbService.CreateBranch("gborg", "gbuser", "testrepo", &CreateBranchRequest{Ref: "refs/heads/featureUP", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
+	gbService.CreateBranch("gborg", "gbuser", "testrepo", &CreateBranchRequest{Ref: "refs/heads/master", SHA: "abcgsd2esdf56b14c9653891f9e74264a383fa43fefbd"})
+	gbService.CreateBranch("gborg", "gbuser", "testrepo", &CreateBranchRequest{Ref: "refs/heads/develop", SHA: "abcgsd2esdf56b14c9653891f9e74264a383fa43fefbd"})
+	title, body := "Update feature", "Please pull these awesome changes in!"
+	prResp, err := gbService.CreatePR("gborg", "gbuser", "testrepo", &PRRequest{Title: &title, Body: &body, Head: "gbuser:featureUP", Base: "master"})
+	assert.NoError(t, err)
+	pullNumber := strconv.Itoa(prResp.Number)
+	newTitle, newBody, emptyBody := "new Title", "updated Body", ""
+
 	tests := []struct {
-		name  string
-		input input
-		//got   got
+		name     string
+		input    input
 		wantResp PRResponse
 		wantErr  error
 	}{
 		{
-			name: "Test approve pr with valid org, user & repo",
+			name: "Test invalid Repo",
 			input: input{
-				orgName:  "gborg",
-				owner:    "gbuser",
-				repoName: "testrepo",
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "gbrepo1",
+				pullNumber: pullNumber,
 			},
-
-			wantResp: PRResponse{
-				URL:    "https://api.gbserver.com/repos/gbuser/gbrepo/1534409025785096406",
-				ID:     "1534409025785096406",
-				NodeID: "w5PCfNJBg=pJfWjYn6eceB0",
-				Title:  "Amazing new feature",
-				Body:   "Please pull these awesome changes in!",
-				State:  "approved",
-				User: OwnerInfo{
-					Login:    "gbuser",
-					ID:       1,
-					NodeID:   "MDQ6VXNlcjE=",
-					UserType: "User",
-				},
-				Commits:      0,
-				Additions:    17,
-				Deletions:    24,
-				ChangedFiles: 41,
-				Head: baseHeadPRResponse{
-					Ref: "master",
-					SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd",
-					User: OwnerInfo{
-						Login:    "gbuser",
-						ID:       1,
-						NodeID:   "MDQ6VXNlcjE=",
-						UserType: "User",
-					},
-					Repo: "gbrepo",
-				},
-				Base: baseHeadPRResponse{
-					Ref: "featureCD",
-					SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd",
-					User: OwnerInfo{
-						Login:    "gbuser",
-						ID:       1,
-						NodeID:   "MDQ6VXNlcjE=",
-						UserType: "User",
-					},
-					Repo: "gbrepo",
-				},
+			wantErr: ErrRepoNotFound,
+		},
+		{
+			name: "Test invalid PR number",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: "9999",
+			},
+			wantErr: ErrPRNotFound,
+		},
+		{
+			name: "Test invalid state",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{State: "approved"},
 			},
+			wantErr: ErrInvalidPRState,
+		},
+		{
+			name: "Test unknown base branch",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{Base: "nobranch"},
+			},
+			wantErr: ErrBaseBranchNotFound,
+		},
+		{
+			name: "Test update title, body & base",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{Title: &newTitle, Body: &newBody, Base: "develop"},
+			},
+			wantResp: PRResponse{Title: "new Title", Body: "updated Body", State: "open", Base: baseHeadPRResponse{Ref: "develop"}},
+		},
+		{
+			name: "Test close PR",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{State: "closed"},
+			},
+			wantResp: PRResponse{Title: "new Title", Body: "updated Body", State: "closed", Base: baseHeadPRResponse{Ref: "develop"}},
+		},
+		{
+			name: "Test retarget closed PR",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{Base: "master"},
+			},
+			wantErr: ErrPRBaseOnClosed,
+		},
+		{
+			name: "Test clear body",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{Body: &emptyBody},
+			},
+			wantResp: PRResponse{Title: "new Title", Body: "", State: "closed", Base: baseHeadPRResponse{Ref: "develop"}},
+		},
+		{
+			name: "Test reopen PR",
+			input: input{
+				orgName:    "gborg",
+				owner:      "gbuser",
+				repoName:   "testrepo",
+				pullNumber: pullNumber,
+				prReq:      PRRequest{State: "open"},
+			},
+			wantResp: PRResponse{Title: "new Title", Body: "", State: "open", Base: baseHeadPRResponse{Ref: "develop"}},
 		},
 	}
 	for _, tt := range tests {
-		resp1, _ := gbService.CreateRepo(tt.input.orgName, tt.input.owner, &CreateRepoRequest{Name: "testrepo", Description: "Test repo request"})
-		resp2, _ := gbService.CreateBranch(tt.input.orgName, tt.input.owner, tt.input.repoName, &CreateBranchRequest{Ref: "refs/heads/featureCD", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
-		resp3, _ := gbService.CreateBranch(tt.input.orgName, tt.input.owner, tt.input.repoName, &CreateBranchRequest{Ref: "refs/heads/master", SHA: "abcgsd2esdf56b14c9653891f9e74264a383fa43fefbd"})
-		resp4, _ := gbService.CreatePR(tt.input.orgName, tt.input.owner, tt.input.repoName, &PRRequest{Title: "Amazing new feature", Body: "Please pull these awesome changes in!", Head: "gbuser:featureCD", Base: "master"})
-		fmt.Println(resp1, resp2, resp3, resp4)
-		pullNumber := resp4.ID
-		updatePRReq := PRRequest{State: "approved"}
-		resp, err := gbService.ApprovePR(tt.input.orgName, tt.input.owner, tt.input.repoName, pullNumber, &updatePRReq)
+		resp, err := gbService.UpdatePR(tt.input.orgName, tt.input.owner, tt.input.repoName, tt.input.pullNumber, &tt.input.prReq)
 		fmt.Println(tt.name, "..", resp, err)
-		if err != nil {
+		if tt.wantErr != nil {
 			assert.Equal(t, tt.wantErr.Error(), err.Error())
 		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantResp.Title, resp.Title)
+			assert.Equal(t, tt.wantResp.Body, resp.Body)
 			assert.Equal(t, tt.wantResp.State, resp.State)
+			assert.Equal(t, tt.wantResp.Base.Ref, resp.Base.Ref)
+			// head.ref is the bare branch name, the owner is in head.user.
+			assert.Equal(t, "featureUP", resp.Head.Ref)
+		}
+	}
+
+	// closing unlinks the head branch, reopening links it again.
+	_, err = gbService.CreatePR("gborg", "gbuser", "testrepo", &PRRequest{Title: &title, Head: "gbuser:featureUP", Base: "master"})
+	assert.Equal(t, ErrPRAlreadyExists, err)
+}
+
+func TestMergePRs(t *testing.T) {
+	type input struct {
+		pullNumber string
+		mergeReq   MergePRRequest
+	}
+	gbService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "mergerepo", Description: "Test merge repo"})
+	gbService.CreateBranch("gborg", "gbuser", "mergerepo", &CreateBranchRequest{Ref: "refs/heads/master", SHA: "abcgsd2esdf56b14c9653891f9e74264a383fa43fefbd"})
+	gbService.CreateBranch("gborg", "gbuser", "mergerepo", &CreateBranchRequest{Ref: "refs/heads/featureMG", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
+	title := "Merge feature"
+	prResp, err := gbService.CreatePR("gborg", "gbuser", "mergerepo", &PRRequest{Title: &title, Head: "gbuser:featureMG", Base: "master"})
+	assert.NoError(t, err)
+	pullNumber := strconv.Itoa(prResp.Number)
+
+	tests := []struct {
+		name     string
+		input    input
+		wantResp MergePRResponse
+		wantErr  error
+	}{
+		{
+			name:    "Test invalid PR number",
+			input:   input{pullNumber: "9999"},
+			wantErr: ErrPRNotFound,
+		},
+		{
+			name:    "Test invalid merge method",
+			input:   input{pullNumber: pullNumber, mergeReq: MergePRRequest{MergeMethod: "octopus"}},
+			wantErr: ErrInvalidMergeMethod,
+		},
+		{
+			name:    "Test stale head sha",
+			input:   input{pullNumber: pullNumber, mergeReq: MergePRRequest{SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd"}},
+			wantErr: ErrPRHeadModified,
+		},
+		{
+			name:     "Test squash merge",
+			input:    input{pullNumber: pullNumber, mergeReq: MergePRRequest{MergeMethod: "squash", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"}},
+			wantResp: MergePRResponse{Merged: true, Message: "Pull Request successfully merged"},
+		},
+		{
+			name:    "Test merge already merged PR",
+			input:   input{pullNumber: pullNumber},
+			wantErr: ErrPRAlreadyMerged,
+		},
+	}
+	for _, tt := range tests {
+		resp, err := gbService.MergePR("gborg", "gbuser", "mergerepo", tt.input.pullNumber, &tt.input.mergeReq)
+		fmt.Println(tt.name, "..", resp, err)
+		if tt.wantErr != nil {
+			assert.Equal(t, tt.wantErr.Error(), err.Error())
+		} else {
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantResp.Merged, resp.Merged)
+			assert.Equal(t, tt.wantResp.Message, resp.Message)
+
+			branches, _ := gbService.ListBranches("gborg", "gbuser", "mergerepo")
+			assert.Equal(t, "master", branches[1].Name)
+			assert.Equal(t, resp.SHA, branches[1].Commit.SHA)
+		}
+	}
+
+	// merged pull requests are closed and can not be reopened.
+	_, err = gbService.UpdatePR("gborg", "gbuser", "mergerepo", pullNumber, &PRRequest{State: "open"})
+	assert.Equal(t, ErrPRAlreadyMerged, err)
+}
+
+func TestCreatePRAsActor(t *testing.T) {
+	actorService := newTestService(t)
+	actorService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/featureAC", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
+
+	title := "Authored"
+	resp, err := actorService.WithActor("gbadmin").CreatePR("gborg", "gbuser", "gbrepo", &PRRequest{Title: &title, Head: "gbuser:featureAC", Base: "master"})
+	assert.NoError(t, err)
+	assert.Equal(t, "gbadmin", resp.User.Login)
+	assert.Equal(t, "featureAC", resp.Head.Ref)
+	assert.Equal(t, "master", resp.Base.Ref)
+
+	// without an actor the path owner stays the author.
+	prList, _ := actorService.ListPRs("gborg", "gbuser", "gbrepo")
+	assert.Equal(t, "gbadmin", prList[0].User.Login)
+	assert.Equal(t, "gbuser", prList[1].User.Login)
+}
+
+func TestCreatePRHead(t *testing.T) {
+	headService := newTestService(t)
+	headService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/bare", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
+	headService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/owned", SHA: "csdsdsdsdf56b14c9653891f9e74264a383fa43fefbd"})
+
+	tests := []struct {
+		name    string
+		head    string
+		wantRef string
+		wantErr error
+	}{
+		{name: "Test bare branch", head: "bare", wantRef: "bare"},
+		{name: "Test owner and branch", head: "gbuser:owned", wantRef: "owned"},
+		{name: "Test head is base", head: "master", wantErr: ErrPRSameHeadBase},
+		{name: "Test owned head is base", head: "gbuser:master", wantErr: ErrPRSameHeadBase},
+		{name: "Test empty head", head: "", wantErr: ErrBranchesNotFound},
+		{name: "Test unknown branch", head: "gbuser:", wantErr: ErrBranchesNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := headService.CreatePR("gborg", "gbuser", "gbrepo", &PRRequest{Head: tt.head, Base: "master"})
+		assert.Equal(t, tt.wantErr, err, tt.name)
+		if tt.wantErr == nil {
+			assert.Equal(t, tt.wantRef, resp.Head.Ref, tt.name)
+			pr, err := headService.GetPR("gborg", "gbuser", "gbrepo", strconv.Itoa(resp.Number))
+			assert.NoError(t, err, tt.name)
+			assert.Equal(t, tt.wantRef, pr.Head.Ref, tt.name)
 		}
 	}
 }
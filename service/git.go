@@ -0,0 +1,353 @@
+package service
+
+import (
+	"bytes"
+	"gbserver/gitstore"
+	"gbserver/models"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// committer is who github puts as the committer of the commits it creates itself, e.g. merge commits.
+var committer = gitstore.Signature{Name: "GbServer", Email: "noreply@gbserver.com"}
+
+// UseGit backs every repository with a bare git repo in gitStore. Branches of the store whose commit
+// does not exist in git (e.g. made up ones from a fixture) are moved to a fresh initial commit.
+func (g *GbService) UseGit(gitStore *gitstore.Store) error {
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	g.Git = gitStore
+	err := g.syncGit()
+	saveErr := g.persist()
+	if err != nil {
+		return err
+	}
+	return saveErr
+}
+
+// syncGit makes the refs of every git repo match the branches of the store and removes the git repos of
+// repos the store does not have. Caller must hold the store lock.
+func (g *GbService) syncGit() error {
+	if g.Git == nil {
+		return nil
+	}
+	for repoKey := range g.GbStoreInstance.Repos {
+		err := g.syncGitRepo(repoKey)
+		if err != nil {
+			return err
+		}
+	}
+	// repos gone from the store lose their git repo, so one created again under the same key starts empty.
+	repoKeys, err := g.Git.RepoKeys()
+	if err != nil {
+		return err
+	}
+	for _, repoKey := range repoKeys {
+		if _, exists := g.GbStoreInstance.Repos[repoKey]; !exists {
+			err = g.Git.Remove(repoKey)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncGitRepo makes the refs of one git repo match the branches of the store. Caller must hold the store lock.
+func (g *GbService) syncGitRepo(repoKey string) error {
+	repo := g.GbStoreInstance.Repos[repoKey]
+	err := g.Git.Init(repoKey)
+	if err != nil {
+		return err
+	}
+	owner, repoName := repo.UserName, repo.Name
+	var initialCommit string
+	for _, branchName := range repo.Branches {
+		branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+		if branch == nil {
+			continue
+		}
+		if !g.Git.CommitExists(repoKey, branch.CommitInfo.SHA) {
+			if initialCommit == "" {
+				initialCommit, err = g.initialCommit(repoKey, repoName, repo.Description, g.GbStoreInstance.Users[repo.OrgName+"/"+owner])
+				if err != nil {
+					return err
+				}
+			}
+			g.logger().Info("Moving branch from unknown commit", "repo", repoKey, "branch", branchName, "sha", branch.CommitInfo.SHA, "to", initialCommit)
+			branch.CommitInfo = commitDetails(owner, repoName, initialCommit)
+		}
+		err = g.Git.UpdateRef(repoKey, "refs/heads/"+branchName, branch.CommitInfo.SHA, "")
+		if err != nil {
+			return err
+		}
+	}
+	refs, err := g.Git.Refs(repoKey, "refs/heads/")
+	if err != nil {
+		return err
+	}
+	for ref := range refs {
+		if _, exists := g.GbStoreInstance.Branches[repoKey+"/"+strings.TrimPrefix(ref, "refs/heads/")]; !exists {
+			err = g.Git.DeleteRef(repoKey, ref)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = g.syncGitTags(repoKey)
+	if err != nil {
+		return err
+	}
+	return g.Git.SetHead(repoKey, g.defaultBranch(repoKey))
+}
+
+// gitRepo checks a smart HTTP request for a repo and returns its key.
+func (g *GbService) gitRepo(orgName, owner, repoName, gitService string) (string, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return "", err
+	}
+	if g.Git == nil {
+		return "", ErrGitDisabled
+	}
+	if !slices.Contains(gitstore.Services, gitService) {
+		return "", ErrInvalidGitService
+	}
+	return orgName + "/" + owner + "/" + repoName, nil
+}
+
+// get /{org}/{owner}/{repo}.git/info/refs
+func (g *GbService) AdvertiseRefs(orgName, owner, repoName, gitService, protocol string, out io.Writer) error {
+	repoKey, err := g.gitRepo(orgName, owner, repoName, gitService)
+	if err != nil {
+		return err
+	}
+	return g.Git.AdvertiseRefs(repoKey, gitService, protocol, out)
+}
+
+// post /{org}/{owner}/{repo}.git/git-upload-pack, post /{org}/{owner}/{repo}.git/git-receive-pack
+// A push is refused up front when it breaks the protection of a branch, forced updates once git has their
+// commits. The lock of the repo is held from the checks until git has updated the refs, so protection cannot
+// change in between, and git refuses the update of a ref that moved since the push read it. After it the
+// branches of the store are brought in line with the refs git ended up with, also when the push failed half
+// way, e.g. because the client went away.
+func (g *GbService) ServeGitRPC(orgName, owner, repoName, gitService, protocol string, in io.Reader, out io.Writer) error {
+	repoKey, err := g.gitRepo(orgName, owner, repoName, gitService)
+	if err != nil {
+		return err
+	}
+	var noForcePush []string
+	if gitService == "git-receive-pack" {
+		defer g.lockPushes(repoKey)()
+		updates, consumed, err := gitstore.ReadPushCommands(in)
+		if err != nil {
+			return err
+		}
+		noForcePush, err = g.checkPush(orgName, owner, repoName, updates)
+		if err != nil {
+			return err
+		}
+		in = io.MultiReader(bytes.NewReader(consumed), in)
+	}
+	err = g.Git.ServiceRPC(repoKey, gitService, protocol, noForcePush, in, out)
+	if gitService != "git-receive-pack" {
+		return err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	syncErr := g.syncPushedRefs(orgName, owner, repoName)
+	saveErr := g.persist()
+	if err != nil {
+		return err
+	}
+	if syncErr != nil {
+		return syncErr
+	}
+	return saveErr
+}
+
+// lockPushes takes the lock of the repo pushes hold, see gitstore.Store.LockRepo, and returns the function
+// releasing it. Without git there are no pushes to keep out.
+func (g *GbService) lockPushes(repoKey string) func() {
+	if g.Git == nil {
+		return func() {}
+	}
+	return g.Git.LockRepo(repoKey)
+}
+
+// checkPush enforces branch protection on the ref updates of a push. The commits only arrive with the pack, so
+// the refs that must not be force pushed are returned for git-receive-pack to check.
+func (g *GbService) checkPush(orgName, owner, repoName string, updates []gitstore.RefUpdate) ([]string, error) {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	repoKey := orgName + "/" + owner + "/" + repoName
+	var noForcePush []string
+	for _, update := range updates {
+		branchName, isBranch := strings.CutPrefix(update.Ref, "refs/heads/")
+		if !isBranch || update.OldSHA == zeroSHA {
+			continue
+		}
+		newSHA := update.NewSHA
+		if newSHA == zeroSHA {
+			newSHA = ""
+		}
+		err := g.checkRefUpdate(orgName, owner, repoName, branchName, update.OldSHA, newSHA)
+		if err != nil {
+			return nil, err
+		}
+		branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+		if newSHA != "" && branch != nil && branch.Protection != nil && !branch.Protection.AllowForcePushes &&
+			!g.protectionBypassed(orgName, owner, branch.Protection) {
+			noForcePush = append(noForcePush, update.Ref)
+		}
+	}
+	return noForcePush, nil
+}
+
+// syncPushedRefs creates, moves and deletes branches and tags of the store to match the refs of git,
+// sending the events github sends for a push. Caller must hold the store lock.
+func (g *GbService) syncPushedRefs(orgName, owner, repoName string) error {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	repo, exists := g.GbStoreInstance.Repos[repoKey]
+	if !exists {
+		// deleted while the push was running.
+		return nil
+	}
+	refs, err := g.Git.Refs(repoKey, "refs/heads/")
+	if err != nil {
+		return err
+	}
+	for _, branchName := range slices.Clone(repo.Branches) {
+		branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+		sha, exists := refs["refs/heads/"+branchName]
+		if !exists {
+			g.removeBranch(orgName, owner, repoName, branchName)
+			continue
+		}
+		if sha == branch.CommitInfo.SHA {
+			continue
+		}
+		before := branch.CommitInfo.SHA
+		branch.CommitInfo = commitDetails(owner, repoName, sha)
+		g.refreshBranchPRStats(repoKey, branchName)
+		g.emitPush(orgName, owner, repoName, branchName, before, sha)
+		if pr, exists := g.GbStoreInstance.PullRequests[branch.PullRequestID]; exists && pr.State == "open" {
+			g.emitPullRequest("synchronize", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), nil)
+		}
+	}
+	var newBranches []string
+	for ref := range refs {
+		if branchName := strings.TrimPrefix(ref, "refs/heads/"); !slices.Contains(repo.Branches, branchName) {
+			newBranches = append(newBranches, branchName)
+		}
+	}
+	sort.Strings(newBranches)
+	for _, branchName := range newBranches {
+		g.addBranch(orgName, owner, repoName, branchName, refs["refs/heads/"+branchName])
+		g.emitBranch("create", orgName, owner, repoName, branchName, refs["refs/heads/"+branchName])
+	}
+	err = g.syncPushedTags(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	// the first push into an empty repo decides its default branch.
+	return g.Git.SetHead(repoKey, g.defaultBranch(repoKey))
+}
+
+func commitDetails(owner, repoName, sha string) models.CommitDetails {
+	return models.CommitDetails{SHA: sha, URL: repoAPIURL(owner, repoName) + "/git/commits/" + sha}
+}
+
+// signatureOf is the git identity of a user.
+func signatureOf(user *models.User, when time.Time) gitstore.Signature {
+	if user == nil {
+		return gitstore.Signature{Name: committer.Name, Email: committer.Email, When: when}
+	}
+	return gitstore.Signature{Name: user.LoginName, Email: strconv.Itoa(user.ID) + "+" + user.LoginName + "@users.noreply.gbserver.com", When: when}
+}
+
+// initialCommit creates the README commit github makes for auto_init.
+func (g *GbService) initialCommit(repoKey, repoName, description string, author *models.User) (string, error) {
+	readme := "# " + repoName + "\n"
+	if description != "" {
+		readme += description + "\n"
+	}
+	now := time.Now().UTC()
+	return g.Git.Commit(repoKey, "", []gitstore.FileChange{{Path: "README.md", Content: []byte(readme)}},
+		"Initial commit", signatureOf(author, now), gitstore.Signature{Name: committer.Name, Email: committer.Email, When: now})
+}
+
+// gitRebase replays the commits of the head onto the base one by one. Every commit keeps its message and
+// author, the merger becomes the committer. Merge commits and changes that do not apply leave the pull
+// request not mergeable. Caller must hold the store lock.
+func (g *GbService) gitRebase(repoKey, headSHA, baseSHA string, merger gitstore.Signature) (string, error) {
+	commits, err := g.Git.RevList(repoKey, baseSHA, headSHA)
+	if err != nil {
+		return "", err
+	}
+	onto := baseSHA
+	for _, sha := range commits {
+		commit, err := g.Git.ReadCommit(repoKey, sha)
+		if err != nil {
+			return "", err
+		}
+		if len(commit.Parents) != 1 {
+			return "", ErrPRNotMergeable
+		}
+		tree := commit.Tree
+		if commit.Parents[0] != onto {
+			tree, err = g.Git.PickTree(repoKey, onto, commit)
+			if err == gitstore.ErrMergeConflict {
+				return "", ErrPRNotMergeable
+			}
+			if err != nil {
+				return "", err
+			}
+		}
+		onto, err = g.Git.CommitTree(repoKey, tree, []string{onto}, commit.Message+"\n", commit.Author, merger)
+		if err != nil {
+			return "", err
+		}
+	}
+	return onto, nil
+}
+
+// gitMerge creates the commit a merge of the pull request leaves on the base branch. Caller must hold the store lock.
+func (g *GbService) gitMerge(repoKey string, prDetails *models.PullRequest, headSHA, baseSHA, mergeMethod string, mergeReq *MergePRRequest, author *models.User) (string, error) {
+	title := mergeReq.CommitTitle
+	message := mergeReq.CommitMessage
+	now := time.Now().UTC()
+	authorSig := signatureOf(author, now)
+	committerSig := gitstore.Signature{Name: committer.Name, Email: committer.Email, When: now}
+
+	if mergeMethod == "rebase" {
+		return g.gitRebase(repoKey, headSHA, baseSHA, authorSig)
+	}
+
+	tree, err := g.Git.MergeTree(repoKey, baseSHA, headSHA)
+	if err == gitstore.ErrMergeConflict {
+		return "", ErrPRNotMergeable
+	}
+	if err != nil {
+		return "", err
+	}
+	parents := []string{baseSHA, headSHA}
+	if title == "" {
+		title = "Merge pull request #" + strconv.Itoa(prDetails.Number) + " from " + strings.Replace(prDetails.FromBranch, ":", "/", 1)
+		if message == "" {
+			message = prDetails.Title
+		}
+	}
+	if mergeMethod != "merge" {
+		parents = []string{baseSHA}
+		if mergeReq.CommitTitle == "" {
+			title = prDetails.Title + " (#" + strconv.Itoa(prDetails.Number) + ")"
+			message = mergeReq.CommitMessage
+		}
+	}
+	return g.Git.CommitTree(repoKey, tree, parents, title+"\n\n"+message+"\n", authorSig, committerSig)
+}
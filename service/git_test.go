@@ -0,0 +1,122 @@
+package service
+
+import (
+	"gbserver/gitstore"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newGitService is a service on the default fixture backed by git repos in a temp dir.
+func newGitService(t *testing.T) *GbService {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	gitStore, err := gitstore.New(t.TempDir())
+	assert.NoError(t, err)
+	gitService := newTestService(t)
+	assert.NoError(t, gitService.UseGit(gitStore))
+	return gitService
+}
+
+func TestGitBackedBranches(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+
+	// the made up fixture SHAs are replaced by a real initial commit.
+	master := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	assert.NotEqual(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", master)
+	assert.True(t, gitService.Git.CommitExists(repoKey, master))
+
+	_, err := gitService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/fix", SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd"})
+	assert.Equal(t, ErrCommitNotFound, err)
+	_, err = gitService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/fix", SHA: master})
+	assert.NoError(t, err)
+	refs, _ := gitService.Git.Refs(repoKey, "refs/heads/")
+	assert.Equal(t, master, refs["refs/heads/fix"])
+
+	_, err = gitService.DeleteBranch("gborg", "gbuser", "gbrepo", "fix")
+	assert.NoError(t, err)
+	refs, _ = gitService.Git.Refs(repoKey, "refs/heads/")
+	assert.NotContains(t, refs, "refs/heads/fix")
+
+	_, err = gitService.CreateRepo("gborg", "gbuser", &CreateRepoRequest{Name: "inited", AutoInit: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main"}, gitService.GbStoreInstance.Repos["gborg/gbuser/inited"].Branches)
+	assert.True(t, gitService.Git.CommitExists("gborg/gbuser/inited", gitService.GbStoreInstance.Branches["gborg/gbuser/inited/main"].CommitInfo.SHA))
+}
+
+func TestGitBackedMerge(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := gitstore.Signature{Name: "gbuser", Email: "gbuser@gbserver.com"}
+	base := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	head, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "feature.txt", Content: []byte("new\n")}}, "Add feature", sig, sig)
+	assert.NoError(t, err)
+	other, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "other.txt", Content: []byte("other\n")}}, "Add other", sig, sig)
+	assert.NoError(t, err)
+	gitService.GbStoreInstance.Branches[repoKey+"/gbbranch"].CommitInfo = commitDetails("gbuser", "gbrepo", head)
+	gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo = commitDetails("gbuser", "gbrepo", other)
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+
+	mergeResp, err := gitService.MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{})
+	assert.NoError(t, err)
+	assert.True(t, mergeResp.Merged)
+	assert.True(t, gitstore.IsSHA(mergeResp.SHA))
+	for _, parent := range []string{other, head} {
+		isAncestor, _ := gitService.Git.IsAncestor(repoKey, parent, mergeResp.SHA)
+		assert.True(t, isAncestor)
+	}
+	// a merge commit on top of the head commit.
+	commits, _ := gitService.Git.RevList(repoKey, other, mergeResp.SHA)
+	assert.Equal(t, []string{head, mergeResp.SHA}, commits)
+	assert.Equal(t, mergeResp.SHA, gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA)
+}
+
+func TestGitBackedRebaseMerge(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := gitstore.Signature{Name: "contributor", Email: "contributor@gbserver.com", When: time.Unix(1700000000, 0).UTC()}
+	base := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	first, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "feature.txt", Content: []byte("new\n")}}, "Add feature\n\nWith a body", sig, sig)
+	assert.NoError(t, err)
+	head, err := gitService.Git.Commit(repoKey, first, []gitstore.FileChange{{Path: "feature.txt", Content: []byte("newer\n")}}, "Change feature", sig, sig)
+	assert.NoError(t, err)
+	other, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "other.txt", Content: []byte("other\n")}}, "Add other", sig, sig)
+	assert.NoError(t, err)
+	gitService.GbStoreInstance.Branches[repoKey+"/gbbranch"].CommitInfo = commitDetails("gbuser", "gbrepo", head)
+	gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo = commitDetails("gbuser", "gbrepo", other)
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+
+	// the base moved on, the head commits are replayed onto it.
+	mergeResp, err := gitService.WithActor("gbadmin").MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{MergeMethod: "rebase"})
+	assert.NoError(t, err)
+	commits, _ := gitService.Git.RevList(repoKey, other, mergeResp.SHA)
+	assert.Len(t, commits, 2)
+	for i, want := range []string{"Add feature\n\nWith a body", "Change feature"} {
+		commit, err := gitService.Git.ReadCommit(repoKey, commits[i])
+		assert.NoError(t, err)
+		assert.Equal(t, want, commit.Message)
+		assert.Equal(t, sig, commit.Author)
+		assert.Equal(t, "gbadmin", commit.Committer.Name)
+	}
+	content, err := gitService.Git.Entry(repoKey, mergeResp.SHA, "feature.txt")
+	assert.NoError(t, err)
+	blob, _ := gitService.Git.ReadBlob(repoKey, content.SHA)
+	assert.Equal(t, "newer\n", string(blob))
+	_, err = gitService.Git.Entry(repoKey, mergeResp.SHA, "other.txt")
+	assert.NoError(t, err)
+
+	// changes that do not apply to the new base are not mergeable.
+	gitService = newGitService(t)
+	base = gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	head, _ = gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "feature.txt", Content: []byte("ours\n")}}, "Add feature", sig, sig)
+	other, _ = gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "feature.txt", Content: []byte("theirs\n")}}, "Add feature too", sig, sig)
+	gitService.GbStoreInstance.Branches[repoKey+"/gbbranch"].CommitInfo = commitDetails("gbuser", "gbrepo", head)
+	gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo = commitDetails("gbuser", "gbrepo", other)
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+	_, err = gitService.MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{MergeMethod: "rebase"})
+	assert.Equal(t, ErrPRNotMergeable, err)
+}
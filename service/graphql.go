@@ -0,0 +1,786 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"gbserver/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// maxPageSize is the most nodes a connection hands out at once, same as github.
+const maxPageSize = 100
+
+// GraphQLRequest is the body of post /graphql.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// The gql types are what the schema resolves fields from, snapshots of the store taken under its lock.
+// Fields without a resolver are matched by name.
+type gqlUser struct {
+	ID         string
+	Login      string
+	DatabaseID int
+	URL        string
+}
+
+type gqlRepository struct {
+	ID            string
+	DatabaseID    int
+	Name          string
+	NameWithOwner string
+	Description   string
+	URL           string
+	OrgName       string
+	OwnerLogin    string
+}
+
+func (r gqlRepository) key() string {
+	return r.OrgName + "/" + r.OwnerLogin + "/" + r.Name
+}
+
+type gqlCommit struct {
+	ID             string
+	Oid            string
+	AbbreviatedOid string
+	CommitURL      string
+}
+
+type gqlTag struct {
+	ID             string
+	Oid            string
+	AbbreviatedOid string
+	Name           string
+	Message        string
+	Target         interface{}
+}
+
+type gqlRef struct {
+	ID         string
+	Name       string
+	Prefix     string
+	Target     interface{}
+	Repository gqlRepository
+}
+
+type gqlPullRequest struct {
+	ID           string
+	Number       int
+	Title        string
+	Body         string
+	State        string
+	URL          string
+	HeadRefName  string
+	BaseRefName  string
+	HeadRefOid   string
+	BaseRefOid   string
+	Merged       bool
+	MergedAt     *string
+	MergeCommit  *gqlCommit
+	Mergeable    string
+	Author       *gqlUser
+	MergedBy     *gqlUser
+	Additions    int
+	Deletions    int
+	ChangedFiles int
+	IsDraft      bool
+	Repository   gqlRepository
+}
+
+type gqlEdge struct {
+	Cursor string
+	Node   interface{}
+}
+
+type gqlPageInfo struct {
+	HasNextPage     bool
+	HasPreviousPage bool
+	StartCursor     *string
+	EndCursor       *string
+}
+
+type gqlConnection struct {
+	Edges      []gqlEdge
+	Nodes      []interface{}
+	PageInfo   gqlPageInfo
+	TotalCount int
+}
+
+func gqlUserOf(user *models.User) *gqlUser {
+	if user == nil {
+		return nil
+	}
+	return &gqlUser{ID: user.NodeID, Login: user.LoginName, DatabaseID: user.ID, URL: "https://gbserver.com/" + user.LoginName}
+}
+
+func gqlRepositoryOf(repo *models.Repository) gqlRepository {
+	return gqlRepository{ID: repo.Node_ID, DatabaseID: repo.ID, Name: repo.Name, NameWithOwner: repo.UserName + "/" + repo.Name,
+		Description: repo.Description, URL: htmlURL(repo.UserName, repo.Name), OrgName: repo.OrgName, OwnerLogin: repo.UserName}
+}
+
+func gqlCommitOf(repo gqlRepository, sha string) gqlCommit {
+	return gqlCommit{ID: commitNodeID(sha), Oid: sha, AbbreviatedOid: sha[:min(7, len(sha))],
+		CommitURL: htmlURL(repo.OwnerLogin, repo.Name) + "/commit/" + sha}
+}
+
+// gqlObjectOf is the commit or annotated tag sha names. Caller must hold the store lock.
+func (g *GbService) gqlObjectOf(repo gqlRepository, sha string) interface{} {
+	tagObject, exists := g.GbStoreInstance.TagObjects[repo.key()+"/"+sha]
+	if !exists {
+		return gqlCommitOf(repo, sha)
+	}
+	return gqlTag{ID: tagObject.NodeID, Oid: sha, AbbreviatedOid: sha[:min(7, len(sha))], Name: tagObject.Tag,
+		Message: tagObject.Message, Target: g.gqlObjectOf(repo, tagObject.Object)}
+}
+
+// gqlRefOf splits a ref into the prefix and name graphql shows. Caller must hold the store lock.
+func (g *GbService) gqlRefOf(repo gqlRepository, refResp CreateBranchResponse) gqlRef {
+	prefix := "refs/heads/"
+	if strings.HasPrefix(refResp.Ref, "refs/tags/") {
+		prefix = "refs/tags/"
+	}
+	return gqlRef{ID: refResp.NodeID, Name: strings.TrimPrefix(refResp.Ref, prefix), Prefix: prefix,
+		Target: g.gqlObjectOf(repo, refResp.Object.SHA), Repository: repo}
+}
+
+// gqlRefNamed finds a ref by its qualified name or, like github, by a bare branch or tag name.
+// Caller must hold the store lock.
+func (g *GbService) gqlRefNamed(repo gqlRepository, name string) *gqlRef {
+	refList := g.refsOf(repo.key(), "refs/")
+	for _, qualifiedName := range []string{name, "refs/heads/" + name, "refs/tags/" + name} {
+		for _, refResp := range refList {
+			if refResp.Ref == qualifiedName {
+				ref := g.gqlRefOf(repo, refResp)
+				return &ref
+			}
+		}
+	}
+	return nil
+}
+
+// gqlPullRequestOf renders a stored pull request. Caller must hold the store lock.
+func (g *GbService) gqlPullRequestOf(repo gqlRepository, pr *models.PullRequest) gqlPullRequest {
+	prResp := g.buildPRResponse(repo.OrgName, repo.OwnerLogin, repo.Name, pr)
+	gqlPR := gqlPullRequest{ID: prResp.NodeID, Number: prResp.Number, Title: prResp.Title, Body: prResp.Body,
+		State: "OPEN", URL: htmlURL(repo.OwnerLogin, repo.Name) + "/pull/" + strconv.Itoa(prResp.Number),
+		HeadRefName: prResp.Head.Ref, BaseRefName: prResp.Base.Ref, HeadRefOid: prResp.Head.SHA, BaseRefOid: prResp.Base.SHA,
+		Merged: prResp.Merged, Mergeable: "UNKNOWN", Author: gqlUserOf(g.userByID(repo.OrgName, pr.AuthorID)),
+		Additions: prResp.Additions, Deletions: prResp.Deletions, ChangedFiles: prResp.ChangedFiles, Repository: repo}
+	if gqlPR.Author == nil {
+		gqlPR.Author = gqlUserOf(g.GbStoreInstance.Users[repo.OrgName+"/"+repo.OwnerLogin])
+	}
+	switch {
+	case prResp.Merged:
+		gqlPR.State = "MERGED"
+	case prResp.State == "closed":
+		gqlPR.State = "CLOSED"
+	}
+	if prResp.Mergeable != nil && *prResp.Mergeable {
+		gqlPR.Mergeable = "MERGEABLE"
+	} else if prResp.Mergeable != nil {
+		gqlPR.Mergeable = "CONFLICTING"
+	}
+	if prResp.Merged {
+		gqlPR.MergedAt = &prResp.MergedAt
+		mergeCommit := gqlCommitOf(repo, prResp.MergeCommit)
+		gqlPR.MergeCommit = &mergeCommit
+		gqlPR.MergedBy = gqlUserOf(g.userByID(repo.OrgName, pr.MergedByID))
+	}
+	return gqlPR
+}
+
+// canSee tells if the caller may read the org, everyone can when there is no authenticated caller.
+// Caller must hold the store lock.
+func (g *GbService) canSee(orgName string) bool {
+	if g.Actor == "" {
+		return true
+	}
+	if account := g.accountOf(g.Actor); account != nil && account.SiteAdmin {
+		return true
+	}
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	return exists && slices.Contains(org.Users, g.Actor)
+}
+
+// visibleRepos are the repos of the orgs the caller can see, sorted by key. Caller must hold the store lock.
+func (g *GbService) visibleRepos() []*models.Repository {
+	repoKeys := []string{}
+	for repoKey, repo := range g.GbStoreInstance.Repos {
+		if g.canSee(repo.OrgName) {
+			repoKeys = append(repoKeys, repoKey)
+		}
+	}
+	slices.Sort(repoKeys)
+	repoList := []*models.Repository{}
+	for _, repoKey := range repoKeys {
+		repoList = append(repoList, g.GbStoreInstance.Repos[repoKey])
+	}
+	return repoList
+}
+
+// gqlRepositoryNamed finds owner/name, the first org in name order wins unless orgName picks one.
+// Caller must hold the store lock.
+func (g *GbService) gqlRepositoryNamed(orgName, owner, name string) (gqlRepository, error) {
+	for _, repo := range g.visibleRepos() {
+		if repo.UserName == owner && repo.Name == name && (orgName == "" || repo.OrgName == orgName) {
+			return gqlRepositoryOf(repo), nil
+		}
+	}
+	return gqlRepository{}, ErrRepoNotFound
+}
+
+// nodeOf looks a global id up among users, repos, refs and pull requests. Caller must hold the store lock.
+func (g *GbService) nodeOf(id string) (interface{}, error) {
+	if id == "" {
+		return nil, ErrNodeNotFound
+	}
+	for _, account := range g.GbStoreInstance.Accounts {
+		if account.NodeID == id {
+			return *gqlUserOf(account), nil
+		}
+	}
+	for _, user := range g.GbStoreInstance.Users {
+		if user.NodeID == id {
+			return *gqlUserOf(user), nil
+		}
+	}
+	for _, repo := range g.visibleRepos() {
+		gqlRepo := gqlRepositoryOf(repo)
+		if repo.Node_ID == id {
+			return gqlRepo, nil
+		}
+		for _, refResp := range g.refsOf(gqlRepo.key(), "refs/") {
+			if refResp.NodeID == id {
+				return g.gqlRefOf(gqlRepo, refResp), nil
+			}
+		}
+		for _, prID := range repo.PrIDs {
+			if pr := g.GbStoreInstance.PullRequests[prID]; pr != nil && pr.NodeID == id {
+				return g.gqlPullRequestOf(gqlRepo, pr), nil
+			}
+		}
+	}
+	return nil, ErrNodeNotFound
+}
+
+func cursorOf(position int) string {
+	return base64.StdEncoding.EncodeToString([]byte("cursor:" + strconv.Itoa(position)))
+}
+
+// positionOf reads a cursor back, positions count from 1.
+func positionOf(cursor string) (int, error) {
+	decoded, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	position, err := strconv.Atoi(strings.TrimPrefix(string(decoded), "cursor:"))
+	if err != nil || position < 1 || !strings.HasPrefix(string(decoded), "cursor:") {
+		return 0, ErrInvalidCursor
+	}
+	return position, nil
+}
+
+// connectionOf pages through items like github's connections, first and after walk forward, last and before
+// walk back from the end. Cursors are positions in items, so they stay valid as long as items only grow at the end.
+func connectionOf(items []interface{}, args map[string]interface{}) (gqlConnection, error) {
+	first, hasFirst := args["first"].(int)
+	last, hasLast := args["last"].(int)
+	if !hasFirst && !hasLast {
+		return gqlConnection{}, ErrPaginationRequired
+	}
+	if (hasFirst && (first < 0 || first > maxPageSize)) || (hasLast && (last < 0 || last > maxPageSize)) {
+		return gqlConnection{}, ErrInvalidPageSize
+	}
+	start, end := 0, len(items)
+	if after, ok := args["after"].(string); ok {
+		position, err := positionOf(after)
+		if err != nil {
+			return gqlConnection{}, err
+		}
+		start = min(position, end)
+	}
+	if before, ok := args["before"].(string); ok {
+		position, err := positionOf(before)
+		if err != nil {
+			return gqlConnection{}, err
+		}
+		end = max(min(position-1, end), start)
+	}
+	if hasFirst && end-start > first {
+		end = start + first
+	}
+	if hasLast && end-start > last {
+		start = end - last
+	}
+
+	connection := gqlConnection{Edges: []gqlEdge{}, Nodes: items[start:end], TotalCount: len(items),
+		PageInfo: gqlPageInfo{HasNextPage: end < len(items), HasPreviousPage: start > 0}}
+	for i, item := range connection.Nodes {
+		connection.Edges = append(connection.Edges, gqlEdge{Cursor: cursorOf(start + i + 1), Node: item})
+	}
+	if len(connection.Edges) > 0 {
+		connection.PageInfo.StartCursor = &connection.Edges[0].Cursor
+		connection.PageInfo.EndCursor = &connection.Edges[len(connection.Edges)-1].Cursor
+	}
+	return connection, nil
+}
+
+type graphqlServiceKey struct{}
+
+// serviceOf is the service the request runs against, acting as its caller.
+func serviceOf(p graphql.ResolveParams) *GbService {
+	return p.Context.Value(graphqlServiceKey{}).(*GbService)
+}
+
+func argString(args map[string]interface{}, name string) string {
+	value, _ := args[name].(string)
+	return value
+}
+
+// connectionArgs are the paging arguments of every connection.
+func connectionArgs(extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"first":  &graphql.ArgumentConfig{Type: graphql.Int},
+		"after":  &graphql.ArgumentConfig{Type: graphql.String},
+		"last":   &graphql.ArgumentConfig{Type: graphql.Int},
+		"before": &graphql.ArgumentConfig{Type: graphql.String},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+func connectionType(name string, nodeType graphql.Output, pageInfoType *graphql.Object) *graphql.Object {
+	edgeType := graphql.NewObject(graphql.ObjectConfig{Name: name + "Edge", Fields: graphql.Fields{
+		"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"node":   &graphql.Field{Type: nodeType},
+	}})
+	return graphql.NewObject(graphql.ObjectConfig{Name: name + "Connection", Fields: graphql.Fields{
+		"edges":      &graphql.Field{Type: graphql.NewList(edgeType)},
+		"nodes":      &graphql.Field{Type: graphql.NewList(nodeType)},
+		"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	}})
+}
+
+var (
+	graphqlSchemaOnce sync.Once
+	graphqlSchema     graphql.Schema
+	graphqlSchemaErr  error
+)
+
+// schema builds the subset of github's v4 schema gbserver answers, once.
+func schema() (graphql.Schema, error) {
+	graphqlSchemaOnce.Do(func() {
+		graphqlSchema, graphqlSchemaErr = graphql.NewSchema(newSchemaConfig())
+	})
+	return graphqlSchema, graphqlSchemaErr
+}
+
+func newSchemaConfig() graphql.SchemaConfig {
+	var userType, repositoryType, refType, commitType, tagType, pullRequestType *graphql.Object
+
+	nodeInterface := graphql.NewInterface(graphql.InterfaceConfig{
+		Name:   "Node",
+		Fields: graphql.Fields{"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)}},
+		ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+			switch p.Value.(type) {
+			case gqlUser:
+				return userType
+			case gqlRepository:
+				return repositoryType
+			case gqlRef:
+				return refType
+			case gqlPullRequest:
+				return pullRequestType
+			case gqlCommit:
+				return commitType
+			case gqlTag:
+				return tagType
+			}
+			return nil
+		},
+	})
+	gitObjectInterface := graphql.NewInterface(graphql.InterfaceConfig{
+		Name: "GitObject",
+		Fields: graphql.Fields{
+			"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"oid":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"abbreviatedOid": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+		ResolveType: func(p graphql.ResolveTypeParams) *graphql.Object {
+			if _, isTag := p.Value.(gqlTag); isTag {
+				return tagType
+			}
+			return commitType
+		},
+	})
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{Name: "PageInfo", Fields: graphql.Fields{
+		"hasNextPage":     &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     &graphql.Field{Type: graphql.String},
+		"endCursor":       &graphql.Field{Type: graphql.String},
+	}})
+	pullRequestStateEnum := graphql.NewEnum(graphql.EnumConfig{Name: "PullRequestState", Values: graphql.EnumValueConfigMap{
+		"OPEN":   &graphql.EnumValueConfig{Value: "OPEN"},
+		"CLOSED": &graphql.EnumValueConfig{Value: "CLOSED"},
+		"MERGED": &graphql.EnumValueConfig{Value: "MERGED"},
+	}})
+	mergeableStateEnum := graphql.NewEnum(graphql.EnumConfig{Name: "MergeableState", Values: graphql.EnumValueConfigMap{
+		"MERGEABLE":   &graphql.EnumValueConfig{Value: "MERGEABLE"},
+		"CONFLICTING": &graphql.EnumValueConfig{Value: "CONFLICTING"},
+		"UNKNOWN":     &graphql.EnumValueConfig{Value: "UNKNOWN"},
+	}})
+	mergeMethodEnum := graphql.NewEnum(graphql.EnumConfig{Name: "PullRequestMergeMethod", Values: graphql.EnumValueConfigMap{
+		"MERGE":  &graphql.EnumValueConfig{Value: "merge"},
+		"SQUASH": &graphql.EnumValueConfig{Value: "squash"},
+		"REBASE": &graphql.EnumValueConfig{Value: "rebase"},
+	}})
+
+	userType = graphql.NewObject(graphql.ObjectConfig{Name: "User", Interfaces: []*graphql.Interface{nodeInterface},
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"login":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"databaseId": &graphql.Field{Type: graphql.Int},
+			"url":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		}})
+	commitType = graphql.NewObject(graphql.ObjectConfig{Name: "Commit",
+		Interfaces: []*graphql.Interface{nodeInterface, gitObjectInterface},
+		Fields: graphql.Fields{
+			"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"oid":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"abbreviatedOid": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"commitUrl":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		}})
+	tagType = graphql.NewObject(graphql.ObjectConfig{Name: "Tag",
+		Interfaces: []*graphql.Interface{nodeInterface, gitObjectInterface},
+		Fields: graphql.Fields{
+			"id":             &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"oid":            &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"abbreviatedOid": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"name":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"message":        &graphql.Field{Type: graphql.String},
+			"target":         &graphql.Field{Type: graphql.NewNonNull(gitObjectInterface)},
+		}})
+	refType = graphql.NewObject(graphql.ObjectConfig{Name: "Ref", Interfaces: []*graphql.Interface{nodeInterface},
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"name":       &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"prefix":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"target":     &graphql.Field{Type: gitObjectInterface},
+				"repository": &graphql.Field{Type: graphql.NewNonNull(repositoryType)},
+			}
+		})})
+	pullRequestType = graphql.NewObject(graphql.ObjectConfig{Name: "PullRequest", Interfaces: []*graphql.Interface{nodeInterface},
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			// headRef and baseRef are gone once their branch is deleted.
+			refResolver := func(refName func(gqlPullRequest) string) graphql.FieldResolveFn {
+				return func(p graphql.ResolveParams) (interface{}, error) {
+					pr := p.Source.(gqlPullRequest)
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					return g.gqlRefNamed(pr.Repository, "refs/heads/"+refName(pr)), nil
+				}
+			}
+			return graphql.Fields{
+				"id":           &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+				"number":       &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"title":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"body":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"state":        &graphql.Field{Type: graphql.NewNonNull(pullRequestStateEnum)},
+				"url":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"headRefName":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"baseRefName":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"headRefOid":   &graphql.Field{Type: graphql.String},
+				"baseRefOid":   &graphql.Field{Type: graphql.String},
+				"isDraft":      &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"merged":       &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+				"mergedAt":     &graphql.Field{Type: graphql.String},
+				"mergeCommit":  &graphql.Field{Type: commitType},
+				"mergeable":    &graphql.Field{Type: graphql.NewNonNull(mergeableStateEnum)},
+				"author":       &graphql.Field{Type: userType},
+				"mergedBy":     &graphql.Field{Type: userType},
+				"additions":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"deletions":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"changedFiles": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+				"repository":   &graphql.Field{Type: graphql.NewNonNull(repositoryType)},
+				"headRef": &graphql.Field{Type: refType,
+					Resolve: refResolver(func(pr gqlPullRequest) string { return pr.HeadRefName })},
+				"baseRef": &graphql.Field{Type: refType,
+					Resolve: refResolver(func(pr gqlPullRequest) string { return pr.BaseRefName })},
+			}
+		})})
+	refConnectionType := connectionType("Ref", refType, pageInfoType)
+	pullRequestConnectionType := connectionType("PullRequest", pullRequestType, pageInfoType)
+
+	repositoryType = graphql.NewObject(graphql.ObjectConfig{Name: "Repository", Interfaces: []*graphql.Interface{nodeInterface},
+		Fields: graphql.Fields{
+			"id":            &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"databaseId":    &graphql.Field{Type: graphql.Int},
+			"name":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"nameWithOwner": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"description":   &graphql.Field{Type: graphql.String},
+			"url":           &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"owner": &graphql.Field{Type: graphql.NewNonNull(userType),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					repo := p.Source.(gqlRepository)
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					return gqlUserOf(g.GbStoreInstance.Users[repo.OrgName+"/"+repo.OwnerLogin]), nil
+				}},
+			"defaultBranchRef": &graphql.Field{Type: refType,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					repo := p.Source.(gqlRepository)
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					return g.gqlRefNamed(repo, "refs/heads/"+g.defaultBranch(repo.key())), nil
+				}},
+			"ref": &graphql.Field{Type: refType,
+				Args: graphql.FieldConfigArgument{"qualifiedName": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					repo := p.Source.(gqlRepository)
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					return g.gqlRefNamed(repo, argString(p.Args, "qualifiedName")), nil
+				}},
+			"refs": &graphql.Field{Type: refConnectionType,
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"refPrefix": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					repo := p.Source.(gqlRepository)
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					refList := []interface{}{}
+					for _, refResp := range g.refsOf(repo.key(), argString(p.Args, "refPrefix")) {
+						refList = append(refList, g.gqlRefOf(repo, refResp))
+					}
+					return connectionOf(refList, p.Args)
+				}},
+			"pullRequests": &graphql.Field{Type: graphql.NewNonNull(pullRequestConnectionType),
+				Args: connectionArgs(graphql.FieldConfigArgument{
+					"states":      &graphql.ArgumentConfig{Type: graphql.NewList(graphql.NewNonNull(pullRequestStateEnum))},
+					"headRefName": &graphql.ArgumentConfig{Type: graphql.String},
+					"baseRefName": &graphql.ArgumentConfig{Type: graphql.String},
+				}),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					repo := p.Source.(gqlRepository)
+					states, _ := p.Args["states"].([]interface{})
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					// oldest first, github's default order for the connection.
+					prList := []gqlPullRequest{}
+					for _, prID := range g.GbStoreInstance.Repos[repo.key()].PrIDs {
+						if pr := g.GbStoreInstance.PullRequests[prID]; pr != nil {
+							prList = append(prList, g.gqlPullRequestOf(repo, pr))
+						}
+					}
+					slices.SortFunc(prList, func(a, b gqlPullRequest) int { return a.Number - b.Number })
+					items := []interface{}{}
+					for _, pr := range prList {
+						if len(states) > 0 && !slices.Contains(states, interface{}(pr.State)) {
+							continue
+						}
+						if headRefName := argString(p.Args, "headRefName"); headRefName != "" && pr.HeadRefName != headRefName {
+							continue
+						}
+						if baseRefName := argString(p.Args, "baseRefName"); baseRefName != "" && pr.BaseRefName != baseRefName {
+							continue
+						}
+						items = append(items, pr)
+					}
+					return connectionOf(items, p.Args)
+				}},
+			"pullRequest": &graphql.Field{Type: pullRequestType,
+				Args: graphql.FieldConfigArgument{"number": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					repo := p.Source.(gqlRepository)
+					number, _ := p.Args["number"].(int)
+					g := serviceOf(p)
+					g.GbStoreInstance.MU.RLock()
+					defer g.GbStoreInstance.MU.RUnlock()
+					pr, err := g.findPR(repo.key(), strconv.Itoa(number))
+					if err != nil {
+						return nil, err
+					}
+					return g.gqlPullRequestOf(repo, pr), nil
+				}},
+		}})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: graphql.Fields{
+		"repository": &graphql.Field{Type: repositoryType,
+			Args: graphql.FieldConfigArgument{
+				"owner": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				"name":  &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				// org is gbserver's own, repos are scoped by org where github has none.
+				"org": &graphql.ArgumentConfig{Type: graphql.String},
+			},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				g := serviceOf(p)
+				g.GbStoreInstance.MU.RLock()
+				defer g.GbStoreInstance.MU.RUnlock()
+				return g.gqlRepositoryNamed(argString(p.Args, "org"), argString(p.Args, "owner"), argString(p.Args, "name"))
+			}},
+		"node": &graphql.Field{Type: nodeInterface,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				g := serviceOf(p)
+				g.GbStoreInstance.MU.RLock()
+				defer g.GbStoreInstance.MU.RUnlock()
+				return g.nodeOf(argString(p.Args, "id"))
+			}},
+		"viewer": &graphql.Field{Type: userType,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				g := serviceOf(p)
+				g.GbStoreInstance.MU.RLock()
+				defer g.GbStoreInstance.MU.RUnlock()
+				return gqlUserOf(g.accountOf(g.Actor)), nil
+			}},
+	}})
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: graphql.Fields{
+		"createRef": &graphql.Field{
+			Type: graphql.NewObject(graphql.ObjectConfig{Name: "CreateRefPayload", Fields: graphql.Fields{
+				"clientMutationId": &graphql.Field{Type: graphql.String},
+				"ref":              &graphql.Field{Type: refType},
+			}}),
+			Args: mutationInput("CreateRefInput", graphql.InputObjectConfigFieldMap{
+				"repositoryId": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"name":         &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+				"oid":          &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				input, _ := p.Args["input"].(map[string]interface{})
+				g := serviceOf(p)
+				repo, err := g.gqlRepositoryByID(argString(input, "repositoryId"))
+				if err != nil {
+					return nil, err
+				}
+				refResp, err := g.CreateBranch(repo.OrgName, repo.OwnerLogin, repo.Name,
+					&CreateBranchRequest{Ref: argString(input, "name"), SHA: argString(input, "oid")})
+				if err != nil {
+					return nil, err
+				}
+				g.GbStoreInstance.MU.RLock()
+				defer g.GbStoreInstance.MU.RUnlock()
+				return map[string]interface{}{"clientMutationId": input["clientMutationId"], "ref": g.gqlRefNamed(repo, refResp.Ref)}, nil
+			}},
+		"createPullRequest": &graphql.Field{
+			Type: pullRequestPayload("CreatePullRequestPayload", pullRequestType),
+			Args: mutationInput("CreatePullRequestInput", graphql.InputObjectConfigFieldMap{
+				"repositoryId": &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"baseRefName":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+				"headRefName":  &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+				"title":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+				"body":         &graphql.InputObjectFieldConfig{Type: graphql.String},
+			}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				input, _ := p.Args["input"].(map[string]interface{})
+				g := serviceOf(p)
+				repo, err := g.gqlRepositoryByID(argString(input, "repositoryId"))
+				if err != nil {
+					return nil, err
+				}
+				title, body := argString(input, "title"), argString(input, "body")
+				prResp, err := g.CreatePR(repo.OrgName, repo.OwnerLogin, repo.Name, &PRRequest{Title: &title,
+					Body: &body, Head: argString(input, "headRefName"), Base: argString(input, "baseRefName")})
+				if err != nil {
+					return nil, err
+				}
+				return g.pullRequestPayload(repo, input, prResp.Number)
+			}},
+		"mergePullRequest": &graphql.Field{
+			Type: pullRequestPayload("MergePullRequestPayload", pullRequestType),
+			Args: mutationInput("MergePullRequestInput", graphql.InputObjectConfigFieldMap{
+				"pullRequestId":   &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.ID)},
+				"commitHeadline":  &graphql.InputObjectFieldConfig{Type: graphql.String},
+				"commitBody":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+				"expectedHeadOid": &graphql.InputObjectFieldConfig{Type: graphql.String},
+				"mergeMethod":     &graphql.InputObjectFieldConfig{Type: mergeMethodEnum},
+			}),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				input, _ := p.Args["input"].(map[string]interface{})
+				g := serviceOf(p)
+				g.GbStoreInstance.MU.RLock()
+				node, err := g.nodeOf(argString(input, "pullRequestId"))
+				g.GbStoreInstance.MU.RUnlock()
+				pr, isPR := node.(gqlPullRequest)
+				if err != nil || !isPR {
+					return nil, ErrNodeNotFound
+				}
+				_, err = g.MergePR(pr.Repository.OrgName, pr.Repository.OwnerLogin, pr.Repository.Name, strconv.Itoa(pr.Number),
+					&MergePRRequest{CommitTitle: argString(input, "commitHeadline"), CommitMessage: argString(input, "commitBody"),
+						SHA: argString(input, "expectedHeadOid"), MergeMethod: argString(input, "mergeMethod")})
+				if err != nil {
+					return nil, err
+				}
+				return g.pullRequestPayload(pr.Repository, input, pr.Number)
+			}},
+	}})
+
+	return graphql.SchemaConfig{Query: queryType, Mutation: mutationType,
+		Types: []graphql.Type{userType, repositoryType, refType, commitType, tagType, pullRequestType}}
+}
+
+// mutationInput is the single input argument of a mutation, every input takes a clientMutationId to echo back.
+func mutationInput(name string, fields graphql.InputObjectConfigFieldMap) graphql.FieldConfigArgument {
+	fields["clientMutationId"] = &graphql.InputObjectFieldConfig{Type: graphql.String}
+	inputType := graphql.NewInputObject(graphql.InputObjectConfig{Name: name, Fields: fields})
+	return graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewNonNull(inputType)}}
+}
+
+func pullRequestPayload(name string, pullRequestType *graphql.Object) *graphql.Object {
+	return graphql.NewObject(graphql.ObjectConfig{Name: name, Fields: graphql.Fields{
+		"clientMutationId": &graphql.Field{Type: graphql.String},
+		"pullRequest":      &graphql.Field{Type: pullRequestType},
+	}})
+}
+
+// gqlRepositoryByID finds a repo the caller can see by its global id.
+func (g *GbService) gqlRepositoryByID(id string) (gqlRepository, error) {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	node, err := g.nodeOf(id)
+	repo, isRepo := node.(gqlRepository)
+	if err != nil || !isRepo {
+		return gqlRepository{}, ErrNodeNotFound
+	}
+	return repo, nil
+}
+
+func (g *GbService) pullRequestPayload(repo gqlRepository, input map[string]interface{}, number int) (interface{}, error) {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(repo.key(), strconv.Itoa(number))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"clientMutationId": input["clientMutationId"], "pullRequest": g.gqlPullRequestOf(repo, pr)}, nil
+}
+
+// post /graphql
+// Errors of single fields come back next to the data, like github answers them.
+func (g *GbService) GraphQL(ctx context.Context, gqlReq *GraphQLRequest) *graphql.Result {
+	gqlSchema, err := schema()
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	return graphql.Do(graphql.Params{Schema: gqlSchema, RequestString: gqlReq.Query, VariableValues: gqlReq.Variables,
+		OperationName: gqlReq.OperationName, Context: context.WithValue(ctx, graphqlServiceKey{}, g)})
+}
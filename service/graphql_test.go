@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runGraphQL answers the query and hands back data and errors the way they go over the wire.
+func runGraphQL(t *testing.T, gbService *GbService, query string, variables map[string]interface{}) (map[string]interface{}, []string) {
+	t.Helper()
+	result := gbService.GraphQL(context.Background(), &GraphQLRequest{Query: query, Variables: variables})
+	body, err := json.Marshal(result)
+	assert.NoError(t, err)
+	var response struct {
+		Data   map[string]interface{}
+		Errors []struct{ Message string }
+	}
+	assert.NoError(t, json.Unmarshal(body, &response))
+	messages := []string{}
+	for _, gqlErr := range response.Errors {
+		messages = append(messages, gqlErr.Message)
+	}
+	return response.Data, messages
+}
+
+func TestGraphQLQueries(t *testing.T) {
+	gbService := newTestService(t)
+	member := gbService.WithActor("gbuser")
+
+	data, errs := runGraphQL(t, member, `{
+		repository(owner: "gbuser", name: "gbrepo") {
+			id nameWithOwner owner { login }
+			defaultBranchRef { name prefix target { oid } }
+			ref(qualifiedName: "gbbranch") { name }
+			refs(refPrefix: "refs/heads/", first: 1) { totalCount pageInfo { hasNextPage endCursor } nodes { name } }
+			pullRequests(states: [OPEN], first: 10) { totalCount nodes { id number state headRefName author { login } mergeable } }
+			pullRequest(number: 1) { title headRef { target { oid } } }
+		}
+	}`, nil)
+	assert.Empty(t, errs)
+	repo := data["repository"].(map[string]interface{})
+	assert.Equal(t, "MDEwOlJlcG9zaXRvcnkxMjk2MjY5", repo["id"])
+	assert.Equal(t, "gbuser/gbrepo", repo["nameWithOwner"])
+	assert.Equal(t, "gbuser", repo["owner"].(map[string]interface{})["login"])
+	defaultRef := repo["defaultBranchRef"].(map[string]interface{})
+	assert.Equal(t, "master", defaultRef["name"])
+	assert.Equal(t, "refs/heads/", defaultRef["prefix"])
+	assert.Equal(t, "aa218f56b14c9653891f9e74264a383fa43fefbd", defaultRef["target"].(map[string]interface{})["oid"])
+	assert.Equal(t, "gbbranch", repo["ref"].(map[string]interface{})["name"])
+
+	refs := repo["refs"].(map[string]interface{})
+	assert.Equal(t, 2.0, refs["totalCount"])
+	assert.Equal(t, "gbbranch", refs["nodes"].([]interface{})[0].(map[string]interface{})["name"])
+	pageInfo := refs["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, pageInfo["hasNextPage"])
+
+	// the next page starts after the cursor.
+	data, errs = runGraphQL(t, member, `query($after: String) {
+		repository(owner: "gbuser", name: "gbrepo") { refs(refPrefix: "refs/heads/", first: 1, after: $after) { pageInfo { hasNextPage hasPreviousPage } nodes { name } } }
+	}`, map[string]interface{}{"after": pageInfo["endCursor"]})
+	assert.Empty(t, errs)
+	refs = data["repository"].(map[string]interface{})["refs"].(map[string]interface{})
+	assert.Equal(t, "master", refs["nodes"].([]interface{})[0].(map[string]interface{})["name"])
+	assert.Equal(t, map[string]interface{}{"hasNextPage": false, "hasPreviousPage": true}, refs["pageInfo"])
+
+	pulls := repo["pullRequests"].(map[string]interface{})
+	assert.Equal(t, 1.0, pulls["totalCount"])
+	pr := pulls["nodes"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "MDExOlB1bGxSZXF1ZXN0MQ==", pr["id"])
+	assert.Equal(t, "OPEN", pr["state"])
+	assert.Equal(t, "gbbranch", pr["headRefName"])
+	assert.Equal(t, "gbuser", pr["author"].(map[string]interface{})["login"])
+	assert.Equal(t, "MERGEABLE", pr["mergeable"])
+	assert.Equal(t, "bc4d1d9a7d0a4d29e1d8b3fd3a383fa43fefbd9e",
+		repo["pullRequest"].(map[string]interface{})["headRef"].(map[string]interface{})["target"].(map[string]interface{})["oid"])
+
+	// every node id stored comes back through node.
+	data, errs = runGraphQL(t, member, `{
+		user: node(id: "MDQ6VXNlcjE=") { ... on User { login } }
+		repo: node(id: "MDEwOlJlcG9zaXRvcnkxMjk2MjY5") { ... on Repository { name } }
+		ref: node(id: "MDM6UmVmcmVmcy9oZWFkcy9mZWF0dXJlQQ==") { ... on Ref { name } }
+		pr: node(id: "MDExOlB1bGxSZXF1ZXN0MQ==") { __typename ... on PullRequest { number } }
+	}`, nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, "gbuser", data["user"].(map[string]interface{})["login"])
+	assert.Equal(t, "gbrepo", data["repo"].(map[string]interface{})["name"])
+	assert.Equal(t, "master", data["ref"].(map[string]interface{})["name"])
+	assert.Equal(t, map[string]interface{}{"__typename": "PullRequest", "number": 1.0}, data["pr"])
+
+	_, errs = runGraphQL(t, member, `{ node(id: "nosuchid") { id } }`, nil)
+	assert.Equal(t, []string{ErrNodeNotFound.Error()}, errs)
+	_, errs = runGraphQL(t, member, `{ repository(owner: "gbuser", name: "gbrepo") { refs(refPrefix: "refs/") { totalCount } } }`, nil)
+	assert.Equal(t, []string{ErrPaginationRequired.Error()}, errs)
+	_, errs = runGraphQL(t, member, `{ repository(owner: "gbuser", name: "gbrepo") { refs(refPrefix: "refs/", first: 101) { totalCount } } }`, nil)
+	assert.Equal(t, []string{ErrInvalidPageSize.Error()}, errs)
+	_, errs = runGraphQL(t, member, `{ repository(owner: "gbuser", name: "gbrepo") { refs(refPrefix: "refs/", first: 1, after: "bogus") { totalCount } } }`, nil)
+	assert.Equal(t, []string{ErrInvalidCursor.Error()}, errs)
+
+	// repos of orgs the caller is not a member of do not exist for it.
+	_, err := gbService.CreateUser(&CreateUserRequest{Login: "outsider"})
+	assert.NoError(t, err)
+	_, errs = runGraphQL(t, gbService.WithActor("outsider"), `{ repository(owner: "gbuser", name: "gbrepo") { id } }`, nil)
+	assert.Equal(t, []string{ErrRepoNotFound.Error()}, errs)
+	_, errs = runGraphQL(t, gbService.WithActor("outsider"), `{ node(id: "MDExOlB1bGxSZXF1ZXN0MQ==") { id } }`, nil)
+	assert.Equal(t, []string{ErrNodeNotFound.Error()}, errs)
+}
+
+func TestGraphQLMutations(t *testing.T) {
+	gbService := newTestService(t)
+	admin := gbService.WithActor("gbadmin")
+	repoID := "MDEwOlJlcG9zaXRvcnkxMjk2MjY5"
+
+	data, errs := runGraphQL(t, admin, `mutation($input: CreateRefInput!) {
+		createRef(input: $input) { clientMutationId ref { id name prefix target { oid } } }
+	}`, map[string]interface{}{"input": map[string]interface{}{"repositoryId": repoID, "name": "refs/heads/feature",
+		"oid": "aa218f56b14c9653891f9e74264a383fa43fefbd", "clientMutationId": "abc"}})
+	assert.Empty(t, errs)
+	createRef := data["createRef"].(map[string]interface{})
+	assert.Equal(t, "abc", createRef["clientMutationId"])
+	assert.Equal(t, "feature", createRef["ref"].(map[string]interface{})["name"])
+	assert.NotEmpty(t, createRef["ref"].(map[string]interface{})["id"])
+	_, errs = runGraphQL(t, admin, `mutation { createRef(input: {repositoryId: "`+repoID+`", name: "refs/heads/feature", oid: "aa218f56b14c9653891f9e74264a383fa43fefbd"}) { ref { id } } }`, nil)
+	assert.Equal(t, []string{ErrBranchesAlreadyExists.Error()}, errs)
+	_, errs = runGraphQL(t, admin, `mutation { createRef(input: {repositoryId: "nosuchrepo", name: "refs/heads/other", oid: "aa218f56b14c9653891f9e74264a383fa43fefbd"}) { ref { id } } }`, nil)
+	assert.Equal(t, []string{ErrNodeNotFound.Error()}, errs)
+
+	data, errs = runGraphQL(t, admin, `mutation {
+		createPullRequest(input: {repositoryId: "`+repoID+`", baseRefName: "master", headRefName: "feature", title: "Feature", body: "Adds it"}) {
+			pullRequest { id number state headRefName author { login } }
+		}
+	}`, nil)
+	assert.Empty(t, errs)
+	pr := data["createPullRequest"].(map[string]interface{})["pullRequest"].(map[string]interface{})
+	assert.Equal(t, 2.0, pr["number"])
+	assert.Equal(t, "OPEN", pr["state"])
+	assert.Equal(t, "feature", pr["headRefName"])
+	assert.Equal(t, "gbadmin", pr["author"].(map[string]interface{})["login"])
+
+	data, errs = runGraphQL(t, admin, `mutation($id: ID!) {
+		mergePullRequest(input: {pullRequestId: $id, mergeMethod: SQUASH}) { pullRequest { state merged mergeCommit { oid } mergedBy { login } } }
+	}`, map[string]interface{}{"id": pr["id"]})
+	assert.Empty(t, errs)
+	merged := data["mergePullRequest"].(map[string]interface{})["pullRequest"].(map[string]interface{})
+	assert.Equal(t, "MERGED", merged["state"])
+	assert.Equal(t, true, merged["merged"])
+	assert.Equal(t, "gbadmin", merged["mergedBy"].(map[string]interface{})["login"])
+	master, _ := gbService.GetRef("gborg", "gbuser", "gbrepo", "heads/master")
+	assert.Equal(t, master.Object.SHA, merged["mergeCommit"].(map[string]interface{})["oid"])
+
+	_, errs = runGraphQL(t, admin, `mutation($id: ID!) { mergePullRequest(input: {pullRequestId: $id}) { pullRequest { id } } }`,
+		map[string]interface{}{"id": pr["id"]})
+	assert.Equal(t, []string{ErrPRAlreadyMerged.Error()}, errs)
+
+	data, errs = runGraphQL(t, admin, `{ repository(owner: "gbuser", name: "gbrepo") {
+		merged: pullRequests(states: [MERGED], first: 10) { nodes { number } }
+		last: pullRequests(last: 1) { nodes { number } pageInfo { hasPreviousPage } }
+	} }`, nil)
+	assert.Empty(t, errs)
+	repo := data["repository"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"number": 2.0}}, repo["merged"].(map[string]interface{})["nodes"])
+	assert.Equal(t, map[string]interface{}{"nodes": []interface{}{map[string]interface{}{"number": 2.0}},
+		"pageInfo": map[string]interface{}{"hasPreviousPage": true}}, repo["last"])
+}
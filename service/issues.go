@@ -0,0 +1,847 @@
+package service
+
+import (
+	"gbserver/models"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAssignees is the most assignees github allows on one issue.
+const maxAssignees = 10
+
+// defaultLabelColor is used for labels created on the fly by adding them to an issue.
+const defaultLabelColor = "ededed"
+
+var labelColorRegexp = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+type LabelResponse struct {
+	ID          int    `json:"id"`
+	NodeID      string `json:"node_id"`
+	URL         string `json:"url"`
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+	Default     bool   `json:"default"`
+}
+
+type LabelRequest struct {
+	Name        string  `json:"name"`
+	NewName     string  `json:"new_name"`
+	Color       string  `json:"color"`
+	Description *string `json:"description"`
+	//'{"name":"bug","description":"Something isn't working","color":"f29513"}'
+}
+
+type IssueResponse struct {
+	URL         string          `json:"url"`
+	ID          string          `json:"id"`
+	NodeID      string          `json:"node_id"`
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	Body        string          `json:"body"`
+	State       string          `json:"state"`
+	StateReason string          `json:"state_reason,omitempty"`
+	User        OwnerInfo       `json:"user"`
+	Labels      []LabelResponse `json:"labels"`
+	Assignee    *OwnerInfo      `json:"assignee"`
+	Assignees   []OwnerInfo     `json:"assignees"`
+	Comments    int             `json:"comments"`
+	Locked      bool            `json:"locked"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	ClosedAt    string          `json:"closed_at,omitempty"`
+	ClosedBy    *OwnerInfo      `json:"closed_by,omitempty"`
+}
+
+// IssueRequest creates or updates an issue. Empty strings and nil lists leave a field unchanged on update,
+// an empty list clears it.
+type IssueRequest struct {
+	Title       string   `json:"title"`
+	Body        string   `json:"body"`
+	State       string   `json:"state"`
+	StateReason string   `json:"state_reason"`
+	Assignee    string   `json:"assignee"`
+	Assignees   []string `json:"assignees"`
+	Labels      []string `json:"labels"`
+	//'{"title":"Found a bug","body":"I'm having a problem with this.","assignees":["gbuser"],"labels":["bug"]}'
+}
+
+type IssueCommentRequest struct {
+	Body string `json:"body"`
+}
+
+type IssueCommentResponse struct {
+	ID        int       `json:"id"`
+	NodeID    string    `json:"node_id"`
+	URL       string    `json:"url"`
+	IssueURL  string    `json:"issue_url"`
+	Body      string    `json:"body"`
+	User      OwnerInfo `json:"user"`
+	CreatedAt string    `json:"created_at"`
+	UpdatedAt string    `json:"updated_at"`
+}
+
+// IssueFilter narrows ListIssues the way the query parameters of github's list endpoint do.
+type IssueFilter struct {
+	// State is open, closed or all, empty means open.
+	State string
+	// Labels must all be on the issue.
+	Labels []string
+	// Assignee is a login, "none" for unassigned or "*" for assigned to anyone.
+	Assignee string
+	Creator  string
+}
+
+func ownerInfoOf(user *models.User) OwnerInfo {
+	return OwnerInfo{Login: user.LoginName, ID: user.ID, NodeID: user.NodeID, UserType: user.UserType}
+}
+
+func labelKey(repoKey, name string) string {
+	return repoKey + "/" + strings.ToLower(name)
+}
+
+func repoAPIURL(owner, repoName string) string {
+	return "https://api.gbserver.com/repos/" + owner + "/" + repoName
+}
+
+func labelResponse(owner, repoName string, label *models.Label) LabelResponse {
+	return LabelResponse{ID: label.ID, NodeID: label.NodeID, URL: repoAPIURL(owner, repoName) + "/labels/" + label.Name,
+		Name: label.Name, Color: label.Color, Description: label.Description}
+}
+
+// findIssue looks an issue up by its number. Caller must hold the store lock.
+func (g *GbService) findIssue(repoKey, issueNumber string) (*models.Issue, error) {
+	issue, exists := g.GbStoreInstance.Issues[hasher(repoKey+"/"+issueNumber)]
+	if !exists || issue.RepoKey != repoKey {
+		return nil, ErrIssueNotFound
+	}
+	return issue, nil
+}
+
+// findIssueComment looks a comment up and checks it belongs to an issue of the repo. Caller must hold the store lock.
+func (g *GbService) findIssueComment(repoKey string, commentID int) (*models.IssueComment, *models.Issue, error) {
+	comment, exists := g.GbStoreInstance.IssueComments[strconv.Itoa(commentID)]
+	if !exists {
+		return nil, nil, ErrIssueCommentNotFound
+	}
+	issue, exists := g.GbStoreInstance.Issues[comment.IssueID]
+	if !exists || issue.RepoKey != repoKey {
+		return nil, nil, ErrIssueCommentNotFound
+	}
+	return comment, issue, nil
+}
+
+// buildIssueResponse renders a stored issue. Caller must hold the store lock.
+func (g *GbService) buildIssueResponse(orgName, owner, repoName string, issue *models.Issue) IssueResponse {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	issueResp := IssueResponse{URL: issue.URL, ID: issue.ID, NodeID: issue.NodeID, Number: issue.Number,
+		Title: issue.Title, Body: issue.Body, State: issue.State, StateReason: issue.StateReason,
+		Labels: []LabelResponse{}, Assignees: []OwnerInfo{}, Comments: len(issue.CommentIDs), Locked: issue.Locked,
+		CreatedAt: issue.CreatedAt, UpdatedAt: issue.UpdatedAt, ClosedAt: issue.ClosedAt}
+	if author := g.userByID(orgName, issue.AuthorID); author != nil {
+		issueResp.User = ownerInfoOf(author)
+	}
+	for _, name := range issue.Labels {
+		if label, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]; exists {
+			issueResp.Labels = append(issueResp.Labels, labelResponse(owner, repoName, label))
+		}
+	}
+	for _, login := range issue.Assignees {
+		if user, exists := g.GbStoreInstance.Users[orgName+"/"+login]; exists {
+			issueResp.Assignees = append(issueResp.Assignees, ownerInfoOf(user))
+		}
+	}
+	if len(issueResp.Assignees) > 0 {
+		issueResp.Assignee = &issueResp.Assignees[0]
+	}
+	if closedBy := g.userByID(orgName, issue.ClosedByID); issue.State == "closed" && closedBy != nil {
+		closedByInfo := ownerInfoOf(closedBy)
+		issueResp.ClosedBy = &closedByInfo
+	}
+	return issueResp
+}
+
+// ensureLabels returns the stored names of the given labels, creating the ones the repo does not have yet
+// like github does when labels are added to an issue. Caller must hold the store lock.
+func (g *GbService) ensureLabels(repoKey string, names []string) ([]string, error) {
+	labelNames := []string{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, ErrInvalidLabel
+		}
+		label, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]
+		if !exists {
+			g.GbStoreInstance.LabelsCount++
+			label = &models.Label{ID: g.GbStoreInstance.LabelsCount, NodeID: generateCustomID("NODEID"),
+				RepoKey: repoKey, Name: name, Color: defaultLabelColor}
+			g.GbStoreInstance.Labels[labelKey(repoKey, name)] = label
+		}
+		if !slices.Contains(labelNames, label.Name) {
+			labelNames = append(labelNames, label.Name)
+		}
+	}
+	return labelNames, nil
+}
+
+// validateAssignees checks every login is a member of the org. Caller must hold the store lock.
+func (g *GbService) validateAssignees(orgName string, logins []string) ([]string, error) {
+	assignees := []string{}
+	for _, login := range logins {
+		if !slices.Contains(g.GbStoreInstance.Orgs[orgName].Users, login) {
+			return nil, ErrInvalidAssignee
+		}
+		if !slices.Contains(assignees, login) {
+			assignees = append(assignees, login)
+		}
+	}
+	if len(assignees) > maxAssignees {
+		return nil, ErrTooManyAssignees
+	}
+	return assignees, nil
+}
+
+// deleteRepoIssues drops the issues, comments and labels of a deleted repo. Caller must hold the store lock.
+func (g *GbService) deleteRepoIssues(repoKey string) {
+	for issueID, issue := range g.GbStoreInstance.Issues {
+		if issue.RepoKey != repoKey {
+			continue
+		}
+		for _, commentID := range issue.CommentIDs {
+			delete(g.GbStoreInstance.IssueComments, strconv.Itoa(commentID))
+		}
+		delete(g.GbStoreInstance.Issues, issueID)
+	}
+	for key, label := range g.GbStoreInstance.Labels {
+		if label.RepoKey == repoKey {
+			delete(g.GbStoreInstance.Labels, key)
+		}
+	}
+}
+
+// get /repos/{org}/{owner}/{repo}/issues
+func (g *GbService) ListIssues(orgName, owner, repoName string, filter IssueFilter) ([]IssueResponse, error) {
+	issueList := []IssueResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return issueList, err
+	}
+	state := filter.State
+	if state == "" {
+		state = "open"
+	}
+	if state != "open" && state != "closed" && state != "all" {
+		return issueList, ErrInvalidIssueState
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	for _, issueID := range g.GbStoreInstance.Repos[repoKey].IssueIDs {
+		issue := g.GbStoreInstance.Issues[issueID]
+		if issue == nil || (state != "all" && issue.State != state) {
+			continue
+		}
+		if !issueHasLabels(issue, filter.Labels) {
+			continue
+		}
+		switch filter.Assignee {
+		case "":
+		case "none":
+			if len(issue.Assignees) > 0 {
+				continue
+			}
+		case "*":
+			if len(issue.Assignees) == 0 {
+				continue
+			}
+		default:
+			if !slices.Contains(issue.Assignees, filter.Assignee) {
+				continue
+			}
+		}
+		if filter.Creator != "" {
+			if author := g.userByID(orgName, issue.AuthorID); author == nil || author.LoginName != filter.Creator {
+				continue
+			}
+		}
+		issueList = append(issueList, g.buildIssueResponse(orgName, owner, repoName, issue))
+	}
+	// newest first, same as github's default sort=created&direction=desc.
+	slices.SortFunc(issueList, func(a, b IssueResponse) int { return b.Number - a.Number })
+	return issueList, nil
+}
+
+func issueHasLabels(issue *models.Issue, labels []string) bool {
+	for _, wanted := range labels {
+		if !slices.ContainsFunc(issue.Labels, func(name string) bool { return strings.EqualFold(name, wanted) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// post /repos/{org}/{owner}/{repo}/issues
+func (g *GbService) CreateIssue(orgName, owner, repoName string, issueReq *IssueRequest) (IssueResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	if strings.TrimSpace(issueReq.Title) == "" {
+		return IssueResponse{}, ErrIssueTitleRequired
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	logins := issueReq.Assignees
+	if issueReq.Assignee != "" {
+		logins = append([]string{issueReq.Assignee}, logins...)
+	}
+	assignees, err := g.validateAssignees(orgName, logins)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	labels, err := g.ensureLabels(repoKey, issueReq.Labels)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+
+	repo := g.GbStoreInstance.Repos[repoKey]
+	repo.TotalPRs++
+	number := strconv.Itoa(repo.TotalPRs)
+	now := time.Now().UTC().Format(time.RFC3339)
+	issue := &models.Issue{ID: hasher(repoKey + "/" + number), NodeID: generateCustomID("NODEID"),
+		URL: repoAPIURL(owner, repoName) + "/issues/" + number, Number: repo.TotalPRs, RepoKey: repoKey,
+		Title: issueReq.Title, Body: issueReq.Body, State: "open", AuthorID: g.actingUser(orgName, owner).ID,
+		Labels: labels, Assignees: assignees, CommentIDs: []int{}, CreatedAt: now, UpdatedAt: now}
+	g.GbStoreInstance.Issues[issue.ID] = issue
+	repo.IssueIDs = append(repo.IssueIDs, issue.ID)
+
+	issueResp := g.buildIssueResponse(orgName, owner, repoName, issue)
+	g.emitIssue("opened", orgName, owner, repoName, issueResp, nil)
+	return issueResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/{issue_number}
+func (g *GbService) GetIssue(orgName, owner, repoName, issueNumber string) (IssueResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	issue, err := g.findIssue(orgName+"/"+owner+"/"+repoName, issueNumber)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	return g.buildIssueResponse(orgName, owner, repoName, issue), nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/issues/{issue_number}
+func (g *GbService) UpdateIssue(orgName, owner, repoName, issueNumber string, issueReq *IssueRequest) (IssueResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	if issueReq.State != "" && issueReq.State != "open" && issueReq.State != "closed" {
+		return IssueResponse{}, ErrInvalidIssueState
+	}
+	if !slices.Contains([]string{"", "completed", "not_planned", "reopened"}, issueReq.StateReason) {
+		return IssueResponse{}, ErrInvalidStateReason
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	issue, err := g.findIssue(repoKey, issueNumber)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	var assignees, labels []string
+	if issueReq.Assignees != nil || issueReq.Assignee != "" {
+		logins := issueReq.Assignees
+		if issueReq.Assignee != "" {
+			logins = append([]string{issueReq.Assignee}, logins...)
+		}
+		assignees, err = g.validateAssignees(orgName, logins)
+		if err != nil {
+			return IssueResponse{}, err
+		}
+	}
+	if issueReq.Labels != nil {
+		labels, err = g.ensureLabels(repoKey, issueReq.Labels)
+		if err != nil {
+			return IssueResponse{}, err
+		}
+	}
+
+	changes := map[string]any{}
+	if issueReq.Title != "" && issueReq.Title != issue.Title {
+		changes["title"] = map[string]string{"from": issue.Title}
+		issue.Title = issueReq.Title
+	}
+	if issueReq.Body != "" && issueReq.Body != issue.Body {
+		changes["body"] = map[string]string{"from": issue.Body}
+		issue.Body = issueReq.Body
+	}
+	oldAssignees := issue.Assignees
+	if assignees != nil {
+		issue.Assignees = assignees
+	}
+	oldLabels := issue.Labels
+	if labels != nil {
+		issue.Labels = labels
+	}
+	oldState := issue.State
+	now := time.Now().UTC().Format(time.RFC3339)
+	switch {
+	case oldState == "open" && issueReq.State == "closed":
+		issue.State = "closed"
+		issue.StateReason = "completed"
+		if issueReq.StateReason == "not_planned" {
+			issue.StateReason = "not_planned"
+		}
+		issue.ClosedAt = now
+		issue.ClosedByID = g.actingUser(orgName, owner).ID
+	case oldState == "closed" && issueReq.State == "open":
+		issue.State = "open"
+		issue.StateReason = "reopened"
+		issue.ClosedAt = ""
+		issue.ClosedByID = 0
+	case oldState == "closed" && issueReq.StateReason != "" && issueReq.StateReason != "reopened":
+		issue.StateReason = issueReq.StateReason
+	}
+	issue.UpdatedAt = now
+
+	issueResp := g.buildIssueResponse(orgName, owner, repoName, issue)
+	if len(changes) > 0 {
+		g.emitIssue("edited", orgName, owner, repoName, issueResp, changes)
+	}
+	g.emitLabelChanges(orgName, owner, repoName, issueResp, oldLabels, issue.Labels)
+	g.emitAssigneeChanges(orgName, owner, repoName, issueResp, oldAssignees, issue.Assignees)
+	if oldState != issue.State {
+		action := "closed"
+		if issue.State == "open" {
+			action = "reopened"
+		}
+		g.emitIssue(action, orgName, owner, repoName, issueResp, nil)
+	}
+	return issueResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/{issue_number}/comments
+func (g *GbService) ListIssueComments(orgName, owner, repoName, issueNumber string) ([]IssueCommentResponse, error) {
+	commentList := []IssueCommentResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return commentList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	issue, err := g.findIssue(orgName+"/"+owner+"/"+repoName, issueNumber)
+	if err != nil {
+		return commentList, err
+	}
+	for _, commentID := range issue.CommentIDs {
+		if comment, exists := g.GbStoreInstance.IssueComments[strconv.Itoa(commentID)]; exists {
+			commentList = append(commentList, g.buildIssueCommentResponse(orgName, owner, repoName, issue, comment))
+		}
+	}
+	return commentList, nil
+}
+
+// buildIssueCommentResponse renders a stored comment. Caller must hold the store lock.
+func (g *GbService) buildIssueCommentResponse(orgName, owner, repoName string, issue *models.Issue, comment *models.IssueComment) IssueCommentResponse {
+	commentResp := IssueCommentResponse{ID: comment.ID, NodeID: comment.NodeID,
+		URL: repoAPIURL(owner, repoName) + "/issues/comments/" + strconv.Itoa(comment.ID), IssueURL: issue.URL,
+		Body: comment.Body, CreatedAt: comment.CreatedAt, UpdatedAt: comment.UpdatedAt}
+	if author := g.userByID(orgName, comment.AuthorID); author != nil {
+		commentResp.User = ownerInfoOf(author)
+	}
+	return commentResp
+}
+
+// post /repos/{org}/{owner}/{repo}/issues/{issue_number}/comments
+func (g *GbService) CreateIssueComment(orgName, owner, repoName, issueNumber string, commentReq *IssueCommentRequest) (IssueCommentResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueCommentResponse{}, err
+	}
+	if strings.TrimSpace(commentReq.Body) == "" {
+		return IssueCommentResponse{}, ErrCommentBodyRequired
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	issue, err := g.findIssue(orgName+"/"+owner+"/"+repoName, issueNumber)
+	if err != nil {
+		return IssueCommentResponse{}, err
+	}
+	if issue.Locked {
+		return IssueCommentResponse{}, ErrIssueLocked
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	g.GbStoreInstance.CommentsCount++
+	comment := &models.IssueComment{ID: g.GbStoreInstance.CommentsCount, NodeID: generateCustomID("NODEID"),
+		IssueID: issue.ID, AuthorID: g.actingUser(orgName, owner).ID, Body: commentReq.Body, CreatedAt: now, UpdatedAt: now}
+	g.GbStoreInstance.IssueComments[strconv.Itoa(comment.ID)] = comment
+	issue.CommentIDs = append(issue.CommentIDs, comment.ID)
+	issue.UpdatedAt = now
+
+	commentResp := g.buildIssueCommentResponse(orgName, owner, repoName, issue, comment)
+	g.emitIssueComment("created", orgName, owner, repoName, issue, commentResp, nil)
+	return commentResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/comments/{comment_id}
+func (g *GbService) GetIssueComment(orgName, owner, repoName string, commentID int) (IssueCommentResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueCommentResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	comment, issue, err := g.findIssueComment(orgName+"/"+owner+"/"+repoName, commentID)
+	if err != nil {
+		return IssueCommentResponse{}, err
+	}
+	return g.buildIssueCommentResponse(orgName, owner, repoName, issue, comment), nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/issues/comments/{comment_id}
+func (g *GbService) UpdateIssueComment(orgName, owner, repoName string, commentID int, commentReq *IssueCommentRequest) (IssueCommentResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueCommentResponse{}, err
+	}
+	if strings.TrimSpace(commentReq.Body) == "" {
+		return IssueCommentResponse{}, ErrCommentBodyRequired
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	comment, issue, err := g.findIssueComment(orgName+"/"+owner+"/"+repoName, commentID)
+	if err != nil {
+		return IssueCommentResponse{}, err
+	}
+	changes := map[string]any{"body": map[string]string{"from": comment.Body}}
+	comment.Body = commentReq.Body
+	comment.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	commentResp := g.buildIssueCommentResponse(orgName, owner, repoName, issue, comment)
+	g.emitIssueComment("edited", orgName, owner, repoName, issue, commentResp, changes)
+	return commentResp, g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/issues/comments/{comment_id}
+func (g *GbService) DeleteIssueComment(orgName, owner, repoName string, commentID int) (bool, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return false, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	comment, issue, err := g.findIssueComment(orgName+"/"+owner+"/"+repoName, commentID)
+	if err != nil {
+		return false, err
+	}
+	commentResp := g.buildIssueCommentResponse(orgName, owner, repoName, issue, comment)
+	delete(g.GbStoreInstance.IssueComments, strconv.Itoa(commentID))
+	issue.CommentIDs = slices.DeleteFunc(issue.CommentIDs, func(id int) bool { return id == commentID })
+	g.emitIssueComment("deleted", orgName, owner, repoName, issue, commentResp, nil)
+	return true, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/labels
+func (g *GbService) ListLabels(orgName, owner, repoName string) ([]LabelResponse, error) {
+	labelList := []LabelResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return labelList, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	for _, label := range g.GbStoreInstance.Labels {
+		if label.RepoKey == repoKey {
+			labelList = append(labelList, labelResponse(owner, repoName, label))
+		}
+	}
+	slices.SortFunc(labelList, func(a, b LabelResponse) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) })
+	return labelList, nil
+}
+
+func normalizeLabelColor(color string) (string, error) {
+	color = strings.TrimPrefix(color, "#")
+	if color == "" {
+		return defaultLabelColor, nil
+	}
+	if !labelColorRegexp.MatchString(color) {
+		return "", ErrInvalidLabel
+	}
+	return strings.ToLower(color), nil
+}
+
+// post /repos/{org}/{owner}/{repo}/labels
+func (g *GbService) CreateLabel(orgName, owner, repoName string, labelReq *LabelRequest) (LabelResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return LabelResponse{}, err
+	}
+	name := strings.TrimSpace(labelReq.Name)
+	if name == "" {
+		return LabelResponse{}, ErrInvalidLabel
+	}
+	color, err := normalizeLabelColor(labelReq.Color)
+	if err != nil {
+		return LabelResponse{}, err
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	if _, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]; exists {
+		return LabelResponse{}, ErrLabelAlreadyExists
+	}
+	g.GbStoreInstance.LabelsCount++
+	label := &models.Label{ID: g.GbStoreInstance.LabelsCount, NodeID: generateCustomID("NODEID"), RepoKey: repoKey,
+		Name: name, Color: color}
+	if labelReq.Description != nil {
+		label.Description = *labelReq.Description
+	}
+	g.GbStoreInstance.Labels[labelKey(repoKey, name)] = label
+
+	labelResp := labelResponse(owner, repoName, label)
+	g.emitLabel("created", orgName, owner, repoName, labelResp, nil)
+	return labelResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/labels/{name}
+func (g *GbService) GetLabel(orgName, owner, repoName, name string) (LabelResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return LabelResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	label, exists := g.GbStoreInstance.Labels[labelKey(orgName+"/"+owner+"/"+repoName, name)]
+	if !exists {
+		return LabelResponse{}, ErrLabelNotFound
+	}
+	return labelResponse(owner, repoName, label), nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/labels/{name}, a new_name renames the label on every issue.
+func (g *GbService) UpdateLabel(orgName, owner, repoName, name string, labelReq *LabelRequest) (LabelResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return LabelResponse{}, err
+	}
+	var color string
+	if labelReq.Color != "" {
+		color, err = normalizeLabelColor(labelReq.Color)
+		if err != nil {
+			return LabelResponse{}, err
+		}
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	label, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]
+	if !exists {
+		return LabelResponse{}, ErrLabelNotFound
+	}
+	changes := map[string]any{}
+	newName := strings.TrimSpace(labelReq.NewName)
+	if newName != "" && newName != label.Name {
+		if other, exists := g.GbStoreInstance.Labels[labelKey(repoKey, newName)]; exists && other != label {
+			return LabelResponse{}, ErrLabelAlreadyExists
+		}
+		changes["name"] = map[string]string{"from": label.Name}
+		for _, issueID := range g.GbStoreInstance.Repos[repoKey].IssueIDs {
+			if issue := g.GbStoreInstance.Issues[issueID]; issue != nil {
+				if i := slices.Index(issue.Labels, label.Name); i >= 0 {
+					issue.Labels[i] = newName
+				}
+			}
+		}
+		delete(g.GbStoreInstance.Labels, labelKey(repoKey, label.Name))
+		label.Name = newName
+		g.GbStoreInstance.Labels[labelKey(repoKey, newName)] = label
+	}
+	if color != "" && color != label.Color {
+		changes["color"] = map[string]string{"from": label.Color}
+		label.Color = color
+	}
+	if labelReq.Description != nil && *labelReq.Description != label.Description {
+		changes["description"] = map[string]string{"from": label.Description}
+		label.Description = *labelReq.Description
+	}
+
+	labelResp := labelResponse(owner, repoName, label)
+	if len(changes) > 0 {
+		g.emitLabel("edited", orgName, owner, repoName, labelResp, changes)
+	}
+	return labelResp, g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/labels/{name}, the label is taken off every issue.
+func (g *GbService) DeleteLabel(orgName, owner, repoName, name string) (bool, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return false, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	label, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]
+	if !exists {
+		return false, ErrLabelNotFound
+	}
+	for _, issueID := range g.GbStoreInstance.Repos[repoKey].IssueIDs {
+		if issue := g.GbStoreInstance.Issues[issueID]; issue != nil {
+			issue.Labels = removeElementByValue(issue.Labels, label.Name)
+		}
+	}
+	delete(g.GbStoreInstance.Labels, labelKey(repoKey, name))
+	g.emitLabel("deleted", orgName, owner, repoName, labelResponse(owner, repoName, label), nil)
+	return true, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels
+func (g *GbService) ListIssueLabels(orgName, owner, repoName, issueNumber string) ([]LabelResponse, error) {
+	issueResp, err := g.GetIssue(orgName, owner, repoName, issueNumber)
+	if err != nil {
+		return []LabelResponse{}, err
+	}
+	return issueResp.Labels, nil
+}
+
+// AddIssueLabels serves post /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels, with replace it serves
+// put (an empty list clears the labels).
+func (g *GbService) AddIssueLabels(orgName, owner, repoName, issueNumber string, names []string, replace bool) ([]LabelResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return []LabelResponse{}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	issue, err := g.findIssue(repoKey, issueNumber)
+	if err != nil {
+		return []LabelResponse{}, err
+	}
+	labels, err := g.ensureLabels(repoKey, names)
+	if err != nil {
+		return []LabelResponse{}, err
+	}
+	oldLabels := issue.Labels
+	if replace {
+		issue.Labels = labels
+	} else {
+		for _, name := range labels {
+			if !slices.Contains(issue.Labels, name) {
+				issue.Labels = append(issue.Labels, name)
+			}
+		}
+	}
+	issue.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	issueResp := g.buildIssueResponse(orgName, owner, repoName, issue)
+	g.emitLabelChanges(orgName, owner, repoName, issueResp, oldLabels, issue.Labels)
+	return issueResp.Labels, g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/issues/{issue_number}/labels/{name}
+func (g *GbService) RemoveIssueLabel(orgName, owner, repoName, issueNumber, name string) ([]LabelResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return []LabelResponse{}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	issue, err := g.findIssue(repoKey, issueNumber)
+	if err != nil {
+		return []LabelResponse{}, err
+	}
+	label, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]
+	if !exists || !slices.Contains(issue.Labels, label.Name) {
+		return []LabelResponse{}, ErrLabelNotFound
+	}
+	oldLabels := issue.Labels
+	issue.Labels = slices.DeleteFunc(slices.Clone(issue.Labels), func(labelName string) bool { return labelName == label.Name })
+	issue.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	issueResp := g.buildIssueResponse(orgName, owner, repoName, issue)
+	g.emitLabelChanges(orgName, owner, repoName, issueResp, oldLabels, issue.Labels)
+	return issueResp.Labels, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/assignees, every member of the org can be assigned.
+func (g *GbService) ListAssignees(orgName, owner, repoName string) ([]OwnerInfo, error) {
+	assigneeList := []OwnerInfo{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return assigneeList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	for _, login := range g.GbStoreInstance.Orgs[orgName].Users {
+		if user, exists := g.GbStoreInstance.Users[orgName+"/"+login]; exists {
+			assigneeList = append(assigneeList, ownerInfoOf(user))
+		}
+	}
+	return assigneeList, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/assignees/{assignee}
+func (g *GbService) CheckAssignee(orgName, owner, repoName, login string) error {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	if !g.IsOrgMember(orgName, login) {
+		return ErrInvalidAssignee
+	}
+	return nil
+}
+
+// AddAssignees serves post /repos/{org}/{owner}/{repo}/issues/{issue_number}/assignees, with remove it serves delete.
+func (g *GbService) AddAssignees(orgName, owner, repoName, issueNumber string, logins []string, remove bool) (IssueResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	issue, err := g.findIssue(orgName+"/"+owner+"/"+repoName, issueNumber)
+	if err != nil {
+		return IssueResponse{}, err
+	}
+	oldAssignees := issue.Assignees
+	if remove {
+		issue.Assignees = slices.DeleteFunc(slices.Clone(issue.Assignees), func(login string) bool { return slices.Contains(logins, login) })
+	} else {
+		// like github, logins that cannot be assigned are left out instead of failing the request.
+		assignees := slices.Clone(issue.Assignees)
+		for _, login := range logins {
+			if slices.Contains(g.GbStoreInstance.Orgs[orgName].Users, login) && !slices.Contains(assignees, login) {
+				assignees = append(assignees, login)
+			}
+		}
+		if len(assignees) > maxAssignees {
+			return IssueResponse{}, ErrTooManyAssignees
+		}
+		issue.Assignees = assignees
+	}
+	issue.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	issueResp := g.buildIssueResponse(orgName, owner, repoName, issue)
+	g.emitAssigneeChanges(orgName, owner, repoName, issueResp, oldAssignees, issue.Assignees)
+	return issueResp, g.persist()
+}
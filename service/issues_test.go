@@ -0,0 +1,111 @@
+package service
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssues(t *testing.T) {
+	issueService := newTestService(t)
+
+	// pull request #1 comes from the fixture, the issue takes the next number and the next PR the one after.
+	issue, err := issueService.CreateIssue("gborg", "gbuser", "gbrepo", &IssueRequest{Title: "Found a bug", Body: "It crashes",
+		Assignees: []string{"gbuser"}, Labels: []string{"bug"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, issue.Number)
+	assert.Equal(t, "open", issue.State)
+	assert.Equal(t, "gbuser", issue.Assignee.Login)
+	assert.Equal(t, "ededed", issue.Labels[0].Color)
+
+	_, err = issueService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/fix", SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd"})
+	assert.NoError(t, err)
+	title := "Fix"
+	pr, err := issueService.CreatePR("gborg", "gbuser", "gbrepo", &PRRequest{Title: &title, Head: "gbuser:fix", Base: "master"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pr.Number)
+	_, err = issueService.GetIssue("gborg", "gbuser", "gbrepo", "3")
+	assert.Equal(t, ErrIssueNotFound, err)
+
+	_, err = issueService.CreateIssue("gborg", "gbuser", "gbrepo", &IssueRequest{Title: " "})
+	assert.Equal(t, ErrIssueTitleRequired, err)
+	_, err = issueService.CreateIssue("gborg", "gbuser", "gbrepo", &IssueRequest{Title: "x", Assignees: []string{"stranger"}})
+	assert.Equal(t, ErrInvalidAssignee, err)
+
+	labels, err := issueService.AddIssueLabels("gborg", "gbuser", "gbrepo", "2", []string{"BUG", "triage"}, false)
+	assert.NoError(t, err)
+	assert.Len(t, labels, 2)
+	_, err = issueService.UpdateLabel("gborg", "gbuser", "gbrepo", "triage", &LabelRequest{NewName: "needs-triage", Color: "#FF0000"})
+	assert.NoError(t, err)
+	issue, _ = issueService.GetIssue("gborg", "gbuser", "gbrepo", "2")
+	assert.Equal(t, "needs-triage", issue.Labels[1].Name)
+	assert.Equal(t, "ff0000", issue.Labels[1].Color)
+
+	comment, err := issueService.CreateIssueComment("gborg", "gbuser", "gbrepo", "2", &IssueCommentRequest{Body: "Me too"})
+	assert.NoError(t, err)
+	_, err = issueService.UpdateIssueComment("gborg", "gbuser", "gbrepo", comment.ID, &IssueCommentRequest{Body: "Me too!"})
+	assert.NoError(t, err)
+	commentList, _ := issueService.ListIssueComments("gborg", "gbuser", "gbrepo", "2")
+	assert.Equal(t, "Me too!", commentList[0].Body)
+
+	issue, err = issueService.UpdateIssue("gborg", "gbuser", "gbrepo", "2", &IssueRequest{State: "closed", StateReason: "not_planned", Assignees: []string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "not_planned", issue.StateReason)
+	assert.Nil(t, issue.Assignee)
+	assert.Equal(t, 1, issue.Comments)
+	assert.Equal(t, "gbuser", issue.ClosedBy.Login)
+
+	openIssues, _ := issueService.ListIssues("gborg", "gbuser", "gbrepo", IssueFilter{})
+	assert.Empty(t, openIssues)
+	closedIssues, _ := issueService.ListIssues("gborg", "gbuser", "gbrepo", IssueFilter{State: "all", Labels: []string{"bug"}, Assignee: "none"})
+	assert.Len(t, closedIssues, 1)
+
+	_, err = issueService.DeleteLabel("gborg", "gbuser", "gbrepo", "bug")
+	assert.NoError(t, err)
+	labels, _ = issueService.ListIssueLabels("gborg", "gbuser", "gbrepo", "2")
+	assert.Len(t, labels, 1)
+	ok, err := issueService.DeleteIssueComment("gborg", "gbuser", "gbrepo", comment.ID)
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	_, err = issueService.GetIssueComment("gborg", "gbuser", "gbrepo", comment.ID)
+	assert.Equal(t, ErrIssueCommentNotFound, err)
+}
+
+func TestIssueAndPRNumbers(t *testing.T) {
+	gbService := newTestService(t)
+	sha := gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/master"].CommitInfo.SHA
+	for i := range 10 {
+		_, err := gbService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/feature" + strconv.Itoa(i), SHA: sha})
+		assert.NoError(t, err)
+	}
+
+	// issues and pull requests opened at the same time never share a number.
+	var wg sync.WaitGroup
+	numbers := make(chan int, 20)
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			issue, err := gbService.CreateIssue("gborg", "gbuser", "gbrepo", &IssueRequest{Title: "Issue"})
+			assert.NoError(t, err)
+			numbers <- issue.Number
+		}()
+		go func() {
+			defer wg.Done()
+			title := "PR"
+			pr, err := gbService.CreatePR("gborg", "gbuser", "gbrepo", &PRRequest{Title: &title, Head: "gbuser:feature" + strconv.Itoa(i), Base: "master"})
+			assert.NoError(t, err)
+			numbers <- pr.Number
+		}()
+	}
+	wg.Wait()
+	close(numbers)
+	seen := map[int]bool{}
+	for number := range numbers {
+		assert.False(t, seen[number], number)
+		seen[number] = true
+	}
+	assert.Len(t, seen, 20)
+}
@@ -0,0 +1,395 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gbserver/models"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// loginRegexp is what github takes for user and org logins: alphanumerics and single hyphens inside.
+var loginRegexp = regexp.MustCompile(`^[A-Za-z0-9]+(-[A-Za-z0-9]+)*$`)
+
+var memberRoles = []string{"admin", "member"}
+
+type OrgResponse struct {
+	Login       string `json:"login"`
+	ID          int    `json:"id"`
+	NodeID      string `json:"node_id"`
+	URL         string `json:"url"`
+	ReposURL    string `json:"repos_url"`
+	HooksURL    string `json:"hooks_url"`
+	MembersURL  string `json:"members_url"`
+	HTMLURL     string `json:"html_url"`
+	PublicRepos int    `json:"public_repos"`
+	Type        string `json:"type"`
+}
+
+type UserResponse struct {
+	Login     string `json:"login"`
+	ID        int    `json:"id"`
+	NodeID    string `json:"node_id"`
+	URL       string `json:"url"`
+	HTMLURL   string `json:"html_url"`
+	Type      string `json:"type"`
+	SiteAdmin bool   `json:"site_admin"`
+	Email     string `json:"email"`
+}
+
+type MembershipRequest struct {
+	Role string `json:"role"`
+	//'{"role":"admin"}'
+}
+
+type MembershipResponse struct {
+	URL             string       `json:"url"`
+	State           string       `json:"state"`
+	Role            string       `json:"role"`
+	OrganizationURL string       `json:"organization_url"`
+	Organization    OrgResponse  `json:"organization"`
+	User            UserResponse `json:"user"`
+}
+
+// CreateUserRequest is the site admin call that creates a user outside of any org.
+type CreateUserRequest struct {
+	Login     string `json:"login"`
+	Email     string `json:"email"`
+	SiteAdmin bool   `json:"site_admin"`
+	//'{"login":"newuser","email":"newuser@gbserver.com"}'
+}
+
+// CreateOrgRequest is the site admin call that creates an org, Admin becomes its first admin.
+type CreateOrgRequest struct {
+	Login string `json:"login"`
+	Admin string `json:"admin"`
+	//'{"login":"neworg","admin":"gbuser"}'
+}
+
+type AuthorizationResponse struct {
+	ID        int    `json:"id"`
+	Token     string `json:"token"`
+	CreatedAt string `json:"created_at"`
+}
+
+func orgResponse(org *models.Organization) OrgResponse {
+	orgURL := "https://api.gbserver.com/orgs/" + org.Name
+	return OrgResponse{Login: org.Name, ID: org.ID, NodeID: org.NodeID, URL: orgURL, ReposURL: orgURL + "/repos",
+		HooksURL: orgURL + "/hooks", MembersURL: orgURL + "/members{/member}", HTMLURL: "https://gbserver.com/" + org.Name,
+		PublicRepos: len(org.Repos), Type: "Organization"}
+}
+
+func userResponse(user *models.User) UserResponse {
+	return UserResponse{Login: user.LoginName, ID: user.ID, NodeID: user.NodeID,
+		URL: "https://api.gbserver.com/users/" + user.LoginName, HTMLURL: "https://gbserver.com/" + user.LoginName,
+		Type: user.UserType, SiteAdmin: user.SiteAdmin, Email: user.Email}
+}
+
+// roleOf is the role of a member, the fixture and older stores leave it empty for members.
+func roleOf(member *models.User) string {
+	if member.Role == "" {
+		return "member"
+	}
+	return member.Role
+}
+
+// accountOf finds a user by login, stores written before accounts existed only have the org memberships.
+// Caller must hold the store lock.
+func (g *GbService) accountOf(login string) *models.User {
+	if account, exists := g.GbStoreInstance.Accounts[login]; exists {
+		return account
+	}
+	for _, user := range g.GbStoreInstance.Users {
+		if user.LoginName == login {
+			return user
+		}
+	}
+	return nil
+}
+
+// checkOrgAdmin lets org admins and site admins through, and everyone when there is no authenticated caller.
+// Caller must hold the store lock.
+func (g *GbService) checkOrgAdmin(orgName string) error {
+	if g.Actor == "" {
+		return nil
+	}
+	if account := g.accountOf(g.Actor); account != nil && account.SiteAdmin {
+		return nil
+	}
+	if member, exists := g.GbStoreInstance.Users[orgName+"/"+g.Actor]; exists && roleOf(member) == "admin" {
+		return nil
+	}
+	return ErrOrgAdminRequired
+}
+
+func (g *GbService) membershipResponse(org *models.Organization, member *models.User) MembershipResponse {
+	return MembershipResponse{URL: "https://api.gbserver.com/orgs/" + org.Name + "/memberships/" + member.LoginName,
+		State: "active", Role: roleOf(member), OrganizationURL: "https://api.gbserver.com/orgs/" + org.Name,
+		Organization: orgResponse(org), User: userResponse(member)}
+}
+
+// get /orgs/{org}
+func (g *GbService) GetOrg(orgName string) (OrgResponse, error) {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	if !exists {
+		return OrgResponse{}, ErrOrgNotFound
+	}
+	return orgResponse(org), nil
+}
+
+// get /users/{user}, get /user
+func (g *GbService) GetUser(login string) (UserResponse, error) {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	account := g.accountOf(login)
+	if account == nil {
+		return UserResponse{}, ErrUserNotFound
+	}
+	return userResponse(account), nil
+}
+
+// get /orgs/{org}/members?role=all|admin|member
+func (g *GbService) ListOrgMembers(orgName, role string) ([]UserResponse, error) {
+	memberList := []UserResponse{}
+	if role == "" {
+		role = "all"
+	}
+	if role != "all" && !slices.Contains(memberRoles, role) {
+		return memberList, ErrInvalidMemberRole
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	if !exists {
+		return memberList, ErrOrgNotFound
+	}
+	logins := slices.Clone(org.Users)
+	slices.Sort(logins)
+	for _, login := range logins {
+		member := g.GbStoreInstance.Users[orgName+"/"+login]
+		if member != nil && (role == "all" || roleOf(member) == role) {
+			memberList = append(memberList, userResponse(member))
+		}
+	}
+	return memberList, nil
+}
+
+// get /orgs/{org}/memberships/{username}, get /orgs/{org}/members/{username}
+func (g *GbService) GetOrgMembership(orgName, login string) (MembershipResponse, error) {
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	if !exists {
+		return MembershipResponse{}, ErrOrgNotFound
+	}
+	member, exists := g.GbStoreInstance.Users[orgName+"/"+login]
+	if !exists {
+		return MembershipResponse{}, ErrMembershipNotFound
+	}
+	return g.membershipResponse(org, member), nil
+}
+
+// put /orgs/{org}/memberships/{username}
+// Adds the user to the org with the role (member if not given), or changes the role of a member.
+func (g *GbService) SetOrgMembership(orgName, login string, membershipReq *MembershipRequest) (MembershipResponse, error) {
+	role := membershipReq.Role
+	if role == "" {
+		role = "member"
+	}
+	if !slices.Contains(memberRoles, role) {
+		return MembershipResponse{}, ErrInvalidMemberRole
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	if !exists {
+		return MembershipResponse{}, ErrOrgNotFound
+	}
+	err := g.checkOrgAdmin(orgName)
+	if err != nil {
+		return MembershipResponse{}, err
+	}
+	account := g.accountOf(login)
+	if account == nil {
+		return MembershipResponse{}, ErrUserNotFound
+	}
+	member, exists := g.GbStoreInstance.Users[orgName+"/"+login]
+	if !exists {
+		member = g.addOrgMember(org, account, role)
+	} else {
+		if roleOf(member) == "admin" && role != "admin" && g.lastOrgAdmin(org, login) {
+			return MembershipResponse{}, ErrLastOrgAdmin
+		}
+		member.Role = role
+	}
+	return g.membershipResponse(org, member), g.persist()
+}
+
+// addOrgMember makes account a member of org. Caller must hold the store lock.
+func (g *GbService) addOrgMember(org *models.Organization, account *models.User, role string) *models.User {
+	member := &models.User{ID: account.ID, LoginName: account.LoginName, OrgID: org.ID, NodeID: account.NodeID,
+		UserType: account.UserType, Repos: []string{}, SiteAdmin: account.SiteAdmin, Tokens: account.Tokens,
+		Email: account.Email, Role: role}
+	g.GbStoreInstance.Users[org.Name+"/"+account.LoginName] = member
+	org.Users = append(org.Users, account.LoginName)
+	return member
+}
+
+// lastOrgAdmin tells if login is the only admin of the org. Caller must hold the store lock.
+func (g *GbService) lastOrgAdmin(org *models.Organization, login string) bool {
+	for _, other := range org.Users {
+		if member := g.GbStoreInstance.Users[org.Name+"/"+other]; other != login && member != nil && roleOf(member) == "admin" {
+			return false
+		}
+	}
+	return true
+}
+
+// delete /orgs/{org}/memberships/{username}, delete /orgs/{org}/members/{username}
+// Members that still own repositories of the org, or are its last admin, cannot be removed.
+func (g *GbService) RemoveOrgMember(orgName, login string) error {
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	org, exists := g.GbStoreInstance.Orgs[orgName]
+	if !exists {
+		return ErrOrgNotFound
+	}
+	// members may always leave on their own.
+	if login != g.Actor {
+		err := g.checkOrgAdmin(orgName)
+		if err != nil {
+			return err
+		}
+	}
+	member, exists := g.GbStoreInstance.Users[orgName+"/"+login]
+	if !exists {
+		return ErrMembershipNotFound
+	}
+	if len(member.Repos) > 0 {
+		return ErrMemberOwnsRepos
+	}
+	if roleOf(member) == "admin" && g.lastOrgAdmin(org, login) {
+		return ErrLastOrgAdmin
+	}
+	delete(g.GbStoreInstance.Users, orgName+"/"+login)
+	org.Users = removeElementByValue(org.Users, login)
+	return g.persist()
+}
+
+// post /admin/users
+func (g *GbService) CreateUser(userReq *CreateUserRequest) (UserResponse, error) {
+	if !loginRegexp.MatchString(userReq.Login) {
+		return UserResponse{}, ErrInvalidLogin
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	if g.accountOf(userReq.Login) != nil || g.GbStoreInstance.Orgs[userReq.Login] != nil {
+		return UserResponse{}, ErrLoginAlreadyExists
+	}
+	userID := 0
+	for _, user := range g.GbStoreInstance.Users {
+		userID = max(userID, user.ID)
+	}
+	for _, account := range g.GbStoreInstance.Accounts {
+		userID = max(userID, account.ID)
+	}
+	userID++
+	account := &models.User{ID: userID, LoginName: userReq.Login, NodeID: generateCustomID("NODEID"), UserType: "User",
+		Repos: []string{}, SiteAdmin: userReq.SiteAdmin, Tokens: []string{}, Email: userReq.Email}
+	g.GbStoreInstance.Accounts[account.LoginName] = account
+	return userResponse(account), g.persist()
+}
+
+// newToken makes an access token. Tokens are what callers are authenticated with, so they come from
+// crypto/rand and not from the math/rand the made up ids use.
+func newToken() (string, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return "gbp_" + hex.EncodeToString(secret), nil
+}
+
+// post /admin/users/{username}/authorizations
+// Hands out a new token for the user, it is valid in every org the user is a member of.
+func (g *GbService) CreateUserToken(login string) (AuthorizationResponse, error) {
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	account := g.accountOf(login)
+	if account == nil {
+		return AuthorizationResponse{}, ErrUserNotFound
+	}
+	token, err := newToken()
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	if _, exists := g.GbStoreInstance.Accounts[login]; !exists {
+		// an account from an older store, keep it from now on.
+		account = &models.User{ID: account.ID, LoginName: account.LoginName, NodeID: account.NodeID, UserType: account.UserType,
+			Repos: []string{}, SiteAdmin: account.SiteAdmin, Tokens: slices.Clone(account.Tokens), Email: account.Email}
+		g.GbStoreInstance.Accounts[login] = account
+	}
+	account.Tokens = append(account.Tokens, token)
+	for _, user := range g.GbStoreInstance.Users {
+		if user.LoginName == login {
+			user.Tokens = slices.Clone(account.Tokens)
+		}
+	}
+	return AuthorizationResponse{ID: len(account.Tokens), Token: token, CreatedAt: time.Now().UTC().Format(time.RFC3339)}, g.persist()
+}
+
+// post /admin/organizations
+func (g *GbService) CreateOrg(orgReq *CreateOrgRequest) (OrgResponse, error) {
+	if !loginRegexp.MatchString(orgReq.Login) {
+		return OrgResponse{}, ErrInvalidLogin
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	if g.GbStoreInstance.Orgs[orgReq.Login] != nil || g.accountOf(orgReq.Login) != nil {
+		return OrgResponse{}, ErrLoginAlreadyExists
+	}
+	admin := g.accountOf(orgReq.Admin)
+	if admin == nil {
+		return OrgResponse{}, ErrUserNotFound
+	}
+	orgID := 0
+	for _, org := range g.GbStoreInstance.Orgs {
+		orgID = max(orgID, org.ID)
+	}
+	org := &models.Organization{ID: orgID + 1, NodeID: generateCustomID("NODEID"), Name: orgReq.Login,
+		Users: []string{}, Repos: []string{}}
+	g.GbStoreInstance.Orgs[org.Name] = org
+	g.addOrgMember(org, admin, "admin")
+	return orgResponse(org), g.persist()
+}
+
+// orgsOf lists the orgs login is a member of, sorted by name. Caller must hold the store lock.
+func (g *GbService) orgsOf(login string) []string {
+	var orgNames []string
+	for key := range g.GbStoreInstance.Users {
+		if orgName, member, _ := strings.Cut(key, "/"); member == login {
+			orgNames = append(orgNames, orgName)
+		}
+	}
+	slices.Sort(orgNames)
+	return orgNames
+}
+
+// get /user/orgs
+func (g *GbService) ListUserOrgs(login string) ([]OrgResponse, error) {
+	orgList := []OrgResponse{}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	if g.accountOf(login) == nil {
+		return orgList, ErrUserNotFound
+	}
+	for _, orgName := range g.orgsOf(login) {
+		orgList = append(orgList, orgResponse(g.GbStoreInstance.Orgs[orgName]))
+	}
+	return orgList, nil
+}
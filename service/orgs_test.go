@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrgsAndUsers(t *testing.T) {
+	gbService := newTestService(t)
+
+	org, err := gbService.GetOrg("gborg")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, org.PublicRepos)
+	_, err = gbService.GetOrg("nosuchorg")
+	assert.Equal(t, ErrOrgNotFound, err)
+	user, err := gbService.GetUser("gbuser")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
+	_, err = gbService.GetUser("nosuchuser")
+	assert.Equal(t, ErrUserNotFound, err)
+
+	admins, err := gbService.ListOrgMembers("gborg", "admin")
+	assert.NoError(t, err)
+	assert.Len(t, admins, 1)
+	assert.Equal(t, "gbadmin", admins[0].Login)
+	_, err = gbService.ListOrgMembers("gborg", "owner")
+	assert.Equal(t, ErrInvalidMemberRole, err)
+
+	_, err = gbService.CreateUser(&CreateUserRequest{Login: "-bad"})
+	assert.Equal(t, ErrInvalidLogin, err)
+	_, err = gbService.CreateUser(&CreateUserRequest{Login: "gbuser"})
+	assert.Equal(t, ErrLoginAlreadyExists, err)
+	newUser, err := gbService.CreateUser(&CreateUserRequest{Login: "newuser", Email: "newuser@gbserver.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, newUser.ID)
+	token, err := gbService.CreateUserToken("newuser")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^gbp_[0-9a-f]{40}$`, token.Token)
+	authUser, err := gbService.Authenticate(token.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, "newuser", authUser.Login)
+	assert.False(t, gbService.IsOrgMember("gborg", "newuser"))
+
+	newOrg, err := gbService.CreateOrg(&CreateOrgRequest{Login: "neworg", Admin: "newuser"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, newOrg.ID)
+	membership, err := gbService.GetOrgMembership("neworg", "newuser")
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", membership.Role)
+	assert.Equal(t, newOrg.ID, gbService.GbStoreInstance.Users["neworg/newuser"].OrgID)
+	orgs, _ := gbService.ListUserOrgs("newuser")
+	assert.Equal(t, "neworg", orgs[0].Login)
+}
+
+func TestOrgMemberships(t *testing.T) {
+	gbService := newTestService(t)
+	_, err := gbService.CreateUser(&CreateUserRequest{Login: "newuser"})
+	assert.NoError(t, err)
+
+	// only org admins and site admins manage memberships.
+	_, err = gbService.WithActor("gbuser").SetOrgMembership("gborg", "newuser", &MembershipRequest{})
+	assert.Equal(t, ErrOrgAdminRequired, err)
+	_, err = gbService.WithActor("gbadmin").SetOrgMembership("gborg", "newuser", &MembershipRequest{Role: "owner"})
+	assert.Equal(t, ErrInvalidMemberRole, err)
+	_, err = gbService.WithActor("gbadmin").SetOrgMembership("gborg", "nosuchuser", &MembershipRequest{})
+	assert.Equal(t, ErrUserNotFound, err)
+	membership, err := gbService.WithActor("gbadmin").SetOrgMembership("gborg", "newuser", &MembershipRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "member", membership.Role)
+	assert.True(t, gbService.IsOrgMember("gborg", "newuser"))
+
+	membership, err = gbService.WithActor("gbadmin").SetOrgMembership("gborg", "newuser", &MembershipRequest{Role: "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", membership.Role)
+	// the new admin can now demote the old one, but not the last one.
+	_, err = gbService.WithActor("newuser").SetOrgMembership("gborg", "gbadmin", &MembershipRequest{Role: "member"})
+	assert.NoError(t, err)
+	_, err = gbService.WithActor("newuser").SetOrgMembership("gborg", "newuser", &MembershipRequest{Role: "member"})
+	assert.Equal(t, ErrLastOrgAdmin, err)
+
+	assert.Equal(t, ErrOrgAdminRequired, gbService.WithActor("gbuser").RemoveOrgMember("gborg", "gbadmin"))
+	assert.Equal(t, ErrMemberOwnsRepos, gbService.WithActor("newuser").RemoveOrgMember("gborg", "gbuser"))
+	assert.Equal(t, ErrLastOrgAdmin, gbService.WithActor("newuser").RemoveOrgMember("gborg", "newuser"))
+	// members may leave on their own.
+	assert.NoError(t, gbService.WithActor("gbadmin").RemoveOrgMember("gborg", "gbadmin"))
+	assert.False(t, gbService.IsOrgMember("gborg", "gbadmin"))
+	_, err = gbService.GetOrgMembership("gborg", "gbadmin")
+	assert.Equal(t, ErrMembershipNotFound, err)
+	// the account stays.
+	_, err = gbService.GetUser("gbadmin")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,358 @@
+package service
+
+import (
+	"gbserver/models"
+	"slices"
+)
+
+// maxRequiredApprovals is the most approving reviews github lets a branch require.
+const maxRequiredApprovals = 6
+
+type RequiredStatusCheck struct {
+	Context string `json:"context"`
+	AppID   *int   `json:"app_id"`
+}
+
+type RequiredStatusChecks struct {
+	URL      string                `json:"url,omitempty"`
+	Strict   bool                  `json:"strict"`
+	Contexts []string              `json:"contexts"`
+	Checks   []RequiredStatusCheck `json:"checks"`
+}
+
+type RequiredPullRequestReviews struct {
+	URL                          string `json:"url,omitempty"`
+	RequiredApprovingReviewCount int    `json:"required_approving_review_count"`
+}
+
+type ProtectionRestrictionsRequest struct {
+	Users []string `json:"users"`
+	Teams []string `json:"teams"`
+}
+
+type BranchProtectionRequest struct {
+	RequiredStatusChecks       *RequiredStatusChecks          `json:"required_status_checks"`
+	EnforceAdmins              *bool                          `json:"enforce_admins"`
+	RequiredPullRequestReviews *RequiredPullRequestReviews    `json:"required_pull_request_reviews"`
+	Restrictions               *ProtectionRestrictionsRequest `json:"restrictions"`
+	AllowForcePushes           *bool                          `json:"allow_force_pushes"`
+	AllowDeletions             *bool                          `json:"allow_deletions"`
+	//'{"required_status_checks":{"strict":true,"contexts":["ci/build"]},"enforce_admins":true,
+	//"required_pull_request_reviews":{"required_approving_review_count":1},"restrictions":null}'
+}
+
+type ProtectionSetting struct {
+	URL     string `json:"url,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+type ProtectionRestrictions struct {
+	URL   string      `json:"url"`
+	Users []OwnerInfo `json:"users"`
+	Teams []string    `json:"teams"`
+	Apps  []string    `json:"apps"`
+}
+
+type BranchProtectionResponse struct {
+	URL                        string                      `json:"url"`
+	RequiredStatusChecks       *RequiredStatusChecks       `json:"required_status_checks,omitempty"`
+	EnforceAdmins              ProtectionSetting           `json:"enforce_admins"`
+	RequiredPullRequestReviews *RequiredPullRequestReviews `json:"required_pull_request_reviews,omitempty"`
+	Restrictions               *ProtectionRestrictions     `json:"restrictions,omitempty"`
+	AllowForcePushes           ProtectionSetting           `json:"allow_force_pushes"`
+	AllowDeletions             ProtectionSetting           `json:"allow_deletions"`
+}
+
+// findBranch looks a branch of the repo up. Caller must hold the store lock.
+func (g *GbService) findBranch(repoKey, branchName string) (*models.Branch, error) {
+	branch, exists := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+	if !exists {
+		return nil, ErrBranchesNotFound
+	}
+	return branch, nil
+}
+
+// buildProtectionResponse renders the protection of a branch. Caller must hold the store lock.
+func (g *GbService) buildProtectionResponse(orgName, owner, repoName, branchName string, protection *models.BranchProtection) BranchProtectionResponse {
+	protectionURL := repoAPIURL(owner, repoName) + "/branches/" + branchName + "/protection"
+	protectionResp := BranchProtectionResponse{URL: protectionURL,
+		EnforceAdmins:    ProtectionSetting{URL: protectionURL + "/enforce_admins", Enabled: protection.EnforceAdmins},
+		AllowForcePushes: ProtectionSetting{Enabled: protection.AllowForcePushes},
+		AllowDeletions:   ProtectionSetting{Enabled: protection.AllowDeletions}}
+	if protection.RequireStatusChecks {
+		checks := &RequiredStatusChecks{URL: protectionURL + "/required_status_checks", Strict: protection.Strict,
+			Contexts: slices.Clone(protection.RequiredContexts), Checks: []RequiredStatusCheck{}}
+		for _, context := range protection.RequiredContexts {
+			checks.Checks = append(checks.Checks, RequiredStatusCheck{Context: context})
+		}
+		protectionResp.RequiredStatusChecks = checks
+	}
+	if protection.RequirePullRequestReviews {
+		protectionResp.RequiredPullRequestReviews = &RequiredPullRequestReviews{URL: protectionURL + "/required_pull_request_reviews",
+			RequiredApprovingReviewCount: protection.RequiredApprovingReviewCount}
+	}
+	if protection.RestrictPushes {
+		restrictions := &ProtectionRestrictions{URL: protectionURL + "/restrictions", Users: []OwnerInfo{}, Teams: []string{}, Apps: []string{}}
+		for _, login := range protection.PushUsers {
+			if user := g.GbStoreInstance.Users[orgName+"/"+login]; user != nil {
+				restrictions.Users = append(restrictions.Users, ownerInfoOf(user))
+			}
+		}
+		protectionResp.Restrictions = restrictions
+	}
+	return protectionResp
+}
+
+// get /repos/{org}/{owner}/{repo}/branches/{branch}/protection
+func (g *GbService) GetBranchProtection(orgName, owner, repoName, branchName string) (BranchProtectionResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return BranchProtectionResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	branch, err := g.findBranch(orgName+"/"+owner+"/"+repoName, branchName)
+	if err != nil {
+		return BranchProtectionResponse{}, err
+	}
+	if branch.Protection == nil {
+		return BranchProtectionResponse{}, ErrBranchNotProtected
+	}
+	return g.buildProtectionResponse(orgName, owner, repoName, branchName, branch.Protection), nil
+}
+
+// put /repos/{org}/{owner}/{repo}/branches/{branch}/protection, replaces every rule of the branch.
+func (g *GbService) UpdateBranchProtection(orgName, owner, repoName, branchName string, protectionReq *BranchProtectionRequest) (BranchProtectionResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return BranchProtectionResponse{}, err
+	}
+	protection := &models.BranchProtection{}
+	if checks := protectionReq.RequiredStatusChecks; checks != nil {
+		protection.RequireStatusChecks = true
+		protection.Strict = checks.Strict
+		protection.RequiredContexts = slices.Clone(checks.Contexts)
+		for _, check := range checks.Checks {
+			protection.RequiredContexts = append(protection.RequiredContexts, check.Context)
+		}
+		protection.RequiredContexts = slices.Compact(slices.Sorted(slices.Values(protection.RequiredContexts)))
+		if slices.Contains(protection.RequiredContexts, "") {
+			return BranchProtectionResponse{}, ErrInvalidBranchProtection
+		}
+	}
+	if reviews := protectionReq.RequiredPullRequestReviews; reviews != nil {
+		if reviews.RequiredApprovingReviewCount < 0 || reviews.RequiredApprovingReviewCount > maxRequiredApprovals {
+			return BranchProtectionResponse{}, ErrInvalidBranchProtection
+		}
+		protection.RequirePullRequestReviews = true
+		protection.RequiredApprovingReviewCount = reviews.RequiredApprovingReviewCount
+	}
+	if protectionReq.EnforceAdmins != nil {
+		protection.EnforceAdmins = *protectionReq.EnforceAdmins
+	}
+	if protectionReq.AllowForcePushes != nil {
+		protection.AllowForcePushes = *protectionReq.AllowForcePushes
+	}
+	if protectionReq.AllowDeletions != nil {
+		protection.AllowDeletions = *protectionReq.AllowDeletions
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	// a push checked against the old rules must be done before the new ones apply.
+	defer g.lockPushes(repoKey)()
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	err = g.checkRepoAdmin(orgName, owner)
+	if err != nil {
+		return BranchProtectionResponse{}, err
+	}
+	branch, err := g.findBranch(repoKey, branchName)
+	if err != nil {
+		return BranchProtectionResponse{}, err
+	}
+	if restrictions := protectionReq.Restrictions; restrictions != nil {
+		protection.RestrictPushes = true
+		protection.PushUsers = []string{}
+		for _, login := range restrictions.Users {
+			if _, exists := g.GbStoreInstance.Users[orgName+"/"+login]; !exists {
+				return BranchProtectionResponse{}, ErrInvalidBranchProtection
+			}
+			if !slices.Contains(protection.PushUsers, login) {
+				protection.PushUsers = append(protection.PushUsers, login)
+			}
+		}
+	}
+	branch.Protected = true
+	branch.Protection = protection
+	return g.buildProtectionResponse(orgName, owner, repoName, branchName, protection), g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/branches/{branch}/protection
+func (g *GbService) DeleteBranchProtection(orgName, owner, repoName, branchName string) error {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	defer g.lockPushes(repoKey)()
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	err = g.checkRepoAdmin(orgName, owner)
+	if err != nil {
+		return err
+	}
+	branch, err := g.findBranch(repoKey, branchName)
+	if err != nil {
+		return err
+	}
+	if branch.Protection == nil {
+		return ErrBranchNotProtected
+	}
+	branch.Protected = false
+	branch.Protection = nil
+	return g.persist()
+}
+
+// checkRepoAdmin lets the owner of the repo through, as well as everyone checkOrgAdmin does.
+// Caller must hold the store lock.
+func (g *GbService) checkRepoAdmin(orgName, owner string) error {
+	if g.Actor == owner || g.checkOrgAdmin(orgName) == nil {
+		return nil
+	}
+	return ErrRepoAdminRequired
+}
+
+// protectionBypassed tells if the acting user is a site or org admin the protection does not apply to.
+// Caller must hold the store lock.
+func (g *GbService) protectionBypassed(orgName, owner string, protection *models.BranchProtection) bool {
+	actor := g.actingUser(orgName, owner)
+	return !protection.EnforceAdmins && actor != nil && (actor.SiteAdmin || roleOf(actor) == "admin")
+}
+
+// checkPushAllowed refuses users a restricted branch does not list. Caller must hold the store lock.
+func (g *GbService) checkPushAllowed(orgName, owner string, protection *models.BranchProtection) error {
+	actor := g.actingUser(orgName, owner)
+	if protection.RestrictPushes && (actor == nil || !slices.Contains(protection.PushUsers, actor.LoginName)) {
+		return ErrBranchPushRestricted
+	}
+	return nil
+}
+
+// contextPassing tells if the latest status of context or the latest check run named like it passed on sha.
+// Caller must hold the store lock.
+func (g *GbService) contextPassing(repoKey, sha, context string) bool {
+	for _, status := range g.latestStatuses(repoKey, sha) {
+		if status.Context == context {
+			return status.State == "success"
+		}
+	}
+	for _, checkRun := range g.checksOf(repoKey, sha) {
+		if checkRun.Name == context {
+			return checkRun.Status == "completed" && slices.Contains(passingConclusions, checkRun.Conclusion)
+		}
+	}
+	return false
+}
+
+// checkRequiredContexts makes sure every required status check passed on sha. Caller must hold the store lock.
+func (g *GbService) checkRequiredContexts(repoKey, sha string, protection *models.BranchProtection) error {
+	if !protection.RequireStatusChecks {
+		return nil
+	}
+	for _, context := range protection.RequiredContexts {
+		if !g.contextPassing(repoKey, sha, context) {
+			return ErrRequiredChecksFailing
+		}
+	}
+	return nil
+}
+
+// isForcePush tells if moving a branch from oldSHA to newSHA drops commits. Without git nothing is known
+// about the history, so no move counts as forced. Caller must hold the store lock.
+func (g *GbService) isForcePush(repoKey, oldSHA, newSHA string) bool {
+	if g.Git == nil || oldSHA == "" || newSHA == "" || oldSHA == newSHA {
+		return false
+	}
+	if !g.Git.CommitExists(repoKey, oldSHA) || !g.Git.CommitExists(repoKey, newSHA) {
+		return false
+	}
+	fastForward, err := g.Git.IsAncestor(repoKey, oldSHA, newSHA)
+	return err == nil && !fastForward
+}
+
+// checkRefUpdate enforces the protection of a branch on a direct update from oldSHA to newSHA, an empty
+// newSHA deletes the branch. Caller must hold the store lock.
+func (g *GbService) checkRefUpdate(orgName, owner, repoName, branchName, oldSHA, newSHA string) error {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+	if branch == nil || branch.Protection == nil || g.protectionBypassed(orgName, owner, branch.Protection) {
+		return nil
+	}
+	protection := branch.Protection
+	err := g.checkPushAllowed(orgName, owner, protection)
+	if err != nil {
+		return err
+	}
+	if newSHA == "" {
+		if !protection.AllowDeletions {
+			return ErrProtectedBranchDeletion
+		}
+		return nil
+	}
+	if !protection.AllowForcePushes && g.isForcePush(repoKey, oldSHA, newSHA) {
+		return ErrProtectedBranchForcePush
+	}
+	if protection.RequirePullRequestReviews {
+		return ErrProtectedBranchPullRequest
+	}
+	return g.checkRequiredContexts(repoKey, newSHA, protection)
+}
+
+// mergeBlocked tells what the protection of the base branch still misses before the pull request may be
+// merged, nil when nothing. Caller must hold the store lock.
+func (g *GbService) mergeBlocked(repoKey string, pr *models.PullRequest, headSHA, baseSHA string) error {
+	baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+pr.ToBranch]
+	if baseBranch == nil || baseBranch.Protection == nil {
+		return nil
+	}
+	protection := baseBranch.Protection
+	if protection.RequirePullRequestReviews {
+		approvals := 0
+		for _, state := range g.latestReviewStates(pr) {
+			if state == "CHANGES_REQUESTED" {
+				return ErrRequiredReviewsMissing
+			}
+			if state == "APPROVED" {
+				approvals++
+			}
+		}
+		if approvals < protection.RequiredApprovingReviewCount {
+			return ErrRequiredReviewsMissing
+		}
+	}
+	err := g.checkRequiredContexts(repoKey, headSHA, protection)
+	if err != nil {
+		return err
+	}
+	if protection.Strict && g.Git != nil {
+		upToDate, err := g.Git.IsAncestor(repoKey, baseSHA, headSHA)
+		if err != nil || !upToDate {
+			return ErrProtectedBranchOutOfDate
+		}
+	}
+	return nil
+}
+
+// checkMerge enforces the protection of the base branch on merging a pull request. Caller must hold the store lock.
+func (g *GbService) checkMerge(orgName, owner, repoName string, pr *models.PullRequest, headSHA, baseSHA string) error {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+pr.ToBranch]
+	if baseBranch == nil || baseBranch.Protection == nil || g.protectionBypassed(orgName, owner, baseBranch.Protection) {
+		return nil
+	}
+	err := g.checkPushAllowed(orgName, owner, baseBranch.Protection)
+	if err != nil {
+		return err
+	}
+	return g.mergeBlocked(repoKey, pr, headSHA, baseSHA)
+}
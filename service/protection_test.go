@@ -0,0 +1,120 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBranchProtection(t *testing.T) {
+	gbService := newTestService(t)
+	author := gbService.WithActor("gbuser")
+	admin := gbService.WithActor("gbadmin")
+	head := gbService.GbStoreInstance.Branches["gborg/gbuser/gbrepo/gbbranch"].CommitInfo.SHA
+	enforced := true
+
+	_, err := gbService.GetBranchProtection("gborg", "gbuser", "gbrepo", "master")
+	assert.Equal(t, ErrBranchNotProtected, err)
+	_, err = gbService.GetBranchProtection("gborg", "gbuser", "gbrepo", "nosuchbranch")
+	assert.Equal(t, ErrBranchesNotFound, err)
+	_, err = gbService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{
+		RequiredPullRequestReviews: &RequiredPullRequestReviews{RequiredApprovingReviewCount: 7}})
+	assert.Equal(t, ErrInvalidBranchProtection, err)
+	_, err = gbService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{
+		Restrictions: &ProtectionRestrictionsRequest{Users: []string{"stranger"}}})
+	assert.Equal(t, ErrInvalidBranchProtection, err)
+
+	protection, err := gbService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{
+		RequiredStatusChecks:       &RequiredStatusChecks{Contexts: []string{"ci/build"}},
+		RequiredPullRequestReviews: &RequiredPullRequestReviews{RequiredApprovingReviewCount: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ci/build"}, protection.RequiredStatusChecks.Contexts)
+	assert.False(t, protection.AllowDeletions.Enabled)
+	branches, _ := gbService.ListBranches("gborg", "gbuser", "gbrepo")
+	assert.True(t, branches[1].Protected)
+	assert.Equal(t, "master", branches[1].Name)
+
+	_, err = author.DeleteBranch("gborg", "gbuser", "gbrepo", "master")
+	assert.Equal(t, ErrProtectedBranchDeletion, err)
+
+	// neither the approval nor the required check are there yet.
+	pr, _ := gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "blocked", pr.MergeableState)
+	_, err = author.MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{})
+	assert.Equal(t, ErrRequiredReviewsMissing, err)
+	_, err = admin.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "APPROVE"})
+	assert.NoError(t, err)
+	_, err = author.MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{})
+	assert.Equal(t, ErrRequiredChecksFailing, err)
+	_, err = gbService.CreateCheckRun("gborg", "gbuser", "gbrepo", &CheckRunRequest{Name: "ci/build", HeadSHA: head, Conclusion: "success"})
+	assert.NoError(t, err)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "clean", pr.MergeableState)
+
+	// only the listed users may push, admins too once the rules are enforced on them.
+	_, err = gbService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{
+		EnforceAdmins: &enforced, Restrictions: &ProtectionRestrictionsRequest{Users: []string{"gbuser"}}})
+	assert.NoError(t, err)
+	_, err = admin.MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{})
+	assert.Equal(t, ErrBranchPushRestricted, err)
+	_, err = author.MergePR("gborg", "gbuser", "gbrepo", "1", &MergePRRequest{})
+	assert.NoError(t, err)
+
+	_, err = gbService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{})
+	assert.NoError(t, err)
+	_, err = admin.DeleteBranch("gborg", "gbuser", "gbrepo", "master")
+	assert.NoError(t, err)
+	assert.Equal(t, ErrBranchesNotFound, gbService.DeleteBranchProtection("gborg", "gbuser", "gbrepo", "master"))
+}
+
+func TestGitBackedBranchProtection(t *testing.T) {
+	gitService := newGitService(t)
+	_, err := gitService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{
+		RequiredPullRequestReviews: &RequiredPullRequestReviews{}})
+	assert.NoError(t, err)
+
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "notes.txt", &FileCommitRequest{Message: "Add notes", Content: "bm90ZXMK"})
+	assert.Equal(t, ErrProtectedBranchPullRequest, err)
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "notes.txt", &FileCommitRequest{Message: "Add notes", Content: "bm90ZXMK", Branch: "gbbranch"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, gitService.DeleteBranchProtection("gborg", "gbuser", "gbrepo", "master"))
+	assert.Equal(t, ErrBranchNotProtected, gitService.DeleteBranchProtection("gborg", "gbuser", "gbrepo", "master"))
+	_, _, err = gitService.PutContents("gborg", "gbuser", "gbrepo", "notes.txt", &FileCommitRequest{Message: "Add notes", Content: "bm90ZXMK"})
+	assert.NoError(t, err)
+}
+
+func TestBranchProtectionAdmins(t *testing.T) {
+	gbService := newTestService(t)
+	admin := gbService.WithActor("gbadmin")
+	for _, login := range []string{"orgadmin", "member"} {
+		_, err := gbService.CreateUser(&CreateUserRequest{Login: login})
+		assert.NoError(t, err)
+	}
+	_, err := admin.SetOrgMembership("gborg", "orgadmin", &MembershipRequest{Role: "admin"})
+	assert.NoError(t, err)
+	_, err = admin.SetOrgMembership("gborg", "member", &MembershipRequest{})
+	assert.NoError(t, err)
+
+	// only the owner and admins change the rules.
+	_, err = gbService.WithActor("member").UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{})
+	assert.Equal(t, ErrRepoAdminRequired, err)
+	_, err = gbService.WithActor("gbuser").UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, ErrRepoAdminRequired, gbService.WithActor("member").DeleteBranchProtection("gborg", "gbuser", "gbrepo", "master"))
+	_, err = gbService.WithActor("orgadmin").UpdateBranchProtection("gborg", "gbuser", "gbrepo", "gbbranch", &BranchProtectionRequest{})
+	assert.NoError(t, err)
+
+	// org admins get past the rules like site admins, until they are enforced on admins.
+	_, err = gbService.WithActor("member").DeleteBranch("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.Equal(t, ErrProtectedBranchDeletion, err)
+	enforced := true
+	_, err = admin.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "gbbranch", &BranchProtectionRequest{EnforceAdmins: &enforced})
+	assert.NoError(t, err)
+	_, err = gbService.WithActor("orgadmin").DeleteBranch("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.Equal(t, ErrProtectedBranchDeletion, err)
+	_, err = admin.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "gbbranch", &BranchProtectionRequest{})
+	assert.NoError(t, err)
+	_, err = gbService.WithActor("orgadmin").DeleteBranch("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.NoError(t, err)
+}
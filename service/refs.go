@@ -0,0 +1,134 @@
+package service
+
+import (
+	"gbserver/models"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// branchRefRegexp matches the refs/heads/<branch> refs branches are created and updated with.
+var branchRefRegexp = regexp.MustCompile(`^refs/heads/([^/]+)$`)
+
+type UpdateRefRequest struct {
+	SHA   string `json:"sha"`
+	Force bool   `json:"force"`
+	//'{"sha":"aa218f56b14c9653891f9e74264a383fa43fefbd","force":true}'
+}
+
+// branchOfRef is the branch a refs/heads/<branch> ref names.
+func branchOfRef(ref string) (string, error) {
+	matches := branchRefRegexp.FindStringSubmatch(ref)
+	if len(matches) < 2 {
+		return "", ErrInvalidBranchName
+	}
+	return matches[1], nil
+}
+
+func refResponse(branch *models.Branch) CreateBranchResponse {
+	return CreateBranchResponse{Ref: "refs/heads/" + branch.Name, NodeID: branch.NodeID, URL: branch.URL,
+		Object: CreateBranchObjectResponse{Type: "commit", SHA: branch.CommitInfo.SHA, URL: branch.CommitInfo.URL}}
+}
+
+// refsOf lists the refs of the repo starting with prefix, sorted by name. Caller must hold the store lock.
+func (g *GbService) refsOf(repoKey, prefix string) []CreateBranchResponse {
+	refList := []CreateBranchResponse{}
+	for _, branchName := range g.GbStoreInstance.Repos[repoKey].Branches {
+		if branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]; branch != nil && strings.HasPrefix("refs/heads/"+branchName, prefix) {
+			refList = append(refList, refResponse(branch))
+		}
+	}
+	for _, tagName := range g.GbStoreInstance.Repos[repoKey].Tags {
+		if tag := g.GbStoreInstance.Tags[repoKey+"/"+tagName]; tag != nil && strings.HasPrefix("refs/tags/"+tagName, prefix) {
+			owner, repoName := g.GbStoreInstance.Repos[repoKey].UserName, g.GbStoreInstance.Repos[repoKey].Name
+			refList = append(refList, g.tagRefResponse(owner, repoName, tag, g.tagObjectType(repoKey, tag.SHA)))
+		}
+	}
+	slices.SortFunc(refList, func(a, b CreateBranchResponse) int { return strings.Compare(a.Ref, b.Ref) })
+	return refList
+}
+
+// get /repos/{org}/{owner}/{repo}/git/refs, get /repos/{org}/{owner}/{repo}/git/matching-refs/{ref}
+// ref is the part after refs/, e.g. heads/feature, empty lists every ref.
+func (g *GbService) ListRefs(orgName, owner, repoName, ref string) ([]CreateBranchResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return []CreateBranchResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	return g.refsOf(orgName+"/"+owner+"/"+repoName, "refs/"+ref), nil
+}
+
+// get /repos/{org}/{owner}/{repo}/git/ref/{ref}
+func (g *GbService) GetRef(orgName, owner, repoName, ref string) (CreateBranchResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CreateBranchResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	for _, refResp := range g.refsOf(orgName+"/"+owner+"/"+repoName, "refs/"+ref) {
+		if refResp.Ref == "refs/"+ref {
+			return refResp, nil
+		}
+	}
+	return CreateBranchResponse{}, ErrRefNotFound
+}
+
+// patch /repos/{org}/{owner}/{repo}/git/refs/heads/{branch}
+// Without git there is no history to tell a fast-forward from a forced update, every move is taken.
+func (g *GbService) UpdateRef(orgName, owner, repoName, ref string, refReq *UpdateRefRequest) (CreateBranchResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return CreateBranchResponse{}, err
+	}
+	branchName, err := branchOfRef("refs/" + ref)
+	if err != nil {
+		return CreateBranchResponse{}, err
+	}
+	if refReq.SHA == "" {
+		return CreateBranchResponse{}, ErrNoCommitForSHA
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	branch, exists := g.GbStoreInstance.Branches[repoKey+"/"+branchName]
+	if !exists {
+		return CreateBranchResponse{}, ErrRefNotFound
+	}
+	before := branch.CommitInfo.SHA
+	if g.Git != nil {
+		if !g.Git.CommitExists(repoKey, refReq.SHA) {
+			return CreateBranchResponse{}, ErrNoCommitForSHA
+		}
+		fastForward, err := g.Git.IsAncestor(repoKey, before, refReq.SHA)
+		if err != nil {
+			return CreateBranchResponse{}, err
+		}
+		if !fastForward && !refReq.Force {
+			return CreateBranchResponse{}, ErrNotFastForward
+		}
+	}
+	err = g.checkRefUpdate(orgName, owner, repoName, branchName, before, refReq.SHA)
+	if err != nil {
+		return CreateBranchResponse{}, err
+	}
+	if before == refReq.SHA {
+		return refResponse(branch), nil
+	}
+	if g.Git != nil {
+		err = g.Git.UpdateRef(repoKey, "refs/heads/"+branchName, refReq.SHA, before)
+		if err != nil {
+			return CreateBranchResponse{}, err
+		}
+	}
+	branch.CommitInfo = commitDetails(owner, repoName, refReq.SHA)
+	g.refreshBranchPRStats(repoKey, branchName)
+	g.emitPush(orgName, owner, repoName, branchName, before, refReq.SHA)
+	if pr, exists := g.GbStoreInstance.PullRequests[branch.PullRequestID]; exists && pr.State == "open" {
+		g.emitPullRequest("synchronize", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), nil)
+	}
+	return refResponse(branch), g.persist()
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"gbserver/gitstore"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefs(t *testing.T) {
+	gbService := newTestService(t)
+
+	listTests := []struct {
+		name     string
+		prefix   string
+		wantRefs []string
+	}{
+		{name: "Test all refs", prefix: "", wantRefs: []string{"refs/heads/gbbranch", "refs/heads/master"}},
+		{name: "Test branch prefix", prefix: "heads/gb", wantRefs: []string{"refs/heads/gbbranch"}},
+		{name: "Test no tags", prefix: "tags/", wantRefs: []string{}},
+	}
+	for _, tt := range listTests {
+		refs, err := gbService.ListRefs("gborg", "gbuser", "gbrepo", tt.prefix)
+		assert.NoError(t, err, tt.name)
+		gotRefs := []string{}
+		for _, ref := range refs {
+			gotRefs = append(gotRefs, ref.Ref)
+		}
+		assert.Equal(t, tt.wantRefs, gotRefs, tt.name)
+	}
+	_, err := gbService.GetRef("gborg", "gbuser", "gbrepo", "heads/gb")
+	assert.Equal(t, ErrRefNotFound, err)
+
+	_, err = gbService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/nosuchbranch", &UpdateRefRequest{SHA: "7638417db6d59f3c431d3e1f261cc637155684cd"})
+	assert.Equal(t, ErrRefNotFound, err)
+	_, err = gbService.UpdateRef("gborg", "gbuser", "gbrepo", "tags/v1", &UpdateRefRequest{SHA: "7638417db6d59f3c431d3e1f261cc637155684cd"})
+	assert.Equal(t, ErrInvalidBranchName, err)
+	moved, err := gbService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/master", &UpdateRefRequest{SHA: "7638417db6d59f3c431d3e1f261cc637155684cd"})
+	assert.NoError(t, err)
+	assert.Equal(t, "7638417db6d59f3c431d3e1f261cc637155684cd", moved.Object.SHA)
+	master, _ := gbService.GetRef("gborg", "gbuser", "gbrepo", "heads/master")
+	assert.Equal(t, moved, master)
+
+	// only refs that start with refs/heads/ name a branch.
+	for _, ref := range []string{"refs/tags/refs/heads/x", "heads/x", "x/refs/heads/x", "refs/heads/a/b"} {
+		_, err = branchOfRef(ref)
+		assert.Equal(t, ErrInvalidBranchName, err, ref)
+	}
+	_, err = gbService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "x/refs/heads/x", SHA: "7638417db6d59f3c431d3e1f261cc637155684cd"})
+	assert.Equal(t, ErrInvalidBranchName, err)
+}
+
+func TestGitBackedUpdateRef(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	sig := gitstore.Signature{Name: "gbuser", Email: "gbuser@gbserver.com"}
+	base := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	next, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "next.txt", Content: []byte("next\n")}}, "Next", sig, sig)
+	assert.NoError(t, err)
+	other, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "other.txt", Content: []byte("other\n")}}, "Other", sig, sig)
+	assert.NoError(t, err)
+
+	_, err = gitService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/master", &UpdateRefRequest{SHA: "0123456789012345678901234567890123456789"})
+	assert.Equal(t, ErrNoCommitForSHA, err)
+	_, err = gitService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/master", &UpdateRefRequest{SHA: next})
+	assert.NoError(t, err)
+	_, err = gitService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/master", &UpdateRefRequest{SHA: other})
+	assert.Equal(t, ErrNotFastForward, err)
+
+	// protection refuses the forced update too.
+	_, err = gitService.UpdateBranchProtection("gborg", "gbuser", "gbrepo", "master", &BranchProtectionRequest{})
+	assert.NoError(t, err)
+	_, err = gitService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/master", &UpdateRefRequest{SHA: other, Force: true})
+	assert.Equal(t, ErrProtectedBranchForcePush, err)
+	assert.NoError(t, gitService.DeleteBranchProtection("gborg", "gbuser", "gbrepo", "master"))
+	forced, err := gitService.UpdateRef("gborg", "gbuser", "gbrepo", "heads/master", &UpdateRefRequest{SHA: other, Force: true})
+	assert.NoError(t, err)
+	assert.Equal(t, other, forced.Object.SHA)
+	refs, _ := gitService.Git.Refs(repoKey, "refs/heads/master")
+	assert.Equal(t, other, refs["refs/heads/master"])
+}
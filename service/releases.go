@@ -0,0 +1,561 @@
+package service
+
+import (
+	"gbserver/gitstore"
+	"gbserver/models"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleaseRequest creates a release, on update fields left out are kept.
+type ReleaseRequest struct {
+	TagName         *string `json:"tag_name"`
+	TargetCommitish *string `json:"target_commitish"`
+	Name            *string `json:"name"`
+	Body            *string `json:"body"`
+	Draft           *bool   `json:"draft"`
+	Prerelease      *bool   `json:"prerelease"`
+	//'{"tag_name":"v1.0.0","target_commitish":"master","name":"v1.0.0","body":"Description of the release","draft":false,"prerelease":false}'
+}
+
+type ReleaseResponse struct {
+	URL             string                 `json:"url"`
+	HTMLURL         string                 `json:"html_url"`
+	AssetsURL       string                 `json:"assets_url"`
+	UploadURL       string                 `json:"upload_url"`
+	TarballURL      string                 `json:"tarball_url"`
+	ZipballURL      string                 `json:"zipball_url"`
+	ID              int                    `json:"id"`
+	NodeID          string                 `json:"node_id"`
+	TagName         string                 `json:"tag_name"`
+	TargetCommitish string                 `json:"target_commitish"`
+	Name            string                 `json:"name"`
+	Body            string                 `json:"body"`
+	Draft           bool                   `json:"draft"`
+	Prerelease      bool                   `json:"prerelease"`
+	CreatedAt       string                 `json:"created_at"`
+	PublishedAt     *string                `json:"published_at"`
+	Author          *OwnerInfo             `json:"author"`
+	Assets          []ReleaseAssetResponse `json:"assets"`
+}
+
+// ReleaseAssetRequest renames or relabels an asset.
+type ReleaseAssetRequest struct {
+	Name  string  `json:"name"`
+	Label *string `json:"label"`
+	//'{"name":"gbserver-linux-amd64.tar.gz","label":"Linux build"}'
+}
+
+type ReleaseAssetResponse struct {
+	URL                string     `json:"url"`
+	BrowserDownloadURL string     `json:"browser_download_url"`
+	ID                 int        `json:"id"`
+	NodeID             string     `json:"node_id"`
+	Name               string     `json:"name"`
+	Label              string     `json:"label"`
+	State              string     `json:"state"`
+	ContentType        string     `json:"content_type"`
+	Size               int64      `json:"size"`
+	DownloadCount      int        `json:"download_count"`
+	CreatedAt          string     `json:"created_at"`
+	UpdatedAt          string     `json:"updated_at"`
+	Uploader           *OwnerInfo `json:"uploader"`
+}
+
+func (g *GbService) buildAssetResponse(orgName, owner, repoName string, release *models.Release, asset *models.ReleaseAsset) ReleaseAssetResponse {
+	assetResp := ReleaseAssetResponse{URL: repoAPIURL(owner, repoName) + "/releases/assets/" + strconv.Itoa(asset.ID),
+		BrowserDownloadURL: htmlURL(owner, repoName) + "/releases/download/" + release.TagName + "/" + asset.Name,
+		ID:                 asset.ID, NodeID: asset.NodeID, Name: asset.Name, Label: asset.Label, State: "uploaded",
+		ContentType: asset.ContentType, Size: asset.Size, DownloadCount: asset.DownloadCount,
+		CreatedAt: asset.CreatedAt, UpdatedAt: asset.UpdatedAt}
+	if uploader := g.userByID(orgName, asset.UploaderID); uploader != nil {
+		uploaderInfo := ownerInfoOf(uploader)
+		assetResp.Uploader = &uploaderInfo
+	}
+	return assetResp
+}
+
+func (g *GbService) buildReleaseResponse(orgName, owner, repoName string, release *models.Release) ReleaseResponse {
+	releaseURL := repoAPIURL(owner, repoName) + "/releases/" + strconv.Itoa(release.ID)
+	releaseResp := ReleaseResponse{URL: releaseURL, HTMLURL: htmlURL(owner, repoName) + "/releases/tag/" + release.TagName,
+		AssetsURL: releaseURL + "/assets", UploadURL: releaseURL + "/assets{?name,label}",
+		TarballURL: repoAPIURL(owner, repoName) + "/tarball/" + release.TagName,
+		ZipballURL: repoAPIURL(owner, repoName) + "/zipball/" + release.TagName,
+		ID:         release.ID, NodeID: release.NodeID, TagName: release.TagName, TargetCommitish: release.TargetCommitish,
+		Name: release.Name, Body: release.Body, Draft: release.Draft, Prerelease: release.Prerelease,
+		CreatedAt: release.CreatedAt, Assets: []ReleaseAssetResponse{}}
+	if release.PublishedAt != "" {
+		publishedAt := release.PublishedAt
+		releaseResp.PublishedAt = &publishedAt
+	}
+	if author := g.userByID(orgName, release.AuthorID); author != nil {
+		authorInfo := ownerInfoOf(author)
+		releaseResp.Author = &authorInfo
+	}
+	for _, assetID := range release.AssetIDs {
+		if asset := g.GbStoreInstance.ReleaseAssets[strconv.Itoa(assetID)]; asset != nil {
+			releaseResp.Assets = append(releaseResp.Assets, g.buildAssetResponse(orgName, owner, repoName, release, asset))
+		}
+	}
+	return releaseResp
+}
+
+// findRelease looks a release of the repo up by id. Caller must hold the store lock.
+func (g *GbService) findRelease(repoKey string, releaseID int) (*models.Release, error) {
+	release, exists := g.GbStoreInstance.Releases[strconv.Itoa(releaseID)]
+	if !exists || release.RepoKey != repoKey {
+		return nil, ErrReleaseNotFound
+	}
+	return release, nil
+}
+
+// releaseOfTag is the release of a tag, nil if it has none. Caller must hold the store lock.
+func (g *GbService) releaseOfTag(repoKey, tagName string) *models.Release {
+	for _, release := range g.GbStoreInstance.Releases {
+		if release.RepoKey == repoKey && release.TagName == tagName {
+			return release
+		}
+	}
+	return nil
+}
+
+// findAsset looks an asset of a release of the repo up by id. Caller must hold the store lock.
+func (g *GbService) findAsset(repoKey string, assetID int) (*models.Release, *models.ReleaseAsset, error) {
+	asset, exists := g.GbStoreInstance.ReleaseAssets[strconv.Itoa(assetID)]
+	if !exists {
+		return nil, nil, ErrReleaseAssetNotFound
+	}
+	release, err := g.findRelease(repoKey, asset.ReleaseID)
+	if err != nil {
+		return nil, nil, ErrReleaseAssetNotFound
+	}
+	return release, asset, nil
+}
+
+// publishTag creates the tag of a release that is published, at its target_commitish, unless it exists.
+// Caller must hold the store lock.
+func (g *GbService) publishTag(orgName, owner, repoName string, release *models.Release) error {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	if _, exists := g.GbStoreInstance.Tags[repoKey+"/"+release.TagName]; exists {
+		return nil
+	}
+	sha, err := g.commitOf(repoKey, release.TargetCommitish)
+	if err != nil {
+		if g.Git != nil || !gitstore.IsSHA(release.TargetCommitish) {
+			return ErrCommitNotFound
+		}
+		// without git any SHA is taken, like it is for branches.
+		sha = release.TargetCommitish
+	}
+	if g.Git != nil {
+		err = g.Git.UpdateRef(repoKey, "refs/tags/"+release.TagName, sha, "")
+		if err != nil {
+			return err
+		}
+	}
+	g.addTag(repoKey, release.TagName, sha)
+	g.emitTag("create", orgName, owner, repoName, release.TagName, sha)
+	return nil
+}
+
+// post /repos/{org}/{owner}/{repo}/releases
+// A tag that does not exist yet is created at target_commitish (the default branch if not given) once the
+// release is published, drafts leave it for later.
+func (g *GbService) CreateRelease(orgName, owner, repoName string, releaseReq *ReleaseRequest) (ReleaseResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	if releaseReq.TagName == nil || *releaseReq.TagName == "" {
+		return ReleaseResponse{}, ErrReleaseTagRequired
+	}
+	if !validTagName(*releaseReq.TagName) {
+		return ReleaseResponse{}, ErrInvalidTagName
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	if g.releaseOfTag(repoKey, *releaseReq.TagName) != nil {
+		return ReleaseResponse{}, ErrReleaseAlreadyExists
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	release := &models.Release{NodeID: generateCustomID("NODEID"), RepoKey: repoKey, TagName: *releaseReq.TagName,
+		TargetCommitish: g.defaultBranch(repoKey), AuthorID: g.actingUser(orgName, owner).ID, CreatedAt: now,
+		AssetIDs: []int{}}
+	applyReleaseRequest(release, releaseReq)
+	if !release.Draft {
+		err = g.publishTag(orgName, owner, repoName, release)
+		if err != nil {
+			return ReleaseResponse{}, err
+		}
+		release.PublishedAt = now
+	}
+	g.GbStoreInstance.ReleasesCount++
+	release.ID = g.GbStoreInstance.ReleasesCount
+	g.GbStoreInstance.Releases[strconv.Itoa(release.ID)] = release
+
+	releaseResp := g.buildReleaseResponse(orgName, owner, repoName, release)
+	g.emitRelease("created", orgName, owner, repoName, releaseResp)
+	if !release.Draft {
+		g.emitRelease("published", orgName, owner, repoName, releaseResp)
+	}
+	return releaseResp, g.persist()
+}
+
+// applyReleaseRequest copies the fields given in the request onto the release.
+func applyReleaseRequest(release *models.Release, releaseReq *ReleaseRequest) {
+	if releaseReq.TagName != nil {
+		release.TagName = *releaseReq.TagName
+	}
+	if releaseReq.TargetCommitish != nil && *releaseReq.TargetCommitish != "" {
+		release.TargetCommitish = *releaseReq.TargetCommitish
+	}
+	if releaseReq.Name != nil {
+		release.Name = *releaseReq.Name
+	}
+	if releaseReq.Body != nil {
+		release.Body = *releaseReq.Body
+	}
+	if releaseReq.Draft != nil {
+		release.Draft = *releaseReq.Draft
+	}
+	if releaseReq.Prerelease != nil {
+		release.Prerelease = *releaseReq.Prerelease
+	}
+}
+
+// get /repos/{org}/{owner}/{repo}/releases
+func (g *GbService) ListReleases(orgName, owner, repoName string) ([]ReleaseResponse, error) {
+	releaseList := []ReleaseResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return releaseList, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	var releases []*models.Release
+	for _, release := range g.GbStoreInstance.Releases {
+		if release.RepoKey == repoKey {
+			releases = append(releases, release)
+		}
+	}
+	slices.SortFunc(releases, func(a, b *models.Release) int { return b.ID - a.ID })
+	for _, release := range releases {
+		releaseList = append(releaseList, g.buildReleaseResponse(orgName, owner, repoName, release))
+	}
+	return releaseList, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/{release_id}
+func (g *GbService) GetRelease(orgName, owner, repoName string, releaseID int) (ReleaseResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	release, err := g.findRelease(orgName+"/"+owner+"/"+repoName, releaseID)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	return g.buildReleaseResponse(orgName, owner, repoName, release), nil
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/tags/{tag}
+func (g *GbService) GetReleaseByTag(orgName, owner, repoName, tagName string) (ReleaseResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	release := g.releaseOfTag(orgName+"/"+owner+"/"+repoName, tagName)
+	if release == nil || release.Draft {
+		return ReleaseResponse{}, ErrReleaseNotFound
+	}
+	return g.buildReleaseResponse(orgName, owner, repoName, release), nil
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/latest
+// The latest release is the most recently published one that is neither a draft nor a prerelease.
+func (g *GbService) GetLatestRelease(orgName, owner, repoName string) (ReleaseResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	var latest *models.Release
+	for _, release := range g.GbStoreInstance.Releases {
+		if release.RepoKey != repoKey || release.Draft || release.Prerelease {
+			continue
+		}
+		if latest == nil || release.PublishedAt > latest.PublishedAt ||
+			(release.PublishedAt == latest.PublishedAt && release.ID > latest.ID) {
+			latest = release
+		}
+	}
+	if latest == nil {
+		return ReleaseResponse{}, ErrReleaseNotFound
+	}
+	return g.buildReleaseResponse(orgName, owner, repoName, latest), nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/releases/{release_id}
+func (g *GbService) UpdateRelease(orgName, owner, repoName string, releaseID int, releaseReq *ReleaseRequest) (ReleaseResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	if releaseReq.TagName != nil && !validTagName(*releaseReq.TagName) {
+		return ReleaseResponse{}, ErrInvalidTagName
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	release, err := g.findRelease(repoKey, releaseID)
+	if err != nil {
+		return ReleaseResponse{}, err
+	}
+	if releaseReq.TagName != nil {
+		if other := g.releaseOfTag(repoKey, *releaseReq.TagName); other != nil && other != release {
+			return ReleaseResponse{}, ErrReleaseAlreadyExists
+		}
+	}
+	updated := *release
+	applyReleaseRequest(&updated, releaseReq)
+	published := release.Draft && !updated.Draft
+	if !updated.Draft && (published || updated.TagName != release.TagName) {
+		err = g.publishTag(orgName, owner, repoName, &updated)
+		if err != nil {
+			return ReleaseResponse{}, err
+		}
+	}
+	if published {
+		updated.PublishedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	*release = updated
+
+	releaseResp := g.buildReleaseResponse(orgName, owner, repoName, release)
+	g.emitRelease("edited", orgName, owner, repoName, releaseResp)
+	if published {
+		g.emitRelease("published", orgName, owner, repoName, releaseResp)
+	}
+	return releaseResp, g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/releases/{release_id}
+// The tag of the release is kept, like github does.
+func (g *GbService) DeleteRelease(orgName, owner, repoName string, releaseID int) error {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	release, err := g.findRelease(orgName+"/"+owner+"/"+repoName, releaseID)
+	if err != nil {
+		return err
+	}
+	releaseResp := g.buildReleaseResponse(orgName, owner, repoName, release)
+	g.removeRelease(release)
+	g.emitRelease("deleted", orgName, owner, repoName, releaseResp)
+	return g.persist()
+}
+
+// removeRelease drops a release with its assets and their content. Caller must hold the store lock.
+func (g *GbService) removeRelease(release *models.Release) {
+	for _, assetID := range release.AssetIDs {
+		g.removeAsset(strconv.Itoa(assetID))
+	}
+	delete(g.GbStoreInstance.Releases, strconv.Itoa(release.ID))
+}
+
+// removeAsset drops an asset and its content. Caller must hold the store lock.
+func (g *GbService) removeAsset(assetID string) {
+	asset, exists := g.GbStoreInstance.ReleaseAssets[assetID]
+	if !exists {
+		return
+	}
+	if g.Assets != nil {
+		g.Assets.Remove(asset.NodeID)
+	}
+	delete(g.GbStoreInstance.ReleaseAssets, assetID)
+}
+
+// deleteRepoReleases drops the releases of a deleted repo. Caller must hold the store lock.
+func (g *GbService) deleteRepoReleases(repoKey string) {
+	for _, release := range g.GbStoreInstance.Releases {
+		if release.RepoKey == repoKey {
+			g.removeRelease(release)
+		}
+	}
+}
+
+// post /repos/{org}/{owner}/{repo}/releases/{release_id}/assets?name=&label=
+// The content is written before the store is locked, so a large upload does not hold up other requests.
+func (g *GbService) UploadReleaseAsset(orgName, owner, repoName string, releaseID int, name, label, contentType string, content io.Reader) (ReleaseAssetResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+	if g.Assets == nil {
+		return ReleaseAssetResponse{}, ErrAssetsDisabled
+	}
+	if name == "" {
+		return ReleaseAssetResponse{}, ErrReleaseAssetNameRequired
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	// check up front so a refused upload is not read at all.
+	g.GbStoreInstance.MU.RLock()
+	err = g.checkAssetName(repoKey, releaseID, name, 0)
+	g.GbStoreInstance.MU.RUnlock()
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+
+	nodeID := generateCustomID("NODEID")
+	size, err := g.Assets.Save(nodeID, content)
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	err = g.checkAssetName(repoKey, releaseID, name, 0)
+	if err != nil {
+		g.Assets.Remove(nodeID)
+		return ReleaseAssetResponse{}, err
+	}
+	release := g.GbStoreInstance.Releases[strconv.Itoa(releaseID)]
+	now := time.Now().UTC().Format(time.RFC3339)
+	g.GbStoreInstance.AssetsCount++
+	asset := &models.ReleaseAsset{ID: g.GbStoreInstance.AssetsCount, NodeID: nodeID, ReleaseID: releaseID, Name: name,
+		Label: label, ContentType: contentType, Size: size, UploaderID: g.actingUser(orgName, owner).ID,
+		CreatedAt: now, UpdatedAt: now}
+	g.GbStoreInstance.ReleaseAssets[strconv.Itoa(asset.ID)] = asset
+	release.AssetIDs = append(release.AssetIDs, asset.ID)
+	return g.buildAssetResponse(orgName, owner, repoName, release, asset), g.persist()
+}
+
+// checkAssetName makes sure the release exists and has no other asset (than assetID) called name.
+// Caller must hold the store lock.
+func (g *GbService) checkAssetName(repoKey string, releaseID int, name string, assetID int) error {
+	release, err := g.findRelease(repoKey, releaseID)
+	if err != nil {
+		return err
+	}
+	for _, otherID := range release.AssetIDs {
+		if other := g.GbStoreInstance.ReleaseAssets[strconv.Itoa(otherID)]; other != nil && otherID != assetID && other.Name == name {
+			return ErrReleaseAssetAlreadyExists
+		}
+	}
+	return nil
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/{release_id}/assets
+func (g *GbService) ListReleaseAssets(orgName, owner, repoName string, releaseID int) ([]ReleaseAssetResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return []ReleaseAssetResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	release, err := g.findRelease(orgName+"/"+owner+"/"+repoName, releaseID)
+	if err != nil {
+		return []ReleaseAssetResponse{}, err
+	}
+	return g.buildReleaseResponse(orgName, owner, repoName, release).Assets, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/assets/{asset_id}
+func (g *GbService) GetReleaseAsset(orgName, owner, repoName string, assetID int) (ReleaseAssetResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	release, asset, err := g.findAsset(orgName+"/"+owner+"/"+repoName, assetID)
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+	return g.buildAssetResponse(orgName, owner, repoName, release, asset), nil
+}
+
+// get /repos/{org}/{owner}/{repo}/releases/assets/{asset_id} with Accept: application/octet-stream
+// Counts the download, the caller closes the content.
+func (g *GbService) DownloadReleaseAsset(orgName, owner, repoName string, assetID int) (ReleaseAssetResponse, io.ReadCloser, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseAssetResponse{}, nil, err
+	}
+	if g.Assets == nil {
+		return ReleaseAssetResponse{}, nil, ErrAssetsDisabled
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	release, asset, err := g.findAsset(orgName+"/"+owner+"/"+repoName, assetID)
+	if err != nil {
+		return ReleaseAssetResponse{}, nil, err
+	}
+	content, err := g.Assets.Open(asset.NodeID)
+	if err != nil {
+		return ReleaseAssetResponse{}, nil, ErrReleaseAssetNotFound
+	}
+	asset.DownloadCount++
+	return g.buildAssetResponse(orgName, owner, repoName, release, asset), content, g.persist()
+}
+
+// patch /repos/{org}/{owner}/{repo}/releases/assets/{asset_id}
+func (g *GbService) UpdateReleaseAsset(orgName, owner, repoName string, assetID int, assetReq *ReleaseAssetRequest) (ReleaseAssetResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	release, asset, err := g.findAsset(repoKey, assetID)
+	if err != nil {
+		return ReleaseAssetResponse{}, err
+	}
+	if name := strings.TrimSpace(assetReq.Name); name != "" {
+		err = g.checkAssetName(repoKey, release.ID, name, asset.ID)
+		if err != nil {
+			return ReleaseAssetResponse{}, err
+		}
+		asset.Name = name
+	}
+	if assetReq.Label != nil {
+		asset.Label = *assetReq.Label
+	}
+	asset.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return g.buildAssetResponse(orgName, owner, repoName, release, asset), g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/releases/assets/{asset_id}
+func (g *GbService) DeleteReleaseAsset(orgName, owner, repoName string, assetID int) error {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	release, asset, err := g.findAsset(orgName+"/"+owner+"/"+repoName, assetID)
+	if err != nil {
+		return err
+	}
+	release.AssetIDs = slices.DeleteFunc(release.AssetIDs, func(id int) bool { return id == asset.ID })
+	g.removeAsset(strconv.Itoa(asset.ID))
+	return g.persist()
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleases(t *testing.T) {
+	gbService := newTestService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	master := gbService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	tagName, draft, published := "v1.0.0", true, false
+
+	_, err := gbService.CreateRelease("gborg", "gbuser", "gbrepo", &ReleaseRequest{})
+	assert.Equal(t, ErrReleaseTagRequired, err)
+	release, err := gbService.CreateRelease("gborg", "gbuser", "gbrepo", &ReleaseRequest{TagName: &tagName, Draft: &draft})
+	assert.NoError(t, err)
+	assert.Equal(t, "master", release.TargetCommitish)
+	assert.Nil(t, release.PublishedAt)
+	assert.Equal(t, "gbuser", release.Author.Login)
+	// drafts leave the tag for later and are not found by tag or as latest.
+	assert.Empty(t, gbService.GbStoreInstance.Repos[repoKey].Tags)
+	_, err = gbService.GetReleaseByTag("gborg", "gbuser", "gbrepo", tagName)
+	assert.Equal(t, ErrReleaseNotFound, err)
+	_, err = gbService.GetLatestRelease("gborg", "gbuser", "gbrepo")
+	assert.Equal(t, ErrReleaseNotFound, err)
+	_, err = gbService.CreateRelease("gborg", "gbuser", "gbrepo", &ReleaseRequest{TagName: &tagName})
+	assert.Equal(t, ErrReleaseAlreadyExists, err)
+
+	release, err = gbService.UpdateRelease("gborg", "gbuser", "gbrepo", release.ID, &ReleaseRequest{Draft: &published})
+	assert.NoError(t, err)
+	assert.NotNil(t, release.PublishedAt)
+	assert.Equal(t, master, gbService.GbStoreInstance.Tags[repoKey+"/"+tagName].SHA)
+	byTag, err := gbService.GetReleaseByTag("gborg", "gbuser", "gbrepo", tagName)
+	assert.NoError(t, err)
+	assert.Equal(t, release.ID, byTag.ID)
+
+	preTag, prerelease := "v2.0.0-rc1", true
+	pre, err := gbService.CreateRelease("gborg", "gbuser", "gbrepo", &ReleaseRequest{TagName: &preTag, Prerelease: &prerelease})
+	assert.NoError(t, err)
+	latest, err := gbService.GetLatestRelease("gborg", "gbuser", "gbrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, release.ID, latest.ID)
+	releases, _ := gbService.ListReleases("gborg", "gbuser", "gbrepo")
+	assert.Equal(t, []int{pre.ID, release.ID}, []int{releases[0].ID, releases[1].ID})
+
+	// releases are kept apart per repo.
+	_, err = gbService.GetRelease("gborg", "gbuser", "gbrepo", pre.ID+1)
+	assert.Equal(t, ErrReleaseNotFound, err)
+	assert.NoError(t, gbService.DeleteRelease("gborg", "gbuser", "gbrepo", pre.ID))
+	_, err = gbService.GetRelease("gborg", "gbuser", "gbrepo", pre.ID)
+	assert.Equal(t, ErrReleaseNotFound, err)
+	// the tag stays.
+	assert.Contains(t, gbService.GbStoreInstance.Repos[repoKey].Tags, preTag)
+}
+
+func TestReleaseAssets(t *testing.T) {
+	gbService := newTestService(t)
+	tagName := "v1.0.0"
+	release, err := gbService.CreateRelease("gborg", "gbuser", "gbrepo", &ReleaseRequest{TagName: &tagName})
+	assert.NoError(t, err)
+
+	_, err = gbService.UploadReleaseAsset("gborg", "gbuser", "gbrepo", release.ID, "app.tar.gz", "", "", strings.NewReader("content"))
+	assert.Equal(t, ErrAssetsDisabled, err)
+	assets, err := NewAssetStore(t.TempDir())
+	assert.NoError(t, err)
+	gbService.Assets = assets
+
+	_, err = gbService.UploadReleaseAsset("gborg", "gbuser", "gbrepo", release.ID, "", "", "", strings.NewReader("content"))
+	assert.Equal(t, ErrReleaseAssetNameRequired, err)
+	asset, err := gbService.UploadReleaseAsset("gborg", "gbuser", "gbrepo", release.ID, "app.tar.gz", "Linux build", "application/gzip", strings.NewReader("content"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), asset.Size)
+	assert.Equal(t, "application/gzip", asset.ContentType)
+	_, err = gbService.UploadReleaseAsset("gborg", "gbuser", "gbrepo", release.ID, "app.tar.gz", "", "", strings.NewReader("other"))
+	assert.Equal(t, ErrReleaseAssetAlreadyExists, err)
+	_, err = gbService.UploadReleaseAsset("gborg", "gbuser", "gbrepo", release.ID+1, "app.tar.gz", "", "", strings.NewReader("other"))
+	assert.Equal(t, ErrReleaseNotFound, err)
+
+	downloaded, content, err := gbService.DownloadReleaseAsset("gborg", "gbuser", "gbrepo", asset.ID)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(content)
+	content.Close()
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, 1, downloaded.DownloadCount)
+
+	label := ""
+	renamed, err := gbService.UpdateReleaseAsset("gborg", "gbuser", "gbrepo", asset.ID, &ReleaseAssetRequest{Name: "app-linux.tar.gz", Label: &label})
+	assert.NoError(t, err)
+	assert.Equal(t, "app-linux.tar.gz", renamed.Name)
+	assert.Empty(t, renamed.Label)
+	listed, _ := gbService.ListReleaseAssets("gborg", "gbuser", "gbrepo", release.ID)
+	assert.Equal(t, []ReleaseAssetResponse{renamed}, listed)
+
+	// deleting the release takes the asset content with it.
+	assert.NoError(t, gbService.DeleteRelease("gborg", "gbuser", "gbrepo", release.ID))
+	_, err = gbService.GetReleaseAsset("gborg", "gbuser", "gbrepo", asset.ID)
+	assert.Equal(t, ErrReleaseAssetNotFound, err)
+	_, err = assets.Open(asset.NodeID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,777 @@
+package service
+
+import (
+	"gbserver/gitstore"
+	"gbserver/models"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// reviewStates maps the event of a review request to the state the review ends up in.
+var reviewStates = map[string]string{"APPROVE": "APPROVED", "REQUEST_CHANGES": "CHANGES_REQUESTED", "COMMENT": "COMMENTED"}
+
+type ReviewResponse struct {
+	ID             int       `json:"id"`
+	NodeID         string    `json:"node_id"`
+	User           OwnerInfo `json:"user"`
+	Body           string    `json:"body"`
+	State          string    `json:"state"`
+	HTMLURL        string    `json:"html_url"`
+	PullRequestURL string    `json:"pull_request_url"`
+	CommitID       string    `json:"commit_id"`
+	SubmittedAt    string    `json:"submitted_at,omitempty"`
+}
+
+// ReviewRequest creates a review, or submits a pending one. An empty Event leaves the review PENDING.
+type ReviewRequest struct {
+	CommitID string                 `json:"commit_id"`
+	Body     string                 `json:"body"`
+	Event    string                 `json:"event"`
+	Comments []ReviewCommentRequest `json:"comments"`
+	//'{"body":"Looks good","event":"APPROVE","comments":[{"path":"README.md","position":1,"body":"typo"}]}'
+}
+
+// ReviewCommentRequest is a comment on the diff of a pull request. It is placed either by Position, the line of
+// the diff, or by Line and Side of the file. A reply only needs InReplyTo and Body.
+type ReviewCommentRequest struct {
+	Body      string `json:"body"`
+	Path      string `json:"path"`
+	Position  int    `json:"position"`
+	Line      int    `json:"line"`
+	Side      string `json:"side"`
+	CommitID  string `json:"commit_id"`
+	InReplyTo int    `json:"in_reply_to"`
+}
+
+type ReviewCommentResponse struct {
+	ID                  int       `json:"id"`
+	NodeID              string    `json:"node_id"`
+	URL                 string    `json:"url"`
+	PullRequestReviewID int       `json:"pull_request_review_id"`
+	Path                string    `json:"path"`
+	Position            *int      `json:"position"`
+	OriginalPosition    *int      `json:"original_position"`
+	Line                *int      `json:"line"`
+	OriginalLine        *int      `json:"original_line"`
+	Side                string    `json:"side"`
+	CommitID            string    `json:"commit_id"`
+	OriginalCommitID    string    `json:"original_commit_id"`
+	InReplyToID         int       `json:"in_reply_to_id,omitempty"`
+	User                OwnerInfo `json:"user"`
+	Body                string    `json:"body"`
+	CreatedAt           string    `json:"created_at"`
+	UpdatedAt           string    `json:"updated_at"`
+	HTMLURL             string    `json:"html_url"`
+	PullRequestURL      string    `json:"pull_request_url"`
+}
+
+type DismissReviewRequest struct {
+	Message string `json:"message"`
+	Event   string `json:"event"`
+}
+
+// ReviewersRequest adds or removes requested reviewers, the store has no teams so TeamReviewers must be empty.
+type ReviewersRequest struct {
+	Reviewers     []string `json:"reviewers"`
+	TeamReviewers []string `json:"team_reviewers"`
+}
+
+type RequestedReviewersResponse struct {
+	Users []OwnerInfo `json:"users"`
+	Teams []any       `json:"teams"`
+}
+
+// findPR looks a pull request up by its number. Caller must hold the store lock.
+func (g *GbService) findPR(repoKey, pullNumber string) (*models.PullRequest, error) {
+	prID := hasher(repoKey + "/" + pullNumber)
+	if !slices.Contains(g.GbStoreInstance.Repos[repoKey].PrIDs, prID) {
+		return nil, ErrPRNotFound
+	}
+	return g.GbStoreInstance.PullRequests[prID], nil
+}
+
+// visibleReview tells if the acting user may see a review, pending reviews are only seen by their author.
+// Caller must hold the store lock.
+func (g *GbService) visibleReview(orgName, owner string, review *models.Review) bool {
+	return review.State != "PENDING" || review.AuthorID == g.actingUser(orgName, owner).ID
+}
+
+// findReview looks a review of the pull request up. Caller must hold the store lock.
+func (g *GbService) findReview(orgName, owner string, pr *models.PullRequest, reviewID int) (*models.Review, error) {
+	review, exists := g.GbStoreInstance.Reviews[strconv.Itoa(reviewID)]
+	if !exists || review.PullRequestID != pr.ID || !g.visibleReview(orgName, owner, review) {
+		return nil, ErrReviewNotFound
+	}
+	return review, nil
+}
+
+// findReviewComment looks a review comment up and checks it belongs to a pull request of the repo.
+// Caller must hold the store lock.
+func (g *GbService) findReviewComment(orgName, owner, repoName string, commentID int) (*models.ReviewComment, *models.PullRequest, error) {
+	comment, exists := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentID)]
+	if !exists || !slices.Contains(g.GbStoreInstance.Repos[orgName+"/"+owner+"/"+repoName].PrIDs, comment.PullRequestID) {
+		return nil, nil, ErrReviewCommentNotFound
+	}
+	if review := g.GbStoreInstance.Reviews[strconv.Itoa(comment.ReviewID)]; review != nil && !g.visibleReview(orgName, owner, review) {
+		return nil, nil, ErrReviewCommentNotFound
+	}
+	return comment, g.GbStoreInstance.PullRequests[comment.PullRequestID], nil
+}
+
+// buildReviewResponse renders a stored review. Caller must hold the store lock.
+func (g *GbService) buildReviewResponse(orgName, owner, repoName string, pr *models.PullRequest, review *models.Review) ReviewResponse {
+	reviewResp := ReviewResponse{ID: review.ID, NodeID: review.NodeID, Body: review.Body, State: review.State,
+		HTMLURL:        htmlURL(owner, repoName) + "/pull/" + strconv.Itoa(pr.Number) + "#pullrequestreview-" + strconv.Itoa(review.ID),
+		PullRequestURL: pr.URL, CommitID: review.CommitID, SubmittedAt: review.SubmittedAt}
+	if author := g.userByID(orgName, review.AuthorID); author != nil {
+		reviewResp.User = ownerInfoOf(author)
+	}
+	return reviewResp
+}
+
+// buildReviewCommentResponse renders a stored review comment. Caller must hold the store lock.
+func (g *GbService) buildReviewCommentResponse(orgName, owner, repoName string, pr *models.PullRequest, comment *models.ReviewComment) ReviewCommentResponse {
+	commentResp := ReviewCommentResponse{ID: comment.ID, NodeID: comment.NodeID,
+		URL:                 repoAPIURL(owner, repoName) + "/pulls/comments/" + strconv.Itoa(comment.ID),
+		PullRequestReviewID: comment.ReviewID, Path: comment.Path, Side: comment.Side, CommitID: comment.CommitID,
+		OriginalCommitID: comment.CommitID, InReplyToID: comment.InReplyToID, Body: comment.Body,
+		CreatedAt: comment.CreatedAt, UpdatedAt: comment.UpdatedAt,
+		HTMLURL:        htmlURL(owner, repoName) + "/pull/" + strconv.Itoa(pr.Number) + "#discussion_r" + strconv.Itoa(comment.ID),
+		PullRequestURL: pr.URL}
+	if comment.Position > 0 {
+		position := comment.Position
+		commentResp.Position, commentResp.OriginalPosition = &position, &position
+	}
+	if comment.Line > 0 {
+		line := comment.Line
+		commentResp.Line, commentResp.OriginalLine = &line, &line
+	}
+	if author := g.userByID(orgName, comment.AuthorID); author != nil {
+		commentResp.User = ownerInfoOf(author)
+	}
+	return commentResp
+}
+
+// headSHA is the commit the head branch of a pull request is at, "" once the branch is gone.
+// Caller must hold the store lock.
+func (g *GbService) headSHA(repoKey string, pr *models.PullRequest) string {
+	if headBranch, exists := g.GbStoreInstance.Branches[repoKey+"/"+headBranchOf(pr)]; exists {
+		return headBranch.CommitInfo.SHA
+	}
+	return ""
+}
+
+// changedPaths lists the files a pull request touches, nil without git. Caller must hold the store lock.
+func (g *GbService) changedPaths(repoKey string, pr *models.PullRequest) ([]string, error) {
+	baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+pr.ToBranch]
+	head := g.headSHA(repoKey, pr)
+	if g.Git == nil || baseBranch == nil || head == "" {
+		return nil, nil
+	}
+	mergeBase, err := g.Git.MergeBase(repoKey, baseBranch.CommitInfo.SHA, head)
+	if err != nil {
+		return nil, err
+	}
+	files, err := g.Git.Diff(repoKey, mergeBase, head)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths, nil
+}
+
+// newReviewComment checks a comment of the diff and stores it on the pull request. A reply takes its place in
+// the diff from the comment it answers. Caller must hold the store lock.
+func (g *GbService) newReviewComment(orgName, owner, repoName string, pr *models.PullRequest, reviewID int, commentReq ReviewCommentRequest, now string) (*models.ReviewComment, error) {
+	if strings.TrimSpace(commentReq.Body) == "" {
+		return nil, ErrCommentBodyRequired
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	comment := &models.ReviewComment{NodeID: generateCustomID("NODEID"), PullRequestID: pr.ID, ReviewID: reviewID,
+		AuthorID: g.actingUser(orgName, owner).ID, Body: commentReq.Body, CreatedAt: now, UpdatedAt: now}
+	if commentReq.InReplyTo != 0 {
+		parent, exists := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentReq.InReplyTo)]
+		if !exists || parent.PullRequestID != pr.ID {
+			return nil, ErrReviewCommentNotFound
+		}
+		// replies to a reply belong to the thread of the first comment.
+		if parent.InReplyToID != 0 {
+			comment.InReplyToID = parent.InReplyToID
+		} else {
+			comment.InReplyToID = parent.ID
+		}
+		comment.Path, comment.Position, comment.Line, comment.Side, comment.CommitID = parent.Path, parent.Position, parent.Line, parent.Side, parent.CommitID
+	} else {
+		if commentReq.Path == "" || (commentReq.Position <= 0 && commentReq.Line <= 0) {
+			return nil, ErrInvalidReviewComment
+		}
+		side := commentReq.Side
+		if side == "" {
+			side = "RIGHT"
+		}
+		if side != "LEFT" && side != "RIGHT" {
+			return nil, ErrInvalidReviewComment
+		}
+		paths, err := g.changedPaths(repoKey, pr)
+		if err != nil {
+			return nil, err
+		}
+		if paths != nil && !slices.Contains(paths, commentReq.Path) {
+			return nil, ErrInvalidReviewCommentPath
+		}
+		commitID := commentReq.CommitID
+		if commitID == "" {
+			commitID = g.headSHA(repoKey, pr)
+		}
+		comment.Path, comment.Position, comment.Line, comment.Side, comment.CommitID = commentReq.Path, commentReq.Position, commentReq.Line, side, commitID
+	}
+	g.GbStoreInstance.CommentsCount++
+	comment.ID = g.GbStoreInstance.CommentsCount
+	g.GbStoreInstance.ReviewComments[strconv.Itoa(comment.ID)] = comment
+	pr.ReviewCommentIDs = append(pr.ReviewCommentIDs, comment.ID)
+	return comment, nil
+}
+
+// reviewState checks the event a review is submitted with and returns the state it leads to. Caller must hold the store lock.
+func (g *GbService) reviewState(orgName, owner string, pr *models.PullRequest, event, body string, hasComments bool) (string, error) {
+	state, valid := reviewStates[event]
+	if !valid {
+		return "", ErrInvalidReviewEvent
+	}
+	if state != "COMMENTED" && g.actingUser(orgName, owner).ID == pr.AuthorID {
+		return "", ErrReviewOwnPR
+	}
+	if strings.TrimSpace(body) == "" && (state == "CHANGES_REQUESTED" || (state == "COMMENTED" && !hasComments)) {
+		return "", ErrReviewBodyRequired
+	}
+	return state, nil
+}
+
+// submitReview moves a review out of PENDING, which answers the request for a review by its author.
+// Caller must hold the store lock.
+func (g *GbService) submitReview(orgName, owner string, pr *models.PullRequest, review *models.Review, state, body string) {
+	review.Body = body
+	review.State = state
+	review.SubmittedAt = time.Now().UTC().Format(time.RFC3339)
+	pr.RequestedReviewers = removeElementByValue(pr.RequestedReviewers, g.actingUser(orgName, owner).LoginName)
+}
+
+// emitSubmittedReview sends the events of a submitted review and of the comments that came with it.
+// Caller must hold the store lock.
+func (g *GbService) emitSubmittedReview(orgName, owner, repoName string, pr *models.PullRequest, review *models.Review) {
+	prResp := g.buildPRResponse(orgName, owner, repoName, pr)
+	g.emitPullRequestReview("submitted", orgName, owner, repoName, prResp, g.buildReviewResponse(orgName, owner, repoName, pr, review), nil)
+	for _, commentID := range pr.ReviewCommentIDs {
+		if comment := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentID)]; comment != nil && comment.ReviewID == review.ID {
+			g.emitPullRequestReviewComment("created", orgName, owner, repoName, prResp,
+				g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment), nil)
+		}
+	}
+}
+
+// deleteReviewComments drops the comments of a pull request drop picks. Caller must hold the store lock.
+func (g *GbService) deleteReviewComments(pr *models.PullRequest, drop func(comment *models.ReviewComment) bool) {
+	pr.ReviewCommentIDs = slices.DeleteFunc(pr.ReviewCommentIDs, func(commentID int) bool {
+		comment := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentID)]
+		if comment == nil || !drop(comment) {
+			return false
+		}
+		delete(g.GbStoreInstance.ReviewComments, strconv.Itoa(commentID))
+		return true
+	})
+}
+
+// deletePRReviews drops the reviews and review comments of a deleted pull request. Caller must hold the store lock.
+func (g *GbService) deletePRReviews(pr *models.PullRequest) {
+	for _, reviewID := range pr.ReviewIDs {
+		delete(g.GbStoreInstance.Reviews, strconv.Itoa(reviewID))
+	}
+	for _, commentID := range pr.ReviewCommentIDs {
+		delete(g.GbStoreInstance.ReviewComments, strconv.Itoa(commentID))
+	}
+}
+
+// latestReviewStates is the state of the latest approval, change request or dismissal of every reviewer
+// by user id. Caller must hold the store lock.
+func (g *GbService) latestReviewStates(pr *models.PullRequest) map[int]string {
+	latest := map[int]string{}
+	for _, reviewID := range pr.ReviewIDs {
+		review := g.GbStoreInstance.Reviews[strconv.Itoa(reviewID)]
+		if review != nil && review.State != "PENDING" && review.State != "COMMENTED" {
+			latest[review.AuthorID] = review.State
+		}
+	}
+	return latest
+}
+
+// mergeableState tells if a pull request can be merged and why not, the way github's mergeable_state does:
+// dirty for conflicts, blocked while the latest review of any reviewer requests changes or the protection of
+// the base branch is not satisfied and unstable while statuses or check runs of the head are failing or not
+// done yet. Caller must hold the store lock.
+func (g *GbService) mergeableState(repoKey string, pr *models.PullRequest) (*bool, string) {
+	baseBranch := g.GbStoreInstance.Branches[repoKey+"/"+pr.ToBranch]
+	head := g.headSHA(repoKey, pr)
+	if pr.State != "open" || baseBranch == nil || head == "" {
+		return nil, "unknown"
+	}
+	mergeable := true
+	if g.Git != nil {
+		_, err := g.Git.MergeTree(repoKey, baseBranch.CommitInfo.SHA, head)
+		if err == gitstore.ErrMergeConflict {
+			mergeable = false
+			return &mergeable, "dirty"
+		}
+		if err != nil {
+			return nil, "unknown"
+		}
+	}
+	for _, state := range g.latestReviewStates(pr) {
+		if state == "CHANGES_REQUESTED" {
+			return &mergeable, "blocked"
+		}
+	}
+	if g.mergeBlocked(repoKey, pr, head, baseBranch.CommitInfo.SHA) != nil {
+		return &mergeable, "blocked"
+	}
+	if !g.commitPassing(repoKey, head) {
+		return &mergeable, "unstable"
+	}
+	return &mergeable, "clean"
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews
+func (g *GbService) ListReviews(orgName, owner, repoName, pullNumber string) ([]ReviewResponse, error) {
+	reviewList := []ReviewResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return reviewList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return reviewList, err
+	}
+	for _, reviewID := range pr.ReviewIDs {
+		if review := g.GbStoreInstance.Reviews[strconv.Itoa(reviewID)]; review != nil && g.visibleReview(orgName, owner, review) {
+			reviewList = append(reviewList, g.buildReviewResponse(orgName, owner, repoName, pr, review))
+		}
+	}
+	return reviewList, nil
+}
+
+// post /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews
+func (g *GbService) CreateReview(orgName, owner, repoName, pullNumber string, reviewReq *ReviewRequest) (ReviewResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if _, valid := reviewStates[reviewReq.Event]; reviewReq.Event != "" && !valid {
+		return ReviewResponse{}, ErrInvalidReviewEvent
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(repoKey, pullNumber)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	actor := g.actingUser(orgName, owner)
+	if reviewReq.Event == "" {
+		for _, reviewID := range pr.ReviewIDs {
+			if review := g.GbStoreInstance.Reviews[strconv.Itoa(reviewID)]; review != nil && review.State == "PENDING" && review.AuthorID == actor.ID {
+				return ReviewResponse{}, ErrReviewPending
+			}
+		}
+	}
+	state := "PENDING"
+	if reviewReq.Event != "" {
+		state, err = g.reviewState(orgName, owner, pr, reviewReq.Event, reviewReq.Body, len(reviewReq.Comments) > 0)
+		if err != nil {
+			return ReviewResponse{}, err
+		}
+	}
+	commitID := reviewReq.CommitID
+	if commitID == "" {
+		commitID = g.headSHA(repoKey, pr)
+	}
+
+	// a bad comment fails the whole review, the ones stored before it are dropped again.
+	reviewID := g.GbStoreInstance.ReviewsCount + 1
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, commentReq := range reviewReq.Comments {
+		commentReq.CommitID = commitID
+		_, err = g.newReviewComment(orgName, owner, repoName, pr, reviewID, commentReq, now)
+		if err != nil {
+			g.deleteReviewComments(pr, func(comment *models.ReviewComment) bool { return comment.ReviewID == reviewID })
+			return ReviewResponse{}, err
+		}
+	}
+	g.GbStoreInstance.ReviewsCount++
+	review := &models.Review{ID: reviewID, NodeID: generateCustomID("NODEID"), PullRequestID: pr.ID,
+		AuthorID: actor.ID, Body: reviewReq.Body, State: "PENDING", CommitID: commitID}
+	if state != "PENDING" {
+		g.submitReview(orgName, owner, pr, review, state, reviewReq.Body)
+	}
+	g.GbStoreInstance.Reviews[strconv.Itoa(review.ID)] = review
+	pr.ReviewIDs = append(pr.ReviewIDs, review.ID)
+
+	if review.State != "PENDING" {
+		g.emitSubmittedReview(orgName, owner, repoName, pr, review)
+	}
+	return g.buildReviewResponse(orgName, owner, repoName, pr, review), g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}
+func (g *GbService) GetReview(orgName, owner, repoName, pullNumber string, reviewID int) (ReviewResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	review, err := g.findReview(orgName, owner, pr, reviewID)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	return g.buildReviewResponse(orgName, owner, repoName, pr, review), nil
+}
+
+// put /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}
+func (g *GbService) UpdateReview(orgName, owner, repoName, pullNumber string, reviewID int, reviewReq *ReviewRequest) (ReviewResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if strings.TrimSpace(reviewReq.Body) == "" {
+		return ReviewResponse{}, ErrReviewBodyRequired
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	review, err := g.findReview(orgName, owner, pr, reviewID)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	changes := map[string]any{"body": map[string]string{"from": review.Body}}
+	review.Body = reviewReq.Body
+
+	reviewResp := g.buildReviewResponse(orgName, owner, repoName, pr, review)
+	if review.State != "PENDING" {
+		g.emitPullRequestReview("edited", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), reviewResp, changes)
+	}
+	return reviewResp, g.persist()
+}
+
+// post /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}/events
+func (g *GbService) SubmitReview(orgName, owner, repoName, pullNumber string, reviewID int, reviewReq *ReviewRequest) (ReviewResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	review, err := g.findReview(orgName, owner, pr, reviewID)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if review.State != "PENDING" {
+		return ReviewResponse{}, ErrReviewNotPending
+	}
+	hasComments := slices.ContainsFunc(pr.ReviewCommentIDs, func(commentID int) bool {
+		comment := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentID)]
+		return comment != nil && comment.ReviewID == review.ID
+	})
+	body := reviewReq.Body
+	if body == "" {
+		body = review.Body
+	}
+	state, err := g.reviewState(orgName, owner, pr, reviewReq.Event, body, hasComments)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	g.submitReview(orgName, owner, pr, review, state, body)
+	g.emitSubmittedReview(orgName, owner, repoName, pr, review)
+	return g.buildReviewResponse(orgName, owner, repoName, pr, review), g.persist()
+}
+
+// put /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}/dismissals
+func (g *GbService) DismissReview(orgName, owner, repoName, pullNumber string, reviewID int, dismissReq *DismissReviewRequest) (ReviewResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if strings.TrimSpace(dismissReq.Message) == "" {
+		return ReviewResponse{}, ErrDismissMessageRequired
+	}
+	if dismissReq.Event != "" && dismissReq.Event != "DISMISS" {
+		return ReviewResponse{}, ErrInvalidReviewEvent
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	review, err := g.findReview(orgName, owner, pr, reviewID)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if review.State != "APPROVED" && review.State != "CHANGES_REQUESTED" {
+		return ReviewResponse{}, ErrReviewNotDismissable
+	}
+	review.State = "DISMISSED"
+
+	reviewResp := g.buildReviewResponse(orgName, owner, repoName, pr, review)
+	g.emitPullRequestReview("dismissed", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), reviewResp, nil)
+	return reviewResp, g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}, only pending reviews can be deleted.
+func (g *GbService) DeleteReview(orgName, owner, repoName, pullNumber string, reviewID int) (ReviewResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	review, err := g.findReview(orgName, owner, pr, reviewID)
+	if err != nil {
+		return ReviewResponse{}, err
+	}
+	if review.State != "PENDING" {
+		return ReviewResponse{}, ErrReviewNotPending
+	}
+	reviewResp := g.buildReviewResponse(orgName, owner, repoName, pr, review)
+	g.deleteReviewComments(pr, func(comment *models.ReviewComment) bool { return comment.ReviewID == review.ID })
+	delete(g.GbStoreInstance.Reviews, strconv.Itoa(review.ID))
+	pr.ReviewIDs = slices.DeleteFunc(pr.ReviewIDs, func(id int) bool { return id == review.ID })
+	return reviewResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/reviews/{review_id}/comments
+func (g *GbService) ListReviewCommentsOfReview(orgName, owner, repoName, pullNumber string, reviewID int) ([]ReviewCommentResponse, error) {
+	commentList := []ReviewCommentResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return commentList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return commentList, err
+	}
+	review, err := g.findReview(orgName, owner, pr, reviewID)
+	if err != nil {
+		return commentList, err
+	}
+	for _, commentID := range pr.ReviewCommentIDs {
+		if comment := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentID)]; comment != nil && comment.ReviewID == review.ID {
+			commentList = append(commentList, g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment))
+		}
+	}
+	return commentList, nil
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/comments
+func (g *GbService) ListReviewComments(orgName, owner, repoName, pullNumber string) ([]ReviewCommentResponse, error) {
+	commentList := []ReviewCommentResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return commentList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return commentList, err
+	}
+	for _, commentID := range pr.ReviewCommentIDs {
+		comment := g.GbStoreInstance.ReviewComments[strconv.Itoa(commentID)]
+		if comment == nil {
+			continue
+		}
+		// comments of someone else's pending review are not published yet.
+		if review := g.GbStoreInstance.Reviews[strconv.Itoa(comment.ReviewID)]; review != nil && !g.visibleReview(orgName, owner, review) {
+			continue
+		}
+		commentList = append(commentList, g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment))
+	}
+	return commentList, nil
+}
+
+// post /repos/{org}/{owner}/{repo}/pulls/{pull_number}/comments
+// Like on github a comment outside of a review gets a COMMENTED review of its own.
+func (g *GbService) CreateReviewComment(orgName, owner, repoName, pullNumber string, commentReq *ReviewCommentRequest) (ReviewCommentResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	comment, err := g.newReviewComment(orgName, owner, repoName, pr, g.GbStoreInstance.ReviewsCount+1, *commentReq, now)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	g.GbStoreInstance.ReviewsCount++
+	review := &models.Review{ID: g.GbStoreInstance.ReviewsCount, NodeID: generateCustomID("NODEID"), PullRequestID: pr.ID,
+		AuthorID: comment.AuthorID, State: "COMMENTED", CommitID: comment.CommitID, SubmittedAt: now}
+	g.GbStoreInstance.Reviews[strconv.Itoa(review.ID)] = review
+	pr.ReviewIDs = append(pr.ReviewIDs, review.ID)
+
+	commentResp := g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment)
+	g.emitPullRequestReviewComment("created", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), commentResp, nil)
+	return commentResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/comments/{comment_id}
+func (g *GbService) GetReviewComment(orgName, owner, repoName string, commentID int) (ReviewCommentResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	comment, pr, err := g.findReviewComment(orgName, owner, repoName, commentID)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	return g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment), nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/pulls/comments/{comment_id}
+func (g *GbService) UpdateReviewComment(orgName, owner, repoName string, commentID int, commentReq *ReviewCommentRequest) (ReviewCommentResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	if strings.TrimSpace(commentReq.Body) == "" {
+		return ReviewCommentResponse{}, ErrCommentBodyRequired
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	comment, pr, err := g.findReviewComment(orgName, owner, repoName, commentID)
+	if err != nil {
+		return ReviewCommentResponse{}, err
+	}
+	changes := map[string]any{"body": map[string]string{"from": comment.Body}}
+	comment.Body = commentReq.Body
+	comment.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	commentResp := g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment)
+	g.emitPullRequestReviewComment("edited", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), commentResp, changes)
+	return commentResp, g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/pulls/comments/{comment_id}
+func (g *GbService) DeleteReviewComment(orgName, owner, repoName string, commentID int) (bool, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return false, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	comment, pr, err := g.findReviewComment(orgName, owner, repoName, commentID)
+	if err != nil {
+		return false, err
+	}
+	commentResp := g.buildReviewCommentResponse(orgName, owner, repoName, pr, comment)
+	g.deleteReviewComments(pr, func(other *models.ReviewComment) bool { return other.ID == commentID })
+	g.emitPullRequestReviewComment("deleted", orgName, owner, repoName, g.buildPRResponse(orgName, owner, repoName, pr), commentResp, nil)
+	return true, g.persist()
+}
+
+// requestedReviewers renders the logins of a pull request's requested reviewers. Caller must hold the store lock.
+func (g *GbService) requestedReviewers(orgName string, pr *models.PullRequest) []OwnerInfo {
+	reviewers := []OwnerInfo{}
+	for _, login := range pr.RequestedReviewers {
+		if user, exists := g.GbStoreInstance.Users[orgName+"/"+login]; exists {
+			reviewers = append(reviewers, ownerInfoOf(user))
+		}
+	}
+	return reviewers
+}
+
+// get /repos/{org}/{owner}/{repo}/pulls/{pull_number}/requested_reviewers
+func (g *GbService) ListRequestedReviewers(orgName, owner, repoName, pullNumber string) (RequestedReviewersResponse, error) {
+	reviewersResp := RequestedReviewersResponse{Users: []OwnerInfo{}, Teams: []any{}}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return reviewersResp, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return reviewersResp, err
+	}
+	reviewersResp.Users = g.requestedReviewers(orgName, pr)
+	return reviewersResp, nil
+}
+
+// post (request) and delete (remove) /repos/{org}/{owner}/{repo}/pulls/{pull_number}/requested_reviewers
+// Reviewers must be members of the org other than the author of the pull request.
+func (g *GbService) RequestReviewers(orgName, owner, repoName, pullNumber string, reviewersReq *ReviewersRequest, remove bool) (PRResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return PRResponse{}, err
+	}
+	if len(reviewersReq.TeamReviewers) > 0 {
+		return PRResponse{}, ErrInvalidReviewer
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	pr, err := g.findPR(orgName+"/"+owner+"/"+repoName, pullNumber)
+	if err != nil {
+		return PRResponse{}, err
+	}
+	author := g.userByID(orgName, pr.AuthorID)
+	for _, login := range reviewersReq.Reviewers {
+		if !slices.Contains(g.GbStoreInstance.Orgs[orgName].Users, login) {
+			return PRResponse{}, ErrInvalidReviewer
+		}
+		if !remove && author != nil && author.LoginName == login {
+			return PRResponse{}, ErrReviewRequestAuthor
+		}
+	}
+
+	oldReviewers := slices.Clone(pr.RequestedReviewers)
+	for _, login := range reviewersReq.Reviewers {
+		if remove {
+			pr.RequestedReviewers = removeElementByValue(pr.RequestedReviewers, login)
+		} else if !slices.Contains(pr.RequestedReviewers, login) {
+			pr.RequestedReviewers = append(pr.RequestedReviewers, login)
+		}
+	}
+
+	prResp := g.buildPRResponse(orgName, owner, repoName, pr)
+	g.emitReviewRequestChanges(orgName, owner, repoName, prResp, oldReviewers, pr.RequestedReviewers)
+	return prResp, g.persist()
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"gbserver/gitstore"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviews(t *testing.T) {
+	gbService := newTestService(t)
+	// pull request #1 of the fixture is authored by gbuser, gbadmin reviews it.
+	author := gbService.WithActor("gbuser")
+	reviewer := gbService.WithActor("gbadmin")
+
+	pr, err := gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.NoError(t, err)
+	assert.Equal(t, "clean", pr.MergeableState)
+	assert.True(t, *pr.Mergeable)
+	_, err = gbService.GetPR("gborg", "gbuser", "gbrepo", "99")
+	assert.Equal(t, ErrPRNotFound, err)
+
+	_, err = author.RequestReviewers("gborg", "gbuser", "gbrepo", "1", &ReviewersRequest{Reviewers: []string{"gbuser"}}, false)
+	assert.Equal(t, ErrReviewRequestAuthor, err)
+	_, err = author.RequestReviewers("gborg", "gbuser", "gbrepo", "1", &ReviewersRequest{Reviewers: []string{"stranger"}}, false)
+	assert.Equal(t, ErrInvalidReviewer, err)
+	pr, err = author.RequestReviewers("gborg", "gbuser", "gbrepo", "1", &ReviewersRequest{Reviewers: []string{"gbadmin"}}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "gbadmin", pr.RequestedReviewers[0].Login)
+
+	_, err = author.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "APPROVE"})
+	assert.Equal(t, ErrReviewOwnPR, err)
+	_, err = reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "REQUEST_CHANGES"})
+	assert.Equal(t, ErrReviewBodyRequired, err)
+	_, err = reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "LGTM"})
+	assert.Equal(t, ErrInvalidReviewEvent, err)
+
+	// a bad comment fails the whole review.
+	_, err = reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "REQUEST_CHANGES", Body: "Not yet",
+		Comments: []ReviewCommentRequest{{Path: "main.go", Line: 3, Body: "typo"}, {Path: "main.go", Body: "nowhere"}}})
+	assert.Equal(t, ErrInvalidReviewComment, err)
+	assert.Empty(t, gbService.GbStoreInstance.ReviewComments)
+
+	changes, err := reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "REQUEST_CHANGES", Body: "Not yet",
+		Comments: []ReviewCommentRequest{{Path: "main.go", Line: 3, Body: "typo"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "CHANGES_REQUESTED", changes.State)
+	assert.Equal(t, "gbadmin", changes.User.Login)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "blocked", pr.MergeableState)
+	assert.Empty(t, pr.RequestedReviewers)
+
+	// the author answers on the thread.
+	comments, _ := author.ListReviewComments("gborg", "gbuser", "gbrepo", "1")
+	assert.Len(t, comments, 1)
+	assert.Equal(t, changes.ID, comments[0].PullRequestReviewID)
+	reply, err := author.CreateReviewComment("gborg", "gbuser", "gbrepo", "1", &ReviewCommentRequest{InReplyTo: comments[0].ID, Body: "Fixed"})
+	assert.NoError(t, err)
+	assert.Equal(t, comments[0].ID, reply.InReplyToID)
+	assert.Equal(t, "main.go", reply.Path)
+	assert.Equal(t, 3, *reply.Line)
+	reviews, _ := author.ListReviews("gborg", "gbuser", "gbrepo", "1")
+	assert.Len(t, reviews, 2)
+	assert.Equal(t, "COMMENTED", reviews[1].State)
+
+	// a pending review is only seen by its author until it is submitted.
+	pending, err := reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "PENDING", pending.State)
+	_, err = reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{})
+	assert.Equal(t, ErrReviewPending, err)
+	_, err = author.GetReview("gborg", "gbuser", "gbrepo", "1", pending.ID)
+	assert.Equal(t, ErrReviewNotFound, err)
+	_, err = reviewer.DismissReview("gborg", "gbuser", "gbrepo", "1", pending.ID, &DismissReviewRequest{Message: "stale"})
+	assert.Equal(t, ErrReviewNotDismissable, err)
+	approval, err := reviewer.SubmitReview("gborg", "gbuser", "gbrepo", "1", pending.ID, &ReviewRequest{Event: "APPROVE"})
+	assert.NoError(t, err)
+	assert.Equal(t, "APPROVED", approval.State)
+	assert.NotEmpty(t, approval.SubmittedAt)
+	_, err = reviewer.DeleteReview("gborg", "gbuser", "gbrepo", "1", approval.ID)
+	assert.Equal(t, ErrReviewNotPending, err)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "clean", pr.MergeableState)
+
+	// the latest review of a reviewer counts, dismissing a change request unblocks the pull request.
+	changes, err = reviewer.CreateReview("gborg", "gbuser", "gbrepo", "1", &ReviewRequest{Event: "REQUEST_CHANGES", Body: "One more thing"})
+	assert.NoError(t, err)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "blocked", pr.MergeableState)
+	_, err = author.DismissReview("gborg", "gbuser", "gbrepo", "1", changes.ID, &DismissReviewRequest{})
+	assert.Equal(t, ErrDismissMessageRequired, err)
+	dismissed, err := author.DismissReview("gborg", "gbuser", "gbrepo", "1", changes.ID, &DismissReviewRequest{Message: "Done elsewhere"})
+	assert.NoError(t, err)
+	assert.Equal(t, "DISMISSED", dismissed.State)
+	pr, _ = gbService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "clean", pr.MergeableState)
+
+	_, err = gbService.DeleteBranch("gborg", "gbuser", "gbrepo", "gbbranch")
+	assert.NoError(t, err)
+	assert.Empty(t, gbService.GbStoreInstance.Reviews)
+	assert.Empty(t, gbService.GbStoreInstance.ReviewComments)
+}
+
+func TestGitBackedReviews(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	reviewer := gitService.WithActor("gbadmin")
+	sig := gitstore.Signature{Name: "gbuser", Email: "gbuser@gbserver.com"}
+	base := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+	head, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "README.md", Content: []byte("# head\n")}}, "Change readme", sig, sig)
+	assert.NoError(t, err)
+	other, err := gitService.Git.Commit(repoKey, base, []gitstore.FileChange{{Path: "README.md", Content: []byte("# base\n")}}, "Change readme too", sig, sig)
+	assert.NoError(t, err)
+	gitService.GbStoreInstance.Branches[repoKey+"/gbbranch"].CommitInfo = commitDetails("gbuser", "gbrepo", head)
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+
+	_, err = reviewer.CreateReviewComment("gborg", "gbuser", "gbrepo", "1", &ReviewCommentRequest{Path: "main.go", Line: 1, Body: "?"})
+	assert.Equal(t, ErrInvalidReviewCommentPath, err)
+	comment, err := reviewer.CreateReviewComment("gborg", "gbuser", "gbrepo", "1", &ReviewCommentRequest{Path: "README.md", Position: 1, Body: "Nice"})
+	assert.NoError(t, err)
+	assert.Equal(t, head, comment.CommitID)
+	pr, _ := gitService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "clean", pr.MergeableState)
+
+	// both sides change the README, the pull request conflicts.
+	gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo = commitDetails("gbuser", "gbrepo", other)
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+	pr, _ = gitService.GetPR("gborg", "gbuser", "gbrepo", "1")
+	assert.Equal(t, "dirty", pr.MergeableState)
+	assert.False(t, *pr.Mergeable)
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"gbserver/models"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// tagRefRegexp matches the refs/tags/<tag> refs tags are created with, tags may contain slashes.
+var tagRefRegexp = regexp.MustCompile(`^refs/tags/(.+)$`)
+
+// tagNameRegexp leaves out what git does not take in a ref name.
+var tagNameRegexp = regexp.MustCompile(`^[^\s~^:?*\[\\]+$`)
+
+// tagTypes are the kinds of objects an annotated tag can point at.
+var tagTypes = []string{"commit", "tree", "blob", "tag"}
+
+// TagRequest creates an annotated tag object, the tagger defaults to the authenticated user.
+type TagRequest struct {
+	Tag     string          `json:"tag"`
+	Message string          `json:"message"`
+	Object  string          `json:"object"`
+	Type    string          `json:"type"`
+	Tagger  *CommitIdentity `json:"tagger"`
+	//'{"tag":"v1.0.0","message":"First release","object":"7638417db6d59f3c431d3e1f261cc637155684cd","type":"commit"}'
+}
+
+type TagObjectResponse struct {
+	NodeID       string                     `json:"node_id"`
+	Tag          string                     `json:"tag"`
+	SHA          string                     `json:"sha"`
+	URL          string                     `json:"url"`
+	Message      string                     `json:"message"`
+	Tagger       GitActor                   `json:"tagger"`
+	Object       CreateBranchObjectResponse `json:"object"`
+	Verification CommitVerification         `json:"verification"`
+}
+
+// TagResponse is a tag as /tags lists it, with the commit it ends up at.
+type TagResponse struct {
+	Name       string    `json:"name"`
+	Commit     ObjectRef `json:"commit"`
+	ZipballURL string    `json:"zipball_url"`
+	TarballURL string    `json:"tarball_url"`
+	NodeID     string    `json:"node_id"`
+}
+
+// tagOfRef is the tag a refs/tags/<tag> ref names.
+func tagOfRef(ref string) (string, bool) {
+	matches := tagRefRegexp.FindStringSubmatch(ref)
+	if len(matches) < 2 {
+		return "", false
+	}
+	return matches[1], true
+}
+
+func validTagName(name string) bool {
+	return tagNameRegexp.MatchString(name) && !strings.Contains(name, "..") && !strings.HasSuffix(name, "/") &&
+		!strings.HasSuffix(name, ".lock") && !strings.HasPrefix(name, "-")
+}
+
+// objectURL is the git database URL of an object of the given type.
+func objectURL(owner, repoName, objectType, sha string) string {
+	return repoAPIURL(owner, repoName) + "/git/" + objectType + "s/" + sha
+}
+
+// tagObjectType tells an annotated tag from a commit for what a tag ref points at. Caller must hold the store lock.
+func (g *GbService) tagObjectType(repoKey, sha string) string {
+	if _, exists := g.GbStoreInstance.TagObjects[repoKey+"/"+sha]; exists {
+		return "tag"
+	}
+	return "commit"
+}
+
+func (g *GbService) tagRefResponse(owner, repoName string, tag *models.Tag, objectType string) CreateBranchResponse {
+	return CreateBranchResponse{Ref: "refs/tags/" + tag.Name, NodeID: tag.NodeID,
+		URL:    repoAPIURL(owner, repoName) + "/git/refs/tags/" + tag.Name,
+		Object: CreateBranchObjectResponse{Type: objectType, SHA: tag.SHA, URL: objectURL(owner, repoName, objectType, tag.SHA)}}
+}
+
+func tagObjectResponse(owner, repoName string, tagObject *models.TagObject) TagObjectResponse {
+	return TagObjectResponse{NodeID: tagObject.NodeID, Tag: tagObject.Tag, SHA: tagObject.SHA,
+		URL: objectURL(owner, repoName, "tag", tagObject.SHA), Message: tagObject.Message,
+		Tagger: GitActor{Name: tagObject.TaggerName, Email: tagObject.TaggerEmail, Date: tagObject.TaggedAt},
+		Object: CreateBranchObjectResponse{Type: tagObject.Type, SHA: tagObject.Object,
+			URL: objectURL(owner, repoName, tagObject.Type, tagObject.Object)},
+		Verification: CommitVerification{Verified: false, Reason: "unsigned"}}
+}
+
+// tagCommit peels a tag down to its commit. Caller must hold the store lock.
+func (g *GbService) tagCommit(repoKey string, tag *models.Tag) string {
+	sha := tag.SHA
+	for {
+		tagObject, exists := g.GbStoreInstance.TagObjects[repoKey+"/"+sha]
+		if !exists {
+			return sha
+		}
+		sha = tagObject.Object
+	}
+}
+
+// addTag stores a new tag of the repo pointing at sha. Caller must hold the store lock.
+func (g *GbService) addTag(repoKey, name, sha string) *models.Tag {
+	tag := &models.Tag{Name: name, NodeID: generateCustomID("NODEID"), SHA: sha}
+	g.GbStoreInstance.Tags[repoKey+"/"+name] = tag
+	repo := g.GbStoreInstance.Repos[repoKey]
+	repo.Tags = append(repo.Tags, name)
+	return tag
+}
+
+// removeTag drops a tag of the repo, its tag object stays like it does in git. Caller must hold the store lock.
+func (g *GbService) removeTag(repoKey, name string) {
+	delete(g.GbStoreInstance.Tags, repoKey+"/"+name)
+	repo := g.GbStoreInstance.Repos[repoKey]
+	repo.Tags = removeElementByValue(repo.Tags, name)
+}
+
+// recordTagObject stores the annotated tag a pushed tag ref points at, if it is one. Caller must hold the store lock.
+func (g *GbService) recordTagObject(repoKey, sha string) {
+	if _, exists := g.GbStoreInstance.TagObjects[repoKey+"/"+sha]; exists {
+		return
+	}
+	if objectType, err := g.Git.ObjectType(repoKey, sha); err != nil || objectType != "tag" {
+		return
+	}
+	tag, err := g.Git.ReadTag(repoKey, sha)
+	if err != nil {
+		return
+	}
+	g.GbStoreInstance.TagObjects[repoKey+"/"+sha] = &models.TagObject{SHA: sha, NodeID: generateCustomID("NODEID"),
+		Tag: tag.Name, Message: tag.Message, Object: tag.Object, Type: tag.Type, TaggerName: tag.Tagger.Name,
+		TaggerEmail: tag.Tagger.Email, TaggedAt: tag.Tagger.When.UTC().Format(time.RFC3339)}
+	g.recordTagObject(repoKey, tag.Object)
+}
+
+// createTagRef is CreateBranch for refs/tags/<tag>, the object may be a commit or an annotated tag.
+// Caller must hold the store lock.
+func (g *GbService) createTagRef(orgName, owner, repoName, tagName, sha string) (CreateBranchResponse, error) {
+	if !validTagName(tagName) {
+		return CreateBranchResponse{}, ErrInvalidTagName
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	if _, exists := g.GbStoreInstance.Tags[repoKey+"/"+tagName]; exists {
+		return CreateBranchResponse{}, ErrTagAlreadyExists
+	}
+	objectType := g.tagObjectType(repoKey, sha)
+	if g.Git != nil {
+		gitType, err := g.Git.ObjectType(repoKey, sha)
+		if err != nil || (gitType != "commit" && gitType != "tag") {
+			return CreateBranchResponse{}, ErrCommitNotFound
+		}
+		g.recordTagObject(repoKey, sha)
+		objectType = gitType
+		err = g.Git.UpdateRef(repoKey, "refs/tags/"+tagName, sha, "")
+		if err != nil {
+			return CreateBranchResponse{}, err
+		}
+	}
+	tag := g.addTag(repoKey, tagName, sha)
+	g.emitTag("create", orgName, owner, repoName, tagName, sha)
+	return g.tagRefResponse(owner, repoName, tag, objectType), g.persist()
+}
+
+// post /repos/{org}/{owner}/{repo}/git/tags
+// Only writes the tag object, a refs/tags ref still has to be created for it.
+func (g *GbService) CreateTagObject(orgName, owner, repoName string, tagReq *TagRequest) (TagObjectResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return TagObjectResponse{}, err
+	}
+	if !validTagName(tagReq.Tag) {
+		return TagObjectResponse{}, ErrInvalidTagName
+	}
+	if tagReq.Message == "" {
+		return TagObjectResponse{}, ErrCommitMessageRequired
+	}
+	objectType := tagReq.Type
+	if objectType == "" {
+		objectType = "commit"
+	}
+	if !slices.Contains(tagTypes, objectType) {
+		return TagObjectResponse{}, ErrInvalidTagType
+	}
+
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	now := time.Now().UTC()
+	tagger := signatureOf(g.actingUser(orgName, owner), now)
+	if tagReq.Tagger != nil {
+		tagger, err = identitySignature(tagReq.Tagger, now)
+		if err != nil {
+			return TagObjectResponse{}, err
+		}
+	}
+	sha := generateCustomID("SHA")
+	if g.Git != nil {
+		gitType, err := g.Git.ObjectType(repoKey, tagReq.Object)
+		if err != nil {
+			return TagObjectResponse{}, ErrCommitNotFound
+		}
+		if gitType != objectType {
+			return TagObjectResponse{}, ErrInvalidTagType
+		}
+		sha, err = g.Git.CreateTag(repoKey, tagReq.Tag, tagReq.Object, objectType, tagReq.Message, tagger)
+		if err != nil {
+			return TagObjectResponse{}, err
+		}
+	} else if tagReq.Object == "" {
+		return TagObjectResponse{}, ErrCommitNotFound
+	}
+	tagObject := &models.TagObject{SHA: sha, NodeID: generateCustomID("NODEID"), Tag: tagReq.Tag,
+		Message: strings.TrimSuffix(tagReq.Message, "\n"), Object: tagReq.Object, Type: objectType,
+		TaggerName: tagger.Name, TaggerEmail: tagger.Email, TaggedAt: tagger.When.UTC().Format(time.RFC3339)}
+	g.GbStoreInstance.TagObjects[repoKey+"/"+sha] = tagObject
+	return tagObjectResponse(owner, repoName, tagObject), g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/git/tags/{sha}
+func (g *GbService) GetTagObject(orgName, owner, repoName, sha string) (TagObjectResponse, error) {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return TagObjectResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	tagObject, exists := g.GbStoreInstance.TagObjects[orgName+"/"+owner+"/"+repoName+"/"+sha]
+	if !exists {
+		return TagObjectResponse{}, ErrTagNotFound
+	}
+	return tagObjectResponse(owner, repoName, tagObject), nil
+}
+
+// get /repos/{org}/{owner}/{repo}/tags
+func (g *GbService) ListTags(orgName, owner, repoName string) ([]TagResponse, error) {
+	tagList := []TagResponse{}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return tagList, err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	tagNames := slices.Clone(g.GbStoreInstance.Repos[repoKey].Tags)
+	// newest versions first, the way github sorts tag names.
+	slices.Sort(tagNames)
+	slices.Reverse(tagNames)
+	for _, tagName := range tagNames {
+		tag := g.GbStoreInstance.Tags[repoKey+"/"+tagName]
+		if tag == nil {
+			continue
+		}
+		commit := g.tagCommit(repoKey, tag)
+		tagList = append(tagList, TagResponse{Name: tagName, NodeID: tag.NodeID,
+			Commit:     ObjectRef{SHA: commit, URL: repoAPIURL(owner, repoName) + "/commits/" + commit},
+			ZipballURL: repoAPIURL(owner, repoName) + "/zipball/refs/tags/" + tagName,
+			TarballURL: repoAPIURL(owner, repoName) + "/tarball/refs/tags/" + tagName})
+	}
+	return tagList, nil
+}
+
+// delete /repos/{org}/{owner}/{repo}/git/refs/tags/{tag}
+func (g *GbService) DeleteTag(orgName, owner, repoName, tagName string) error {
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	repoKey := orgName + "/" + owner + "/" + repoName
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	tag, exists := g.GbStoreInstance.Tags[repoKey+"/"+tagName]
+	if !exists {
+		return ErrRefNotFound
+	}
+	if g.Git != nil {
+		err = g.Git.DeleteRef(repoKey, "refs/tags/"+tagName)
+		if err != nil {
+			return err
+		}
+	}
+	g.removeTag(repoKey, tagName)
+	g.emitTag("delete", orgName, owner, repoName, tagName, tag.SHA)
+	return g.persist()
+}
+
+// syncGitTags makes the refs/tags of a git repo match the tags of the store, tags and tag objects that
+// are not in git (e.g. written without git storage) are dropped. Caller must hold the store lock.
+func (g *GbService) syncGitTags(repoKey string) error {
+	repo := g.GbStoreInstance.Repos[repoKey]
+	for key, tagObject := range g.GbStoreInstance.TagObjects {
+		if !strings.HasPrefix(key, repoKey+"/") {
+			continue
+		}
+		if _, err := g.Git.ObjectType(repoKey, tagObject.SHA); err != nil {
+			delete(g.GbStoreInstance.TagObjects, key)
+		}
+	}
+	for _, tagName := range slices.Clone(repo.Tags) {
+		tag := g.GbStoreInstance.Tags[repoKey+"/"+tagName]
+		if tag == nil {
+			continue
+		}
+		if _, err := g.Git.ObjectType(repoKey, tag.SHA); err != nil {
+			g.logger().Warn("Dropping tag of unknown object", "repo", repoKey, "tag", tagName, "sha", tag.SHA)
+			g.removeTag(repoKey, tagName)
+			continue
+		}
+		err := g.Git.UpdateRef(repoKey, "refs/tags/"+tagName, tag.SHA, "")
+		if err != nil {
+			return err
+		}
+	}
+	refs, err := g.Git.Refs(repoKey, "refs/tags/")
+	if err != nil {
+		return err
+	}
+	for ref := range refs {
+		if _, exists := g.GbStoreInstance.Tags[repoKey+"/"+strings.TrimPrefix(ref, "refs/tags/")]; !exists {
+			err = g.Git.DeleteRef(repoKey, ref)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// syncPushedTags creates, moves and deletes tags of the store to match the refs/tags of git after a push.
+// Caller must hold the store lock.
+func (g *GbService) syncPushedTags(orgName, owner, repoName string) error {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	repo := g.GbStoreInstance.Repos[repoKey]
+	refs, err := g.Git.Refs(repoKey, "refs/tags/")
+	if err != nil {
+		return err
+	}
+	for _, tagName := range slices.Clone(repo.Tags) {
+		tag := g.GbStoreInstance.Tags[repoKey+"/"+tagName]
+		sha, exists := refs["refs/tags/"+tagName]
+		if !exists {
+			g.removeTag(repoKey, tagName)
+			g.emitTag("delete", orgName, owner, repoName, tagName, tag.SHA)
+			continue
+		}
+		if sha == tag.SHA {
+			continue
+		}
+		before := tag.SHA
+		g.recordTagObject(repoKey, sha)
+		tag.SHA = sha
+		g.emitRefPush(orgName, owner, repoName, "refs/tags/"+tagName, before, sha)
+	}
+	var newTags []string
+	for ref := range refs {
+		if tagName := strings.TrimPrefix(ref, "refs/tags/"); !slices.Contains(repo.Tags, tagName) {
+			newTags = append(newTags, tagName)
+		}
+	}
+	slices.Sort(newTags)
+	for _, tagName := range newTags {
+		sha := refs["refs/tags/"+tagName]
+		g.recordTagObject(repoKey, sha)
+		g.addTag(repoKey, tagName, sha)
+		g.emitTag("create", orgName, owner, repoName, tagName, sha)
+	}
+	return nil
+}
+
+// deleteRepoTags drops the tags and tag objects of a deleted repo. Caller must hold the store lock.
+func (g *GbService) deleteRepoTags(repoKey string) {
+	for key := range g.GbStoreInstance.Tags {
+		if strings.HasPrefix(key, repoKey+"/") {
+			delete(g.GbStoreInstance.Tags, key)
+		}
+	}
+	for key := range g.GbStoreInstance.TagObjects {
+		if strings.HasPrefix(key, repoKey+"/") {
+			delete(g.GbStoreInstance.TagObjects, key)
+		}
+	}
+}
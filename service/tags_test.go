@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	gbService := newTestService(t)
+	sha := "7638417db6d59f3c431d3e1f261cc637155684cd"
+
+	tagRef, err := gbService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/tags/v1.0.0", SHA: sha})
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/tags/v1.0.0", tagRef.Ref)
+	assert.Equal(t, "commit", tagRef.Object.Type)
+	_, err = gbService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/tags/v1.0.0", SHA: sha})
+	assert.Equal(t, ErrTagAlreadyExists, err)
+	_, err = gbService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/tags/bad..name", SHA: sha})
+	assert.Equal(t, ErrInvalidTagName, err)
+
+	_, err = gbService.CreateTagObject("gborg", "gbuser", "gbrepo", &TagRequest{Tag: "v2.0.0", Object: sha})
+	assert.Equal(t, ErrCommitMessageRequired, err)
+	tagObject, err := gbService.CreateTagObject("gborg", "gbuser", "gbrepo", &TagRequest{Tag: "v2.0.0", Message: "Second release", Object: sha})
+	assert.NoError(t, err)
+	assert.Equal(t, "gbuser", tagObject.Tagger.Name)
+	assert.Equal(t, sha, tagObject.Object.SHA)
+	got, err := gbService.GetTagObject("gborg", "gbuser", "gbrepo", tagObject.SHA)
+	assert.NoError(t, err)
+	assert.Equal(t, tagObject, got)
+	annotated, err := gbService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/tags/v2.0.0", SHA: tagObject.SHA})
+	assert.NoError(t, err)
+	assert.Equal(t, "tag", annotated.Object.Type)
+
+	refs, _ := gbService.ListRefs("gborg", "gbuser", "gbrepo", "tags/")
+	assert.Len(t, refs, 2)
+	tags, err := gbService.ListTags("gborg", "gbuser", "gbrepo")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2.0.0", tags[0].Name)
+	// the annotated tag is peeled down to its commit.
+	assert.Equal(t, sha, tags[0].Commit.SHA)
+
+	assert.NoError(t, gbService.DeleteTag("gborg", "gbuser", "gbrepo", "v1.0.0"))
+	assert.Equal(t, ErrRefNotFound, gbService.DeleteTag("gborg", "gbuser", "gbrepo", "v1.0.0"))
+	tags, _ = gbService.ListTags("gborg", "gbuser", "gbrepo")
+	assert.Len(t, tags, 1)
+}
+
+func TestGitBackedTags(t *testing.T) {
+	gitService := newGitService(t)
+	repoKey := "gborg/gbuser/gbrepo"
+	master := gitService.GbStoreInstance.Branches[repoKey+"/master"].CommitInfo.SHA
+
+	_, err := gitService.CreateTagObject("gborg", "gbuser", "gbrepo", &TagRequest{Tag: "v1.0.0", Message: "First release", Object: master, Type: "tree"})
+	assert.Equal(t, ErrInvalidTagType, err)
+	_, err = gitService.CreateTagObject("gborg", "gbuser", "gbrepo", &TagRequest{Tag: "v1.0.0", Message: "First release", Object: "0123456789012345678901234567890123456789"})
+	assert.Equal(t, ErrCommitNotFound, err)
+	tagObject, err := gitService.CreateTagObject("gborg", "gbuser", "gbrepo", &TagRequest{Tag: "v1.0.0", Message: "First release", Object: master,
+		Tagger: &CommitIdentity{Name: "Release Bot", Email: "release@gbserver.com", Date: "2024-01-02T03:04:05Z"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-01-02T03:04:05Z", tagObject.Tagger.Date)
+	gitTag, err := gitService.Git.ReadTag(repoKey, tagObject.SHA)
+	assert.NoError(t, err)
+	assert.Equal(t, "Release Bot", gitTag.Tagger.Name)
+
+	_, err = gitService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/tags/v1.0.0", SHA: tagObject.SHA})
+	assert.NoError(t, err)
+	refs, _ := gitService.Git.Refs(repoKey, "refs/tags/")
+	assert.Equal(t, tagObject.SHA, refs["refs/tags/v1.0.0"])
+	commit, err := gitService.GetCommit("gborg", "gbuser", "gbrepo", "v1.0.0")
+	assert.NoError(t, err)
+	assert.Equal(t, master, commit.SHA)
+
+	// tags of the store that git does not have are dropped on sync, and git's extra tags removed.
+	gitService.addTag(repoKey, "made-up", "aa218f56b14c9653891f9e74264a383fa43fefbd")
+	assert.NoError(t, gitService.Git.UpdateRef(repoKey, "refs/tags/extra", master, ""))
+	assert.NoError(t, gitService.UseGit(gitService.Git))
+	assert.NotContains(t, gitService.GbStoreInstance.Repos[repoKey].Tags, "made-up")
+	refs, _ = gitService.Git.Refs(repoKey, "refs/tags/")
+	assert.Equal(t, map[string]string{"refs/tags/v1.0.0": tagObject.SHA}, refs)
+
+	assert.NoError(t, gitService.DeleteTag("gborg", "gbuser", "gbrepo", "v1.0.0"))
+	refs, _ = gitService.Git.Refs(repoKey, "refs/tags/")
+	assert.Empty(t, refs)
+}
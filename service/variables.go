@@ -9,9 +9,111 @@ var ErrOwnerNotFound = errors.New("owner not found")
 var ErrRepoNotFound = errors.New("repo not found")
 var ErrBranchesNotFound = errors.New("branch not found")
 var ErrRepoAlreadyExists = errors.New("repo name already exists")
+var ErrInvalidRepoName = errors.New("invalid repository name. Use only letters, digits, ., - and _")
 var ErrBranchesAlreadyExists = errors.New("branch name already exists")
 var ErrPRNotFound = errors.New("no PRs found. Invalid PR number")
 var ErrOwnerNotInSameOrg = errors.New("owners are not belonging to same organization")
 var ErrPRAlreadyExists = errors.New("already PR exists on the branch")
 var ErrPRAlreadyClosed = errors.New("already PR got closed on the branch")
 var ErrInvalidBranchName = errors.New("invalid branch name. Specify as refs/heads/<branch>")
+var ErrInvalidPRState = errors.New("invalid state. Specify as open or closed")
+var ErrBaseBranchNotFound = errors.New("proposed base branch was not found")
+var ErrPRBaseOnClosed = errors.New("cannot change the base branch of a closed pull request")
+var ErrPRSameHeadBase = errors.New("head and base branch must be different")
+var ErrPRHeadBranchMissing = errors.New("cannot reopen the pull request, head branch was deleted")
+var ErrPRAlreadyMerged = errors.New("pull request is already merged")
+var ErrPRNotMergeable = errors.New("pull request is not mergeable")
+var ErrPRHeadModified = errors.New("head branch was modified. Review and try the merge again")
+var ErrInvalidMergeMethod = errors.New("invalid merge method. Specify as merge, squash or rebase")
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+var ErrSnapshotsDisabled = errors.New("snapshots are not enabled on this server")
+var ErrBadCredentials = errors.New("bad credentials")
+var ErrHookNotFound = errors.New("hook not found")
+var ErrHookDeliveryNotFound = errors.New("hook delivery not found")
+var ErrInvalidHookConfig = errors.New("invalid hook config. Specify an http(s) url, content_type as json or form and insecure_ssl as 0 or 1")
+var ErrInvalidHookName = errors.New("invalid hook name. Specify as web")
+var ErrInvalidHookEvent = errors.New("invalid hook event")
+var ErrIssueNotFound = errors.New("issue not found")
+var ErrIssueTitleRequired = errors.New("issue title is required")
+var ErrInvalidIssueState = errors.New("invalid state. Specify as open, closed or all")
+var ErrInvalidStateReason = errors.New("invalid state_reason. Specify as completed, not_planned or reopened")
+var ErrIssueLocked = errors.New("issue is locked")
+var ErrIssueCommentNotFound = errors.New("issue comment not found")
+var ErrCommentBodyRequired = errors.New("comment body is required")
+var ErrInvalidAssignee = errors.New("invalid assignee. Assignees must be members of the organization")
+var ErrTooManyAssignees = errors.New("an issue can have at most 10 assignees")
+var ErrLabelNotFound = errors.New("label not found")
+var ErrLabelAlreadyExists = errors.New("label name already exists")
+var ErrInvalidLabel = errors.New("invalid label. Specify a name and a 6 character hex color")
+var ErrCommitNotFound = errors.New("object does not exist")
+var ErrGitDisabled = errors.New("repository is not backed by git")
+var ErrInvalidGitService = errors.New("service not supported. Use git-upload-pack or git-receive-pack")
+var ErrRepoEmpty = errors.New("git repository is empty")
+var ErrInvalidTimestamp = errors.New("invalid timestamp. Use ISO 8601 format YYYY-MM-DDTHH:MM:SSZ")
+var ErrInvalidBaseHead = errors.New("invalid comparison. Use base...head")
+var ErrNoCommonAncestor = errors.New("no common ancestor between base and head")
+var ErrContentNotFound = errors.New("not found")
+var ErrInvalidContentPath = errors.New("invalid path")
+var ErrInvalidContent = errors.New("content is not valid Base64")
+var ErrContentIsDirectory = errors.New("path is a directory")
+var ErrContentSHARequired = errors.New("\"sha\" wasn't supplied")
+var ErrContentSHAMismatch = errors.New("sha does not match the current file")
+var ErrCommitMessageRequired = errors.New("message is required")
+var ErrInvalidCommitIdentity = errors.New("author and committer need a name and an email")
+var ErrReviewNotFound = errors.New("review not found")
+var ErrReviewCommentNotFound = errors.New("review comment not found")
+var ErrInvalidReviewEvent = errors.New("invalid event. Specify as APPROVE, REQUEST_CHANGES or COMMENT")
+var ErrReviewBodyRequired = errors.New("body is required for this review event")
+var ErrReviewOwnPR = errors.New("can not approve or request changes on your own pull request")
+var ErrReviewPending = errors.New("user can only have one pending review per pull request")
+var ErrReviewNotPending = errors.New("review is not pending")
+var ErrReviewNotDismissable = errors.New("only approving or change requesting reviews can be dismissed")
+var ErrDismissMessageRequired = errors.New("message is required to dismiss a review")
+var ErrInvalidReviewComment = errors.New("invalid review comment. Specify a path and a position or line, side as LEFT or RIGHT")
+var ErrInvalidReviewCommentPath = errors.New("path is not part of the pull request diff")
+var ErrInvalidReviewer = errors.New("reviews may only be requested from members of the organization")
+var ErrReviewRequestAuthor = errors.New("review cannot be requested from pull request author")
+var ErrInvalidStatusState = errors.New("invalid state. Specify as error, failure, pending or success")
+var ErrNoCommitForSHA = errors.New("no commit found for SHA")
+var ErrInvalidCheckRun = errors.New("check run needs a name and a head_sha")
+var ErrInvalidCheckStatus = errors.New("invalid status. Specify as queued, in_progress or completed")
+var ErrInvalidCheckConclusion = errors.New("invalid conclusion. Specify as action_required, cancelled, failure, neutral, success, skipped, stale or timed_out")
+var ErrCheckConclusionRequired = errors.New("conclusion is required when status is completed")
+var ErrCheckRunNotFound = errors.New("check run not found")
+var ErrCheckSuiteNotFound = errors.New("check suite not found")
+var ErrBranchNotProtected = errors.New("branch not protected")
+var ErrInvalidBranchProtection = errors.New("invalid branch protection. Approving review count must be between 0 and 6, contexts not empty and restricted users members of the organization")
+var ErrBranchPushRestricted = errors.New("you are not allowed to push to this protected branch")
+var ErrProtectedBranchDeletion = errors.New("cannot delete this protected branch")
+var ErrProtectedBranchForcePush = errors.New("cannot force-push to this protected branch")
+var ErrProtectedBranchPullRequest = errors.New("changes must be made through a pull request")
+var ErrRequiredChecksFailing = errors.New("required status checks have not succeeded")
+var ErrRequiredReviewsMissing = errors.New("at least the required number of approving reviews is required")
+var ErrProtectedBranchOutOfDate = errors.New("head branch is not up to date with the base branch")
+var ErrRefNotFound = errors.New("reference does not exist")
+var ErrNotFastForward = errors.New("update is not a fast forward")
+var ErrTagNotFound = errors.New("tag not found")
+var ErrTagAlreadyExists = errors.New("reference already exists")
+var ErrInvalidTagName = errors.New("invalid tag name")
+var ErrInvalidTagType = errors.New("invalid type. Specify as commit, tree, blob or tag, matching the object")
+var ErrReleaseNotFound = errors.New("release not found")
+var ErrReleaseAlreadyExists = errors.New("a release for this tag_name already exists")
+var ErrReleaseTagRequired = errors.New("tag_name is required")
+var ErrReleaseAssetNotFound = errors.New("release asset not found")
+var ErrReleaseAssetAlreadyExists = errors.New("an asset with this name already exists on the release")
+var ErrReleaseAssetNameRequired = errors.New("name is required")
+var ErrAssetsDisabled = errors.New("release assets are not stored")
+var ErrUserNotFound = errors.New("user not found")
+var ErrMembershipNotFound = errors.New("user is not a member of the organization")
+var ErrInvalidMemberRole = errors.New("invalid role. Specify as admin or member")
+var ErrOrgAdminRequired = errors.New("you must be an admin of the organization")
+var ErrRepoAdminRequired = errors.New("you must be an admin of the repository")
+var ErrStoreNotSaved = errors.New("the change could not be saved")
+var ErrLastOrgAdmin = errors.New("cannot remove the last admin of the organization")
+var ErrMemberOwnsRepos = errors.New("user owns repositories in the organization")
+var ErrInvalidLogin = errors.New("invalid login. Use alphanumeric characters or single hyphens, not at the start or end")
+var ErrLoginAlreadyExists = errors.New("login has already been taken")
+var ErrNodeNotFound = errors.New("could not resolve to a node with the given global id")
+var ErrPaginationRequired = errors.New("you must provide a first or last value to properly paginate the connection")
+var ErrInvalidPageSize = errors.New("first and last must be between 0 and 100")
+var ErrInvalidCursor = errors.New("invalid cursor")
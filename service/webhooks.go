@@ -0,0 +1,1040 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"gbserver/models"
+	"hash"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxHookDeliveries is how many deliveries are kept per hook, older ones are dropped.
+const maxHookDeliveries = 100
+
+// hookEvents are the events a hook can subscribe to, "*" means all of them.
+var hookEvents = []string{"*", "check_run", "check_suite", "create", "delete", "issue_comment", "issues", "label", "pull_request", "pull_request_review", "pull_request_review_comment", "push", "release", "repository", "status"}
+
+type HookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Secret      string `json:"secret,omitempty"`
+	InsecureSSL string `json:"insecure_ssl"`
+}
+
+type HookRequest struct {
+	Name         string      `json:"name"`
+	Config       *HookConfig `json:"config"`
+	Events       []string    `json:"events"`
+	AddEvents    []string    `json:"add_events"`
+	RemoveEvents []string    `json:"remove_events"`
+	Active       *bool       `json:"active"`
+	//'{"name":"web","active":true,"events":["push","pull_request"],"config":{"url":"https://example.com/webhook","content_type":"json","insecure_ssl":"0"}}'
+}
+
+type HookResponse struct {
+	Type          string     `json:"type"`
+	ID            int        `json:"id"`
+	Name          string     `json:"name"`
+	Active        bool       `json:"active"`
+	Events        []string   `json:"events"`
+	Config        HookConfig `json:"config"`
+	UpdatedAt     string     `json:"updated_at"`
+	CreatedAt     string     `json:"created_at"`
+	URL           string     `json:"url"`
+	PingURL       string     `json:"ping_url"`
+	DeliveriesURL string     `json:"deliveries_url"`
+}
+
+type HookDeliveryRequest struct {
+	Headers map[string]string `json:"headers"`
+	Payload json.RawMessage   `json:"payload"`
+}
+
+type HookDeliveryResponseData struct {
+	Headers map[string]string `json:"headers"`
+	Payload string            `json:"payload"`
+}
+
+type HookDeliveryResponse struct {
+	ID          int                       `json:"id"`
+	GUID        string                    `json:"guid"`
+	DeliveredAt string                    `json:"delivered_at"`
+	Redelivery  bool                      `json:"redelivery"`
+	Duration    float64                   `json:"duration"`
+	Status      string                    `json:"status"`
+	StatusCode  int                       `json:"status_code"`
+	Event       string                    `json:"event"`
+	Action      string                    `json:"action"`
+	Attempts    int                       `json:"attempts"`
+	Request     *HookDeliveryRequest      `json:"request,omitempty"`
+	Response    *HookDeliveryResponseData `json:"response,omitempty"`
+}
+
+// Webhooks delivers events to hooks in the background, retrying failed deliveries with backoff.
+type Webhooks struct {
+	queue          chan webhookJob
+	stop           chan struct{}
+	stopOnce       sync.Once
+	client         *http.Client
+	insecureClient *http.Client
+	MaxAttempts    int
+	Backoff        time.Duration
+}
+
+type webhookJob struct {
+	hook     models.Hook
+	delivery *models.HookDelivery
+	// log is the logger of the service that queued the job, so failures can be tied to the request.
+	log *slog.Logger
+}
+
+func NewWebhooks() *Webhooks {
+	return &Webhooks{
+		queue:  make(chan webhookJob, 1000),
+		stop:   make(chan struct{}),
+		client: &http.Client{Timeout: 10 * time.Second},
+		insecureClient: &http.Client{Timeout: 10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}},
+		MaxAttempts: 3,
+		Backoff:     time.Second,
+	}
+}
+
+// start runs the delivery workers until Close, results are recorded in the store of g.
+func (w *Webhooks) start(g *GbService, workers int) {
+	for range workers {
+		go func() {
+			for {
+				select {
+				case job := <-w.queue:
+					w.deliver(job)
+					g.recordDelivery(job.delivery)
+				case <-w.stop:
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Close stops the delivery workers once they are done with the delivery at hand.
+func (w *Webhooks) Close() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+func (w *Webhooks) enqueue(job webhookJob) {
+	select {
+	case <-w.stop:
+		job.log.Warn("Webhook delivery is stopped, dropping delivery", "delivery", job.delivery.GUID, "hook_id", job.hook.ID)
+	case w.queue <- job:
+	default:
+		job.log.Warn("Webhook queue is full, dropping delivery", "delivery", job.delivery.GUID, "hook_id", job.hook.ID)
+	}
+}
+
+func hookSignature(newHash func() hash.Hash, secret string, body []byte) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhooks) deliver(job webhookJob) {
+	hook := job.hook
+	delivery := job.delivery
+	body := []byte(delivery.Payload)
+	contentType := "application/json"
+	if hook.ContentType == "form" {
+		body = []byte("payload=" + url.QueryEscape(delivery.Payload))
+		contentType = "application/x-www-form-urlencoded"
+	}
+	targetType := "repository"
+	if hook.RepoKey == "" {
+		targetType = "organization"
+	}
+	delivery.RequestHeaders = map[string]string{
+		"Accept":                                 "*/*",
+		"Content-Type":                           contentType,
+		"User-Agent":                             "GitHub-Hookshot/gbserver",
+		"X-GitHub-Delivery":                      delivery.GUID,
+		"X-GitHub-Event":                         delivery.Event,
+		"X-GitHub-Hook-ID":                       strconv.Itoa(hook.ID),
+		"X-GitHub-Hook-Installation-Target-Type": targetType,
+	}
+	if hook.Secret != "" {
+		delivery.RequestHeaders["X-Hub-Signature"] = "sha1=" + hookSignature(sha1.New, hook.Secret, body)
+		delivery.RequestHeaders["X-Hub-Signature-256"] = "sha256=" + hookSignature(sha256.New, hook.Secret, body)
+	}
+	client := w.client
+	if hook.InsecureSSL == "1" {
+		client = w.insecureClient
+	}
+
+	for attempt := 1; attempt <= max(w.MaxAttempts, 1); attempt++ {
+		delivery.Attempts = attempt
+		start := time.Now()
+		delivery.DeliveredAt = start.UTC().Format(time.RFC3339)
+		statusCode, status, respHeaders, respBody := w.post(client, hook.URL, delivery.RequestHeaders, body)
+		delivery.Duration = time.Since(start).Seconds()
+		delivery.StatusCode = statusCode
+		delivery.Status = status
+		delivery.ResponseHeaders = respHeaders
+		delivery.ResponseBody = respBody
+		if statusCode >= 200 && statusCode < 300 {
+			return
+		}
+		if attempt < w.MaxAttempts {
+			time.Sleep(w.Backoff * time.Duration(1<<(attempt-1)))
+		}
+	}
+	job.log.Warn("Webhook delivery failed", "delivery", delivery.GUID, "hook_id", hook.ID, "url", hook.URL, "status", delivery.Status)
+}
+
+func (w *Webhooks) post(client *http.Client, hookURL string, headers map[string]string, body []byte) (int, string, map[string]string, string) {
+	req, err := http.NewRequest(http.MethodPost, hookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err.Error(), nil, ""
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err.Error(), nil, ""
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	respHeaders := map[string]string{}
+	for key := range resp.Header {
+		respHeaders[strings.ToLower(key)] = resp.Header.Get(key)
+	}
+	status := "OK"
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		status = "Invalid HTTP Response: " + strconv.Itoa(resp.StatusCode)
+	}
+	return resp.StatusCode, status, respHeaders, string(respBody)
+}
+
+// recordDelivery adds a finished delivery to the log of its hook. Deliveries are not saved on their own, the
+// delivery log goes to storage with the next change saved and when the service is closed.
+func (g *GbService) recordDelivery(delivery *models.HookDelivery) {
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	hookID := strconv.Itoa(delivery.HookID)
+	if _, exists := g.GbStoreInstance.Hooks[hookID]; !exists {
+		return
+	}
+	g.GbStoreInstance.DeliveryCount++
+	delivery.ID = g.GbStoreInstance.DeliveryCount
+	deliveries := append(g.GbStoreInstance.HookDeliveries[hookID], delivery)
+	if len(deliveries) > maxHookDeliveries {
+		deliveries = deliveries[len(deliveries)-maxHookDeliveries:]
+	}
+	g.GbStoreInstance.HookDeliveries[hookID] = deliveries
+}
+
+// emit queues event for every active hook of the repo and its org that subscribed to it.
+// Caller must hold the store lock.
+func (g *GbService) emit(event, action, orgName, repoKey string, payload any) {
+	if g.Webhooks == nil {
+		return
+	}
+	var hooks []*models.Hook
+	for _, hook := range g.GbStoreInstance.Hooks {
+		if !hook.Active || hook.OrgName != orgName || (hook.RepoKey != "" && hook.RepoKey != repoKey) {
+			continue
+		}
+		if slices.Contains(hook.Events, event) || slices.Contains(hook.Events, "*") {
+			hooks = append(hooks, hook)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		g.logger().Error("Error occurred while encoding the webhook payload.", "event", event, "error", err)
+		return
+	}
+	for _, hook := range hooks {
+		g.Webhooks.enqueue(webhookJob{hook: *hook, delivery: &models.HookDelivery{
+			GUID: uuid.New().String(), HookID: hook.ID, Event: event, Action: action, Payload: string(data)}, log: g.logger()})
+	}
+}
+
+// hookScope validates the org, or the repo when repoName is set, and returns the repo key ("" for org hooks).
+func (g *GbService) hookScope(orgName, owner, repoName string) (string, error) {
+	if repoName == "" {
+		g.GbStoreInstance.MU.RLock()
+		defer g.GbStoreInstance.MU.RUnlock()
+		if _, exists := g.GbStoreInstance.Orgs[orgName]; !exists {
+			return "", ErrOrgNotFound
+		}
+		return "", nil
+	}
+	err := g.validateOrgOwnerRepo(orgName, owner, repoName)
+	if err != nil {
+		return "", err
+	}
+	return orgName + "/" + owner + "/" + repoName, nil
+}
+
+// findHook returns the hook when it belongs to the scope. Caller must hold the store lock.
+func (g *GbService) findHook(orgName, repoKey string, hookID int) (*models.Hook, error) {
+	hook, exists := g.GbStoreInstance.Hooks[strconv.Itoa(hookID)]
+	if !exists || hook.OrgName != orgName || hook.RepoKey != repoKey {
+		return nil, ErrHookNotFound
+	}
+	return hook, nil
+}
+
+func (g *GbService) hookResponse(hook *models.Hook) HookResponse {
+	hookURL := "https://api.gbserver.com/orgs/" + hook.OrgName + "/hooks/" + strconv.Itoa(hook.ID)
+	hookType := "Organization"
+	if hook.RepoKey != "" {
+		hookType = "Repository"
+		hookURL = "https://api.gbserver.com/repos/" + hook.RepoKey + "/hooks/" + strconv.Itoa(hook.ID)
+	}
+	config := HookConfig{URL: hook.URL, ContentType: hook.ContentType, InsecureSSL: hook.InsecureSSL}
+	if hook.Secret != "" {
+		config.Secret = "********"
+	}
+	return HookResponse{Type: hookType, ID: hook.ID, Name: "web", Active: hook.Active, Events: hook.Events,
+		Config: config, UpdatedAt: hook.UpdatedAt, CreatedAt: hook.CreatedAt,
+		URL: hookURL, PingURL: hookURL + "/pings", DeliveriesURL: hookURL + "/deliveries"}
+}
+
+func validateHookConfig(config *HookConfig) error {
+	hookURL, err := url.Parse(config.URL)
+	if err != nil || (hookURL.Scheme != "http" && hookURL.Scheme != "https") || hookURL.Host == "" {
+		return ErrInvalidHookConfig
+	}
+	if config.ContentType == "" {
+		config.ContentType = "form"
+	}
+	if config.ContentType != "json" && config.ContentType != "form" {
+		return ErrInvalidHookConfig
+	}
+	if config.InsecureSSL == "" {
+		config.InsecureSSL = "0"
+	}
+	if config.InsecureSSL != "0" && config.InsecureSSL != "1" {
+		return ErrInvalidHookConfig
+	}
+	return nil
+}
+
+func validateHookEvents(events []string) error {
+	for _, event := range events {
+		if !slices.Contains(hookEvents, event) {
+			return ErrInvalidHookEvent
+		}
+	}
+	return nil
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks, get /orgs/{org}/hooks
+func (g *GbService) ListHooks(orgName, owner, repoName string) ([]HookResponse, error) {
+	hookList := []HookResponse{}
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return hookList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	for _, hook := range g.GbStoreInstance.Hooks {
+		if hook.OrgName == orgName && hook.RepoKey == repoKey {
+			hookList = append(hookList, g.hookResponse(hook))
+		}
+	}
+	slices.SortFunc(hookList, func(a, b HookResponse) int { return a.ID - b.ID })
+	return hookList, nil
+}
+
+// post /repos/{org}/{owner}/{repo}/hooks, post /orgs/{org}/hooks
+func (g *GbService) CreateHook(orgName, owner, repoName string, hookReq *HookRequest) (HookResponse, error) {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	if hookReq.Name != "" && hookReq.Name != "web" {
+		return HookResponse{}, ErrInvalidHookName
+	}
+	if hookReq.Config == nil {
+		return HookResponse{}, ErrInvalidHookConfig
+	}
+	config := *hookReq.Config
+	err = validateHookConfig(&config)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	events := hookReq.Events
+	if len(events) == 0 {
+		events = []string{"push"}
+	}
+	err = validateHookEvents(events)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	active := true
+	if hookReq.Active != nil {
+		active = *hookReq.Active
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	g.GbStoreInstance.HooksCount++
+	hook := &models.Hook{ID: g.GbStoreInstance.HooksCount, OrgName: orgName, RepoKey: repoKey, URL: config.URL,
+		ContentType: config.ContentType, Secret: config.Secret, InsecureSSL: config.InsecureSSL,
+		Events: slices.Compact(slices.Sorted(slices.Values(events))), Active: active, CreatedAt: now, UpdatedAt: now}
+	g.GbStoreInstance.Hooks[strconv.Itoa(hook.ID)] = hook
+	hookResp := g.hookResponse(hook)
+	g.ping(hook, owner, repoName)
+	return hookResp, g.persist()
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks/{hook_id}, get /orgs/{org}/hooks/{hook_id}
+func (g *GbService) GetHook(orgName, owner, repoName string, hookID int) (HookResponse, error) {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	hook, err := g.findHook(orgName, repoKey, hookID)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	return g.hookResponse(hook), nil
+}
+
+// patch /repos/{org}/{owner}/{repo}/hooks/{hook_id}, patch /orgs/{org}/hooks/{hook_id}
+func (g *GbService) UpdateHook(orgName, owner, repoName string, hookID int, hookReq *HookRequest) (HookResponse, error) {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	var config HookConfig
+	if hookReq.Config != nil {
+		config = *hookReq.Config
+		err = validateHookConfig(&config)
+		if err != nil {
+			return HookResponse{}, err
+		}
+	}
+	err = validateHookEvents(slices.Concat(hookReq.Events, hookReq.AddEvents, hookReq.RemoveEvents))
+	if err != nil {
+		return HookResponse{}, err
+	}
+
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	hook, err := g.findHook(orgName, repoKey, hookID)
+	if err != nil {
+		return HookResponse{}, err
+	}
+	if hookReq.Config != nil {
+		hook.URL = config.URL
+		hook.ContentType = config.ContentType
+		hook.InsecureSSL = config.InsecureSSL
+		// github keeps the secret when the new config leaves it out.
+		if config.Secret != "" {
+			hook.Secret = config.Secret
+		}
+	}
+	events := hook.Events
+	if len(hookReq.Events) > 0 {
+		events = hookReq.Events
+	}
+	events = slices.Concat(events, hookReq.AddEvents)
+	events = slices.DeleteFunc(slices.Clone(events), func(event string) bool { return slices.Contains(hookReq.RemoveEvents, event) })
+	hook.Events = slices.Compact(slices.Sorted(slices.Values(events)))
+	if hookReq.Active != nil {
+		hook.Active = *hookReq.Active
+	}
+	hook.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	return g.hookResponse(hook), g.persist()
+}
+
+// delete /repos/{org}/{owner}/{repo}/hooks/{hook_id}, delete /orgs/{org}/hooks/{hook_id}
+func (g *GbService) DeleteHook(orgName, owner, repoName string, hookID int) (bool, error) {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return false, err
+	}
+	g.GbStoreInstance.MU.Lock()
+	defer g.GbStoreInstance.MU.Unlock()
+	_, err = g.findHook(orgName, repoKey, hookID)
+	if err != nil {
+		return false, err
+	}
+	delete(g.GbStoreInstance.Hooks, strconv.Itoa(hookID))
+	delete(g.GbStoreInstance.HookDeliveries, strconv.Itoa(hookID))
+	return true, g.persist()
+}
+
+// post /repos/{org}/{owner}/{repo}/hooks/{hook_id}/pings, post /orgs/{org}/hooks/{hook_id}/pings
+func (g *GbService) PingHook(orgName, owner, repoName string, hookID int) error {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	hook, err := g.findHook(orgName, repoKey, hookID)
+	if err != nil {
+		return err
+	}
+	g.ping(hook, owner, repoName)
+	return nil
+}
+
+// ping sends the ping event github sends when a hook is created. Caller must hold the store lock.
+func (g *GbService) ping(hook *models.Hook, owner, repoName string) {
+	if g.Webhooks == nil {
+		return
+	}
+	payload := map[string]any{
+		"zen":          "Keep it logically awesome.",
+		"hook_id":      hook.ID,
+		"hook":         g.hookResponse(hook),
+		"organization": g.hookOrganization(hook.OrgName),
+		"sender":       g.hookSender(hook.OrgName, owner),
+	}
+	if hook.RepoKey != "" {
+		payload["repository"] = g.hookRepository(hook.OrgName, owner, repoName)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		g.logger().Error("Error occurred while encoding the ping payload.", "error", err)
+		return
+	}
+	g.Webhooks.enqueue(webhookJob{hook: *hook, delivery: &models.HookDelivery{
+		GUID: uuid.New().String(), HookID: hook.ID, Event: "ping", Payload: string(data)}, log: g.logger()})
+}
+
+func hookDeliveryResponse(delivery *models.HookDelivery, withDetails bool) HookDeliveryResponse {
+	deliveryResp := HookDeliveryResponse{ID: delivery.ID, GUID: delivery.GUID, DeliveredAt: delivery.DeliveredAt,
+		Redelivery: delivery.Redelivery, Duration: delivery.Duration, Status: delivery.Status,
+		StatusCode: delivery.StatusCode, Event: delivery.Event, Action: delivery.Action, Attempts: delivery.Attempts}
+	if withDetails {
+		deliveryResp.Request = &HookDeliveryRequest{Headers: delivery.RequestHeaders, Payload: json.RawMessage(delivery.Payload)}
+		deliveryResp.Response = &HookDeliveryResponseData{Headers: delivery.ResponseHeaders, Payload: delivery.ResponseBody}
+	}
+	return deliveryResp
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks/{hook_id}/deliveries, newest first.
+func (g *GbService) ListHookDeliveries(orgName, owner, repoName string, hookID int) ([]HookDeliveryResponse, error) {
+	deliveryList := []HookDeliveryResponse{}
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return deliveryList, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	_, err = g.findHook(orgName, repoKey, hookID)
+	if err != nil {
+		return deliveryList, err
+	}
+	deliveries := g.GbStoreInstance.HookDeliveries[strconv.Itoa(hookID)]
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		deliveryList = append(deliveryList, hookDeliveryResponse(deliveries[i], false))
+	}
+	return deliveryList, nil
+}
+
+// findHookDelivery looks a delivery up in the log of a hook. Caller must hold the store lock.
+func (g *GbService) findHookDelivery(orgName, repoKey string, hookID, deliveryID int) (*models.Hook, *models.HookDelivery, error) {
+	hook, err := g.findHook(orgName, repoKey, hookID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, delivery := range g.GbStoreInstance.HookDeliveries[strconv.Itoa(hookID)] {
+		if delivery.ID == deliveryID {
+			return hook, delivery, nil
+		}
+	}
+	return nil, nil, ErrHookDeliveryNotFound
+}
+
+// get /repos/{org}/{owner}/{repo}/hooks/{hook_id}/deliveries/{delivery_id}
+func (g *GbService) GetHookDelivery(orgName, owner, repoName string, hookID, deliveryID int) (HookDeliveryResponse, error) {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return HookDeliveryResponse{}, err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	_, delivery, err := g.findHookDelivery(orgName, repoKey, hookID, deliveryID)
+	if err != nil {
+		return HookDeliveryResponse{}, err
+	}
+	return hookDeliveryResponse(delivery, true), nil
+}
+
+// post /repos/{org}/{owner}/{repo}/hooks/{hook_id}/deliveries/{delivery_id}/attempts
+func (g *GbService) RedeliverHook(orgName, owner, repoName string, hookID, deliveryID int) error {
+	repoKey, err := g.hookScope(orgName, owner, repoName)
+	if err != nil {
+		return err
+	}
+	g.GbStoreInstance.MU.RLock()
+	defer g.GbStoreInstance.MU.RUnlock()
+	hook, delivery, err := g.findHookDelivery(orgName, repoKey, hookID, deliveryID)
+	if err != nil {
+		return err
+	}
+	if g.Webhooks == nil {
+		return nil
+	}
+	g.Webhooks.enqueue(webhookJob{hook: *hook, delivery: &models.HookDelivery{GUID: delivery.GUID, HookID: hook.ID,
+		Event: delivery.Event, Action: delivery.Action, Payload: delivery.Payload, Redelivery: true}, log: g.logger()})
+	return nil
+}
+
+type HookUser struct {
+	Login  string `json:"login"`
+	ID     int    `json:"id"`
+	NodeID string `json:"node_id"`
+	Type   string `json:"type"`
+}
+
+type HookOrganization struct {
+	Login string `json:"login"`
+	ID    int    `json:"id"`
+	URL   string `json:"url"`
+}
+
+type HookRepository struct {
+	ID            int      `json:"id"`
+	NodeID        string   `json:"node_id"`
+	Name          string   `json:"name"`
+	FullName      string   `json:"full_name"`
+	Private       bool     `json:"private"`
+	Owner         HookUser `json:"owner"`
+	Description   string   `json:"description"`
+	URL           string   `json:"url"`
+	DefaultBranch string   `json:"default_branch"`
+}
+
+type RepositoryEvent struct {
+	Action       string           `json:"action"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+// RefEvent is the payload of both create and delete events.
+type RefEvent struct {
+	Ref          string           `json:"ref"`
+	RefType      string           `json:"ref_type"`
+	MasterBranch string           `json:"master_branch,omitempty"`
+	PusherType   string           `json:"pusher_type"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type PushEvent struct {
+	Ref          string           `json:"ref"`
+	Before       string           `json:"before"`
+	After        string           `json:"after"`
+	Created      bool             `json:"created"`
+	Deleted      bool             `json:"deleted"`
+	Forced       bool             `json:"forced"`
+	Compare      string           `json:"compare"`
+	Pusher       HookUser         `json:"pusher"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type PullRequestEvent struct {
+	Action            string           `json:"action"`
+	Number            int              `json:"number"`
+	Changes           map[string]any   `json:"changes,omitempty"`
+	PullRequest       PRResponse       `json:"pull_request"`
+	RequestedReviewer *OwnerInfo       `json:"requested_reviewer,omitempty"`
+	Repository        HookRepository   `json:"repository"`
+	Organization      HookOrganization `json:"organization"`
+	Sender            HookUser         `json:"sender"`
+}
+
+type PullRequestReviewEvent struct {
+	Action       string           `json:"action"`
+	Review       ReviewResponse   `json:"review"`
+	PullRequest  PRResponse       `json:"pull_request"`
+	Changes      map[string]any   `json:"changes,omitempty"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type PullRequestReviewCommentEvent struct {
+	Action       string                `json:"action"`
+	Comment      ReviewCommentResponse `json:"comment"`
+	PullRequest  PRResponse            `json:"pull_request"`
+	Changes      map[string]any        `json:"changes,omitempty"`
+	Repository   HookRepository        `json:"repository"`
+	Organization HookOrganization      `json:"organization"`
+	Sender       HookUser              `json:"sender"`
+}
+
+type IssuesEvent struct {
+	Action       string           `json:"action"`
+	Issue        IssueResponse    `json:"issue"`
+	Changes      map[string]any   `json:"changes,omitempty"`
+	Label        *LabelResponse   `json:"label,omitempty"`
+	Assignee     *OwnerInfo       `json:"assignee,omitempty"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type IssueCommentEvent struct {
+	Action       string               `json:"action"`
+	Issue        IssueResponse        `json:"issue"`
+	Comment      IssueCommentResponse `json:"comment"`
+	Changes      map[string]any       `json:"changes,omitempty"`
+	Repository   HookRepository       `json:"repository"`
+	Organization HookOrganization     `json:"organization"`
+	Sender       HookUser             `json:"sender"`
+}
+
+type LabelEvent struct {
+	Action       string           `json:"action"`
+	Label        LabelResponse    `json:"label"`
+	Changes      map[string]any   `json:"changes,omitempty"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type StatusBranch struct {
+	Name   string   `json:"name"`
+	Commit CheckRef `json:"commit"`
+}
+
+type StatusEvent struct {
+	ID           int              `json:"id"`
+	SHA          string           `json:"sha"`
+	Name         string           `json:"name"`
+	TargetURL    string           `json:"target_url"`
+	Context      string           `json:"context"`
+	Description  string           `json:"description"`
+	State        string           `json:"state"`
+	Branches     []StatusBranch   `json:"branches"`
+	CreatedAt    string           `json:"created_at"`
+	UpdatedAt    string           `json:"updated_at"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type CheckRunEvent struct {
+	Action       string           `json:"action"`
+	CheckRun     CheckRunResponse `json:"check_run"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+type CheckSuiteEvent struct {
+	Action       string             `json:"action"`
+	CheckSuite   CheckSuiteResponse `json:"check_suite"`
+	Repository   HookRepository     `json:"repository"`
+	Organization HookOrganization   `json:"organization"`
+	Sender       HookUser           `json:"sender"`
+}
+
+type ReleaseEvent struct {
+	Action       string           `json:"action"`
+	Release      ReleaseResponse  `json:"release"`
+	Repository   HookRepository   `json:"repository"`
+	Organization HookOrganization `json:"organization"`
+	Sender       HookUser         `json:"sender"`
+}
+
+// zeroSHA is what github sends as before/after of a push that creates or deletes a ref.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// hookRepository renders a repo for event payloads. Caller must hold the store lock.
+func (g *GbService) hookRepository(orgName, owner, repoName string) HookRepository {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	hookRepo := HookRepository{Name: repoName, FullName: owner + "/" + repoName,
+		URL: "https://api.gbserver.com/repos/" + owner + "/" + repoName, DefaultBranch: g.defaultBranch(repoKey)}
+	if repo, exists := g.GbStoreInstance.Repos[repoKey]; exists {
+		hookRepo.ID = repo.ID
+		hookRepo.NodeID = repo.Node_ID
+		hookRepo.Description = repo.Description
+	}
+	if user, exists := g.GbStoreInstance.Users[orgName+"/"+owner]; exists {
+		hookRepo.Owner = HookUser{Login: user.LoginName, ID: user.ID, NodeID: user.NodeID, Type: user.UserType}
+	}
+	return hookRepo
+}
+
+// defaultBranch guesses the default branch, the store has no notion of one. Caller must hold the store lock.
+func (g *GbService) defaultBranch(repoKey string) string {
+	repo, exists := g.GbStoreInstance.Repos[repoKey]
+	if !exists {
+		return "master"
+	}
+	for _, name := range []string{"master", "main"} {
+		if slices.Contains(repo.Branches, name) {
+			return name
+		}
+	}
+	if len(repo.Branches) > 0 {
+		return repo.Branches[0]
+	}
+	return "master"
+}
+
+// hookOrganization renders an org for event payloads. Caller must hold the store lock.
+func (g *GbService) hookOrganization(orgName string) HookOrganization {
+	hookOrg := HookOrganization{Login: orgName, URL: "https://api.gbserver.com/orgs/" + orgName}
+	if org, exists := g.GbStoreInstance.Orgs[orgName]; exists {
+		hookOrg.ID = org.ID
+	}
+	return hookOrg
+}
+
+// hookSender is the user that triggered an event. Caller must hold the store lock.
+func (g *GbService) hookSender(orgName, owner string) HookUser {
+	user := g.actingUser(orgName, owner)
+	if user == nil {
+		return HookUser{Login: owner, Type: "User"}
+	}
+	return HookUser{Login: user.LoginName, ID: user.ID, NodeID: user.NodeID, Type: user.UserType}
+}
+
+// emitRepository sends a repository event. Caller must hold the store lock.
+func (g *GbService) emitRepository(action, orgName, owner, repoName string) {
+	g.emit("repository", action, orgName, orgName+"/"+owner+"/"+repoName, RepositoryEvent{Action: action,
+		Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+		Sender: g.hookSender(orgName, owner)})
+}
+
+// emitBranch sends the create or delete event of a branch along with its push. Caller must hold the store lock.
+func (g *GbService) emitBranch(event, orgName, owner, repoName, branch, sha string) {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	repository := g.hookRepository(orgName, owner, repoName)
+	organization := g.hookOrganization(orgName)
+	sender := g.hookSender(orgName, owner)
+	g.emit(event, "", orgName, repoKey, RefEvent{Ref: branch, RefType: "branch", MasterBranch: repository.DefaultBranch,
+		PusherType: "user", Repository: repository, Organization: organization, Sender: sender})
+	if event == "create" {
+		g.emitPush(orgName, owner, repoName, branch, zeroSHA, sha)
+	} else {
+		g.emitPush(orgName, owner, repoName, branch, sha, zeroSHA)
+	}
+}
+
+// emitTag sends the create or delete event of a tag along with its push event. Caller must hold the store lock.
+func (g *GbService) emitTag(event, orgName, owner, repoName, tag, sha string) {
+	repository := g.hookRepository(orgName, owner, repoName)
+	g.emit(event, "", orgName, orgName+"/"+owner+"/"+repoName, RefEvent{Ref: tag, RefType: "tag",
+		MasterBranch: repository.DefaultBranch, PusherType: "user", Repository: repository,
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+	if event == "create" {
+		g.emitRefPush(orgName, owner, repoName, "refs/tags/"+tag, zeroSHA, sha)
+	} else {
+		g.emitRefPush(orgName, owner, repoName, "refs/tags/"+tag, sha, zeroSHA)
+	}
+}
+
+// emitPush sends a push event for a branch moving from before to after. Caller must hold the store lock.
+func (g *GbService) emitPush(orgName, owner, repoName, branch, before, after string) {
+	g.emitRefPush(orgName, owner, repoName, "refs/heads/"+branch, before, after)
+}
+
+// emitRefPush sends a push event for any ref. Caller must hold the store lock.
+func (g *GbService) emitRefPush(orgName, owner, repoName, ref, before, after string) {
+	repository := g.hookRepository(orgName, owner, repoName)
+	sender := g.hookSender(orgName, owner)
+	g.emit("push", "", orgName, orgName+"/"+owner+"/"+repoName, PushEvent{Ref: ref,
+		Before: before, After: after, Created: before == zeroSHA, Deleted: after == zeroSHA,
+		Compare: "https://gbserver.com/" + owner + "/" + repoName + "/compare/" + shortSHA(before) + "..." + shortSHA(after),
+		Pusher:  sender, Repository: repository, Organization: g.hookOrganization(orgName), Sender: sender})
+}
+
+// emitPullRequest sends a pull_request event. Caller must hold the store lock.
+func (g *GbService) emitPullRequest(action, orgName, owner, repoName string, prResp PRResponse, changes map[string]any) {
+	g.emit("pull_request", action, orgName, orgName+"/"+owner+"/"+repoName, PullRequestEvent{Action: action,
+		Number: prResp.Number, Changes: changes, PullRequest: prResp,
+		Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+		Sender: g.hookSender(orgName, owner)})
+}
+
+// emitReviewRequestChanges sends review_requested and review_request_removed events for every requested
+// reviewer that came or went. Caller must hold the store lock.
+func (g *GbService) emitReviewRequestChanges(orgName, owner, repoName string, prResp PRResponse, oldReviewers, newReviewers []string) {
+	send := func(action, login string) {
+		user, exists := g.GbStoreInstance.Users[orgName+"/"+login]
+		if !exists {
+			return
+		}
+		reviewer := ownerInfoOf(user)
+		g.emit("pull_request", action, orgName, orgName+"/"+owner+"/"+repoName, PullRequestEvent{Action: action,
+			Number: prResp.Number, PullRequest: prResp, RequestedReviewer: &reviewer,
+			Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+			Sender: g.hookSender(orgName, owner)})
+	}
+	for _, login := range newReviewers {
+		if !slices.Contains(oldReviewers, login) {
+			send("review_requested", login)
+		}
+	}
+	for _, login := range oldReviewers {
+		if !slices.Contains(newReviewers, login) {
+			send("review_request_removed", login)
+		}
+	}
+}
+
+// emitPullRequestReview sends a pull_request_review event. Caller must hold the store lock.
+func (g *GbService) emitPullRequestReview(action, orgName, owner, repoName string, prResp PRResponse, reviewResp ReviewResponse, changes map[string]any) {
+	g.emit("pull_request_review", action, orgName, orgName+"/"+owner+"/"+repoName, PullRequestReviewEvent{Action: action,
+		Review: reviewResp, PullRequest: prResp, Changes: changes, Repository: g.hookRepository(orgName, owner, repoName),
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+}
+
+// emitPullRequestReviewComment sends a pull_request_review_comment event. Caller must hold the store lock.
+func (g *GbService) emitPullRequestReviewComment(action, orgName, owner, repoName string, prResp PRResponse, commentResp ReviewCommentResponse, changes map[string]any) {
+	g.emit("pull_request_review_comment", action, orgName, orgName+"/"+owner+"/"+repoName, PullRequestReviewCommentEvent{
+		Action: action, Comment: commentResp, PullRequest: prResp, Changes: changes,
+		Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+		Sender: g.hookSender(orgName, owner)})
+}
+
+// emitIssue sends an issues event. Caller must hold the store lock.
+func (g *GbService) emitIssue(action, orgName, owner, repoName string, issueResp IssueResponse, changes map[string]any) {
+	g.emit("issues", action, orgName, orgName+"/"+owner+"/"+repoName, IssuesEvent{Action: action, Issue: issueResp,
+		Changes: changes, Repository: g.hookRepository(orgName, owner, repoName),
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+}
+
+// emitLabelChanges sends labeled and unlabeled events for every label that came or went. Caller must hold the store lock.
+func (g *GbService) emitLabelChanges(orgName, owner, repoName string, issueResp IssueResponse, oldLabels, newLabels []string) {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	send := func(action, name string) {
+		label, exists := g.GbStoreInstance.Labels[labelKey(repoKey, name)]
+		if !exists {
+			return
+		}
+		labelResp := labelResponse(owner, repoName, label)
+		g.emit("issues", action, orgName, repoKey, IssuesEvent{Action: action, Issue: issueResp, Label: &labelResp,
+			Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+			Sender: g.hookSender(orgName, owner)})
+	}
+	for _, name := range newLabels {
+		if !slices.Contains(oldLabels, name) {
+			send("labeled", name)
+		}
+	}
+	for _, name := range oldLabels {
+		if !slices.Contains(newLabels, name) {
+			send("unlabeled", name)
+		}
+	}
+}
+
+// emitAssigneeChanges sends assigned and unassigned events for every assignee that came or went. Caller must hold the store lock.
+func (g *GbService) emitAssigneeChanges(orgName, owner, repoName string, issueResp IssueResponse, oldAssignees, newAssignees []string) {
+	send := func(action, login string) {
+		user, exists := g.GbStoreInstance.Users[orgName+"/"+login]
+		if !exists {
+			return
+		}
+		assignee := ownerInfoOf(user)
+		g.emit("issues", action, orgName, orgName+"/"+owner+"/"+repoName, IssuesEvent{Action: action, Issue: issueResp,
+			Assignee: &assignee, Repository: g.hookRepository(orgName, owner, repoName),
+			Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+	}
+	for _, login := range newAssignees {
+		if !slices.Contains(oldAssignees, login) {
+			send("assigned", login)
+		}
+	}
+	for _, login := range oldAssignees {
+		if !slices.Contains(newAssignees, login) {
+			send("unassigned", login)
+		}
+	}
+}
+
+// emitIssueComment sends an issue_comment event. Caller must hold the store lock.
+func (g *GbService) emitIssueComment(action, orgName, owner, repoName string, issue *models.Issue, commentResp IssueCommentResponse, changes map[string]any) {
+	g.emit("issue_comment", action, orgName, orgName+"/"+owner+"/"+repoName, IssueCommentEvent{Action: action,
+		Issue: g.buildIssueResponse(orgName, owner, repoName, issue), Comment: commentResp, Changes: changes,
+		Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+		Sender: g.hookSender(orgName, owner)})
+}
+
+// emitLabel sends a label event. Caller must hold the store lock.
+func (g *GbService) emitLabel(action, orgName, owner, repoName string, labelResp LabelResponse, changes map[string]any) {
+	g.emit("label", action, orgName, orgName+"/"+owner+"/"+repoName, LabelEvent{Action: action, Label: labelResp,
+		Changes: changes, Repository: g.hookRepository(orgName, owner, repoName),
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+}
+
+// emitStatus sends a status event listing the branches at the commit. Caller must hold the store lock.
+func (g *GbService) emitStatus(orgName, owner, repoName string, statusResp StatusResponse, sha string) {
+	repoKey := orgName + "/" + owner + "/" + repoName
+	branches := []StatusBranch{}
+	for _, branchName := range g.GbStoreInstance.Repos[repoKey].Branches {
+		if branch := g.GbStoreInstance.Branches[repoKey+"/"+branchName]; branch != nil && branch.CommitInfo.SHA == sha {
+			branches = append(branches, StatusBranch{Name: branchName, Commit: CheckRef{SHA: sha}})
+		}
+	}
+	g.emit("status", "", orgName, repoKey, StatusEvent{ID: statusResp.ID, SHA: sha, Name: owner + "/" + repoName,
+		TargetURL: statusResp.TargetURL, Context: statusResp.Context, Description: statusResp.Description,
+		State: statusResp.State, Branches: branches, CreatedAt: statusResp.CreatedAt, UpdatedAt: statusResp.UpdatedAt,
+		Repository: g.hookRepository(orgName, owner, repoName), Organization: g.hookOrganization(orgName),
+		Sender: g.hookSender(orgName, owner)})
+}
+
+// emitCheckRun sends a check_run event. Caller must hold the store lock.
+func (g *GbService) emitCheckRun(action, orgName, owner, repoName string, checkRunResp CheckRunResponse) {
+	g.emit("check_run", action, orgName, orgName+"/"+owner+"/"+repoName, CheckRunEvent{Action: action,
+		CheckRun: checkRunResp, Repository: g.hookRepository(orgName, owner, repoName),
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+}
+
+// emitCheckSuite sends a check_suite event. Caller must hold the store lock.
+func (g *GbService) emitCheckSuite(action, orgName, owner, repoName string, suiteResp CheckSuiteResponse) {
+	g.emit("check_suite", action, orgName, orgName+"/"+owner+"/"+repoName, CheckSuiteEvent{Action: action,
+		CheckSuite: suiteResp, Repository: g.hookRepository(orgName, owner, repoName),
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+}
+
+// emitRelease sends a release event. Caller must hold the store lock.
+func (g *GbService) emitRelease(action, orgName, owner, repoName string, releaseResp ReleaseResponse) {
+	g.emit("release", action, orgName, orgName+"/"+owner+"/"+repoName, ReleaseEvent{Action: action,
+		Release: releaseResp, Repository: g.hookRepository(orgName, owner, repoName),
+		Organization: g.hookOrganization(orgName), Sender: g.hookSender(orgName, owner)})
+}
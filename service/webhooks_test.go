@@ -0,0 +1,166 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"gbserver/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+func hookReceiver(t *testing.T, failures int) (*httptest.Server, func() []receivedHook) {
+	var mu sync.Mutex
+	var received []receivedHook
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			rw.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received = append(received, receivedHook{header: r.Header, body: body})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []receivedHook {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedHook{}, received...)
+	}
+}
+
+func waitForDeliveries(t *testing.T, hookService *GbService, repoName string, hookID, count int) []HookDeliveryResponse {
+	var deliveryList []HookDeliveryResponse
+	assert.Eventually(t, func() bool {
+		deliveryList, _ = hookService.ListHookDeliveries("gborg", "gbuser", repoName, hookID)
+		return len(deliveryList) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+	return deliveryList
+}
+
+func TestWebhookWorkers(t *testing.T) {
+	server, _ := hookReceiver(t, 0)
+	hookService := newTestService(t)
+
+	// the workers record into the service handed out, so storage set up afterwards gets the deliveries.
+	storePath := filepath.Join(t.TempDir(), "gbstore.json")
+	hookService.Storage = models.NewFileStorage(storePath, nil)
+	active := true
+	hook, err := hookService.CreateHook("gborg", "gbuser", "gbrepo", &HookRequest{Name: "web", Active: &active,
+		Config: &HookConfig{URL: server.URL, ContentType: "json"}})
+	assert.NoError(t, err)
+	waitForDeliveries(t, hookService, "gbrepo", hook.ID, 1)
+	// deliveries are not saved on their own, they go along with the next change or with Close.
+	saved, err := models.NewFileStorage(storePath, nil).Load()
+	assert.NoError(t, err)
+	assert.Empty(t, saved.HookDeliveries[strconv.Itoa(hook.ID)])
+	hookService.Close()
+	saved, err = models.NewFileStorage(storePath, nil).Load()
+	assert.NoError(t, err)
+	assert.Len(t, saved.HookDeliveries[strconv.Itoa(hook.ID)], 1)
+
+	// closed services leave no workers behind.
+	before := runtime.NumGoroutine()
+	for range 3 {
+		NewGbService(models.NewGbStore(), nil, nil).Close()
+	}
+	for deadline := time.Now().Add(5 * time.Second); runtime.NumGoroutine() > before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	server, received := hookReceiver(t, 0)
+	hookService := newTestService(t)
+
+	active := true
+	hook, err := hookService.CreateHook("gborg", "gbuser", "gbrepo", &HookRequest{Name: "web", Active: &active,
+		Events: []string{"create", "pull_request"},
+		Config: &HookConfig{URL: server.URL, ContentType: "json", Secret: "s3cret"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "********", hook.Config.Secret)
+	assert.Equal(t, []string{"create", "pull_request"}, hook.Events)
+
+	// ping on creation, then a create event; the push of the new branch is not subscribed to.
+	_, err = hookService.CreateBranch("gborg", "gbuser", "gbrepo", &CreateBranchRequest{Ref: "refs/heads/hooked", SHA: "aa218f56b14c9653891f9e74264a383fa43fefbd"})
+	assert.NoError(t, err)
+	deliveryList := waitForDeliveries(t, hookService, "gbrepo", hook.ID, 2)
+	assert.Len(t, deliveryList, 2)
+
+	events := map[string]receivedHook{}
+	for _, hookReq := range received() {
+		events[hookReq.header.Get("X-GitHub-Event")] = hookReq
+	}
+	assert.Contains(t, events, "ping")
+	createHook := events["create"]
+	assert.NotEmpty(t, createHook.header.Get("X-GitHub-Delivery"))
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(createHook.body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), createHook.header.Get("X-Hub-Signature-256"))
+	var refEvent RefEvent
+	assert.NoError(t, json.Unmarshal(createHook.body, &refEvent))
+	assert.Equal(t, "hooked", refEvent.Ref)
+	assert.Equal(t, "gbuser/gbrepo", refEvent.Repository.FullName)
+
+	createDelivery := deliveryList[0]
+	if createDelivery.Event != "create" {
+		createDelivery = deliveryList[1]
+	}
+	delivery, err := hookService.GetHookDelivery("gborg", "gbuser", "gbrepo", hook.ID, createDelivery.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, delivery.StatusCode)
+	assert.Equal(t, createHook.header.Get("X-GitHub-Delivery"), delivery.Request.Headers["X-GitHub-Delivery"])
+
+	assert.NoError(t, hookService.RedeliverHook("gborg", "gbuser", "gbrepo", hook.ID, delivery.ID))
+	deliveryList = waitForDeliveries(t, hookService, "gbrepo", hook.ID, 3)
+	assert.True(t, deliveryList[0].Redelivery)
+	assert.Equal(t, delivery.GUID, deliveryList[0].GUID)
+
+	_, err = hookService.GetHook("gborg", "gbuser", "gbrepo", 99)
+	assert.Equal(t, ErrHookNotFound, err)
+	_, err = hookService.CreateHook("gborg", "gbuser", "gbrepo", &HookRequest{Config: &HookConfig{URL: "ftp://example.com"}})
+	assert.Equal(t, ErrInvalidHookConfig, err)
+}
+
+func TestWebhookRetryAndOrgHooks(t *testing.T) {
+	server, received := hookReceiver(t, 2)
+	hookService := newTestService(t)
+	hookService.Webhooks.Backoff = time.Millisecond
+
+	hook, err := hookService.CreateHook("gborg", "", "", &HookRequest{Events: []string{"repository"},
+		Config: &HookConfig{URL: server.URL}})
+	assert.NoError(t, err)
+	assert.Equal(t, "Organization", hook.Type)
+	assert.Equal(t, "form", hook.Config.ContentType)
+
+	// the ping fails twice and is delivered on the last attempt.
+	deliveryList := waitForDeliveries(t, hookService, "", hook.ID, 1)
+	assert.Equal(t, 3, deliveryList[0].Attempts)
+	assert.Equal(t, "OK", deliveryList[0].Status)
+
+	_, err = hookService.DeleteRepo("gborg", "gbuser", "gbrepo")
+	assert.NoError(t, err)
+	deliveryList = waitForDeliveries(t, hookService, "", hook.ID, 2)
+	assert.Equal(t, "repository", deliveryList[0].Event)
+	assert.Equal(t, "deleted", deliveryList[0].Action)
+	assert.Len(t, received(), 2)
+	assert.Equal(t, "application/x-www-form-urlencoded", received()[1].header.Get("Content-Type"))
+}